@@ -0,0 +1,338 @@
+package callbook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Field identifies a field of Info that can be merged from different providers.
+type Field string
+
+// All fields of Info that are merged by the Aggregator.
+const (
+	FieldName       Field = "Name"
+	FieldAddress    Field = "Address"
+	FieldQTH        Field = "QTH"
+	FieldCountry    Field = "Country"
+	FieldLocator    Field = "Locator"
+	FieldLatLon     Field = "LatLon"
+	FieldCQZone     Field = "CQZone"
+	FieldITUZone    Field = "ITUZone"
+	FieldTimeOffset Field = "TimeOffset"
+	FieldDXCCEntity Field = "DXCCEntity"
+
+	FieldEmail        Field = "Email"
+	FieldQSLManager   Field = "QSLManager"
+	FieldLoTW         Field = "LoTW"
+	FieldEQSL         Field = "EQSL"
+	FieldPaperQSL     Field = "PaperQSL"
+	FieldLicenseClass Field = "LicenseClass"
+	FieldBirthYear    Field = "BirthYear"
+	FieldUSState      Field = "USState"
+	FieldUSCounty     Field = "USCounty"
+	FieldIOTA         Field = "IOTA"
+	FieldImageURL     Field = "ImageURL"
+	FieldLastUpdate   Field = "LastUpdate"
+)
+
+// Sources maps the fields of a merged Info to the name of the provider that supplied the field's value.
+type Sources map[Field]string
+
+// Provider is a named callbook that is used by an Aggregator.
+type Provider struct {
+	Name     string
+	Callbook Callbook
+}
+
+// Strategy defines how an Aggregator combines the results of its providers.
+type Strategy int
+
+// The strategies supported by the Aggregator.
+const (
+	// MergeFields waits for all providers and merges their results field by field. The Locator and the LastUpdate
+	// are taken from the provider with the most precise locator and the most recent update respectively, regardless
+	// of the Precedence.
+	MergeFields Strategy = iota
+	// FirstResult returns the first successful result and cancels all other lookups.
+	FirstResult
+)
+
+// Aggregator is a Callbook that looks up a callsign in several providers concurrently.
+type Aggregator struct {
+	// Strategy defines how the results of the providers are combined.
+	Strategy Strategy
+	// Precedence defines the order in which the providers are considered for a specific field when merging the results.
+	// Providers that are not listed for a field are considered afterwards in the order of the providers.
+	Precedence map[Field][]string
+
+	providers []Provider
+}
+
+// NewAggregator creates a new Aggregator for the given providers. The order of the providers defines their default
+// precedence when merging the results.
+func NewAggregator(providers ...Provider) *Aggregator {
+	return &Aggregator{
+		Strategy:   MergeFields,
+		Precedence: make(map[Field][]string),
+		providers:  providers,
+	}
+}
+
+// Lookup looks up information about the given callsign in all providers.
+func (a *Aggregator) Lookup(callsign string) (Info, error) {
+	return a.LookupContext(context.Background(), callsign)
+}
+
+// LookupContext looks up information about the given callsign in all providers.
+// The lookups are cancelled when the given context is done.
+func (a *Aggregator) LookupContext(ctx context.Context, callsign string) (Info, error) {
+	info, _, err := a.LookupSources(ctx, callsign)
+	return info, err
+}
+
+type providerResult struct {
+	index int
+	info  Info
+	err   error
+}
+
+// LookupSources looks up information about the given callsign in all providers and additionally
+// reports which provider supplied which field of the resulting info.
+func (a *Aggregator) LookupSources(ctx context.Context, callsign string) (Info, Sources, error) {
+	if len(a.providers) == 0 {
+		return Info{}, nil, fmt.Errorf("no callbook providers available")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan providerResult, len(a.providers))
+	for i, provider := range a.providers {
+		go func(index int, callbook Callbook) {
+			info, err := LookupContext(ctx, callbook, callsign)
+			results <- providerResult{index, info, err}
+		}(i, provider.Callbook)
+	}
+
+	infos := make([]*Info, len(a.providers))
+	errs := make([]error, 0, len(a.providers))
+	for range a.providers {
+		result := <-results
+		if result.err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", a.providers[result.index].Name, result.err))
+			continue
+		}
+		if a.Strategy == FirstResult {
+			return result.info, a.sources(result.index, result.info), nil
+		}
+		info := result.info
+		infos[result.index] = &info
+	}
+
+	if len(errs) == len(a.providers) {
+		return Info{}, nil, combineErrors(errs)
+	}
+	info, sources := a.merge(infos)
+	return info, sources, nil
+}
+
+// sources attributes all fields that are set in the given info to the provider with the given index.
+func (a *Aggregator) sources(index int, info Info) Sources {
+	result := make(Sources)
+	for _, merger := range fieldMergers {
+		if merger.isSet(info) {
+			result[merger.field] = a.providers[index].Name
+		}
+	}
+	return result
+}
+
+// combineErrors reports ErrNotFound if all providers did not find the callsign, otherwise the first other error.
+func combineErrors(errs []error) error {
+	for _, err := range errs {
+		if !errors.Is(err, ErrNotFound) {
+			return err
+		}
+	}
+	return fmt.Errorf("%w in any callbook", ErrNotFound)
+}
+
+// merge combines the given infos field by field. Infos that are nil are skipped.
+func (a *Aggregator) merge(infos []*Info) (Info, Sources) {
+	var result Info
+	sources := make(Sources)
+	for _, index := range a.order("") {
+		if infos[index] != nil {
+			result.Callsign = infos[index].Callsign
+			break
+		}
+	}
+
+	for _, merger := range fieldMergers {
+		selected := -1
+		for _, index := range a.order(merger.field) {
+			info := infos[index]
+			if info == nil || !merger.isSet(*info) {
+				continue
+			}
+			if selected == -1 || (merger.precision != nil && merger.precision(*info) > merger.precision(*infos[selected])) {
+				selected = index
+			}
+		}
+		if selected == -1 {
+			continue
+		}
+		merger.copy(&result, *infos[selected])
+		sources[merger.field] = a.providers[selected].Name
+	}
+	return result, sources
+}
+
+// order returns the indexes of the providers in the order of precedence for the given field.
+func (a *Aggregator) order(field Field) []int {
+	result := make([]int, 0, len(a.providers))
+	used := make(map[int]bool, len(a.providers))
+	for _, name := range a.Precedence[field] {
+		for i, provider := range a.providers {
+			if provider.Name == name && !used[i] {
+				result = append(result, i)
+				used[i] = true
+			}
+		}
+	}
+	for i := range a.providers {
+		if !used[i] {
+			result = append(result, i)
+		}
+	}
+	return result
+}
+
+// fieldMerger describes how a field of Info is merged.
+type fieldMerger struct {
+	field Field
+	isSet func(Info) bool
+	// precision ranks the values of the field, a higher precision is preferred. If precision is nil,
+	// only the precedence of the providers is used.
+	precision func(Info) int
+	copy      func(*Info, Info)
+}
+
+var fieldMergers = []fieldMerger{
+	{
+		field: FieldName,
+		isSet: func(i Info) bool { return i.Name != "" },
+		copy:  func(dst *Info, src Info) { dst.Name = src.Name },
+	},
+	{
+		field: FieldAddress,
+		isSet: func(i Info) bool { return i.Address != "" },
+		copy:  func(dst *Info, src Info) { dst.Address = src.Address },
+	},
+	{
+		field: FieldQTH,
+		isSet: func(i Info) bool { return i.QTH != "" },
+		copy:  func(dst *Info, src Info) { dst.QTH = src.QTH },
+	},
+	{
+		field: FieldCountry,
+		isSet: func(i Info) bool { return i.Country != "" },
+		copy:  func(dst *Info, src Info) { dst.Country = src.Country },
+	},
+	{
+		field:     FieldLocator,
+		isSet:     func(i Info) bool { return !i.Locator.IsZero() },
+		precision: func(i Info) int { return len(i.Locator.String()) },
+		copy:      func(dst *Info, src Info) { dst.Locator = src.Locator },
+	},
+	{
+		field: FieldLatLon,
+		isSet: func(i Info) bool { return i.LatLonValid },
+		copy: func(dst *Info, src Info) {
+			dst.LatLon = src.LatLon
+			dst.LatLonValid = src.LatLonValid
+		},
+	},
+	{
+		field: FieldCQZone,
+		isSet: func(i Info) bool { return i.CQZone != 0 },
+		copy:  func(dst *Info, src Info) { dst.CQZone = src.CQZone },
+	},
+	{
+		field: FieldITUZone,
+		isSet: func(i Info) bool { return i.ITUZone != 0 },
+		copy:  func(dst *Info, src Info) { dst.ITUZone = src.ITUZone },
+	},
+	{
+		field: FieldTimeOffset,
+		isSet: func(i Info) bool { return i.TimeOffset != 0 },
+		copy:  func(dst *Info, src Info) { dst.TimeOffset = src.TimeOffset },
+	},
+	{
+		field: FieldDXCCEntity,
+		isSet: func(i Info) bool { return i.DXCCEntity != 0 },
+		copy:  func(dst *Info, src Info) { dst.DXCCEntity = src.DXCCEntity },
+	},
+	{
+		field: FieldEmail,
+		isSet: func(i Info) bool { return i.Email != "" },
+		copy:  func(dst *Info, src Info) { dst.Email = src.Email },
+	},
+	{
+		field: FieldQSLManager,
+		isSet: func(i Info) bool { return i.QSLManager != "" },
+		copy:  func(dst *Info, src Info) { dst.QSLManager = src.QSLManager },
+	},
+	{
+		field: FieldLoTW,
+		isSet: func(i Info) bool { return i.LoTW },
+		copy:  func(dst *Info, src Info) { dst.LoTW = src.LoTW },
+	},
+	{
+		field: FieldEQSL,
+		isSet: func(i Info) bool { return i.EQSL },
+		copy:  func(dst *Info, src Info) { dst.EQSL = src.EQSL },
+	},
+	{
+		field: FieldPaperQSL,
+		isSet: func(i Info) bool { return i.PaperQSL },
+		copy:  func(dst *Info, src Info) { dst.PaperQSL = src.PaperQSL },
+	},
+	{
+		field: FieldLicenseClass,
+		isSet: func(i Info) bool { return i.LicenseClass != "" },
+		copy:  func(dst *Info, src Info) { dst.LicenseClass = src.LicenseClass },
+	},
+	{
+		field: FieldBirthYear,
+		isSet: func(i Info) bool { return i.BirthYear != 0 },
+		copy:  func(dst *Info, src Info) { dst.BirthYear = src.BirthYear },
+	},
+	{
+		field: FieldUSState,
+		isSet: func(i Info) bool { return i.USState != "" },
+		copy:  func(dst *Info, src Info) { dst.USState = src.USState },
+	},
+	{
+		field: FieldUSCounty,
+		isSet: func(i Info) bool { return i.USCounty != "" },
+		copy:  func(dst *Info, src Info) { dst.USCounty = src.USCounty },
+	},
+	{
+		field: FieldIOTA,
+		isSet: func(i Info) bool { return i.IOTA != "" },
+		copy:  func(dst *Info, src Info) { dst.IOTA = src.IOTA },
+	},
+	{
+		field: FieldImageURL,
+		isSet: func(i Info) bool { return i.ImageURL != "" },
+		copy:  func(dst *Info, src Info) { dst.ImageURL = src.ImageURL },
+	},
+	{
+		field:     FieldLastUpdate,
+		isSet:     func(i Info) bool { return !i.LastUpdate.IsZero() },
+		precision: func(i Info) int { return int(i.LastUpdate.Unix()) },
+		copy:      func(dst *Info, src Info) { dst.LastUpdate = src.LastUpdate },
+	},
+}
@@ -0,0 +1,134 @@
+package callbook
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ftl/hamradio/callsign"
+	"github.com/ftl/hamradio/locator"
+)
+
+type staticCallbook struct {
+	info  Info
+	err   error
+	delay time.Duration
+}
+
+func (c staticCallbook) Lookup(call string) (Info, error) {
+	return c.LookupContext(context.Background(), call)
+}
+
+func (c staticCallbook) LookupContext(ctx context.Context, call string) (Info, error) {
+	select {
+	case <-ctx.Done():
+		return Info{}, ctx.Err()
+	case <-time.After(c.delay):
+		return c.info, c.err
+	}
+}
+
+func TestAggregator_MergeFields(t *testing.T) {
+	dl1abc := callsign.MustParse("DL1ABC")
+	aggregator := NewAggregator(
+		Provider{"one", staticCallbook{info: Info{
+			Callsign: dl1abc,
+			Name:     "Fred",
+			Locator:  locator.MustParse("JN59"),
+			CQZone:   14,
+		}}},
+		Provider{"two", staticCallbook{info: Info{
+			Callsign: dl1abc,
+			Name:     "Frederick",
+			QTH:      "Nuremberg",
+			Locator:  locator.MustParse("JN59nk"),
+			CQZone:   15,
+		}}},
+		Provider{"three", staticCallbook{err: errors.New("connection refused")}},
+	)
+	aggregator.Precedence[FieldCQZone] = []string{"two"}
+
+	info, sources, err := aggregator.LookupSources(context.Background(), "DL1ABC")
+	require.NoError(t, err)
+
+	assert.Equal(t, dl1abc, info.Callsign)
+	assert.Equal(t, "Fred", info.Name)
+	assert.Equal(t, "Nuremberg", info.QTH)
+	assert.Equal(t, "JN59nk", info.Locator.String())
+	assert.Equal(t, 15, int(info.CQZone))
+	assert.Equal(t, Sources{
+		FieldName:    "one",
+		FieldQTH:     "two",
+		FieldLocator: "two",
+		FieldCQZone:  "two",
+	}, sources)
+}
+
+func TestAggregator_FirstResult(t *testing.T) {
+	aggregator := NewAggregator(
+		Provider{"slow", staticCallbook{info: Info{Name: "slow"}, delay: time.Second}},
+		Provider{"failing", staticCallbook{err: ErrNotFound}},
+		Provider{"fast", staticCallbook{info: Info{Name: "fast"}, delay: 10 * time.Millisecond}},
+	)
+	aggregator.Strategy = FirstResult
+
+	start := time.Now()
+	info, sources, err := aggregator.LookupSources(context.Background(), "DL1ABC")
+	require.NoError(t, err)
+
+	assert.Equal(t, "fast", info.Name)
+	assert.Equal(t, Sources{FieldName: "fast"}, sources)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+}
+
+func TestAggregator_Errors(t *testing.T) {
+	notFound := NewAggregator(
+		Provider{"one", staticCallbook{err: ErrNotFound}},
+		Provider{"two", staticCallbook{err: ErrNotFound}},
+	)
+	_, err := notFound.Lookup("DL1ABC")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	failing := errors.New("failing")
+	failed := NewAggregator(
+		Provider{"one", staticCallbook{err: ErrNotFound}},
+		Provider{"two", staticCallbook{err: failing}},
+	)
+	_, err = failed.Lookup("DL1ABC")
+	assert.ErrorIs(t, err, failing)
+
+	_, err = NewAggregator().Lookup("DL1ABC")
+	assert.Error(t, err)
+}
+
+func TestAggregator_MergeExtendedFields(t *testing.T) {
+	older := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	aggregator := NewAggregator(
+		Provider{"one", staticCallbook{info: Info{
+			Email:      "one@example.com",
+			LoTW:       false,
+			LastUpdate: older,
+		}}},
+		Provider{"two", staticCallbook{info: Info{
+			Email:        "two@example.com",
+			LoTW:         true,
+			LicenseClass: "E",
+			LastUpdate:   newer,
+		}}},
+	)
+
+	info, sources, err := aggregator.LookupSources(context.Background(), "DL1ABC")
+	require.NoError(t, err)
+
+	assert.Equal(t, "one@example.com", info.Email)
+	assert.True(t, info.LoTW)
+	assert.Equal(t, "E", info.LicenseClass)
+	assert.Equal(t, newer, info.LastUpdate)
+	assert.Equal(t, "two", sources[FieldLoTW])
+	assert.Equal(t, "two", sources[FieldLastUpdate])
+}
@@ -0,0 +1,72 @@
+package callbook
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ftl/hamradio/callsign"
+)
+
+// DefaultBatchConcurrency is the default number of concurrent lookups in a batch.
+const DefaultBatchConcurrency = 4
+
+// BatchResult contains the result of the lookup of one callsign in a batch.
+type BatchResult struct {
+	// Callsign is the callsign as it was given to the batch.
+	Callsign string
+	Info     Info
+	Err      error
+}
+
+// LookupBatch looks up all given callsigns in the given callbook with at most the given number of concurrent lookups.
+// Variants of the same callsign (e.g. DL1ABC, DL1ABC/P and EA8/DL1ABC) are looked up only once by their base call.
+// The results are returned in the order of the given callsigns, each result carries its own error. If the given
+// context is done, the remaining lookups are cancelled.
+func LookupBatch(ctx context.Context, callbook Callbook, callsigns []string, concurrency int) []BatchResult {
+	if concurrency < 1 {
+		concurrency = DefaultBatchConcurrency
+	}
+
+	results := make([]BatchResult, len(callsigns))
+	baseCalls := make(map[string][]int)
+	order := make([]string, 0, len(callsigns))
+	for i, call := range callsigns {
+		results[i].Callsign = call
+		parsed, err := callsign.Parse(call)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		if _, ok := baseCalls[parsed.BaseCall]; !ok {
+			order = append(order, parsed.BaseCall)
+		}
+		baseCalls[parsed.BaseCall] = append(baseCalls[parsed.BaseCall], i)
+	}
+
+	baseCallsToLookup := make(chan string)
+	var lock sync.Mutex
+	var waiter sync.WaitGroup
+	for i := 0; i < concurrency && i < len(order); i++ {
+		waiter.Add(1)
+		go func() {
+			defer waiter.Done()
+			for baseCall := range baseCallsToLookup {
+				info, err := LookupContext(ctx, callbook, baseCall)
+				lock.Lock()
+				for _, index := range baseCalls[baseCall] {
+					results[index].Info = info
+					results[index].Err = err
+				}
+				lock.Unlock()
+			}
+		}()
+	}
+
+	for _, baseCall := range order {
+		baseCallsToLookup <- baseCall
+	}
+	close(baseCallsToLookup)
+	waiter.Wait()
+
+	return results
+}
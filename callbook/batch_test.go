@@ -0,0 +1,81 @@
+package callbook
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type countingCallbook struct {
+	lock       sync.Mutex
+	requests   []string
+	active     int
+	maxActive  int
+	infos      map[string]Info
+	processing time.Duration
+}
+
+func (c *countingCallbook) Lookup(call string) (Info, error) {
+	c.lock.Lock()
+	c.requests = append(c.requests, call)
+	c.active++
+	if c.active > c.maxActive {
+		c.maxActive = c.active
+	}
+	c.lock.Unlock()
+
+	time.Sleep(c.processing)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.active--
+	info, ok := c.infos[call]
+	if !ok {
+		return Info{}, ErrNotFound
+	}
+	return info, nil
+}
+
+func TestLookupBatch(t *testing.T) {
+	callbook := &countingCallbook{
+		infos: map[string]Info{
+			"DL1ABC": {Name: "one"},
+			"DL2ABC": {Name: "two"},
+			"DL3ABC": {Name: "three"},
+		},
+		processing: 10 * time.Millisecond,
+	}
+	calls := []string{"dl1abc", "DL2ABC", "DL1ABC/p", "not a call", "EA8/DL1ABC", "DL3ABC", "DL4ABC"}
+
+	results := LookupBatch(context.Background(), callbook, calls, 2)
+
+	assert.Len(t, results, len(calls))
+	for i, result := range results {
+		assert.Equal(t, calls[i], result.Callsign)
+	}
+	assert.Equal(t, "one", results[0].Info.Name)
+	assert.Equal(t, "two", results[1].Info.Name)
+	assert.Equal(t, "one", results[2].Info.Name)
+	assert.Error(t, results[3].Err)
+	assert.Equal(t, "one", results[4].Info.Name)
+	assert.Equal(t, "three", results[5].Info.Name)
+	assert.ErrorIs(t, results[6].Err, ErrNotFound)
+
+	assert.ElementsMatch(t, []string{"DL1ABC", "DL2ABC", "DL3ABC", "DL4ABC"}, callbook.requests)
+	assert.Equal(t, 2, callbook.maxActive)
+}
+
+func TestLookupBatch_Cancel(t *testing.T) {
+	callbook := &countingCallbook{processing: time.Second}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	results := LookupBatch(ctx, callbook, []string{"DL1ABC", "DL2ABC"}, 0)
+
+	for _, result := range results {
+		assert.ErrorIs(t, result.Err, context.Canceled)
+	}
+}
@@ -0,0 +1,311 @@
+package callbook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ftl/hamradio/cfg"
+)
+
+// DefaultCacheTTL is the default duration for which a cached callbook entry is considered valid.
+const DefaultCacheTTL = 30 * 24 * time.Hour
+
+// DefaultNegativeCacheTTL is the default duration for which a callsign that was not found is not requested again.
+const DefaultNegativeCacheTTL = 24 * time.Hour
+
+// DefaultCacheSaveInterval is the default minimum time between two writes of the cache file.
+const DefaultCacheSaveInterval = time.Minute
+
+// ErrNotCached is returned by a Cache in offline mode if the requested callsign is not cached.
+var ErrNotCached = errors.New("callsign not cached")
+
+// Cache is a Callbook that caches the results of another Callbook on disk.
+// Callsigns that are not found by the underlying callbook are cached, too, but for a shorter time (negative caching).
+// Concurrent lookups of the same callsign share one request to the underlying callbook.
+//
+// New entries are written to the cache file at most once per SaveInterval, call Flush before the application exits to
+// write the remaining entries.
+type Cache struct {
+	// TTL is the duration for which a cached entry is considered valid.
+	TTL time.Duration
+	// NegativeTTL is the duration for which a callsign that was not found is not requested again.
+	NegativeTTL time.Duration
+	// MaxEntries limits the number of cached entries. If it is 0, the number of entries is not limited.
+	MaxEntries int
+	// Offline indicates that only cached entries are served, regardless of their age.
+	Offline bool
+	// SaveInterval is the minimum time between two writes of the cache file. If it is 0, the file is written after
+	// every change.
+	SaveInterval time.Duration
+
+	callbook Callbook
+	filename string
+	now      func() time.Time
+
+	lock      *sync.Mutex
+	entries   map[string]cacheEntry
+	inflight  map[string]*cacheRequest
+	stats     CacheStats
+	dirty     bool
+	lastSaved time.Time
+}
+
+// cacheRequest is a pending request to the underlying callbook. The result is available when done is closed.
+type cacheRequest struct {
+	done chan struct{}
+	info Info
+	err  error
+}
+
+type cacheEntry struct {
+	Info     Info      `json:"info"`
+	NotFound bool      `json:"not_found,omitempty"`
+	Created  time.Time `json:"created"`
+}
+
+// CacheStats contains statistical information about the usage of a Cache.
+type CacheStats struct {
+	Entries      int
+	Hits         int
+	NegativeHits int
+	Misses       int
+	// Shared counts the lookups that used the result of a pending lookup of the same callsign.
+	Shared    int
+	Evictions int
+}
+
+// CacheFilename returns the absolute path of the cache file for the given callbook provider in the configuration directory.
+func CacheFilename(provider string) (string, error) {
+	dir, err := cfg.Directory("")
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "callbook_"+strings.ToLower(provider)+"_cache.json"), nil
+}
+
+// NewCache creates a new Cache for the given callbook that is stored in the given file.
+// If the file already exists, the cached entries are loaded from it. If the filename is empty,
+// the cache is kept only in memory.
+func NewCache(callbook Callbook, filename string) (*Cache, error) {
+	result := &Cache{
+		TTL:          DefaultCacheTTL,
+		NegativeTTL:  DefaultNegativeCacheTTL,
+		SaveInterval: DefaultCacheSaveInterval,
+		callbook:     callbook,
+		filename:     filename,
+		now:          time.Now,
+		lock:         new(sync.Mutex),
+		entries:      make(map[string]cacheEntry),
+		inflight:     make(map[string]*cacheRequest),
+	}
+	if filename == "" {
+		return result, nil
+	}
+
+	data, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return result, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(data, &result.entries)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Lookup looks up information about the given callsign, either from the cache or from the underlying callbook.
+func (c *Cache) Lookup(callsign string) (Info, error) {
+	return c.LookupContext(context.Background(), callsign)
+}
+
+// LookupContext looks up information about the given callsign, either from the cache or from the underlying callbook.
+// A request to the underlying callbook is cancelled when the given context is done. If another lookup of the same
+// callsign is already pending, its result is used. If that lookup was cancelled by its own context, the request is
+// repeated with the given context.
+//
+// The result of the underlying callbook is returned even if the cache file cannot be written, the error is logged.
+func (c *Cache) LookupContext(ctx context.Context, callsign string) (Info, error) {
+	key := normalizeCallsign(callsign)
+
+	for {
+		c.lock.Lock()
+		entry, cached := c.entries[key]
+		if cached && (c.Offline || !c.expired(entry)) {
+			if entry.NotFound {
+				c.stats.NegativeHits++
+			} else {
+				c.stats.Hits++
+			}
+			c.lock.Unlock()
+			return entry.result()
+		}
+		if c.Offline {
+			c.stats.Misses++
+			c.lock.Unlock()
+			return Info{}, ErrNotCached
+		}
+		request, pending := c.inflight[key]
+		if !pending {
+			c.stats.Misses++
+			request = &cacheRequest{done: make(chan struct{})}
+			c.inflight[key] = request
+			c.lock.Unlock()
+			return c.request(ctx, key, callsign, request)
+		}
+		c.lock.Unlock()
+
+		select {
+		case <-request.done:
+		case <-ctx.Done():
+			return Info{}, ctx.Err()
+		}
+		if isContextError(request.err) && ctx.Err() == nil {
+			continue
+		}
+		c.lock.Lock()
+		c.stats.Shared++
+		c.lock.Unlock()
+		return request.info, request.err
+	}
+}
+
+// request performs the given pending request to the underlying callbook and caches the result. Lookups of the same
+// callsign that arrive in the meantime wait for the request to be done.
+func (c *Cache) request(ctx context.Context, key string, callsign string, request *cacheRequest) (Info, error) {
+	request.info, request.err = LookupContext(ctx, c.callbook, callsign)
+	notFound := errors.Is(request.err, ErrNotFound)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.inflight, key)
+	close(request.done)
+	if request.err != nil && !notFound {
+		return Info{}, request.err
+	}
+	c.entries[key] = cacheEntry{Info: request.info, NotFound: notFound, Created: c.now()}
+	c.limit()
+	c.dirty = true
+	if c.now().Sub(c.lastSaved) >= c.SaveInterval {
+		err := c.save()
+		if err != nil {
+			log.Printf("cannot save the callbook cache: %v", err)
+		}
+	}
+	return request.info, request.err
+}
+
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+func (e cacheEntry) result() (Info, error) {
+	if e.NotFound {
+		return Info{}, ErrNotFound
+	}
+	return e.Info, nil
+}
+
+func (c *Cache) expired(entry cacheEntry) bool {
+	ttl := c.TTL
+	if entry.NotFound {
+		ttl = c.NegativeTTL
+	}
+	return c.now().Sub(entry.Created) > ttl
+}
+
+// Stats returns the current statistics of this cache.
+func (c *Cache) Stats() CacheStats {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	result := c.stats
+	result.Entries = len(c.entries)
+	return result
+}
+
+// Evict removes all expired entries from the cache and stores the result.
+func (c *Cache) Evict() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for key, entry := range c.entries {
+		if c.expired(entry) {
+			delete(c.entries, key)
+			c.stats.Evictions++
+		}
+	}
+	return c.save()
+}
+
+// Clear removes all entries from the cache and stores the result.
+func (c *Cache) Clear() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.stats.Evictions += len(c.entries)
+	c.entries = make(map[string]cacheEntry)
+	return c.save()
+}
+
+// Flush writes the cache file if it contains entries that are not yet saved.
+func (c *Cache) Flush() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if !c.dirty {
+		return nil
+	}
+	return c.save()
+}
+
+// limit removes the oldest entries if the cache contains more than MaxEntries.
+func (c *Cache) limit() {
+	if c.MaxEntries <= 0 || len(c.entries) <= c.MaxEntries {
+		return
+	}
+	keys := make([]string, 0, len(c.entries))
+	for key := range c.entries {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return c.entries[keys[i]].Created.Before(c.entries[keys[j]].Created)
+	})
+	for _, key := range keys[:len(keys)-c.MaxEntries] {
+		delete(c.entries, key)
+		c.stats.Evictions++
+	}
+}
+
+// save writes the cache to a temporary file and renames it to the cache file afterwards.
+func (c *Cache) save() error {
+	if c.filename == "" {
+		return nil
+	}
+	data, err := json.Marshal(c.entries)
+	if err != nil {
+		return err
+	}
+	tempFilename := c.filename + ".tmp"
+	err = os.WriteFile(tempFilename, data, 0600)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(tempFilename, c.filename)
+	if err != nil {
+		return err
+	}
+	c.dirty = false
+	c.lastSaved = c.now()
+	return nil
+}
+
+func normalizeCallsign(callsign string) string {
+	return strings.ToUpper(strings.TrimSpace(callsign))
+}
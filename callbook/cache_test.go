@@ -0,0 +1,262 @@
+package callbook
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ftl/hamradio/callsign"
+)
+
+type fakeCallbook struct {
+	infos    map[string]Info
+	requests int
+}
+
+func (f *fakeCallbook) Lookup(call string) (Info, error) {
+	f.requests++
+	info, ok := f.infos[normalizeCallsign(call)]
+	if !ok {
+		return Info{}, fmt.Errorf("%w: %s", ErrNotFound, call)
+	}
+	return info, nil
+}
+
+func newFakeCallbook(calls ...string) *fakeCallbook {
+	result := &fakeCallbook{infos: make(map[string]Info)}
+	for _, call := range calls {
+		result.infos[call] = Info{Callsign: callsign.MustParse(call), Name: "name of " + call}
+	}
+	return result
+}
+
+func TestCache_Lookup(t *testing.T) {
+	provider := newFakeCallbook("DL1ABC")
+	cache, err := NewCache(provider, "")
+	require.NoError(t, err)
+
+	info, err := cache.Lookup("dl1abc")
+	require.NoError(t, err)
+	assert.Equal(t, "name of DL1ABC", info.Name)
+
+	info, err = cache.Lookup("DL1ABC ")
+	require.NoError(t, err)
+	assert.Equal(t, "name of DL1ABC", info.Name)
+	assert.Equal(t, 1, provider.requests)
+
+	_, err = cache.Lookup("DL2ABC")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = cache.Lookup("DL2ABC")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Equal(t, 2, provider.requests)
+
+	assert.Equal(t, CacheStats{Entries: 2, Hits: 1, NegativeHits: 1, Misses: 2}, cache.Stats())
+}
+
+func TestCache_Expiration(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	provider := newFakeCallbook("DL1ABC")
+	cache, err := NewCache(provider, "")
+	require.NoError(t, err)
+	cache.now = func() time.Time { return now }
+	cache.TTL = 10 * time.Hour
+	cache.NegativeTTL = time.Hour
+
+	cache.Lookup("DL1ABC")
+	cache.Lookup("DL2ABC")
+	assert.Equal(t, 2, provider.requests)
+
+	now = now.Add(2 * time.Hour)
+	cache.Lookup("DL1ABC")
+	cache.Lookup("DL2ABC")
+	assert.Equal(t, 3, provider.requests, "only the negative entry should be expired")
+
+	now = now.Add(20 * time.Hour)
+	cache.Offline = true
+	info, err := cache.Lookup("DL1ABC")
+	assert.NoError(t, err, "offline mode should serve expired entries")
+	assert.Equal(t, "name of DL1ABC", info.Name)
+	_, err = cache.Lookup("DL3ABC")
+	assert.ErrorIs(t, err, ErrNotCached)
+	assert.Equal(t, 3, provider.requests)
+
+	require.NoError(t, cache.Evict())
+	assert.Equal(t, 0, cache.Stats().Entries)
+	assert.Equal(t, 2, cache.Stats().Evictions)
+}
+
+func TestCache_MaxEntries(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	provider := newFakeCallbook("DL1ABC", "DL2ABC", "DL3ABC")
+	cache, err := NewCache(provider, "")
+	require.NoError(t, err)
+	cache.now = func() time.Time {
+		now = now.Add(time.Minute)
+		return now
+	}
+	cache.MaxEntries = 2
+
+	cache.Lookup("DL1ABC")
+	cache.Lookup("DL2ABC")
+	cache.Lookup("DL3ABC")
+	cache.Lookup("DL2ABC")
+	cache.Lookup("DL1ABC")
+
+	assert.Equal(t, 4, provider.requests)
+	assert.Equal(t, 2, cache.Stats().Entries)
+	assert.Equal(t, 2, cache.Stats().Evictions)
+}
+
+func TestCache_Persistence(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "cache.json")
+	provider := newFakeCallbook("DL1ABC")
+	cache, err := NewCache(provider, filename)
+	require.NoError(t, err)
+	cache.Lookup("DL1ABC")
+	cache.Lookup("DL2ABC")
+	require.NoError(t, cache.Flush())
+
+	reloaded, err := NewCache(provider, filename)
+	require.NoError(t, err)
+	reloaded.Offline = true
+
+	info, err := reloaded.Lookup("DL1ABC")
+	require.NoError(t, err)
+	assert.Equal(t, callsign.MustParse("DL1ABC"), info.Callsign)
+	_, err = reloaded.Lookup("DL2ABC")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Equal(t, 2, provider.requests)
+
+	require.NoError(t, reloaded.Clear())
+	cleared, err := NewCache(provider, filename)
+	require.NoError(t, err)
+	assert.Equal(t, 0, cleared.Stats().Entries)
+}
+
+func TestCache_SaveInterval(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	filename := filepath.Join(t.TempDir(), "cache.json")
+	cache, err := NewCache(newFakeCallbook("DL1ABC", "DL2ABC", "DL3ABC"), filename)
+	require.NoError(t, err)
+	cache.now = func() time.Time { return now }
+	cache.SaveInterval = time.Minute
+	savedEntries := func() int {
+		saved, err := NewCache(nil, filename)
+		require.NoError(t, err)
+		return saved.Stats().Entries
+	}
+
+	cache.Lookup("DL1ABC")
+	assert.Equal(t, 1, savedEntries())
+	cache.Lookup("DL2ABC")
+	assert.Equal(t, 1, savedEntries(), "the file should not be written within the save interval")
+
+	now = now.Add(time.Minute)
+	cache.Lookup("DL3ABC")
+	assert.Equal(t, 3, savedEntries())
+
+	cache.Lookup("DL4ABC")
+	require.NoError(t, cache.Flush())
+	assert.Equal(t, 4, savedEntries())
+}
+
+func TestCache_SaveError(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "missing", "cache.json")
+	cache, err := NewCache(newFakeCallbook("DL1ABC"), filename)
+	require.NoError(t, err)
+
+	info, err := cache.Lookup("DL1ABC")
+
+	assert.NoError(t, err, "a failed save must not fail the lookup")
+	assert.Equal(t, "name of DL1ABC", info.Name)
+	assert.Error(t, cache.Flush())
+	_, err = os.Stat(filename)
+	assert.True(t, os.IsNotExist(err))
+}
+
+type blockingCallbook struct {
+	requests int32
+	started  chan struct{}
+	release  chan struct{}
+}
+
+func (b *blockingCallbook) LookupContext(ctx context.Context, call string) (Info, error) {
+	if atomic.AddInt32(&b.requests, 1) == 1 {
+		close(b.started)
+	}
+	select {
+	case <-b.release:
+		return Info{Callsign: callsign.MustParse(call)}, nil
+	case <-ctx.Done():
+		return Info{}, ctx.Err()
+	}
+}
+
+func (b *blockingCallbook) Lookup(call string) (Info, error) {
+	return b.LookupContext(context.Background(), call)
+}
+
+func TestCache_ConcurrentLookups(t *testing.T) {
+	provider := &blockingCallbook{started: make(chan struct{}), release: make(chan struct{})}
+	cache, err := NewCache(provider, "")
+	require.NoError(t, err)
+
+	const lookups = 5
+	var waiter sync.WaitGroup
+	for i := 0; i < lookups; i++ {
+		waiter.Add(1)
+		go func() {
+			defer waiter.Done()
+			info, err := cache.Lookup("DL1ABC")
+			assert.NoError(t, err)
+			assert.Equal(t, callsign.MustParse("DL1ABC"), info.Callsign)
+		}()
+	}
+	<-provider.started
+	time.Sleep(50 * time.Millisecond)
+	close(provider.release)
+	waiter.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&provider.requests))
+	stats := cache.Stats()
+	assert.Equal(t, 1, stats.Misses)
+	assert.Equal(t, lookups-1, stats.Shared+stats.Hits)
+}
+
+func TestCache_ConcurrentLookupCancelled(t *testing.T) {
+	provider := &blockingCallbook{started: make(chan struct{}), release: make(chan struct{})}
+	cache, err := NewCache(provider, "")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := cache.LookupContext(ctx, "DL1ABC")
+		firstErr <- err
+	}()
+	<-provider.started
+
+	secondErr := make(chan error, 1)
+	go func() {
+		_, err := cache.LookupContext(context.Background(), "DL1ABC")
+		secondErr <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	assert.ErrorIs(t, <-firstErr, context.Canceled)
+
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&provider.requests) == 2
+	}, time.Second, 10*time.Millisecond, "the waiting lookup should repeat the request")
+	close(provider.release)
+	assert.NoError(t, <-secondErr)
+	assert.Equal(t, 2, cache.Stats().Misses)
+}
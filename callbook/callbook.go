@@ -1,9 +1,13 @@
 // Package callbook allows to retrieve information about a call from various online sources.
-// Supported sources: qrz.com, hamqth.com
+// Supported sources: qrz.com, hamqth.com, hamcall.net, callook.info and a local copy of the FCC ULS database.
 package callbook
 
 import (
-	"net/http"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/ftl/hamradio/callsign"
@@ -25,6 +29,20 @@ type Info struct {
 	CQZone      dxcc.CQZone
 	ITUZone     dxcc.ITUZone
 	TimeOffset  dxcc.TimeOffset
+	DXCCEntity  int
+
+	Email        string
+	QSLManager   string
+	LoTW         bool
+	EQSL         bool
+	PaperQSL     bool
+	LicenseClass string
+	BirthYear    int
+	USState      string
+	USCounty     string
+	IOTA         string
+	ImageURL     string
+	LastUpdate   time.Time
 }
 
 // Callbook defines the Lookup functionality in a callbook.
@@ -32,9 +50,95 @@ type Callbook interface {
 	Lookup(callsign string) (Info, error)
 }
 
+// ContextCallbook is a Callbook that allows to cancel a lookup through a context.
+type ContextCallbook interface {
+	Callbook
+	LookupContext(ctx context.Context, callsign string) (Info, error)
+}
+
+// LookupContext looks up the given callsign in the given callbook. If the callbook does not support a context,
+// the lookup keeps running in the background, but LookupContext returns as soon as the context is done.
+func LookupContext(ctx context.Context, callbook Callbook, callsign string) (Info, error) {
+	if callbook, ok := callbook.(ContextCallbook); ok {
+		return callbook.LookupContext(ctx, callsign)
+	}
+
+	type result struct {
+		info Info
+		err  error
+	}
+	results := make(chan result, 1)
+	go func() {
+		info, err := callbook.Lookup(callsign)
+		results <- result{info, err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return Info{}, ctx.Err()
+	case r := <-results:
+		return r.info, r.err
+	}
+}
+
+// ErrNotFound is returned by a callbook if the requested callsign is not known.
+var ErrNotFound = errors.New("callsign not found")
+
 // Factory is a function that creates a new callbook instance from username and password
 type Factory func(username, password string) Callbook
 
-var httpClient = &http.Client{
-	Timeout: time.Second * 10,
+// Factories contains the factories of all callbook providers that are supported by this package,
+// indexed by the name of the provider. Providers that do not need a user account ignore username and password.
+var Factories = map[string]Factory{
+	"hamqth": func(username, password string) Callbook {
+		return NewHamQTH(username, password)
+	},
+	"qrz": func(username, password string) Callbook {
+		return NewQRZ(username, password)
+	},
+	"hamcall": func(username, password string) Callbook {
+		return NewHamCall(username, password)
+	},
+	"callook": func(_, _ string) Callbook {
+		return NewCallook()
+	},
+	"uls": func(_, _ string) Callbook {
+		filename, err := ULSIndexFilename()
+		if err != nil {
+			return failedCallbook{fmt.Errorf("cannot find the ULS index: %w", err)}
+		}
+		return NewULS(filename)
+	},
+}
+
+// failedCallbook is returned by a factory that cannot create its callbook. Every lookup reports the cause.
+type failedCallbook struct {
+	err error
+}
+
+func (c failedCallbook) Lookup(string) (Info, error) {
+	return Info{}, c.err
+}
+
+// parseFlag parses the boolean flags used by the callbook services ("1", "Y", "yes").
+func parseFlag(s string) bool {
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case "1", "Y", "YES", "TRUE":
+		return true
+	default:
+		return false
+	}
+}
+
+// parseYear parses a year from a date that starts with the year ("1970", "1970-01-31").
+func parseYear(s string) int {
+	s = strings.TrimSpace(s)
+	if len(s) < 4 {
+		return 0
+	}
+	year, err := strconv.Atoi(s[:4])
+	if err != nil {
+		return 0
+	}
+	return year
 }
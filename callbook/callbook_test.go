@@ -1 +1,40 @@
 package callbook
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type slowCallbook struct {
+	delay time.Duration
+}
+
+func (c slowCallbook) Lookup(call string) (Info, error) {
+	time.Sleep(c.delay)
+	return Info{Name: call}, nil
+}
+
+func TestLookupContext_WithoutContextSupport(t *testing.T) {
+	callbook := slowCallbook{delay: 20 * time.Millisecond}
+
+	info, err := LookupContext(context.Background(), callbook, "DL1ABC")
+	require.NoError(t, err)
+	assert.Equal(t, "DL1ABC", info.Name)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	_, err = LookupContext(ctx, callbook, "DL1ABC")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestFailedCallbook(t *testing.T) {
+	callbook := failedCallbook{assert.AnError}
+
+	_, err := callbook.Lookup("DL1ABC")
+
+	assert.ErrorIs(t, err, assert.AnError)
+}
@@ -0,0 +1,130 @@
+package callbook
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ftl/hamradio/callsign"
+	"github.com/ftl/hamradio/latlon"
+	"github.com/ftl/hamradio/locator"
+)
+
+// Callook represents a connection to callook.info, which provides information about US callsigns from the FCC database.
+// callook.info does not need a user account. For more information about the API see https://callook.info/api_reference.php.
+type Callook struct {
+	webClient
+}
+
+// CallookURL is the default URL of the callook.info API.
+const CallookURL = "https://callook.info/"
+
+// CallookDateFormat describes the date format used by callook.info.
+const CallookDateFormat = "01/02/2006"
+
+// NewCallook creates a new Callook instance.
+func NewCallook() *Callook {
+	return NewCallookWithOptions(DefaultOptions)
+}
+
+// NewCallookWithOptions creates a new Callook instance that uses the given options to access callook.info.
+func NewCallookWithOptions(options Options) *Callook {
+	return &Callook{
+		webClient: newWebClient(options, CallookURL),
+	}
+}
+
+// Lookup looks up information about the given callsign.
+func (c *Callook) Lookup(callsign string) (Info, error) {
+	return c.LookupContext(context.Background(), callsign)
+}
+
+// LookupContext looks up information about the given callsign. The lookup is cancelled when the given context is done.
+func (c *Callook) LookupContext(ctx context.Context, callsign string) (Info, error) {
+	var response callookResponse
+	requestURL := strings.TrimSuffix(c.url, "/") + "/" + url.PathEscape(normalizeCallsign(callsign)) + "/json"
+	err := c.getJSON(ctx, requestURL, &response)
+	if err != nil {
+		return Info{}, err
+	}
+
+	switch response.Status {
+	case "VALID":
+		return callookResponseToInfo(response)
+	case "INVALID":
+		return Info{}, fmt.Errorf("%w: %s", ErrNotFound, callsign)
+	default:
+		return Info{}, fmt.Errorf("callook.info status %s", response.Status)
+	}
+}
+
+type callookResponse struct {
+	Status  string `json:"status"`
+	Type    string `json:"type"`
+	Current struct {
+		Callsign      string `json:"callsign"`
+		OperatorClass string `json:"operClass"`
+	} `json:"current"`
+	Previous struct {
+		Callsign      string `json:"callsign"`
+		OperatorClass string `json:"operClass"`
+	} `json:"previous"`
+	Trustee struct {
+		Callsign string `json:"callsign"`
+		Name     string `json:"name"`
+	} `json:"trustee"`
+	Name    string `json:"name"`
+	Address struct {
+		Line1     string `json:"line1"`
+		Line2     string `json:"line2"`
+		Attention string `json:"attn"`
+	} `json:"address"`
+	Location struct {
+		Latitude   string `json:"latitude"`
+		Longitude  string `json:"longitude"`
+		Gridsquare string `json:"gridsquare"`
+	} `json:"location"`
+	OtherInfo struct {
+		GrantDate      string `json:"grantDate"`
+		ExpiryDate     string `json:"expiryDate"`
+		LastActionDate string `json:"lastActionDate"`
+		FRN            string `json:"frn"`
+		ULSURL         string `json:"ulsUrl"`
+	} `json:"otherInfo"`
+}
+
+func callookResponseToInfo(c callookResponse) (Info, error) {
+	var result Info
+	var err error
+	result.Callsign, err = callsign.Parse(c.Current.Callsign)
+	if err != nil {
+		return Info{}, err
+	}
+	result.Name = strings.TrimSpace(c.Name)
+	result.Address = join(", ", c.Address.Attention, c.Address.Line1, c.Address.Line2)
+	result.QTH = strings.TrimSpace(c.Address.Line2)
+	result.Country = "United States"
+	result.Locator, _ = locator.Parse(c.Location.Gridsquare)
+	result.LatLon, err = latlon.ParseLatLon(c.Location.Latitude, c.Location.Longitude)
+	result.LatLonValid = err == nil
+	result.LicenseClass = strings.TrimSpace(c.Current.OperatorClass)
+	result.USState = usStateFromAddress(c.Address.Line2)
+	result.LastUpdate, _ = time.Parse(CallookDateFormat, c.OtherInfo.LastActionDate)
+
+	return result, nil
+}
+
+// usStateFromAddress extracts the state from the last line of a US address ("NEWINGTON, CT 06111").
+func usStateFromAddress(line string) string {
+	parts := strings.Split(line, ",")
+	if len(parts) < 2 {
+		return ""
+	}
+	fields := strings.Fields(parts[len(parts)-1])
+	if len(fields) == 0 || len(fields[0]) != 2 {
+		return ""
+	}
+	return fields[0]
+}
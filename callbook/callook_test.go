@@ -0,0 +1,48 @@
+package callbook
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCallook_Lookup(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/W1AW/json":
+			w.Write([]byte(`{
+				"status": "VALID",
+				"type": "CLUB",
+				"current": {"callsign": "W1AW", "operClass": ""},
+				"trustee": {"callsign": "W1AW", "name": "the_trustee"},
+				"name": "ARRL HQ OPERATORS CLUB",
+				"address": {"line1": "225 MAIN ST", "line2": "NEWINGTON, CT 06111", "attn": "the_attention"},
+				"location": {"latitude": "41.714775", "longitude": "-72.727260", "gridsquare": "FN31pr"},
+				"otherInfo": {"grantDate": "05/14/2020", "expiryDate": "07/11/2030", "lastActionDate": "05/14/2020"}
+			}`))
+		default:
+			w.Write([]byte(`{"status": "INVALID"}`))
+		}
+	}))
+	defer testServer.Close()
+
+	callook := NewCallookWithOptions(Options{URL: testServer.URL})
+
+	info, err := callook.Lookup("w1aw")
+	require.NoError(t, err)
+	assert.Equal(t, "W1AW", info.Callsign.String())
+	assert.Equal(t, "ARRL HQ OPERATORS CLUB", info.Name)
+	assert.Equal(t, "the_attention, 225 MAIN ST, NEWINGTON, CT 06111", info.Address)
+	assert.Equal(t, "NEWINGTON, CT 06111", info.QTH)
+	assert.Equal(t, "CT", info.USState)
+	assert.Equal(t, "FN31pr", info.Locator.String())
+	assert.True(t, info.LatLonValid)
+	assert.Equal(t, time.Date(2020, 5, 14, 0, 0, 0, 0, time.UTC), info.LastUpdate)
+
+	_, err = callook.Lookup("DL1ABC")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
@@ -0,0 +1,150 @@
+package callbook
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ftl/hamradio/callsign"
+	"github.com/ftl/hamradio/dxcc"
+	"github.com/ftl/hamradio/latlon"
+	"github.com/ftl/hamradio/locator"
+)
+
+// HamCall represents a connection to hamcall.net with a certain user account.
+// HamCall authenticates every request with username and password, there is no session handling.
+// For more information about the API see https://hamcall.net/xmlinfo.html.
+type HamCall struct {
+	webClient
+	Username string
+	password string
+}
+
+// HamCallURL is the default URL of the hamcall.net XML API.
+const HamCallURL = "https://hamcall.net/call"
+
+// HamCallDateFormat describes the date format used by hamcall.net.
+const HamCallDateFormat = "2006-01-02"
+
+// NewHamCall creates a new HamCall instance with the given username and password.
+func NewHamCall(username, password string) *HamCall {
+	return NewHamCallWithOptions(username, password, DefaultOptions)
+}
+
+// NewHamCallWithOptions creates a new HamCall instance with the given username and password that uses the given
+// options to access hamcall.net.
+func NewHamCallWithOptions(username, password string, options Options) *HamCall {
+	return &HamCall{
+		webClient: newWebClient(options, HamCallURL),
+		Username:  username,
+		password:  password,
+	}
+}
+
+// Lookup looks up information about the given callsign.
+func (hamcall *HamCall) Lookup(callsign string) (Info, error) {
+	return hamcall.LookupContext(context.Background(), callsign)
+}
+
+// LookupContext looks up information about the given callsign. The lookup is cancelled when the given context is done.
+func (hamcall *HamCall) LookupContext(ctx context.Context, callsign string) (Info, error) {
+	var response hamcallResponse
+	err := hamcall.getXML(ctx, map[string]string{
+		"username":  hamcall.Username,
+		"password":  hamcall.password,
+		"rawlookup": "1",
+		"callsign":  callsign,
+		"program":   "go-hamradio-callbook",
+	}, &response)
+	if err != nil {
+		return Info{}, err
+	}
+
+	message := strings.TrimSpace(response.Error)
+	switch {
+	case message == "":
+	case strings.HasPrefix(strings.ToLower(message), "not found"):
+		return Info{}, fmt.Errorf("%w: %s", ErrNotFound, message)
+	default:
+		return Info{}, fmt.Errorf("%v", message)
+	}
+	if response.Call == nil {
+		return Info{}, fmt.Errorf("%w: %s", ErrNotFound, callsign)
+	}
+
+	return hamcallCallToInfo(response.Call)
+}
+
+type hamcallResponse struct {
+	XMLName xml.Name     `xml:"HamCallOnline"`
+	Error   string       `xml:"Error"`
+	Call    *hamcallCall `xml:"Call"`
+}
+
+type hamcallCall struct {
+	XMLName      xml.Name `xml:"Call"`
+	Callsign     string   `xml:"callsign"`
+	FirstName    string   `xml:"first_name"`
+	LastName     string   `xml:"last_name"`
+	Nickname     string   `xml:"nickname"`
+	Address      string   `xml:"address"`
+	City         string   `xml:"city"`
+	State        string   `xml:"state"`
+	ZIP          string   `xml:"zip"`
+	County       string   `xml:"county"`
+	Country      string   `xml:"country"`
+	DXCCEntity   string   `xml:"dxcc"`
+	Grid         string   `xml:"grid"`
+	Latitude     string   `xml:"latitude"`
+	Longitude    string   `xml:"longitude"`
+	CQZone       string   `xml:"cq_zone"`
+	ITUZone      string   `xml:"itu_zone"`
+	UTCOffset    string   `xml:"utc_offset"`
+	LicenseClass string   `xml:"class"`
+	Email        string   `xml:"email"`
+	QSLManager   string   `xml:"qsl_manager"`
+	Lotw         string   `xml:"lotw"`
+	Eqsl         string   `xml:"eqsl"`
+	PaperQSL     string   `xml:"qsl_direct"`
+	BirthYear    string   `xml:"birth_year"`
+	IOTA         string   `xml:"iota"`
+	Picture      string   `xml:"picture"`
+	LastUpdate   string   `xml:"last_update"`
+}
+
+func hamcallCallToInfo(h *hamcallCall) (Info, error) {
+	var result Info
+	var err error
+	result.Callsign, err = callsign.Parse(h.Callsign)
+	if err != nil {
+		return Info{}, err
+	}
+	result.Name = join(" ", h.FirstName, h.LastName)
+	result.Address = join(", ", h.Address, join(" ", h.ZIP, h.City), h.State)
+	result.QTH = join(", ", h.City, h.State)
+	result.Country = strings.TrimSpace(h.Country)
+	result.Locator, _ = locator.Parse(h.Grid)
+	result.LatLon, err = latlon.ParseLatLon(h.Latitude, h.Longitude)
+	result.LatLonValid = err == nil
+	result.CQZone, _ = dxcc.ParseCQZone(h.CQZone)
+	result.ITUZone, _ = dxcc.ParseITUZone(h.ITUZone)
+	result.TimeOffset, _ = dxcc.ParseTimeOffset(h.UTCOffset)
+	result.DXCCEntity, _ = strconv.Atoi(h.DXCCEntity)
+	result.Email = strings.TrimSpace(h.Email)
+	result.QSLManager = strings.TrimSpace(h.QSLManager)
+	result.LoTW = parseFlag(h.Lotw)
+	result.EQSL = parseFlag(h.Eqsl)
+	result.PaperQSL = parseFlag(h.PaperQSL)
+	result.LicenseClass = strings.TrimSpace(h.LicenseClass)
+	result.BirthYear = parseYear(h.BirthYear)
+	result.USState = strings.TrimSpace(h.State)
+	result.USCounty = strings.TrimSpace(h.County)
+	result.IOTA = strings.TrimSpace(h.IOTA)
+	result.ImageURL = strings.TrimSpace(h.Picture)
+	result.LastUpdate, _ = time.Parse(HamCallDateFormat, h.LastUpdate)
+
+	return result, nil
+}
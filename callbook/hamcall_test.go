@@ -0,0 +1,56 @@
+package callbook
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHamCall_Lookup(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		switch {
+		case query.Get("username") != "the_username" || query.Get("password") != "the_password":
+			w.Write([]byte(`<HamCallOnline><Error>Invalid username or password</Error></HamCallOnline>`))
+		case query.Get("callsign") == "dl1abc":
+			w.Write([]byte(`<HamCallOnline><Call>
+				<callsign>DL1ABC</callsign>
+				<first_name>the_firstname</first_name>
+				<last_name>the_lastname</last_name>
+				<city>the_city</city>
+				<country>Germany</country>
+				<dxcc>230</dxcc>
+				<grid>JN59nk</grid>
+				<cq_zone>14</cq_zone>
+				<itu_zone>28</itu_zone>
+				<lotw>Y</lotw>
+				<last_update>2021-02-03</last_update>
+			</Call></HamCallOnline>`))
+		default:
+			w.Write([]byte(`<HamCallOnline><Error>Not found</Error></HamCallOnline>`))
+		}
+	}))
+	defer testServer.Close()
+
+	hamcall := NewHamCallWithOptions("the_username", "the_password", Options{URL: testServer.URL})
+
+	info, err := hamcall.Lookup("dl1abc")
+	require.NoError(t, err)
+	assert.Equal(t, "DL1ABC", info.Callsign.String())
+	assert.Equal(t, "the_firstname the_lastname", info.Name)
+	assert.Equal(t, "the_city", info.QTH)
+	assert.Equal(t, "JN59nk", info.Locator.String())
+	assert.Equal(t, 230, info.DXCCEntity)
+	assert.Equal(t, 14, int(info.CQZone))
+	assert.True(t, info.LoTW)
+
+	_, err = hamcall.Lookup("dl2abc")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	wrongUser := NewHamCallWithOptions("the_username", "wrong", Options{URL: testServer.URL})
+	_, err = wrongUser.Lookup("dl1abc")
+	assert.EqualError(t, err, "Invalid username or password")
+}
@@ -1,11 +1,12 @@
 package callbook
 
 import (
-	"bytes"
+	"context"
 	"encoding/xml"
 	"fmt"
-	"net/http"
+	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/ftl/hamradio/callsign"
 	"github.com/ftl/hamradio/dxcc"
@@ -16,34 +17,56 @@ import (
 // HamQTH represents a connection to hamqth.com with a certain user account.
 // For more information about the API see https://www.hamqth.com/developers.php.
 type HamQTH struct {
+	webClient
 	Username  string
 	password  string
 	sessionID string
-	url       string
+	lock      *sync.Mutex
 }
 
+// HamQTHURL is the default URL of the hamqth.com XML API.
+const HamQTHURL = "https://www.hamqth.com/xml.php"
+
 // NewHamQTH creates a new HamQTH instance with the given username and password.
 func NewHamQTH(username, password string) *HamQTH {
-	return &HamQTH{Username: username, password: password, url: "https://www.hamqth.com/xml.php"}
+	return NewHamQTHWithOptions(username, password, DefaultOptions)
+}
+
+// NewHamQTHWithOptions creates a new HamQTH instance with the given username and password that uses the given options
+// to access hamqth.com.
+func NewHamQTHWithOptions(username, password string, options Options) *HamQTH {
+	return &HamQTH{
+		webClient: newWebClient(options, HamQTHURL),
+		Username:  username,
+		password:  password,
+		lock:      new(sync.Mutex),
+	}
 }
 
 // Lookup looks up information about the given callsign.
 func (hamqth *HamQTH) Lookup(callsign string) (Info, error) {
+	return hamqth.LookupContext(context.Background(), callsign)
+}
+
+// LookupContext looks up information about the given callsign. The lookup is cancelled when the given context is done.
+// If the current session expired, LookupContext logs in again automatically.
+func (hamqth *HamQTH) LookupContext(ctx context.Context, callsign string) (Info, error) {
 	var response hamqthResponse
 	var err error
 	for retryCount := 0; retryCount < 2; retryCount++ {
-		err = hamqth.login()
+		var sessionID string
+		sessionID, err = hamqth.login(ctx)
 		if err != nil {
 			return Info{}, err
 		}
 
-		response, err = hamqth.get(map[string]string{
-			"id":       hamqth.sessionID,
+		response, err = hamqth.get(ctx, map[string]string{
+			"id":       sessionID,
 			"callsign": callsign,
 			"prg":      "go-hamradio-callbook",
 		})
 		if err != nil && err.Error() == "Session does not exist or expired" {
-			hamqth.sessionID = ""
+			hamqth.resetSession(sessionID)
 			continue
 		} else if err != nil {
 			return Info{}, err
@@ -62,58 +85,51 @@ func (hamqth *HamQTH) Lookup(callsign string) (Info, error) {
 	return info, nil
 }
 
-func (hamqth *HamQTH) login() error {
+func (hamqth *HamQTH) login(ctx context.Context) (string, error) {
+	hamqth.lock.Lock()
+	defer hamqth.lock.Unlock()
+
 	if hamqth.sessionID != "" {
-		return nil
+		return hamqth.sessionID, nil
 	}
-	response, err := hamqth.get(map[string]string{
+	response, err := hamqth.get(ctx, map[string]string{
 		"u": hamqth.Username,
 		"p": hamqth.password,
 	})
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	if response.Session == nil || response.Session.SessionID == "" {
-		return fmt.Errorf("failed to get a session ID from hamqth.com")
+		return "", fmt.Errorf("failed to get a session ID from hamqth.com")
 	}
 	hamqth.sessionID = response.Session.SessionID
 
-	return nil
+	return hamqth.sessionID, nil
 }
 
-func (hamqth HamQTH) get(params map[string]string) (hamqthResponse, error) {
-	request, err := http.NewRequest("GET", hamqth.url, nil)
-	if err != nil {
-		return hamqthResponse{}, err
-	}
-
-	query := request.URL.Query()
-	for key, value := range params {
-		query.Add(key, value)
-	}
-	request.URL.RawQuery = query.Encode()
-
-	response, err := httpClient.Do(request)
-	if err != nil {
-		return hamqthResponse{}, err
-	}
-	defer response.Body.Close()
+func (hamqth *HamQTH) resetSession(expiredSessionID string) {
+	hamqth.lock.Lock()
+	defer hamqth.lock.Unlock()
 
-	var buffer bytes.Buffer
-	_, err = buffer.ReadFrom(response.Body)
-	if err != nil {
-		return hamqthResponse{}, err
+	if hamqth.sessionID == expiredSessionID {
+		hamqth.sessionID = ""
 	}
+}
 
+func (hamqth *HamQTH) get(ctx context.Context, params map[string]string) (hamqthResponse, error) {
 	result := hamqthResponse{}
-	err = xml.Unmarshal(buffer.Bytes(), &result)
+	err := hamqth.getXML(ctx, params, &result)
 	if err != nil {
 		return hamqthResponse{}, err
 	}
 
 	if result.Session != nil && result.Session.Error != "" {
-		return hamqthResponse{}, fmt.Errorf("%v", strings.TrimSpace(result.Session.Error))
+		message := strings.TrimSpace(result.Session.Error)
+		if message == "Callsign not found" {
+			return hamqthResponse{}, fmt.Errorf("%w: %s", ErrNotFound, message)
+		}
+		return hamqthResponse{}, fmt.Errorf("%v", message)
 	}
 
 	return result, nil
@@ -198,6 +214,17 @@ func hamqthSearchToInfo(h *hamqthSearch) (Info, error) {
 	result.CQZone, _ = dxcc.ParseCQZone(h.CQZone)
 	result.ITUZone, _ = dxcc.ParseITUZone(h.ITUZone)
 	result.TimeOffset, _ = dxcc.ParseTimeOffset(h.UTCOffset)
+	result.DXCCEntity, _ = strconv.Atoi(h.DXCCCountryCode)
+	result.Email = strings.TrimSpace(h.Email)
+	result.QSLManager = strings.TrimSpace(h.QSLVia)
+	result.LoTW = parseFlag(h.Lotw)
+	result.EQSL = parseFlag(h.Eqsl)
+	result.PaperQSL = parseFlag(h.QSLBuro) || parseFlag(h.QSLDirect)
+	result.BirthYear = parseYear(h.BirthYear)
+	result.USState = strings.TrimSpace(h.USState)
+	result.USCounty = strings.TrimSpace(h.USCounty)
+	result.IOTA = strings.TrimSpace(h.IOTA)
+	result.ImageURL = strings.TrimSpace(h.Picture)
 
 	return result, nil
 }
@@ -1,11 +1,15 @@
 package callbook
 
 import (
+	"context"
 	"encoding/xml"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestHamQTH_get(t *testing.T) {
@@ -15,14 +19,14 @@ func TestHamQTH_get(t *testing.T) {
 	hamQTH := NewHamQTH("the_username", "the_password")
 	hamQTH.url = testServer.URL
 
-	info, err := hamQTH.get(map[string]string{})
+	info, err := hamQTH.get(context.Background(), map[string]string{})
 	if err.Error() != "Username or password missing" {
 		t.Errorf("connection error: %v", err)
 	} else if err == nil {
 		t.Errorf("request without parameters should raise an error: %v", info)
 	}
 
-	info, err = hamQTH.get(map[string]string{
+	info, err = hamQTH.get(context.Background(), map[string]string{
 		"u": hamQTH.Username,
 		"p": hamQTH.password,
 	})
@@ -33,7 +37,7 @@ func TestHamQTH_get(t *testing.T) {
 		t.Errorf("failed to parse session id: %v", info)
 	}
 
-	info, err = hamQTH.get(map[string]string{
+	info, err = hamQTH.get(context.Background(), map[string]string{
 		"id":       "the_session",
 		"callsign": "the_callsign",
 	})
@@ -63,14 +67,14 @@ func TestHamQTH_login(t *testing.T) {
 	hamQTH := NewHamQTH("the_username", "the_password")
 	hamQTH.url = testServer.URL
 
-	err := hamQTH.login()
+	_, err := hamQTH.login(context.Background())
 	if err.Error() != "Wrong user name or password" {
 		t.Errorf("connection error: %v", err)
 	} else if err == nil {
 		t.Errorf("login should faile on first attempt")
 	}
 
-	err = hamQTH.login()
+	_, err = hamQTH.login(context.Background())
 	if err != nil {
 		t.Errorf("login failed on second attempt: %v", err)
 	}
@@ -81,7 +85,7 @@ func TestHamQTH_login(t *testing.T) {
 		t.Errorf("failed to set received session ID: %v", hamQTH)
 	}
 
-	err = hamQTH.login()
+	_, err = hamQTH.login(context.Background())
 	if err != nil {
 		t.Errorf("login failed on third attempt: %v", err)
 	}
@@ -115,6 +119,15 @@ func TestHamQTH_Lookup(t *testing.T) {
 	if info.Locator.String() != "EM42lm" {
 		t.Errorf("failed to receive locator: %v", info.Locator)
 	}
+	assert.Equal(t, 230, info.DXCCEntity)
+	assert.Equal(t, "the_email", info.Email)
+	assert.Equal(t, "the_manager", info.QSLManager)
+	assert.True(t, info.LoTW)
+	assert.True(t, info.EQSL)
+	assert.True(t, info.PaperQSL)
+	assert.Equal(t, 1970, info.BirthYear)
+	assert.Equal(t, "EU-042", info.IOTA)
+	assert.Equal(t, "the_picture", info.ImageURL)
 }
 
 var serveValidHamQTHRequest = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -130,10 +143,20 @@ var serveValidHamQTHRequest = http.HandlerFunc(func(w http.ResponseWriter, r *ht
 		response.Session = &hamqthSession{Error: "Session does not exist or expired"}
 	} else if sessionID != "" && callsign != "" {
 		response.Search = &hamqthSearch{
-			Callsign: strings.ToUpper(callsign),
-			Nick:     "the_nick",
-			QTH:      "the_qth",
-			Grid:     "EM42lm",
+			Callsign:        strings.ToUpper(callsign),
+			Nick:            "the_nick",
+			QTH:             "the_qth",
+			Grid:            "EM42lm",
+			DXCCCountryCode: "230",
+			Email:           "the_email",
+			QSLVia:          "the_manager",
+			Lotw:            "Y",
+			Eqsl:            "Y",
+			QSLBuro:         "N",
+			QSLDirect:       "Y",
+			BirthYear:       "1970",
+			IOTA:            "EU-042",
+			Picture:         "the_picture",
 		}
 	} else {
 		response.Session = &hamqthSession{Error: "Username or password missing"}
@@ -142,3 +165,46 @@ var serveValidHamQTHRequest = http.HandlerFunc(func(w http.ResponseWriter, r *ht
 	body, _ := xml.Marshal(response)
 	w.Write(body)
 })
+
+func TestHamQTH_Relogin(t *testing.T) {
+	var loginCount int
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("u") != "" {
+			loginCount++
+		}
+		serveValidHamQTHRequest(w, r)
+	}))
+	defer testServer.Close()
+
+	hamQTH := NewHamQTHWithOptions("the_username", "the_password", Options{URL: testServer.URL})
+
+	_, err := hamQTH.Lookup("dl1abc")
+	require.NoError(t, err)
+	_, err = hamQTH.Lookup("dl2abc")
+	require.NoError(t, err)
+	assert.Equal(t, 1, loginCount)
+
+	hamQTH.sessionID = "timeout"
+	_, err = hamQTH.Lookup("dl3abc")
+	require.NoError(t, err)
+	assert.Equal(t, 2, loginCount)
+}
+
+func TestHamQTH_NotFound(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var response hamqthResponse
+		if r.URL.Query().Get("u") != "" {
+			response.Session = &hamqthSession{SessionID: "123"}
+		} else {
+			response.Session = &hamqthSession{Error: "Callsign not found"}
+		}
+		body, _ := xml.Marshal(response)
+		w.Write(body)
+	}))
+	defer testServer.Close()
+
+	hamQTH := NewHamQTHWithOptions("the_username", "the_password", Options{URL: testServer.URL})
+
+	_, err := hamQTH.Lookup("dl1abc")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
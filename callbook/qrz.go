@@ -2,10 +2,12 @@ package callbook
 
 import (
 	"bytes"
+	"context"
 	"encoding/xml"
 	"fmt"
-	"net/http"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/ftl/hamradio/callsign"
@@ -17,34 +19,56 @@ import (
 // QRZ represents a connection to qrz.com with a certain user account.
 // For more information about the API see https://www.qrz.com/page/current_spec.html.
 type QRZ struct {
+	webClient
 	Username  string
 	password  string
 	sessionID string
-	url       string
+	lock      *sync.Mutex
 }
 
+// QRZURL is the default URL of the qrz.com XML API.
+const QRZURL = "https://xmldata.qrz.com/xml/current/"
+
 // NewQRZ creates a new QRZ instance with the given username and password.
 func NewQRZ(username, password string) *QRZ {
-	return &QRZ{Username: username, password: password, url: "https://xmldata.qrz.com/xml/current/"}
+	return NewQRZWithOptions(username, password, DefaultOptions)
+}
+
+// NewQRZWithOptions creates a new QRZ instance with the given username and password that uses the given options
+// to access qrz.com.
+func NewQRZWithOptions(username, password string, options Options) *QRZ {
+	return &QRZ{
+		webClient: newWebClient(options, QRZURL),
+		Username:  username,
+		password:  password,
+		lock:      new(sync.Mutex),
+	}
 }
 
 // Lookup looks up information about the given callsign.
 func (qrz *QRZ) Lookup(callsign string) (Info, error) {
+	return qrz.LookupContext(context.Background(), callsign)
+}
+
+// LookupContext looks up information about the given callsign. The lookup is cancelled when the given context is done.
+// If the current session expired, LookupContext logs in again automatically.
+func (qrz *QRZ) LookupContext(ctx context.Context, callsign string) (Info, error) {
 	var response qrzResponse
 	var err error
 	for retryCount := 0; retryCount < 2; retryCount++ {
-		err = qrz.login()
+		var sessionID string
+		sessionID, err = qrz.login(ctx)
 		if err != nil {
 			return Info{}, err
 		}
 
-		response, err = qrz.get(map[string]string{
-			"s":        qrz.sessionID,
+		response, err = qrz.get(ctx, map[string]string{
+			"s":        sessionID,
 			"callsign": callsign,
 			"agent":    "go-hamradio-callbook",
 		})
-		if err != nil && err.Error() == "Session Timeout" {
-			qrz.sessionID = ""
+		if err != nil && qrzSessionExpired(err) {
+			qrz.resetSession(sessionID)
 			continue
 		} else if err != nil {
 			return Info{}, err
@@ -63,58 +87,56 @@ func (qrz *QRZ) Lookup(callsign string) (Info, error) {
 	return info, nil
 }
 
-func (qrz *QRZ) login() error {
+func qrzSessionExpired(err error) bool {
+	message := err.Error()
+	return message == "Session Timeout" || strings.HasPrefix(message, "Invalid session key")
+}
+
+func (qrz *QRZ) login(ctx context.Context) (string, error) {
+	qrz.lock.Lock()
+	defer qrz.lock.Unlock()
+
 	if qrz.sessionID != "" {
-		return nil
+		return qrz.sessionID, nil
 	}
-	response, err := qrz.get(map[string]string{
+	response, err := qrz.get(ctx, map[string]string{
 		"username": qrz.Username,
 		"password": qrz.password,
 	})
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	if response.Session == nil || response.Session.SessionID == "" {
-		return fmt.Errorf("failed to get a session ID from qrz.com")
+		return "", fmt.Errorf("failed to get a session ID from qrz.com")
 	}
 	qrz.sessionID = response.Session.SessionID
 
-	return nil
+	return qrz.sessionID, nil
 }
 
-func (qrz QRZ) get(params map[string]string) (qrzResponse, error) {
-	request, err := http.NewRequest("GET", qrz.url, nil)
-	if err != nil {
-		return qrzResponse{}, err
-	}
-
-	query := request.URL.Query()
-	for key, value := range params {
-		query.Add(key, value)
-	}
-	request.URL.RawQuery = query.Encode()
+func (qrz *QRZ) resetSession(expiredSessionID string) {
+	qrz.lock.Lock()
+	defer qrz.lock.Unlock()
 
-	response, err := httpClient.Do(request)
-	if err != nil {
-		return qrzResponse{}, err
-	}
-	defer response.Body.Close()
-
-	var buffer bytes.Buffer
-	_, err = buffer.ReadFrom(response.Body)
-	if err != nil {
-		return qrzResponse{}, err
+	if qrz.sessionID == expiredSessionID {
+		qrz.sessionID = ""
 	}
+}
 
+func (qrz *QRZ) get(ctx context.Context, params map[string]string) (qrzResponse, error) {
 	result := qrzResponse{}
-	err = xml.Unmarshal(buffer.Bytes(), &result)
+	err := qrz.getXML(ctx, params, &result)
 	if err != nil {
 		return qrzResponse{}, err
 	}
 
 	if result.Session != nil && result.Session.Error != "" {
-		return qrzResponse{}, fmt.Errorf("%v", strings.TrimSpace(result.Session.Error))
+		message := strings.TrimSpace(result.Session.Error)
+		if strings.HasPrefix(message, "Not found") {
+			return qrzResponse{}, fmt.Errorf("%w: %s", ErrNotFound, message)
+		}
+		return qrzResponse{}, fmt.Errorf("%v", message)
 	}
 
 	return result, nil
@@ -149,6 +171,7 @@ type qrzCallsign struct {
 	Country               string   `xml:"country"`
 	DXCCCountryCode       string   `xml:"ccode"`
 	DXCCCountryName       string   `xml:"land"`
+	DXCCEntity            string   `xml:"dxcc"`
 	ITUZone               string   `xml:"ituzone"`
 	CQZone                string   `xml:"cqzone"`
 	Grid                  string   `xml:"grid"`
@@ -191,6 +214,9 @@ type qrzTimestamp time.Time
 // QRZTimeFormat describes the time format used by qrz.com
 const QRZTimeFormat = "Mon Jan  2 15:04:05 2006"
 
+// QRZDateTimeFormat describes the format of the modification date of a record on qrz.com
+const QRZDateTimeFormat = "2006-01-02 15:04:05"
+
 func (t *qrzTimestamp) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 	var value string
 	err := d.DecodeElement(&value, &start)
@@ -230,6 +256,19 @@ func qrzCallsignToInfo(q *qrzCallsign) (Info, error) {
 	result.CQZone, _ = dxcc.ParseCQZone(q.CQZone)
 	result.ITUZone, _ = dxcc.ParseITUZone(q.ITUZone)
 	result.TimeOffset, _ = dxcc.ParseTimeOffset(q.UTCOffset)
+	result.DXCCEntity, _ = strconv.Atoi(q.DXCCEntity)
+	result.Email = strings.TrimSpace(q.Email)
+	result.QSLManager = strings.TrimSpace(q.QSLManager)
+	result.LoTW = parseFlag(q.Lotw)
+	result.EQSL = parseFlag(q.Eqsl)
+	result.PaperQSL = parseFlag(q.PaperQSL)
+	result.LicenseClass = strings.TrimSpace(q.LicenseClass)
+	result.BirthYear = parseYear(q.DateOfBirth)
+	result.USState = strings.TrimSpace(q.USState)
+	result.USCounty = strings.TrimSpace(q.USCounty)
+	result.IOTA = strings.TrimSpace(q.IOTA)
+	result.ImageURL = strings.TrimSpace(q.ImageURL)
+	result.LastUpdate, _ = time.Parse(QRZDateTimeFormat, q.LastUpdate)
 
 	return result, nil
 }
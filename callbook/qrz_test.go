@@ -1,12 +1,15 @@
 package callbook
 
 import (
+	"context"
 	"encoding/xml"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestQRZ_get(t *testing.T) {
@@ -16,14 +19,14 @@ func TestQRZ_get(t *testing.T) {
 	qrz := NewQRZ("the_username", "the_password")
 	qrz.url = testServer.URL
 
-	response, err := qrz.get(map[string]string{})
+	response, err := qrz.get(context.Background(), map[string]string{})
 	if err.Error() != "Username/password incorrect" {
 		t.Errorf("connection error: %v", err)
 	} else if err == nil {
 		t.Errorf("request without parameters should raise an error: %v", response)
 	}
 
-	response, err = qrz.get(map[string]string{
+	response, err = qrz.get(context.Background(), map[string]string{
 		"username": qrz.Username,
 		"password": qrz.password,
 	})
@@ -34,7 +37,7 @@ func TestQRZ_get(t *testing.T) {
 		t.Errorf("failed to parse session id: %v", response)
 	}
 
-	response, err = qrz.get(map[string]string{
+	response, err = qrz.get(context.Background(), map[string]string{
 		"s":        "the_session",
 		"callsign": "the_callsign",
 	})
@@ -65,14 +68,14 @@ func TestQRZ_login(t *testing.T) {
 	qrz := NewQRZ("the_username", "the_password")
 	qrz.url = testServer.URL
 
-	err := qrz.login()
+	_, err := qrz.login(context.Background())
 	if err.Error() != "Username/password incorrect" {
 		t.Errorf("connection error: %v", err)
 	} else if err == nil {
 		t.Errorf("login should faile on first attempt")
 	}
 
-	err = qrz.login()
+	_, err = qrz.login(context.Background())
 	if err != nil {
 		t.Errorf("login failed on second attempt: %v", err)
 	}
@@ -83,7 +86,7 @@ func TestQRZ_login(t *testing.T) {
 		t.Errorf("failed to set received session ID: %v", qrz)
 	}
 
-	err = qrz.login()
+	_, err = qrz.login(context.Background())
 	if err != nil {
 		t.Errorf("login failed on third attempt: %v", err)
 	}
@@ -139,6 +142,18 @@ func TestQRZ_Lookup(t *testing.T) {
 	if info.Locator.String() != "EM42lm" {
 		t.Errorf("failed to receive locator: %v", info.Locator)
 	}
+	assert.Equal(t, 291, info.DXCCEntity)
+	assert.Equal(t, "the_email", info.Email)
+	assert.Equal(t, "the_manager", info.QSLManager)
+	assert.True(t, info.LoTW)
+	assert.False(t, info.EQSL)
+	assert.True(t, info.PaperQSL)
+	assert.Equal(t, "E", info.LicenseClass)
+	assert.Equal(t, 1970, info.BirthYear)
+	assert.Equal(t, "TX", info.USState)
+	assert.Equal(t, "Dallas", info.USCounty)
+	assert.Equal(t, "the_image", info.ImageURL)
+	assert.Equal(t, time.Date(2020, 3, 4, 5, 6, 7, 0, time.UTC), info.LastUpdate)
 }
 
 var serveValidQRZRequest = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -156,12 +171,24 @@ var serveValidQRZRequest = http.HandlerFunc(func(w http.ResponseWriter, r *http.
 	} else if sessionID != "" && callsign != "" {
 		response.Session = &qrzSession{SessionID: "123", Timestamp: timestamp}
 		response.Callsign = &qrzCallsign{
-			Callsign:  strings.ToUpper(callsign),
-			FirstName: "the_firstname",
-			LastName:  "the_lastname",
-			Address1:  "the_street",
-			Address2:  "the_city",
-			Grid:      "EM42lm",
+			Callsign:     strings.ToUpper(callsign),
+			FirstName:    "the_firstname",
+			LastName:     "the_lastname",
+			Address1:     "the_street",
+			Address2:     "the_city",
+			Grid:         "EM42lm",
+			DXCCEntity:   "291",
+			Email:        "the_email",
+			QSLManager:   "the_manager",
+			Lotw:         "1",
+			Eqsl:         "0",
+			PaperQSL:     "1",
+			LicenseClass: "E",
+			DateOfBirth:  "1970",
+			USState:      "TX",
+			USCounty:     "Dallas",
+			ImageURL:     "the_image",
+			LastUpdate:   "2020-03-04 05:06:07",
 		}
 	} else {
 		response.Session = &qrzSession{Error: "Username/password incorrect ", Timestamp: timestamp}
@@ -170,3 +197,22 @@ var serveValidQRZRequest = http.HandlerFunc(func(w http.ResponseWriter, r *http.
 	body, _ := xml.Marshal(response)
 	w.Write(body)
 })
+
+func TestQRZ_LookupContext_Cancel(t *testing.T) {
+	requests := make(chan struct{})
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(requests)
+		<-r.Context().Done()
+	}))
+	defer testServer.Close()
+
+	qrz := NewQRZWithOptions("the_username", "the_password", Options{URL: testServer.URL, Retries: 2})
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-requests
+		cancel()
+	}()
+
+	_, err := qrz.LookupContext(ctx, "dl1abc")
+	assert.ErrorIs(t, err, context.Canceled)
+}
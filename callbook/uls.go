@@ -0,0 +1,292 @@
+package callbook
+
+import (
+	"bufio"
+	"context"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ftl/hamradio/callsign"
+	"github.com/ftl/hamradio/cfg"
+)
+
+// DefaultULSIndexFilename is the name of the local ULS index file, relative to the configuration directory.
+const DefaultULSIndexFilename = "uls.idx"
+
+// ULSDateFormat describes the date format used in the FCC ULS database dumps.
+const ULSDateFormat = "01/02/2006"
+
+// ULS is an offline callbook that uses a local index of the amateur radio licenses from the FCC Universal Licensing
+// System (ULS). The index is built from the pipe-delimited EN.dat, HD.dat and AM.dat files that are part of the
+// weekly database dumps, see https://www.fcc.gov/uls/transactions/daily-weekly.
+type ULS struct {
+	filename string
+
+	lock    *sync.Mutex
+	loaded  bool
+	entries map[string]ulsEntry
+}
+
+type ulsEntry struct {
+	Callsign     string
+	EntityName   string
+	FirstName    string
+	MiddleName   string
+	LastName     string
+	Suffix       string
+	Email        string
+	Street       string
+	POBox        string
+	City         string
+	State        string
+	ZIP          string
+	LicenseClass string
+	LastAction   time.Time
+}
+
+var ulsLicenseClasses = map[string]string{
+	"A": "Advanced",
+	"E": "Extra",
+	"G": "General",
+	"N": "Novice",
+	"P": "Technician Plus",
+	"T": "Technician",
+}
+
+// ULSIndexFilename returns the absolute path of the default ULS index file in the configuration directory.
+func ULSIndexFilename() (string, error) {
+	dir, err := cfg.Directory("")
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, DefaultULSIndexFilename), nil
+}
+
+// NewULS creates a new ULS instance that uses the index in the given file. The index is loaded with the first lookup.
+func NewULS(filename string) *ULS {
+	return &ULS{
+		filename: filename,
+		lock:     new(sync.Mutex),
+	}
+}
+
+// Lookup looks up information about the given callsign in the local ULS index.
+// Callsigns with prefix or suffix are looked up by their base call.
+func (uls *ULS) Lookup(call string) (Info, error) {
+	return uls.LookupContext(context.Background(), call)
+}
+
+// LookupContext looks up information about the given callsign in the local ULS index.
+func (uls *ULS) LookupContext(ctx context.Context, call string) (Info, error) {
+	err := uls.load()
+	if err != nil {
+		return Info{}, err
+	}
+
+	key := normalizeCallsign(call)
+	entry, ok := uls.entries[key]
+	if !ok {
+		parsed, err := callsign.Parse(key)
+		if err == nil {
+			entry, ok = uls.entries[parsed.BaseCall]
+		}
+	}
+	if !ok {
+		return Info{}, fmt.Errorf("%w: %s", ErrNotFound, call)
+	}
+	return ulsEntryToInfo(entry)
+}
+
+// Len returns the number of licenses in the index.
+func (uls *ULS) Len() int {
+	return len(uls.entries)
+}
+
+func (uls *ULS) load() error {
+	uls.lock.Lock()
+	defer uls.lock.Unlock()
+	if uls.loaded {
+		return nil
+	}
+
+	file, err := os.Open(uls.filename)
+	if err != nil {
+		return fmt.Errorf("cannot open the ULS index, import the ULS database first: %w", err)
+	}
+	defer file.Close()
+
+	entries := make(map[string]ulsEntry)
+	err = gob.NewDecoder(bufio.NewReader(file)).Decode(&entries)
+	if err != nil {
+		return err
+	}
+	uls.entries = entries
+	uls.loaded = true
+	return nil
+}
+
+// Save writes the index to the file of this ULS instance.
+func (uls *ULS) Save() error {
+	uls.lock.Lock()
+	defer uls.lock.Unlock()
+
+	tempFilename := uls.filename + ".tmp"
+	file, err := os.Create(tempFilename)
+	if err != nil {
+		return err
+	}
+	out := bufio.NewWriter(file)
+	err = gob.NewEncoder(out).Encode(uls.entries)
+	if err == nil {
+		err = out.Flush()
+	}
+	closeErr := file.Close()
+	if err != nil {
+		return err
+	}
+	if closeErr != nil {
+		return closeErr
+	}
+	return os.Rename(tempFilename, uls.filename)
+}
+
+// ImportULSDirectory imports the EN.dat, HD.dat and AM.dat files from the given directory into a new index
+// that is stored in the given file.
+func ImportULSDirectory(dir string, filename string) (*ULS, error) {
+	readers := make([]io.Reader, 0, 3)
+	for _, name := range []string{"EN.dat", "HD.dat", "AM.dat"} {
+		file, err := os.Open(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		readers = append(readers, file)
+	}
+
+	result, err := ImportULS(readers[0], readers[1], readers[2])
+	if err != nil {
+		return nil, err
+	}
+	result.filename = filename
+	return result, result.Save()
+}
+
+// ImportULS builds a new index from the given EN (entity), HD (license header) and AM (amateur) records.
+// Only active licenses are imported.
+func ImportULS(en, hd, am io.Reader) (*ULS, error) {
+	type header struct {
+		active     bool
+		lastAction time.Time
+	}
+	headers := make(map[string]header)
+	err := readULSRecords(hd, "HD", 44, func(fields []string) {
+		lastAction, _ := time.Parse(ULSDateFormat, fields[43])
+		headers[fields[1]] = header{
+			active:     fields[5] == "A",
+			lastAction: lastAction,
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	classes := make(map[string]string)
+	err = readULSRecords(am, "AM", 6, func(fields []string) {
+		classes[fields[1]] = ulsLicenseClasses[fields[5]]
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make(map[string]ulsEntry)
+	err = readULSRecords(en, "EN", 20, func(fields []string) {
+		id := fields[1]
+		call := strings.ToUpper(fields[4])
+		if h, ok := headers[id]; !ok || !h.active || call == "" || fields[5] != "L" {
+			return
+		}
+		entries[call] = ulsEntry{
+			Callsign:     call,
+			EntityName:   fields[7],
+			FirstName:    fields[8],
+			MiddleName:   fields[9],
+			LastName:     fields[10],
+			Suffix:       fields[11],
+			Email:        fields[14],
+			Street:       fields[15],
+			City:         fields[16],
+			State:        fields[17],
+			ZIP:          fields[18],
+			POBox:        fields[19],
+			LicenseClass: classes[id],
+			LastAction:   headers[id].lastAction,
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ULS{
+		lock:    new(sync.Mutex),
+		loaded:  true,
+		entries: entries,
+	}, nil
+}
+
+// readULSRecords reads pipe-delimited records of the given type and calls handle for each record
+// with at least the given number of fields.
+func readULSRecords(in io.Reader, recordType string, minFields int, handle func([]string)) error {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		fields := strings.Split(line, "|")
+		if fields[0] != recordType {
+			return fmt.Errorf("unexpected record type %s, expected %s", fields[0], recordType)
+		}
+		if len(fields) < minFields {
+			continue
+		}
+		handle(fields)
+	}
+	return scanner.Err()
+}
+
+func ulsEntryToInfo(e ulsEntry) (Info, error) {
+	var result Info
+	var err error
+	result.Callsign, err = callsign.Parse(e.Callsign)
+	if err != nil {
+		return Info{}, err
+	}
+	result.Name = join(" ", e.FirstName, e.MiddleName, e.LastName, e.Suffix)
+	if result.Name == "" {
+		result.Name = strings.TrimSpace(e.EntityName)
+	}
+	street := e.Street
+	if street == "" && e.POBox != "" {
+		street = "PO Box " + e.POBox
+	}
+	zip := e.ZIP
+	if len(zip) > 5 {
+		zip = zip[:5]
+	}
+	result.Address = join(", ", street, e.City, join(" ", e.State, zip))
+	result.QTH = join(", ", e.City, e.State)
+	result.Country = "United States"
+	result.Email = strings.TrimSpace(e.Email)
+	result.LicenseClass = e.LicenseClass
+	result.USState = strings.TrimSpace(e.State)
+	result.LastUpdate = e.LastAction
+
+	return result, nil
+}
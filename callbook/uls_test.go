@@ -0,0 +1,46 @@
+package callbook
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImportULSDirectory(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "uls.idx")
+	imported, err := ImportULSDirectory("./testdata/uls", filename)
+	require.NoError(t, err)
+	assert.Equal(t, 2, imported.Len(), "only active licenses should be imported")
+
+	uls := NewULS(filename)
+
+	info, err := uls.Lookup("w1aw")
+	require.NoError(t, err)
+	assert.Equal(t, "W1AW", info.Callsign.String())
+	assert.Equal(t, "ARRL INC", info.Name)
+	assert.Equal(t, "225 MAIN ST, NEWINGTON, CT 06111", info.Address)
+	assert.Equal(t, "NEWINGTON, CT", info.QTH)
+	assert.Equal(t, "CT", info.USState)
+	assert.Equal(t, "hq@arrl.org", info.Email)
+	assert.Equal(t, time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC), info.LastUpdate)
+
+	info, err = uls.Lookup("N0CALL/P")
+	require.NoError(t, err)
+	assert.Equal(t, "JANE Q PUBLIC", info.Name)
+	assert.Equal(t, "PO Box 42, DENVER, CO 80201", info.Address)
+	assert.Equal(t, "Extra", info.LicenseClass)
+
+	_, err = uls.Lookup("K1XYZ")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestULS_MissingIndex(t *testing.T) {
+	uls := NewULS(filepath.Join(t.TempDir(), "missing.idx"))
+
+	_, err := uls.Lookup("W1AW")
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrNotFound)
+}
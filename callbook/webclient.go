@@ -0,0 +1,198 @@
+package callbook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Options configures how a callbook client accesses the web service of its provider.
+type Options struct {
+	// HTTPClient is used for all requests. If it is nil, a default client with a timeout of 10s is used.
+	HTTPClient *http.Client
+	// URL is the base URL of the web service. If it is empty, the default URL of the provider is used.
+	URL string
+	// Retries is the number of retries after a request failed because of a network or server error.
+	Retries int
+	// Backoff is the delay before the first retry, it is doubled for every further retry.
+	Backoff time.Duration
+	// RateLimit is the minimum interval between two requests. If it is 0, the requests are not limited.
+	RateLimit time.Duration
+}
+
+// DefaultOptions are used by the constructors of the callbook clients that do not take explicit options.
+var DefaultOptions = Options{
+	Retries: 2,
+	Backoff: 500 * time.Millisecond,
+}
+
+var defaultHTTPClient = &http.Client{
+	Timeout: time.Second * 10,
+}
+
+// webClient implements the access to an XML or JSON based web service with retries and rate limiting.
+type webClient struct {
+	httpClient *http.Client
+	url        string
+	retries    int
+	backoff    time.Duration
+	limiter    *rateLimiter
+}
+
+func newWebClient(options Options, defaultURL string) webClient {
+	result := webClient{
+		httpClient: options.HTTPClient,
+		url:        options.URL,
+		retries:    options.Retries,
+		backoff:    options.Backoff,
+		limiter:    &rateLimiter{interval: options.RateLimit},
+	}
+	if result.httpClient == nil {
+		result.httpClient = defaultHTTPClient
+	}
+	if result.url == "" {
+		result.url = defaultURL
+	}
+	return result
+}
+
+// getXML requests the URL of the web service with the given query parameters and unmarshals the XML response into result.
+func (c *webClient) getXML(ctx context.Context, params map[string]string, result interface{}) error {
+	body, err := c.request(ctx, c.url, params)
+	if err != nil {
+		return err
+	}
+	return xml.Unmarshal(body, result)
+}
+
+// getJSON requests the given URL and unmarshals the JSON response into result.
+func (c *webClient) getJSON(ctx context.Context, url string, result interface{}) error {
+	body, err := c.request(ctx, url, nil)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, result)
+}
+
+// request requests the given URL with the given query parameters and returns the body of the response.
+// Requests that failed because of a network or server error are retried with an exponential backoff.
+func (c *webClient) request(ctx context.Context, url string, params map[string]string) ([]byte, error) {
+	backoff := c.backoff
+	var err error
+	for attempt := 0; attempt <= c.retries; attempt++ {
+		if attempt > 0 {
+			err := sleep(ctx, backoff)
+			if err != nil {
+				return nil, err
+			}
+			backoff *= 2
+		}
+
+		err = c.limiter.Wait(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		var body []byte
+		body, err = c.get(ctx, url, params)
+		if err == nil {
+			return body, nil
+		}
+		if !isTemporary(err) || ctx.Err() != nil {
+			return nil, err
+		}
+	}
+	return nil, err
+}
+
+func (c *webClient) get(ctx context.Context, url string, params map[string]string) ([]byte, error) {
+	request, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	query := request.URL.Query()
+	for key, value := range params {
+		query.Add(key, value)
+	}
+	request.URL.RawQuery = query.Encode()
+
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, statusError(response.StatusCode)
+	}
+
+	var buffer bytes.Buffer
+	_, err = buffer.ReadFrom(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+type statusError int
+
+func (e statusError) Error() string {
+	return fmt.Sprintf("HTTP status %d %s", int(e), http.StatusText(int(e)))
+}
+
+// isTemporary indicates if the given error is worth a retry.
+func isTemporary(err error) bool {
+	var status statusError
+	if errors.As(err, &status) {
+		return status >= 500 || status == http.StatusTooManyRequests
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+func sleep(ctx context.Context, duration time.Duration) error {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// rateLimiter ensures a minimum interval between two requests.
+type rateLimiter struct {
+	interval time.Duration
+	lock     sync.Mutex
+	next     time.Time
+}
+
+// Wait blocks until the next request is allowed or the given context is done.
+func (l *rateLimiter) Wait(ctx context.Context) error {
+	if l.interval <= 0 {
+		return nil
+	}
+
+	l.lock.Lock()
+	now := time.Now()
+	wait := l.next.Sub(now)
+	if wait < 0 {
+		wait = 0
+	}
+	l.next = now.Add(wait + l.interval)
+	l.lock.Unlock()
+
+	if wait == 0 {
+		return nil
+	}
+	return sleep(ctx, wait)
+}
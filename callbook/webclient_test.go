@@ -0,0 +1,98 @@
+package callbook
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebClient_Retries(t *testing.T) {
+	var requestCount int
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestCount++
+		if requestCount < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		serveValidQRZRequest(w, r)
+	}))
+	defer testServer.Close()
+
+	qrz := NewQRZWithOptions("the_username", "the_password", Options{
+		URL:     testServer.URL,
+		Retries: 2,
+		Backoff: time.Millisecond,
+	})
+
+	info, err := qrz.Lookup("dl1abc")
+	require.NoError(t, err)
+	assert.Equal(t, "DL1ABC", info.Callsign.String())
+	assert.Equal(t, 4, requestCount, "two failed and one successful login request, one lookup request")
+}
+
+func TestWebClient_NoRetryOnClientError(t *testing.T) {
+	var requestCount int
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestCount++
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer testServer.Close()
+
+	hamqth := NewHamQTHWithOptions("the_username", "the_password", Options{
+		URL:     testServer.URL,
+		Retries: 2,
+		Backoff: time.Millisecond,
+	})
+
+	_, err := hamqth.Lookup("dl1abc")
+	assert.Equal(t, statusError(http.StatusForbidden), err)
+	assert.Equal(t, 1, requestCount)
+}
+
+func TestWebClient_CancelBackoff(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer testServer.Close()
+
+	qrz := NewQRZWithOptions("the_username", "the_password", Options{
+		URL:     testServer.URL,
+		Retries: 5,
+		Backoff: time.Hour,
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := qrz.LookupContext(ctx, "dl1abc")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestRateLimiter(t *testing.T) {
+	limiter := &rateLimiter{interval: 20 * time.Millisecond}
+
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		require.NoError(t, limiter.Wait(context.Background()))
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 60*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	limiter.Wait(ctx)
+	assert.ErrorIs(t, limiter.Wait(ctx), context.Canceled)
+}
+
+func TestRateLimiter_Unlimited(t *testing.T) {
+	limiter := &rateLimiter{}
+
+	start := time.Now()
+	for i := 0; i < 100; i++ {
+		require.NoError(t, limiter.Wait(context.Background()))
+	}
+	assert.Less(t, time.Since(start), 10*time.Millisecond)
+}
@@ -233,28 +233,33 @@ func (config Configuration) Get(key Key, defaultValue interface{}) interface{} {
 }
 
 // GetStrings retrieves the value at the given path as string slice. If the key path
-// cannot be found, the given default value is returned.
+// cannot be found or the value is not an array of strings, the given default value is returned.
 func (config Configuration) GetStrings(key Key, defaultValue []string) []string {
 	rawValues := config.Get(key, nil)
-	if rawValues == nil {
+	values, ok := rawValues.([]interface{})
+	if !ok {
 		return defaultValue
 	}
-	values := rawValues.([]interface{})
 
 	result := make([]string, len(values))
 	for i, value := range values {
-		result[i] = value.(string)
+		s, ok := value.(string)
+		if !ok {
+			return defaultValue
+		}
+		result[i] = s
 	}
 	return result
 }
 
 // GetSlice retrieves the value at the given path as array. It iterates over its elements and calls the given callback for each element.
+// Elements that are not objects are skipped. If the value is not an array, the callback is not called at all.
 func (config Configuration) GetSlice(key Key, readElement func(int, map[string]interface{})) {
 	rawValues := config.Get(key, nil)
-	if rawValues == nil {
+	values, ok := rawValues.([]interface{})
+	if !ok {
 		return
 	}
-	values := rawValues.([]interface{})
 
 	for i, rawValue := range values {
 		value, ok := rawValue.(map[string]interface{})
@@ -264,3 +269,68 @@ func (config Configuration) GetSlice(key Key, readElement func(int, map[string]i
 		readElement(i, value)
 	}
 }
+
+// Set stores the given value at the given path in the configuration data. Missing intermediate objects are created,
+// intermediate values that are not objects are replaced.
+func (config Configuration) Set(key Key, value interface{}) {
+	elements := strings.Split(string(key), ".")
+	path := elements[:len(elements)-1]
+	nodeName := elements[len(elements)-1]
+	currentNode := map[string]interface{}(config)
+	for _, element := range path {
+		nextNode, ok := currentNode[element].(map[string]interface{})
+		if !ok {
+			nextNode = make(map[string]interface{})
+			currentNode[element] = nextNode
+		}
+		currentNode = nextNode
+	}
+	currentNode[nodeName] = value
+}
+
+// Delete removes the value at the given path from the configuration data. It returns false if the key path
+// cannot be found. Intermediate objects are kept, even if they become empty.
+func (config Configuration) Delete(key Key) bool {
+	elements := strings.Split(string(key), ".")
+	path := elements[:len(elements)-1]
+	nodeName := elements[len(elements)-1]
+	currentNode := map[string]interface{}(config)
+	for _, element := range path {
+		nextNode, ok := currentNode[element].(map[string]interface{})
+		if !ok {
+			return false
+		}
+		currentNode = nextNode
+	}
+	if _, exists := currentNode[nodeName]; !exists {
+		return false
+	}
+	delete(currentNode, nodeName)
+	return true
+}
+
+// copy returns a deep copy of the configuration data.
+func (config Configuration) copy() Configuration {
+	return Configuration(copyValue(map[string]interface{}(config)).(map[string]interface{}))
+}
+
+func copyValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(value))
+		for k, v := range value {
+			result[k] = copyValue(v)
+		}
+		return result
+	case Configuration:
+		return copyValue(map[string]interface{}(value))
+	case []interface{}:
+		result := make([]interface{}, len(value))
+		for i, v := range value {
+			result[i] = copyValue(v)
+		}
+		return result
+	default:
+		return value
+	}
+}
@@ -0,0 +1,190 @@
+package cfg
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// Layer identifies a source of configuration data in Layers.
+type Layer int
+
+// The layers of a layered configuration, in ascending order of precedence.
+const (
+	NoLayer Layer = iota
+	DefaultLayer
+	FileLayer
+	EnvironmentLayer
+	OverrideLayer
+)
+
+const layerCount = int(OverrideLayer)
+
+func (l Layer) String() string {
+	switch l {
+	case NoLayer:
+		return "none"
+	case DefaultLayer:
+		return "default"
+	case FileLayer:
+		return "file"
+	case EnvironmentLayer:
+		return "environment"
+	case OverrideLayer:
+		return "override"
+	default:
+		return fmt.Sprintf("Layer(%d)", int(l))
+	}
+}
+
+// EnvironmentPrefix is the prefix of all environment variables that are mapped to configuration keys.
+const EnvironmentPrefix = "HAMRADIO_"
+
+// Layers combines several sources of configuration data. A value in a higher layer shadows the values
+// in the lower layers; objects are merged key by key.
+type Layers struct {
+	layers [layerCount]Configuration
+}
+
+// NewLayers returns a new empty layered configuration.
+func NewLayers() *Layers {
+	result := &Layers{}
+	for i := range result.layers {
+		result.layers[i] = Configuration{}
+	}
+	return result
+}
+
+// LoadDefaultLayers loads a layered configuration with the default file in the default configuration directory,
+// see LoadLayers.
+func LoadDefaultLayers(defaults, overrides Configuration) (*Layers, error) {
+	return LoadLayers("", "", defaults, overrides)
+}
+
+// LoadLayers loads a layered configuration: the given defaults, the given file in the given directory, the
+// HAMRADIO_* environment variables and the given overrides. A missing configuration file is not an error.
+// If the path is the empty string, the default configuration directory is used. If the given filename is the
+// empty string, the default filename is used.
+func LoadLayers(path, filename string, defaults, overrides Configuration) (*Layers, error) {
+	result := NewLayers()
+	result.SetLayer(DefaultLayer, defaults)
+	result.SetLayer(EnvironmentLayer, Environment(os.Environ()))
+	result.SetLayer(OverrideLayer, overrides)
+
+	file, err := Load(path, filename)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return result, err
+	}
+	result.SetLayer(FileLayer, file)
+
+	return result, nil
+}
+
+// SetLayer replaces the configuration data of the given layer.
+func (l *Layers) SetLayer(layer Layer, config Configuration) {
+	if layer <= NoLayer || int(layer) > layerCount {
+		return
+	}
+	if config == nil {
+		config = Configuration{}
+	}
+	l.layers[layer-1] = config
+}
+
+// Layer returns the configuration data of the given layer.
+func (l *Layers) Layer(layer Layer) Configuration {
+	if layer <= NoLayer || int(layer) > layerCount {
+		return Configuration{}
+	}
+	return l.layers[layer-1]
+}
+
+// Get retrieves the value at the given path from the highest layer that contains the key path. If the key path
+// cannot be found in any layer, the given default value is returned.
+func (l *Layers) Get(key Key, defaultValue interface{}) interface{} {
+	return l.Configuration().Get(key, defaultValue)
+}
+
+// Source returns the highest layer that contains the given key path, or NoLayer if the key path cannot be found.
+func (l *Layers) Source(key Key) Layer {
+	for i := len(l.layers) - 1; i >= 0; i-- {
+		if l.layers[i].Get(key, nil) != nil {
+			return Layer(i + 1)
+		}
+	}
+	return NoLayer
+}
+
+// Configuration returns the merged configuration data of all layers.
+func (l *Layers) Configuration() Configuration {
+	result := Configuration{}
+	for _, layer := range l.layers {
+		merge(result, layer)
+	}
+	return result
+}
+
+func merge(target, source map[string]interface{}) {
+	for key, value := range source {
+		sourceNode, ok := value.(map[string]interface{})
+		if !ok {
+			target[key] = copyValue(value)
+			continue
+		}
+		targetNode, ok := target[key].(map[string]interface{})
+		if !ok {
+			targetNode = make(map[string]interface{})
+			target[key] = targetNode
+		}
+		merge(targetNode, sourceNode)
+	}
+}
+
+// Environment maps the HAMRADIO_* variables of the given environment (in the form of os.Environ) to configuration
+// keys: the prefix is removed, the name is converted to lower case, a single underscore separates the elements of
+// the key path and a double underscore stands for an underscore within a key (e.g. HAMRADIO_MY_CALL is mapped to
+// my.call, HAMRADIO_TEST__KEY is mapped to test_key). All values are strings.
+func Environment(environ []string) Configuration {
+	result := Configuration{}
+	for _, variable := range environ {
+		name, value, found := strings.Cut(variable, "=")
+		if !found || !strings.HasPrefix(name, EnvironmentPrefix) {
+			continue
+		}
+		key := environmentKey(strings.TrimPrefix(name, EnvironmentPrefix))
+		if key == "" {
+			continue
+		}
+		result.Set(key, value)
+	}
+	return result
+}
+
+func environmentKey(name string) Key {
+	elements := strings.Split(strings.ToLower(name), "__")
+	for i, element := range elements {
+		elements[i] = strings.ReplaceAll(element, "_", ".")
+	}
+	key := strings.Join(elements, "_")
+	if key == "" || strings.HasPrefix(key, ".") || strings.HasSuffix(key, ".") || strings.Contains(key, "..") {
+		return ""
+	}
+	return Key(key)
+}
+
+// ParseOverrides parses the given overrides in the form key=value (e.g. my.call=DL1ABC) into configuration data.
+// All values are strings.
+func ParseOverrides(overrides []string) (Configuration, error) {
+	result := Configuration{}
+	for _, override := range overrides {
+		key, value, found := strings.Cut(override, "=")
+		key = strings.TrimSpace(key)
+		if !found || key == "" {
+			return nil, fmt.Errorf("invalid override %q, use key=value", override)
+		}
+		result.Set(Key(key), value)
+	}
+	return result, nil
+}
@@ -0,0 +1,95 @@
+package cfg
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvironment(t *testing.T) {
+	environ := []string{
+		"HAMRADIO_MY_CALL=DL1ABC",
+		"HAMRADIO_CALLBOOK_QRZ_USERNAME=user",
+		"HAMRADIO_TEST__KEY=value",
+		"HAMRADIO_=ignored",
+		"HAMRADIO__LEADING=ignored",
+		"HOME=/home/user",
+		"NOVALUE",
+	}
+
+	config := Environment(environ)
+
+	assert.Equal(t, Configuration{
+		"my":       map[string]interface{}{"call": "DL1ABC"},
+		"callbook": map[string]interface{}{"qrz": map[string]interface{}{"username": "user"}},
+		"test_key": "value",
+	}, config)
+}
+
+func TestLayers(t *testing.T) {
+	layers := NewLayers()
+	layers.SetLayer(DefaultLayer, Configuration{
+		"my": map[string]interface{}{"call": "DEFAULT", "locator": "AA00"},
+	})
+	layers.SetLayer(FileLayer, Configuration{
+		"my":       map[string]interface{}{"call": "DL1ABC"},
+		"callbook": map[string]interface{}{"qrz": map[string]interface{}{"username": "file", "password": "secret"}},
+	})
+	layers.SetLayer(EnvironmentLayer, Environment([]string{"HAMRADIO_CALLBOOK_QRZ_USERNAME=env"}))
+	layers.SetLayer(OverrideLayer, Configuration{"my": map[string]interface{}{"locator": "JN59"}})
+
+	assert.Equal(t, "DL1ABC", layers.Get(MyCall, nil))
+	assert.Equal(t, FileLayer, layers.Source(MyCall))
+	assert.Equal(t, "JN59", layers.Get(MyLocator, nil))
+	assert.Equal(t, OverrideLayer, layers.Source(MyLocator))
+	assert.Equal(t, "env", layers.Get("callbook.qrz.username", nil))
+	assert.Equal(t, EnvironmentLayer, layers.Source("callbook.qrz.username"))
+	assert.Equal(t, "secret", layers.Get("callbook.qrz.password", nil))
+	assert.Equal(t, FileLayer, layers.Source("callbook.qrz.password"))
+	assert.Equal(t, NoLayer, layers.Source("missing"))
+	assert.Equal(t, "default", layers.Get("missing", "default"))
+
+	config := layers.Configuration()
+	config.Set(MyCall, "CHANGED")
+	assert.Equal(t, "DL1ABC", layers.Get(MyCall, nil), "the merged configuration must be a copy")
+}
+
+func TestLoadLayers(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, DefaultFilename), []byte(`{"my": {"call": "DL1ABC"}}`), 0600))
+	t.Setenv("HAMRADIO_MY_LOCATOR", "JN59")
+
+	layers, err := LoadLayers(dir, "", Configuration{"cw": map[string]interface{}{"speed": 20.0}}, Configuration{"cw": map[string]interface{}{"speed": "25"}})
+
+	require.NoError(t, err)
+	assert.Equal(t, FileLayer, layers.Source(MyCall))
+	assert.Equal(t, EnvironmentLayer, layers.Source(MyLocator))
+	assert.Equal(t, OverrideLayer, layers.Source("cw.speed"))
+	speed, err := layers.Configuration().GetInt("cw.speed", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 25, speed)
+}
+
+func TestLoadLayers_MissingFile(t *testing.T) {
+	t.Setenv("HAMRADIO_MY_CALL", "DL1ABC")
+
+	layers, err := LoadLayers(t.TempDir(), "missing.json", nil, nil)
+
+	require.NoError(t, err)
+	assert.Equal(t, "DL1ABC", layers.Get(MyCall, nil))
+}
+
+func TestParseOverrides(t *testing.T) {
+	config, err := ParseOverrides([]string{"my.call=DL1ABC", "my.locator=JN59=x"})
+	require.NoError(t, err)
+	assert.Equal(t, "DL1ABC", config.Get(MyCall, nil))
+	assert.Equal(t, "JN59=x", config.Get(MyLocator, nil))
+
+	_, err = ParseOverrides([]string{"invalid"})
+	assert.Error(t, err)
+	_, err = ParseOverrides([]string{"=value"})
+	assert.Error(t, err)
+}
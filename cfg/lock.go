@@ -0,0 +1,39 @@
+package cfg
+
+import (
+	"os"
+	"time"
+)
+
+// LockRetryInterval is the interval in which LockFile retries to acquire a lock that is held by another process.
+var LockRetryInterval = 50 * time.Millisecond
+
+// LockTimeout is the maximum time LockFile waits for a lock that is held by another process.
+var LockTimeout = 10 * time.Second
+
+// FileLock is an exclusive lock on a file that is shared between processes. The lock is held on a separate
+// lock file (the filename with the suffix .lock), because the file itself is replaced when it is saved.
+type FileLock struct {
+	filename string
+	file     *os.File
+}
+
+// LockFile acquires an exclusive lock for the given file. It blocks until the lock is acquired or LockTimeout
+// is exceeded.
+func LockFile(filename string) (*FileLock, error) {
+	lock := &FileLock{filename: filename + ".lock"}
+	deadline := time.Now().Add(LockTimeout)
+	for {
+		acquired, err := lock.tryLock()
+		if err != nil {
+			return nil, err
+		}
+		if acquired {
+			return lock, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, &os.PathError{Op: "lock", Path: filename, Err: os.ErrDeadlineExceeded}
+		}
+		time.Sleep(LockRetryInterval)
+	}
+}
@@ -0,0 +1,34 @@
+//go:build !unix
+
+package cfg
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+)
+
+func (l *FileLock) tryLock() (bool, error) {
+	file, err := os.OpenFile(l.filename, os.O_RDWR|os.O_CREATE|os.O_EXCL, defaultFileMode)
+	if errors.Is(err, fs.ErrExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	l.file = file
+	return true, nil
+}
+
+// Unlock releases the lock.
+func (l *FileLock) Unlock() error {
+	if l.file == nil {
+		return nil
+	}
+	defer func() { l.file = nil }()
+	err := l.file.Close()
+	if err != nil {
+		return err
+	}
+	return os.Remove(l.filename)
+}
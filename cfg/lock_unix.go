@@ -0,0 +1,41 @@
+//go:build unix
+
+package cfg
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+func (l *FileLock) tryLock() (bool, error) {
+	file, err := os.OpenFile(l.filename, os.O_RDWR|os.O_CREATE, defaultFileMode)
+	if err != nil {
+		return false, err
+	}
+	err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		file.Close()
+		return false, nil
+	}
+	if err != nil {
+		file.Close()
+		return false, err
+	}
+	l.file = file
+	return true, nil
+}
+
+// Unlock releases the lock.
+func (l *FileLock) Unlock() error {
+	if l.file == nil {
+		return nil
+	}
+	defer func() { l.file = nil }()
+	err := syscall.Flock(int(l.file.Fd()), syscall.LOCK_UN)
+	if err != nil {
+		l.file.Close()
+		return err
+	}
+	return l.file.Close()
+}
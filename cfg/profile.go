@@ -0,0 +1,222 @@
+package cfg
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ftl/hamradio/callsign"
+	"github.com/ftl/hamradio/dxcc"
+	"github.com/ftl/hamradio/locator"
+)
+
+// Keys of the station profiles.
+const (
+	// StationProfiles contains an object with one station profile per name.
+	StationProfiles Key = "station.profiles"
+	// ActiveStation contains the name of the active station profile.
+	ActiveStation Key = "station.active"
+)
+
+// Profile describes a station from which we operate, e.g. home, portable or as guest operator.
+//
+// The profiles are stored in the configuration below "station.profiles", the name of a profile must not contain dots:
+//
+//	"station": {
+//		"active": "portable",
+//		"profiles": {
+//			"portable": {
+//				"call": "DL1ABC/P",
+//				"operator": "DL1ABC",
+//				"locator": "JN59nk",
+//				"dxcc": 230,
+//				"cq": 14,
+//				"itu": 28,
+//				"power": 10,
+//				"antenna": "EFHW",
+//				"callbook": {
+//					"qrz": {"username": "...", "password": "..."}
+//				}
+//			}
+//		}
+//	}
+type Profile struct {
+	Name       string
+	Call       callsign.Callsign
+	Operator   string
+	Locator    locator.Locator
+	DXCCEntity int
+	CQZone     dxcc.CQZone
+	ITUZone    dxcc.ITUZone
+	// Power is the transmit power in watts.
+	Power   float64
+	Antenna string
+	// Callbook contains the default credentials for the callbooks, by the name of the callbook (e.g. qrz or hamqth).
+	Callbook map[string]Credentials
+}
+
+// Credentials contains a username and a password.
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// Credentials returns the credentials for the given callbook.
+func (p Profile) Credentials(callbook string) (Credentials, bool) {
+	result, ok := p.Callbook[callbook]
+	return result, ok && result.Username != "" && result.Password != ""
+}
+
+// ProfileNames returns the names of all station profiles in alphabetical order.
+func (config Configuration) ProfileNames() []string {
+	profiles, _ := config.Get(StationProfiles, nil).(map[string]interface{})
+	result := make([]string, 0, len(profiles))
+	for name := range profiles {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// Profile returns the station profile with the given name.
+func (config Configuration) Profile(name string) (Profile, error) {
+	if name == "" || strings.Contains(name, ".") {
+		return Profile{}, fmt.Errorf("invalid profile name %q", name)
+	}
+	key := StationProfiles + Key("."+name)
+	if _, ok := config.Get(key, nil).(map[string]interface{}); !ok {
+		return Profile{}, fmt.Errorf("unknown station profile %q", name)
+	}
+
+	var errs Errors
+	collect := func(err error) {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	result := Profile{Name: name}
+	var err error
+	result.Call, err = config.GetCallsign(key+".call", callsign.NoCallsign)
+	collect(err)
+	result.Operator, err = config.GetString(key+".operator", "")
+	collect(err)
+	result.Locator, err = config.GetLocator(key+".locator", locator.Locator{})
+	collect(err)
+	result.DXCCEntity, err = config.GetInt(key+".dxcc", 0)
+	collect(err)
+	cqZone, err := config.GetInt(key+".cq", 0)
+	collect(err)
+	result.CQZone = dxcc.CQZone(cqZone)
+	ituZone, err := config.GetInt(key+".itu", 0)
+	collect(err)
+	result.ITUZone = dxcc.ITUZone(ituZone)
+	result.Power, err = config.GetFloat(key+".power", 0)
+	collect(err)
+	result.Antenna, err = config.GetString(key+".antenna", "")
+	collect(err)
+
+	callbooks, _ := config.Get(key+".callbook", nil).(map[string]interface{})
+	if len(callbooks) > 0 {
+		result.Callbook = make(map[string]Credentials, len(callbooks))
+	}
+	for callbook := range callbooks {
+		credentialsKey := key + Key(".callbook."+callbook)
+		var credentials Credentials
+		credentials.Username, err = config.GetString(credentialsKey+".username", "")
+		collect(err)
+		credentials.Password, err = config.GetString(credentialsKey+".password", "")
+		collect(err)
+		result.Callbook[callbook] = credentials
+	}
+
+	if len(errs) > 0 {
+		return result, errs
+	}
+	return result, nil
+}
+
+// ActiveProfile returns the active station profile. If no profile is selected as active, but there is only one
+// profile, this profile is returned. Without any profiles, ActiveProfile falls back to my.call and my.locator.
+func (config Configuration) ActiveProfile() (Profile, error) {
+	name, err := config.GetString(ActiveStation, "")
+	if err != nil {
+		return Profile{}, err
+	}
+	if name != "" {
+		return config.Profile(name)
+	}
+
+	names := config.ProfileNames()
+	switch len(names) {
+	case 0:
+		return config.legacyProfile()
+	case 1:
+		return config.Profile(names[0])
+	default:
+		return Profile{}, fmt.Errorf("no active station profile selected, use %s to select one of %s", ActiveStation, strings.Join(names, ", "))
+	}
+}
+
+func (config Configuration) legacyProfile() (Profile, error) {
+	var errs Errors
+	var result Profile
+	var err error
+	result.Call, err = config.GetCallsign(MyCall, callsign.NoCallsign)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	result.Locator, err = config.GetLocator(MyLocator, locator.Locator{})
+	if err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		return result, errs
+	}
+	return result, nil
+}
+
+// SetProfile stores the given station profile in the configuration. Empty fields are omitted.
+func (config Configuration) SetProfile(profile Profile) error {
+	if profile.Name == "" || strings.Contains(profile.Name, ".") {
+		return fmt.Errorf("invalid profile name %q", profile.Name)
+	}
+
+	value := make(map[string]interface{})
+	if profile.Call != callsign.NoCallsign {
+		value["call"] = profile.Call.String()
+	}
+	if profile.Operator != "" {
+		value["operator"] = profile.Operator
+	}
+	if !profile.Locator.IsZero() {
+		value["locator"] = profile.Locator.String()
+	}
+	if profile.DXCCEntity != 0 {
+		value["dxcc"] = profile.DXCCEntity
+	}
+	if profile.CQZone != 0 {
+		value["cq"] = int(profile.CQZone)
+	}
+	if profile.ITUZone != 0 {
+		value["itu"] = int(profile.ITUZone)
+	}
+	if profile.Power != 0 {
+		value["power"] = profile.Power
+	}
+	if profile.Antenna != "" {
+		value["antenna"] = profile.Antenna
+	}
+	if len(profile.Callbook) > 0 {
+		callbooks := make(map[string]interface{}, len(profile.Callbook))
+		for callbook, credentials := range profile.Callbook {
+			callbooks[callbook] = map[string]interface{}{
+				"username": credentials.Username,
+				"password": credentials.Password,
+			}
+		}
+		value["callbook"] = callbooks
+	}
+
+	config.Set(StationProfiles+Key("."+profile.Name), value)
+	return nil
+}
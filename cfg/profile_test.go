@@ -0,0 +1,120 @@
+package cfg
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ftl/hamradio/callsign"
+	"github.com/ftl/hamradio/dxcc"
+	"github.com/ftl/hamradio/locator"
+)
+
+const profilesConfig = `{
+	"my": {"call": "DL1ABC", "locator": "JN59"},
+	"station": {
+		"active": "portable",
+		"profiles": {
+			"home": {
+				"call": "DL1ABC",
+				"locator": "JN59nk",
+				"dxcc": 230,
+				"cq": 14,
+				"itu": 28,
+				"power": 100,
+				"antenna": "Dipole",
+				"callbook": {
+					"qrz": {"username": "home", "password": "secret"}
+				}
+			},
+			"portable": {
+				"call": "DL1ABC/P",
+				"operator": "DL1ABC",
+				"locator": "JO40",
+				"power": 5.5
+			},
+			"broken": {
+				"locator": "not a locator",
+				"cq": "x"
+			}
+		}
+	}
+}`
+
+func TestConfiguration_Profile(t *testing.T) {
+	config, err := Read(strings.NewReader(profilesConfig))
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"broken", "home", "portable"}, config.ProfileNames())
+
+	home, err := config.Profile("home")
+	require.NoError(t, err)
+	assert.Equal(t, "home", home.Name)
+	assert.Equal(t, "DL1ABC", home.Call.String())
+	assert.Equal(t, "JN59nk", home.Locator.String())
+	assert.Equal(t, 230, home.DXCCEntity)
+	assert.Equal(t, dxcc.CQZone(14), home.CQZone)
+	assert.Equal(t, dxcc.ITUZone(28), home.ITUZone)
+	assert.Equal(t, 100.0, home.Power)
+	assert.Equal(t, "Dipole", home.Antenna)
+	credentials, ok := home.Credentials("qrz")
+	assert.True(t, ok)
+	assert.Equal(t, Credentials{Username: "home", Password: "secret"}, credentials)
+	_, ok = home.Credentials("hamqth")
+	assert.False(t, ok)
+
+	_, err = config.Profile("broken")
+	var errs Errors
+	assert.ErrorAs(t, err, &errs)
+	assert.Len(t, errs, 2)
+
+	_, err = config.Profile("missing")
+	assert.Error(t, err)
+	_, err = config.Profile("home.call")
+	assert.Error(t, err)
+}
+
+func TestConfiguration_ActiveProfile(t *testing.T) {
+	config, err := Read(strings.NewReader(profilesConfig))
+	require.NoError(t, err)
+
+	active, err := config.ActiveProfile()
+	require.NoError(t, err)
+	assert.Equal(t, "portable", active.Name)
+	assert.Equal(t, "DL1ABC", active.Operator)
+	assert.Equal(t, 5.5, active.Power)
+
+	config.Delete(ActiveStation)
+	_, err = config.ActiveProfile()
+	assert.Error(t, err, "several profiles and none is active")
+
+	config.Delete(StationProfiles)
+	legacy, err := config.ActiveProfile()
+	require.NoError(t, err)
+	assert.Equal(t, "", legacy.Name)
+	assert.Equal(t, "DL1ABC", legacy.Call.String())
+	assert.Equal(t, "JN59", legacy.Locator.String())
+}
+
+func TestConfiguration_SetProfile(t *testing.T) {
+	config := Configuration{}
+	call, _ := callsign.Parse("DL1ABC")
+	loc, _ := locator.Parse("JN59nk")
+	profile := Profile{
+		Name:     "home",
+		Call:     call,
+		Locator:  loc,
+		CQZone:   14,
+		Power:    100,
+		Callbook: map[string]Credentials{"qrz": {Username: "user", Password: "secret"}},
+	}
+
+	require.NoError(t, config.SetProfile(profile))
+	assert.Error(t, config.SetProfile(Profile{Name: "in.valid"}))
+
+	active, err := config.ActiveProfile()
+	require.NoError(t, err)
+	assert.Equal(t, profile, active)
+}
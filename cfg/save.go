@@ -0,0 +1,286 @@
+package cfg
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// defaultFileMode is used for new configuration files. The configuration may contain credentials,
+// therefore it is only readable by the owner.
+const defaultFileMode = 0600
+
+func absoluteFilename(path, filename string) (string, error) {
+	absolutePath, err := Directory(path)
+	if err != nil {
+		return "", err
+	}
+	if filename == "" {
+		return filepath.Join(absolutePath, DefaultFilename), nil
+	}
+	return filepath.Join(absolutePath, filename), nil
+}
+
+// Save saves the configuration to the given file in the given directory. If the path is the empty string, the
+// default configuration directory is used. If the given filename is the empty string, the default filename is used.
+//
+// The file is replaced atomically. If the file already exists, its indentation, the order of its keys and its
+// permissions are kept. New keys are appended to their object in alphabetical order. Save does not lock the file, use Update to modify the configuration safely if other tools may write it at
+// the same time.
+func Save(path, filename string, config Configuration) error {
+	absoluteFilename, err := absoluteFilename(path, filename)
+	if err != nil {
+		return err
+	}
+
+	prefix, indent, newline := "", "\t", true
+	mode := fs.FileMode(defaultFileMode)
+	var order *keyOrder
+	existing, err := os.ReadFile(absoluteFilename)
+	if err == nil {
+		prefix, indent, newline = detectFormat(existing)
+		order = readKeyOrder(existing)
+		if info, err := os.Stat(absoluteFilename); err == nil {
+			mode = info.Mode().Perm()
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	compact := new(bytes.Buffer)
+	err = encodeOrdered(compact, map[string]interface{}(config), order)
+	if err != nil {
+		return err
+	}
+	buf := compact
+	if indent != "" {
+		buf = new(bytes.Buffer)
+		err = json.Indent(buf, compact.Bytes(), prefix, indent)
+		if err != nil {
+			return err
+		}
+	}
+	if newline {
+		buf.WriteByte('\n')
+	}
+
+	return writeFileAtomic(absoluteFilename, buf.Bytes(), mode)
+}
+
+// Update reads the configuration from the given file in the given directory, calls the given function to modify it
+// and saves the result. The file is locked during the update, so that several tools can update the same file at the
+// same time. A missing file is treated as an empty configuration. If the given function returns an error, the file
+// is not changed.
+func Update(path, filename string, update func(Configuration) error) error {
+	absoluteFilename, err := absoluteFilename(path, filename)
+	if err != nil {
+		return err
+	}
+
+	lock, err := LockFile(absoluteFilename)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	config, err := Load(filepath.Dir(absoluteFilename), filepath.Base(absoluteFilename))
+	if errors.Is(err, fs.ErrNotExist) {
+		config = Configuration{}
+	} else if err != nil {
+		return err
+	}
+
+	err = update(config)
+	if err != nil {
+		return err
+	}
+
+	return Save(filepath.Dir(absoluteFilename), filepath.Base(absoluteFilename), config)
+}
+
+// detectFormat returns the prefix and indentation of the given JSON data and whether it ends with a newline.
+// An empty indentation means the data is in compact form.
+func detectFormat(data []byte) (prefix string, indent string, newline bool) {
+	newline = bytes.HasSuffix(data, []byte("\n"))
+	lines := bytes.Split(bytes.TrimSpace(data), []byte("\n"))
+	if len(lines) < 2 {
+		return "", "", newline
+	}
+
+	lastLine := lines[len(lines)-1]
+	prefix = string(lastLine[:len(lastLine)-len(bytes.TrimLeft(lastLine, " \t"))])
+	for _, line := range lines[1:] {
+		trimmed := bytes.TrimLeft(line, " \t")
+		if len(trimmed) == 0 {
+			continue
+		}
+		whitespace := string(line[:len(line)-len(trimmed)])
+		if len(whitespace) > len(prefix) {
+			return prefix, whitespace[len(prefix):], newline
+		}
+	}
+	return prefix, "\t", newline
+}
+
+// keyOrder is the order of the keys of a JSON object and of the objects nested in it.
+type keyOrder struct {
+	keys     []string
+	children map[string]*keyOrder
+}
+
+// readKeyOrder returns the order of the keys in the given JSON data, or nil if the data is not a valid JSON object.
+func readKeyOrder(data []byte) *keyOrder {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	result, err := readValueOrder(decoder)
+	if err != nil {
+		return nil
+	}
+	return result
+}
+
+// readValueOrder reads the next value from the given decoder. It returns the order of the keys if the value is an
+// object, otherwise nil.
+func readValueOrder(decoder *json.Decoder) (*keyOrder, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch token {
+	case json.Delim('{'):
+		result := &keyOrder{children: make(map[string]*keyOrder)}
+		for decoder.More() {
+			token, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			key, ok := token.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid key %v", token)
+			}
+			child, err := readValueOrder(decoder)
+			if err != nil {
+				return nil, err
+			}
+			if _, exists := result.children[key]; !exists {
+				result.keys = append(result.keys, key)
+			}
+			result.children[key] = child
+		}
+		_, err = decoder.Token()
+		return result, err
+	case json.Delim('['):
+		for decoder.More() {
+			_, err := readValueOrder(decoder)
+			if err != nil {
+				return nil, err
+			}
+		}
+		_, err = decoder.Token()
+		return nil, err
+	default:
+		return nil, nil
+	}
+}
+
+// encodeOrdered writes the given value as compact JSON into the given buffer. The keys of the objects are written in
+// the given order, the remaining keys follow in alphabetical order. Unlike json.Marshal, <, > and & are not escaped.
+func encodeOrdered(buf *bytes.Buffer, value interface{}, order *keyOrder) error {
+	var object map[string]interface{}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		object = v
+	case Configuration:
+		object = v
+	default:
+		encoder := json.NewEncoder(buf)
+		encoder.SetEscapeHTML(false)
+		err := encoder.Encode(value)
+		if err != nil {
+			return err
+		}
+		buf.Truncate(buf.Len() - 1) // Encode appends a newline
+		return nil
+	}
+
+	if order == nil {
+		order = &keyOrder{}
+	}
+	keys := make([]string, 0, len(object))
+	for _, key := range order.keys {
+		if _, ok := object[key]; ok {
+			keys = append(keys, key)
+		}
+	}
+	known := len(keys)
+	for key := range object {
+		if _, ok := order.children[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys[known:])
+
+	buf.WriteByte('{')
+	for i, key := range keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		err := encodeOrdered(buf, key, nil)
+		if err != nil {
+			return err
+		}
+		buf.WriteByte(':')
+		err = encodeOrdered(buf, object[key], order.children[key])
+		if err != nil {
+			return err
+		}
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+// writeFileAtomic writes the given data into a temporary file in the same directory and renames it to the given
+// filename afterwards.
+func writeFileAtomic(filename string, data []byte, mode fs.FileMode) error {
+	file, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tempFilename := file.Name()
+	committed := false
+	defer func() {
+		if !committed {
+			os.Remove(tempFilename)
+		}
+	}()
+
+	_, err = file.Write(data)
+	if err != nil {
+		file.Close()
+		return err
+	}
+	err = file.Sync()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	err = file.Close()
+	if err != nil {
+		return err
+	}
+	err = os.Chmod(tempFilename, mode)
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(tempFilename, filename)
+	if err != nil {
+		return err
+	}
+	committed = true
+	return nil
+}
@@ -0,0 +1,159 @@
+package cfg
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfiguration_SetDelete(t *testing.T) {
+	config := Configuration{"root": "value"}
+
+	config.Set("my.call", "DL1ABC")
+	config.Set("root.child", 1)
+
+	assert.Equal(t, "DL1ABC", config.Get("my.call", nil))
+	assert.Equal(t, 1, config.Get("root.child", nil))
+
+	assert.True(t, config.Delete("my.call"))
+	assert.False(t, config.Delete("my.call"))
+	assert.False(t, config.Delete("missing.key"))
+	assert.Nil(t, config.Get("my.call", nil))
+	assert.NotNil(t, config.Get("my", nil))
+}
+
+func TestSave_KeepsFormat(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, DefaultFilename)
+	original := `{
+    "unknown": {
+        "url": "https://example.com/?a=<1>&b=2",
+        "key": true
+    },
+    "my": {
+        "operator": "DL2XYZ",
+        "call": "DL1ABC"
+    }
+}
+`
+	require.NoError(t, os.WriteFile(filename, []byte(original), 0640))
+
+	config, err := Load(dir, "")
+	require.NoError(t, err)
+	config.Set("my.locator", "JN59")
+	config.Set("my.email", "dl1abc@example.com")
+	config.Set("new", []interface{}{1, "<&>"})
+	require.NoError(t, Save(dir, "", config))
+
+	saved, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	assert.Equal(t, `{
+    "unknown": {
+        "url": "https://example.com/?a=<1>&b=2",
+        "key": true
+    },
+    "my": {
+        "operator": "DL2XYZ",
+        "call": "DL1ABC",
+        "email": "dl1abc@example.com",
+        "locator": "JN59"
+    },
+    "new": [
+        1,
+        "<&>"
+    ]
+}
+`, string(saved))
+
+	info, err := os.Stat(filename)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), info.Mode().Perm())
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "no temporary files must be left behind")
+}
+
+func TestSave_NewFile(t *testing.T) {
+	dir := t.TempDir()
+
+	require.NoError(t, Save(dir, "new.json", Configuration{"key": "value"}))
+
+	saved, err := os.ReadFile(filepath.Join(dir, "new.json"))
+	require.NoError(t, err)
+	assert.Equal(t, "{\n\t\"key\": \"value\"\n}\n", string(saved))
+	info, err := os.Stat(filepath.Join(dir, "new.json"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(defaultFileMode), info.Mode().Perm())
+}
+
+func TestDetectFormat(t *testing.T) {
+	testCases := []struct {
+		desc    string
+		data    string
+		prefix  string
+		indent  string
+		newline bool
+	}{
+		{desc: "compact", data: `{"a":1}`, indent: ""},
+		{desc: "tabs", data: "{\n\t\"a\": 1\n}\n", indent: "\t", newline: true},
+		{desc: "two spaces", data: "{\n  \"a\": {\n    \"b\": 1\n  }\n}", indent: "  "},
+		{desc: "empty object", data: "{\n}\n", indent: "\t", newline: true},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			prefix, indent, newline := detectFormat([]byte(tC.data))
+			assert.Equal(t, tC.prefix, prefix)
+			assert.Equal(t, tC.indent, indent)
+			assert.Equal(t, tC.newline, newline)
+		})
+	}
+}
+
+func TestUpdate_Concurrent(t *testing.T) {
+	dir := t.TempDir()
+	const updates = 20
+
+	var waiter sync.WaitGroup
+	for i := 0; i < updates; i++ {
+		waiter.Add(1)
+		go func() {
+			defer waiter.Done()
+			err := Update(dir, "", func(config Configuration) error {
+				count, err := config.GetInt("counter", 0)
+				if err != nil {
+					return err
+				}
+				config.Set("counter", count+1)
+				return nil
+			})
+			assert.NoError(t, err)
+		}()
+	}
+	waiter.Wait()
+
+	config, err := Load(dir, "")
+	require.NoError(t, err)
+	count, err := config.GetInt("counter", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, updates, count)
+}
+
+func TestUpdate_Error(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, Save(dir, "", Configuration{"key": "value"}))
+
+	err := Update(dir, "", func(config Configuration) error {
+		config.Set("key", "changed")
+		return assert.AnError
+	})
+
+	assert.ErrorIs(t, err, assert.AnError)
+	config, err := Load(dir, "")
+	require.NoError(t, err)
+	assert.Equal(t, "value", config.Get("key", nil))
+}
@@ -0,0 +1,190 @@
+package cfg
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ftl/hamradio"
+	"github.com/ftl/hamradio/callsign"
+	"github.com/ftl/hamradio/locator"
+)
+
+// ValueType describes the expected type of a configuration value.
+type ValueType int
+
+// The value types that are supported by a Schema.
+const (
+	StringValue ValueType = iota
+	IntValue
+	FloatValue
+	BoolValue
+	DurationValue
+	LocatorValue
+	CallsignValue
+	FrequencyValue
+)
+
+func (t ValueType) String() string {
+	switch t {
+	case StringValue:
+		return "string"
+	case IntValue:
+		return "integer"
+	case FloatValue:
+		return "number"
+	case BoolValue:
+		return "boolean"
+	case DurationValue:
+		return "duration"
+	case LocatorValue:
+		return "locator"
+	case CallsignValue:
+		return "callsign"
+	case FrequencyValue:
+		return "frequency"
+	default:
+		return fmt.Sprintf("ValueType(%d)", int(t))
+	}
+}
+
+// get retrieves the value at the given key converted into the Go type that corresponds to this value type.
+func (t ValueType) get(config Configuration, key Key) (interface{}, error) {
+	switch t {
+	case StringValue:
+		return config.GetString(key, "")
+	case IntValue:
+		return config.GetInt(key, 0)
+	case FloatValue:
+		return config.GetFloat(key, 0)
+	case BoolValue:
+		return config.GetBool(key, false)
+	case DurationValue:
+		return config.GetDuration(key, 0)
+	case LocatorValue:
+		return config.GetLocator(key, locator.Locator{})
+	case CallsignValue:
+		return config.GetCallsign(key, callsign.NoCallsign)
+	case FrequencyValue:
+		return config.GetFrequency(key, 0)
+	default:
+		return nil, fmt.Errorf("%s: unknown value type %v", key, t)
+	}
+}
+
+// Setting declares a known configuration key.
+type Setting struct {
+	Key         Key
+	Type        ValueType
+	Description string
+	// Default is used by Schema.Apply if the key is missing in the configuration. It must be given in the
+	// JSON representation of the value (e.g. "10s" for a duration, "JN59" for a locator).
+	Default interface{}
+	// Required indicates that the key must be present in the configuration.
+	Required bool
+	// Validate is called with the converted value, i.e. a string, int, float64, bool, time.Duration,
+	// locator.Locator, callsign.Callsign or hamradio.Frequency.
+	Validate func(value interface{}) error
+}
+
+// Schema declares the known keys of a configuration.
+type Schema []Setting
+
+// Errors contains all problems that were found in a configuration.
+type Errors []error
+
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Setting returns the declaration of the given key.
+func (s Schema) Setting(key Key) (Setting, bool) {
+	for _, setting := range s {
+		if setting.Key == key {
+			return setting, true
+		}
+	}
+	return Setting{}, false
+}
+
+// Validate checks the given configuration against this schema. All problems are reported at once,
+// the returned error is of type Errors. If the configuration is valid, nil is returned.
+func (s Schema) Validate(config Configuration) error {
+	var result Errors
+	for _, setting := range s {
+		if config.Get(setting.Key, nil) == nil {
+			if setting.Required {
+				result = append(result, fmt.Errorf("%s: missing required %v", setting.Key, setting.Type))
+			}
+			continue
+		}
+		value, err := setting.Type.get(config, setting.Key)
+		if err != nil {
+			result = append(result, err)
+			continue
+		}
+		if setting.Validate == nil {
+			continue
+		}
+		err = setting.Validate(value)
+		if err != nil {
+			result = append(result, fmt.Errorf("%s: %w", setting.Key, err))
+		}
+	}
+	if len(result) == 0 {
+		return nil
+	}
+	return result
+}
+
+// Apply returns a copy of the given configuration that contains the default values for all missing keys.
+// The resulting configuration is validated against this schema.
+func (s Schema) Apply(config Configuration) (Configuration, error) {
+	result := config.copy()
+	for _, setting := range s {
+		if setting.Default == nil || result.Get(setting.Key, nil) != nil {
+			continue
+		}
+		result.Set(setting.Key, setting.Default)
+	}
+	return result, s.Validate(result)
+}
+
+// Range returns a validation function that checks if a numeric value is within the given bounds.
+func Range(min, max float64) func(interface{}) error {
+	return func(value interface{}) error {
+		var v float64
+		switch value := value.(type) {
+		case int:
+			v = float64(value)
+		case float64:
+			v = value
+		case time.Duration:
+			v = float64(value)
+		case hamradio.Frequency:
+			v = float64(value)
+		default:
+			return fmt.Errorf("%v is not numeric", value)
+		}
+		if v < min || v > max {
+			return fmt.Errorf("%v is not within [%v, %v]", value, min, max)
+		}
+		return nil
+	}
+}
+
+// OneOf returns a validation function that checks if a string value is one of the given values.
+func OneOf(values ...string) func(interface{}) error {
+	return func(value interface{}) error {
+		for _, v := range values {
+			if value == v {
+				return nil
+			}
+		}
+		return fmt.Errorf("%v is not one of %s", value, strings.Join(values, ", "))
+	}
+}
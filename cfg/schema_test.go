@@ -0,0 +1,67 @@
+package cfg
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testSchema = Schema{
+	{Key: MyCall, Type: CallsignValue, Required: true},
+	{Key: MyLocator, Type: LocatorValue},
+	{Key: "cw.speed", Type: IntValue, Default: 20.0, Validate: Range(5, 60)},
+	{Key: "cw.mode", Type: StringValue, Default: "iambic", Validate: OneOf("iambic", "straight")},
+	{Key: "callbook.timeout", Type: DurationValue, Default: "10s"},
+}
+
+func TestSchema_Validate(t *testing.T) {
+	config, err := Read(strings.NewReader(`{
+		"my": {"locator": "not a locator"},
+		"cw": {"speed": 100, "mode": "bug"}
+	}`))
+	require.NoError(t, err)
+
+	err = testSchema.Validate(config)
+
+	var errs Errors
+	require.True(t, errors.As(err, &errs))
+	assert.Len(t, errs, 4)
+	assert.Contains(t, err.Error(), "my.call: missing required callsign")
+	assert.Contains(t, err.Error(), "my.locator")
+	assert.Contains(t, err.Error(), "cw.speed")
+	assert.Contains(t, err.Error(), "cw.mode")
+}
+
+func TestSchema_Apply(t *testing.T) {
+	config, err := Read(strings.NewReader(`{
+		"my": {"call": "DL1ABC"},
+		"cw": {"speed": 25}
+	}`))
+	require.NoError(t, err)
+
+	result, err := testSchema.Apply(config)
+
+	require.NoError(t, err)
+	speed, _ := result.GetInt("cw.speed", 0)
+	assert.Equal(t, 25, speed)
+	mode, _ := result.GetString("cw.mode", "")
+	assert.Equal(t, "iambic", mode)
+	timeout, _ := result.GetDuration("callbook.timeout", 0)
+	assert.Equal(t, 10*time.Second, timeout)
+
+	assert.Nil(t, config.Get("cw.mode", nil), "the original configuration must not be modified")
+	assert.Nil(t, config.Get("callbook", nil), "the original configuration must not be modified")
+}
+
+func TestSchema_Setting(t *testing.T) {
+	setting, ok := testSchema.Setting("cw.speed")
+	assert.True(t, ok)
+	assert.Equal(t, IntValue, setting.Type)
+
+	_, ok = testSchema.Setting("unknown")
+	assert.False(t, ok)
+}
@@ -0,0 +1,219 @@
+package cfg
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Prefixes of secret references. A configuration value that starts with one of these prefixes is not used
+// literally, but resolved by ResolveSecret.
+const (
+	// EnvSecretPrefix references an environment variable, e.g. "env:QRZ_PASSWORD".
+	EnvSecretPrefix = "env:"
+	// FileSecretPrefix references a file that contains the secret, e.g. "file:~/.config/hamradio/qrz.secret".
+	// The file must not be accessible by group or others. If the reference ends with #key, the file must contain
+	// a JSON object and the secret is the value at the given key path, e.g. "file:~/.secrets.json#qrz.password".
+	FileSecretPrefix = "file:"
+	// CmdSecretPrefix references a command that prints the secret to stdout, e.g. "cmd:pass show qrz.com".
+	// The command is executed without a shell, the arguments are separated by whitespace. Only the first line of
+	// the output is used.
+	CmdSecretPrefix = "cmd:"
+)
+
+// SecretCommandTimeout is the maximum time a command that is referenced as secret may run.
+var SecretCommandTimeout = 30 * time.Second
+
+// secretKeys are the names of keys that usually contain credentials.
+var secretKeys = []string{"password", "secret", "token", "apikey", "api_key"}
+
+// IsSecretReference returns true if the given value references a secret.
+func IsSecretReference(value string) bool {
+	return strings.HasPrefix(value, EnvSecretPrefix) || strings.HasPrefix(value, FileSecretPrefix) || strings.HasPrefix(value, CmdSecretPrefix)
+}
+
+// ResolveSecret resolves the given secret reference. Values that are no secret references are returned as they are.
+func ResolveSecret(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, EnvSecretPrefix):
+		name := strings.TrimPrefix(value, EnvSecretPrefix)
+		result, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		return result, nil
+	case strings.HasPrefix(value, FileSecretPrefix):
+		return readSecretFile(strings.TrimPrefix(value, FileSecretPrefix))
+	case strings.HasPrefix(value, CmdSecretPrefix):
+		return runSecretCommand(strings.TrimPrefix(value, CmdSecretPrefix))
+	default:
+		return value, nil
+	}
+}
+
+func readSecretFile(reference string) (string, error) {
+	filename, key, _ := strings.Cut(reference, "#")
+	filename, err := resolvePath(filename)
+	if err != nil {
+		return "", err
+	}
+
+	info, err := os.Stat(filename)
+	if err != nil {
+		return "", err
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0077 != 0 {
+		return "", fmt.Errorf("%s is accessible by other users (mode %v), use chmod 600 to restrict the access", filename, info.Mode().Perm())
+	}
+
+	if key == "" {
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+
+	secrets, err := Load(filepath.Dir(filename), filepath.Base(filename))
+	if err != nil {
+		return "", err
+	}
+	result, err := secrets.GetString(Key(key), "")
+	if err != nil {
+		return "", err
+	}
+	if result == "" {
+		return "", fmt.Errorf("%s does not contain %s", filename, key)
+	}
+	return result, nil
+}
+
+func runSecretCommand(command string) (string, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return "", fmt.Errorf("empty secret command")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), SecretCommandTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		message := strings.TrimSpace(stderr.String())
+		if message != "" {
+			return "", fmt.Errorf("%s: %w: %s", args[0], err, message)
+		}
+		return "", fmt.Errorf("%s: %w", args[0], err)
+	}
+
+	line, _, _ := strings.Cut(string(output), "\n")
+	return strings.TrimRight(line, "\r"), nil
+}
+
+// GetSecret retrieves the value at the given path as string and resolves it if it is a secret reference.
+// If the key path cannot be found, the empty string is returned.
+func (config Configuration) GetSecret(key Key) (string, error) {
+	value, err := config.GetString(key, "")
+	if err != nil {
+		return "", err
+	}
+	result, err := ResolveSecret(value)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", key, err)
+	}
+	return result, nil
+}
+
+// GetCredentials retrieves the username and password below the given path, both may be secret references.
+func (config Configuration) GetCredentials(key Key) (Credentials, error) {
+	var result Credentials
+	var err error
+	result.Username, err = config.GetSecret(key + ".username")
+	if err != nil {
+		return Credentials{}, err
+	}
+	result.Password, err = config.GetSecret(key + ".password")
+	if err != nil {
+		return Credentials{}, err
+	}
+	return result, nil
+}
+
+// Resolve resolves the username and password of these credentials if they are secret references.
+func (c Credentials) Resolve() (Credentials, error) {
+	var result Credentials
+	var err error
+	result.Username, err = ResolveSecret(c.Username)
+	if err != nil {
+		return Credentials{}, err
+	}
+	result.Password, err = ResolveSecret(c.Password)
+	if err != nil {
+		return Credentials{}, err
+	}
+	return result, nil
+}
+
+// PlainSecrets returns the sorted list of all keys in the configuration that contain credentials (e.g. passwords)
+// in plain text instead of secret references.
+func PlainSecrets(config Configuration) []Key {
+	result := make([]Key, 0)
+	for key, value := range flatten(config) {
+		s, ok := value.(string)
+		if !ok || s == "" || IsSecretReference(s) || !isSecretKey(key) {
+			continue
+		}
+		result = append(result, key)
+	}
+	sortKeys(result)
+	return result
+}
+
+func isSecretKey(key Key) bool {
+	elements := strings.Split(string(key), ".")
+	name := strings.ToLower(elements[len(elements)-1])
+	for _, secretKey := range secretKeys {
+		if name == secretKey {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckCredentials checks if the given configuration file is readable by other users while it contains
+// credentials in plain text. If the path is the empty string, the default configuration directory is used.
+// If the given filename is the empty string, the default filename is used.
+func CheckCredentials(path, filename string, config Configuration) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	absoluteFilename, err := absoluteFilename(path, filename)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(absoluteFilename)
+	if err != nil {
+		return nil
+	}
+	if info.Mode().Perm()&0044 == 0 {
+		return nil
+	}
+	plainSecrets := PlainSecrets(config)
+	if len(plainSecrets) == 0 {
+		return nil
+	}
+	keys := make([]string, len(plainSecrets))
+	for i, key := range plainSecrets {
+		keys[i] = string(key)
+	}
+	return fmt.Errorf("%s is readable by other users (mode %v) but contains credentials in plain text (%s), use chmod 600 or secret references (env:, file:, cmd:)",
+		absoluteFilename, info.Mode().Perm(), strings.Join(keys, ", "))
+}
@@ -0,0 +1,107 @@
+package cfg
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveSecret(t *testing.T) {
+	dir := t.TempDir()
+	plainFile := filepath.Join(dir, "plain.secret")
+	require.NoError(t, os.WriteFile(plainFile, []byte("file secret\n"), 0600))
+	jsonFile := filepath.Join(dir, "secrets.json")
+	require.NoError(t, os.WriteFile(jsonFile, []byte(`{"qrz": {"password": "json secret"}}`), 0600))
+	t.Setenv("TEST_SECRET", "env secret")
+
+	testCases := []struct {
+		desc     string
+		value    string
+		expected string
+		invalid  bool
+	}{
+		{desc: "plain", value: "plain secret", expected: "plain secret"},
+		{desc: "env", value: "env:TEST_SECRET", expected: "env secret"},
+		{desc: "missing env", value: "env:TEST_MISSING_SECRET", invalid: true},
+		{desc: "file", value: "file:" + plainFile, expected: "file secret"},
+		{desc: "json file", value: "file:" + jsonFile + "#qrz.password", expected: "json secret"},
+		{desc: "missing json key", value: "file:" + jsonFile + "#hamqth.password", invalid: true},
+		{desc: "missing file", value: "file:" + filepath.Join(dir, "missing"), invalid: true},
+		{desc: "command", value: "cmd:echo command secret", expected: "command secret"},
+		{desc: "failing command", value: "cmd:false", invalid: true},
+		{desc: "empty command", value: "cmd: ", invalid: true},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			if runtime.GOOS == "windows" && tC.desc == "command" {
+				t.Skip("echo is not available as command on windows")
+			}
+			actual, err := ResolveSecret(tC.value)
+			if tC.invalid {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tC.expected, actual)
+		})
+	}
+}
+
+func TestResolveSecret_InsecureFile(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file permissions are not checked on windows")
+	}
+	filename := filepath.Join(t.TempDir(), "insecure.secret")
+	require.NoError(t, os.WriteFile(filename, []byte("secret"), 0644))
+	require.NoError(t, os.Chmod(filename, 0644))
+
+	_, err := ResolveSecret("file:" + filename)
+
+	assert.Error(t, err)
+}
+
+func TestConfiguration_GetCredentials(t *testing.T) {
+	t.Setenv("TEST_QRZ_PASSWORD", "secret")
+	config := Configuration{
+		"callbook": map[string]interface{}{
+			"qrz": map[string]interface{}{"username": "user", "password": "env:TEST_QRZ_PASSWORD"},
+		},
+	}
+
+	credentials, err := config.GetCredentials("callbook.qrz")
+	require.NoError(t, err)
+	assert.Equal(t, Credentials{Username: "user", Password: "secret"}, credentials)
+
+	credentials, err = Credentials{Username: "user", Password: "env:TEST_QRZ_PASSWORD"}.Resolve()
+	require.NoError(t, err)
+	assert.Equal(t, "secret", credentials.Password)
+}
+
+func TestCheckCredentials(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file permissions are not checked on windows")
+	}
+	dir := t.TempDir()
+	filename := filepath.Join(dir, DefaultFilename)
+	require.NoError(t, os.WriteFile(filename, []byte("{}"), 0600))
+	plain := Configuration{
+		"callbook": map[string]interface{}{
+			"qrz":    map[string]interface{}{"username": "user", "password": "plain"},
+			"hamqth": map[string]interface{}{"username": "user", "password": "env:HAMQTH_PASSWORD"},
+		},
+	}
+	assert.Equal(t, []Key{"callbook.qrz.password"}, PlainSecrets(plain))
+
+	assert.NoError(t, CheckCredentials(dir, "", plain), "the file is only readable by the owner")
+
+	require.NoError(t, os.Chmod(filename, 0644))
+	err := CheckCredentials(dir, "", plain)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "callbook.qrz.password")
+
+	assert.NoError(t, CheckCredentials(dir, "", Configuration{"my": map[string]interface{}{"call": "DL1ABC"}}))
+}
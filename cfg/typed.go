@@ -0,0 +1,222 @@
+package cfg
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ftl/hamradio"
+	"github.com/ftl/hamradio/callsign"
+	"github.com/ftl/hamradio/locator"
+)
+
+// TypeError is returned by the typed getters if the value at a key cannot be converted into the requested type.
+type TypeError struct {
+	Key   Key
+	Value interface{}
+	Type  string
+	Err   error
+}
+
+func (e *TypeError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v is not a valid %s: %v", e.Key, e.Value, e.Type, e.Err)
+	}
+	return fmt.Sprintf("%s: %v is not a valid %s", e.Key, e.Value, e.Type)
+}
+
+func (e *TypeError) Unwrap() error {
+	return e.Err
+}
+
+// GetString retrieves the value at the given path as string. If the key path cannot be found,
+// the given default value is returned. If the value is not a string, an error is returned.
+func (config Configuration) GetString(key Key, defaultValue string) (string, error) {
+	rawValue := config.Get(key, nil)
+	if rawValue == nil {
+		return defaultValue, nil
+	}
+	value, ok := rawValue.(string)
+	if !ok {
+		return defaultValue, &TypeError{Key: key, Value: rawValue, Type: "string"}
+	}
+	return value, nil
+}
+
+// GetInt retrieves the value at the given path as int. If the key path cannot be found,
+// the given default value is returned. Strings are parsed as decimal numbers.
+func (config Configuration) GetInt(key Key, defaultValue int) (int, error) {
+	rawValue := config.Get(key, nil)
+	switch value := rawValue.(type) {
+	case nil:
+		return defaultValue, nil
+	case float64:
+		if value != math.Trunc(value) {
+			return defaultValue, &TypeError{Key: key, Value: rawValue, Type: "integer"}
+		}
+		return int(value), nil
+	case int:
+		return value, nil
+	case string:
+		result, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return defaultValue, &TypeError{Key: key, Value: rawValue, Type: "integer", Err: err}
+		}
+		return result, nil
+	default:
+		return defaultValue, &TypeError{Key: key, Value: rawValue, Type: "integer"}
+	}
+}
+
+// GetFloat retrieves the value at the given path as float64. If the key path cannot be found,
+// the given default value is returned. Strings are parsed as decimal numbers.
+func (config Configuration) GetFloat(key Key, defaultValue float64) (float64, error) {
+	rawValue := config.Get(key, nil)
+	switch value := rawValue.(type) {
+	case nil:
+		return defaultValue, nil
+	case float64:
+		return value, nil
+	case int:
+		return float64(value), nil
+	case string:
+		result, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return defaultValue, &TypeError{Key: key, Value: rawValue, Type: "number", Err: err}
+		}
+		return result, nil
+	default:
+		return defaultValue, &TypeError{Key: key, Value: rawValue, Type: "number"}
+	}
+}
+
+// GetBool retrieves the value at the given path as bool. If the key path cannot be found,
+// the given default value is returned. Strings are parsed with strconv.ParseBool.
+func (config Configuration) GetBool(key Key, defaultValue bool) (bool, error) {
+	rawValue := config.Get(key, nil)
+	switch value := rawValue.(type) {
+	case nil:
+		return defaultValue, nil
+	case bool:
+		return value, nil
+	case string:
+		result, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return defaultValue, &TypeError{Key: key, Value: rawValue, Type: "boolean", Err: err}
+		}
+		return result, nil
+	default:
+		return defaultValue, &TypeError{Key: key, Value: rawValue, Type: "boolean"}
+	}
+}
+
+// GetDuration retrieves the value at the given path as time.Duration. If the key path cannot be found,
+// the given default value is returned. Strings are parsed with time.ParseDuration (e.g. "1m30s"),
+// numbers are interpreted as seconds.
+func (config Configuration) GetDuration(key Key, defaultValue time.Duration) (time.Duration, error) {
+	rawValue := config.Get(key, nil)
+	switch value := rawValue.(type) {
+	case nil:
+		return defaultValue, nil
+	case float64:
+		return time.Duration(value * float64(time.Second)), nil
+	case int:
+		return time.Duration(value) * time.Second, nil
+	case string:
+		result, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil {
+			return defaultValue, &TypeError{Key: key, Value: rawValue, Type: "duration", Err: err}
+		}
+		return result, nil
+	default:
+		return defaultValue, &TypeError{Key: key, Value: rawValue, Type: "duration"}
+	}
+}
+
+// GetLocator retrieves the value at the given path as maidenhead locator. If the key path cannot be found,
+// the given default value is returned.
+func (config Configuration) GetLocator(key Key, defaultValue locator.Locator) (locator.Locator, error) {
+	rawValue := config.Get(key, nil)
+	switch value := rawValue.(type) {
+	case nil:
+		return defaultValue, nil
+	case string:
+		result, err := locator.Parse(value)
+		if err != nil {
+			return defaultValue, &TypeError{Key: key, Value: rawValue, Type: "locator", Err: err}
+		}
+		return result, nil
+	default:
+		return defaultValue, &TypeError{Key: key, Value: rawValue, Type: "locator"}
+	}
+}
+
+// GetCallsign retrieves the value at the given path as callsign. If the key path cannot be found,
+// the given default value is returned.
+func (config Configuration) GetCallsign(key Key, defaultValue callsign.Callsign) (callsign.Callsign, error) {
+	rawValue := config.Get(key, nil)
+	switch value := rawValue.(type) {
+	case nil:
+		return defaultValue, nil
+	case string:
+		result, err := callsign.Parse(value)
+		if err != nil {
+			return defaultValue, &TypeError{Key: key, Value: rawValue, Type: "callsign", Err: err}
+		}
+		return result, nil
+	default:
+		return defaultValue, &TypeError{Key: key, Value: rawValue, Type: "callsign"}
+	}
+}
+
+// GetFrequency retrieves the value at the given path as frequency. If the key path cannot be found,
+// the given default value is returned. Numbers are interpreted as Hz, strings may contain
+// one of the units Hz, kHz, MHz or GHz (e.g. "7.025MHz").
+func (config Configuration) GetFrequency(key Key, defaultValue hamradio.Frequency) (hamradio.Frequency, error) {
+	rawValue := config.Get(key, nil)
+	switch value := rawValue.(type) {
+	case nil:
+		return defaultValue, nil
+	case float64:
+		return hamradio.Frequency(value), nil
+	case int:
+		return hamradio.Frequency(value), nil
+	case string:
+		result, err := parseFrequency(value)
+		if err != nil {
+			return defaultValue, &TypeError{Key: key, Value: rawValue, Type: "frequency", Err: err}
+		}
+		return result, nil
+	default:
+		return defaultValue, &TypeError{Key: key, Value: rawValue, Type: "frequency"}
+	}
+}
+
+var frequencyUnits = []struct {
+	suffix string
+	factor float64
+}{
+	{"ghz", 1e9},
+	{"mhz", 1e6},
+	{"khz", 1e3},
+	{"hz", 1},
+}
+
+func parseFrequency(s string) (hamradio.Frequency, error) {
+	normalString := strings.ToLower(strings.TrimSpace(s))
+	factor := 1.0
+	for _, unit := range frequencyUnits {
+		if strings.HasSuffix(normalString, unit.suffix) {
+			normalString = strings.TrimSpace(strings.TrimSuffix(normalString, unit.suffix))
+			factor = unit.factor
+			break
+		}
+	}
+	value, err := strconv.ParseFloat(normalString, 64)
+	if err != nil {
+		return 0, err
+	}
+	return hamradio.Frequency(value * factor), nil
+}
@@ -0,0 +1,156 @@
+package cfg
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ftl/hamradio"
+	"github.com/ftl/hamradio/callsign"
+	"github.com/ftl/hamradio/locator"
+)
+
+const typedConfig = `{
+	"string": "value",
+	"int": 42,
+	"float": 1.5,
+	"intString": "17",
+	"bool": true,
+	"boolString": "false",
+	"duration": "1m30s",
+	"seconds": 2.5,
+	"locator": "JN59nk",
+	"callsign": "DL1ABC/P",
+	"frequency": 7025000,
+	"frequencyString": "14.074 MHz",
+	"strings": ["a", "b"],
+	"mixed": ["a", 1],
+	"object": {"key": "value"}
+}`
+
+func readTypedConfig(t *testing.T) Configuration {
+	config, err := Read(strings.NewReader(typedConfig))
+	require.NoError(t, err)
+	return config
+}
+
+func TestConfiguration_GetString(t *testing.T) {
+	config := readTypedConfig(t)
+
+	value, err := config.GetString("string", "default")
+	assert.NoError(t, err)
+	assert.Equal(t, "value", value)
+
+	value, err = config.GetString("missing", "default")
+	assert.NoError(t, err)
+	assert.Equal(t, "default", value)
+
+	value, err = config.GetString("int", "default")
+	var typeErr *TypeError
+	assert.ErrorAs(t, err, &typeErr)
+	assert.Equal(t, Key("int"), typeErr.Key)
+	assert.Equal(t, "default", value)
+}
+
+func TestConfiguration_GetNumbers(t *testing.T) {
+	config := readTypedConfig(t)
+
+	i, err := config.GetInt("int", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 42, i)
+
+	i, err = config.GetInt("intString", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 17, i)
+
+	i, err = config.GetInt("float", -1)
+	assert.Error(t, err)
+	assert.Equal(t, -1, i)
+
+	f, err := config.GetFloat("float", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 1.5, f)
+
+	f, err = config.GetFloat("int", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 42.0, f)
+
+	_, err = config.GetFloat("object", 0)
+	assert.Error(t, err)
+}
+
+func TestConfiguration_GetBool(t *testing.T) {
+	config := readTypedConfig(t)
+
+	b, err := config.GetBool("bool", false)
+	assert.NoError(t, err)
+	assert.True(t, b)
+
+	b, err = config.GetBool("boolString", true)
+	assert.NoError(t, err)
+	assert.False(t, b)
+
+	b, err = config.GetBool("string", true)
+	assert.Error(t, err)
+	assert.True(t, b)
+}
+
+func TestConfiguration_GetDuration(t *testing.T) {
+	config := readTypedConfig(t)
+
+	d, err := config.GetDuration("duration", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 90*time.Second, d)
+
+	d, err = config.GetDuration("seconds", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 2500*time.Millisecond, d)
+
+	_, err = config.GetDuration("string", 0)
+	assert.Error(t, err)
+}
+
+func TestConfiguration_GetHamradioTypes(t *testing.T) {
+	config := readTypedConfig(t)
+
+	loc, err := config.GetLocator("locator", locator.Locator{})
+	assert.NoError(t, err)
+	assert.Equal(t, "JN59nk", loc.String())
+
+	_, err = config.GetLocator("string", locator.Locator{})
+	assert.Error(t, err)
+
+	call, err := config.GetCallsign("callsign", callsign.NoCallsign)
+	assert.NoError(t, err)
+	assert.Equal(t, "DL1ABC", call.BaseCall)
+
+	_, err = config.GetCallsign("int", callsign.NoCallsign)
+	assert.Error(t, err)
+
+	frequency, err := config.GetFrequency("frequency", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, hamradio.Frequency(7025000), frequency)
+
+	frequency, err = config.GetFrequency("frequencyString", 0)
+	assert.NoError(t, err)
+	assert.InDelta(t, 14074000, float64(frequency), 0.001)
+
+	_, err = config.GetFrequency("string", 0)
+	assert.Error(t, err)
+}
+
+func TestConfiguration_GetStrings_WrongType(t *testing.T) {
+	config := readTypedConfig(t)
+
+	assert.Equal(t, []string{"a", "b"}, config.GetStrings("strings", nil))
+	assert.Equal(t, []string{"default"}, config.GetStrings("mixed", []string{"default"}))
+	assert.Equal(t, []string{"default"}, config.GetStrings("string", []string{"default"}))
+	assert.NotPanics(t, func() {
+		config.GetSlice("string", func(int, map[string]interface{}) {
+			t.Error("callback must not be called")
+		})
+	})
+}
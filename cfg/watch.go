@@ -0,0 +1,295 @@
+package cfg
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultPollInterval is the interval in which a Watcher checks the configuration file for changes if the
+// operating system cannot notify about changes.
+const DefaultPollInterval = 2 * time.Second
+
+// settleTime is the time the Watcher waits after a notification before it reloads the configuration,
+// so that several write operations of an editor are combined into one reload.
+const settleTime = 50 * time.Millisecond
+
+// ChangeHandler is called with the new configuration and the sorted list of keys that have changed.
+type ChangeHandler func(config Configuration, changed []Key)
+
+// ErrorHandler is called if the configuration file cannot be reloaded or the new content is invalid.
+type ErrorHandler func(err error)
+
+// Watcher reloads the configuration when the configuration file changes. The new content is validated against
+// the given schema before it is swapped in, invalid content is reported to the error handlers and ignored.
+// On Linux the Watcher uses inotify, on other platforms or if inotify is not available, it polls the file
+// in the given interval.
+type Watcher struct {
+	filename     string
+	schema       Schema
+	pollInterval time.Duration
+
+	lock          sync.RWMutex
+	config        Configuration
+	modTime       time.Time
+	size          int64
+	subscribers   []ChangeHandler
+	errorHandlers []ErrorHandler
+
+	closed    chan struct{}
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+type notifier interface {
+	Events() <-chan struct{}
+	Close() error
+}
+
+// NewWatcher loads the given file in the given directory and watches it for changes. If the path is the empty
+// string, the default configuration directory is used. If the given filename is the empty string, the default
+// filename is used. A missing file is treated as an empty configuration. If the poll interval is zero,
+// DefaultPollInterval is used.
+func NewWatcher(path, filename string, schema Schema, pollInterval time.Duration) (*Watcher, error) {
+	absoluteFilename, err := absoluteFilename(path, filename)
+	if err != nil {
+		return nil, err
+	}
+	return newWatcher(absoluteFilename, schema, pollInterval, true)
+}
+
+func newWatcher(filename string, schema Schema, pollInterval time.Duration, useNotifier bool) (*Watcher, error) {
+	if pollInterval <= 0 {
+		pollInterval = DefaultPollInterval
+	}
+	result := &Watcher{
+		filename:     filename,
+		schema:       schema,
+		pollInterval: pollInterval,
+		closed:       make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+
+	config, modTime, size, err := result.load()
+	if err != nil {
+		return nil, err
+	}
+	result.config = config
+	result.modTime = modTime
+	result.size = size
+
+	var n notifier
+	if useNotifier {
+		n, err = newNotifier(filename)
+		if err != nil {
+			n = nil
+		}
+	}
+	go result.run(n)
+
+	return result, nil
+}
+
+// Configuration returns a copy of the current configuration.
+func (w *Watcher) Configuration() Configuration {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+	return w.config.copy()
+}
+
+// Subscribe registers the given handler to be notified about changes of the configuration.
+func (w *Watcher) Subscribe(handler ChangeHandler) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.subscribers = append(w.subscribers, handler)
+}
+
+// OnError registers the given handler to be notified about errors while reloading the configuration.
+func (w *Watcher) OnError(handler ErrorHandler) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.errorHandlers = append(w.errorHandlers, handler)
+}
+
+// Close stops watching the configuration file.
+func (w *Watcher) Close() error {
+	w.closeOnce.Do(func() {
+		close(w.closed)
+	})
+	<-w.done
+	return nil
+}
+
+// Reload reloads the configuration file immediately. If the content is valid and has changed,
+// the subscribers are notified.
+func (w *Watcher) Reload() error {
+	config, modTime, size, err := w.load()
+
+	w.lock.Lock()
+	// remember the state of the file also if it is invalid, to report the problem only once
+	w.modTime = modTime
+	w.size = size
+	if err != nil {
+		w.lock.Unlock()
+		return err
+	}
+	changed := changedKeys(w.config, config)
+	if len(changed) == 0 {
+		w.lock.Unlock()
+		return nil
+	}
+	w.config = config
+	subscribers := make([]ChangeHandler, len(w.subscribers))
+	copy(subscribers, w.subscribers)
+	w.lock.Unlock()
+
+	for _, subscriber := range subscribers {
+		subscriber(config.copy(), changed)
+	}
+	return nil
+}
+
+func (w *Watcher) load() (Configuration, time.Time, int64, error) {
+	var modTime time.Time
+	var size int64
+	info, err := os.Stat(w.filename)
+	if err == nil {
+		modTime = info.ModTime()
+		size = info.Size()
+	}
+
+	config, err := Load(filepath.Dir(w.filename), filepath.Base(w.filename))
+	if errors.Is(err, fs.ErrNotExist) {
+		config = Configuration{}
+	} else if err != nil {
+		return nil, modTime, size, err
+	}
+
+	config, err = w.schema.Apply(config)
+	if err != nil {
+		return nil, modTime, size, err
+	}
+	return config, modTime, size, nil
+}
+
+func (w *Watcher) run(n notifier) {
+	defer close(w.done)
+
+	var events <-chan struct{}
+	var poll <-chan time.Time
+	if n != nil {
+		defer n.Close()
+		events = n.Events()
+	} else {
+		ticker := time.NewTicker(w.pollInterval)
+		defer ticker.Stop()
+		poll = ticker.C
+	}
+
+	for {
+		select {
+		case <-w.closed:
+			return
+		case _, ok := <-events:
+			if !ok {
+				events = nil
+				ticker := time.NewTicker(w.pollInterval)
+				defer ticker.Stop()
+				poll = ticker.C
+				continue
+			}
+			select {
+			case <-w.closed:
+				return
+			case <-time.After(settleTime):
+			}
+			w.reload()
+		case <-poll:
+			if w.modified() {
+				w.reload()
+			}
+		}
+	}
+}
+
+func (w *Watcher) modified() bool {
+	var modTime time.Time
+	var size int64
+	info, err := os.Stat(w.filename)
+	if err == nil {
+		modTime = info.ModTime()
+		size = info.Size()
+	}
+
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+	return !modTime.Equal(w.modTime) || size != w.size
+}
+
+func (w *Watcher) reload() {
+	err := w.Reload()
+	if err == nil {
+		return
+	}
+
+	w.lock.RLock()
+	errorHandlers := make([]ErrorHandler, len(w.errorHandlers))
+	copy(errorHandlers, w.errorHandlers)
+	w.lock.RUnlock()
+
+	for _, handler := range errorHandlers {
+		handler(err)
+	}
+}
+
+// changedKeys returns the sorted list of all key paths whose values differ between the two configurations.
+// Only leaf values are compared, arrays are compared as a whole.
+func changedKeys(oldConfig, newConfig Configuration) []Key {
+	oldValues := flatten(oldConfig)
+	newValues := flatten(newConfig)
+	result := make([]Key, 0)
+	for key, oldValue := range oldValues {
+		newValue, ok := newValues[key]
+		if !ok || !reflect.DeepEqual(oldValue, newValue) {
+			result = append(result, key)
+		}
+	}
+	for key := range newValues {
+		if _, ok := oldValues[key]; !ok {
+			result = append(result, key)
+		}
+	}
+	sortKeys(result)
+	return result
+}
+
+func sortKeys(keys []Key) {
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i] < keys[j]
+	})
+}
+
+func flatten(config Configuration) map[Key]interface{} {
+	result := make(map[Key]interface{})
+	var walk func(prefix Key, node map[string]interface{})
+	walk = func(prefix Key, node map[string]interface{}) {
+		for name, value := range node {
+			key := Key(name)
+			if prefix != "" {
+				key = prefix + "." + key
+			}
+			if child, ok := value.(map[string]interface{}); ok && len(child) > 0 {
+				walk(key, child)
+				continue
+			}
+			result[key] = value
+		}
+	}
+	walk("", config)
+	return result
+}
@@ -0,0 +1,77 @@
+package cfg
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+// inotifyNotifier watches the directory of the configuration file, because the file itself is replaced when
+// it is saved atomically.
+type inotifyNotifier struct {
+	file   *os.File
+	events chan struct{}
+}
+
+func newNotifier(filename string) (notifier, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+	mask := uint32(syscall.IN_CLOSE_WRITE | syscall.IN_MOVED_TO | syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MOVED_FROM)
+	_, err = syscall.InotifyAddWatch(fd, filepath.Dir(filename), mask)
+	if err != nil {
+		syscall.Close(fd)
+		return nil, os.NewSyscallError("inotify_add_watch", err)
+	}
+
+	result := &inotifyNotifier{
+		// the file descriptor is non-blocking, therefore os.File uses the runtime poller and Close interrupts Read
+		file:   os.NewFile(uintptr(fd), "inotify"),
+		events: make(chan struct{}, 1),
+	}
+	go result.run(filepath.Base(filename))
+	return result, nil
+}
+
+func (n *inotifyNotifier) Events() <-chan struct{} {
+	return n.events
+}
+
+func (n *inotifyNotifier) Close() error {
+	return n.file.Close()
+}
+
+func (n *inotifyNotifier) run(name string) {
+	defer close(n.events)
+
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		count, err := n.file.Read(buf)
+		if err != nil {
+			return
+		}
+
+		offset := 0
+		for offset+syscall.SizeofInotifyEvent <= count {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameStart := offset + syscall.SizeofInotifyEvent
+			nameEnd := nameStart + int(event.Len)
+			if nameEnd > count {
+				break
+			}
+			eventName := string(bytes.TrimRight(buf[nameStart:nameEnd], "\x00"))
+			offset = nameEnd
+
+			if eventName != name {
+				continue
+			}
+			select {
+			case n.events <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package cfg
+
+import "errors"
+
+func newNotifier(filename string) (notifier, error) {
+	return nil, errors.New("file change notifications are not supported on this platform")
+}
@@ -0,0 +1,114 @@
+package cfg
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type watchedChange struct {
+	config  Configuration
+	changed []Key
+}
+
+func testWatcher(t *testing.T, useNotifier bool) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, DefaultFilename)
+	require.NoError(t, os.WriteFile(filename, []byte(`{"my": {"call": "DL1ABC", "locator": "JN59"}, "keep": 1}`), 0600))
+	schema := Schema{
+		{Key: MyLocator, Type: LocatorValue},
+		{Key: "cw.speed", Type: IntValue, Default: 20.0},
+	}
+
+	pollInterval := 10 * time.Millisecond
+	if useNotifier {
+		pollInterval = time.Hour // make sure that the notifications are used on Linux
+	}
+	watcher, err := newWatcher(filename, schema, pollInterval, useNotifier)
+	require.NoError(t, err)
+	defer watcher.Close()
+	assert.Equal(t, 20.0, watcher.Configuration().Get("cw.speed", nil), "defaults are applied")
+
+	changes := make(chan watchedChange, 10)
+	errs := make(chan error, 10)
+	watcher.Subscribe(func(config Configuration, changed []Key) {
+		changes <- watchedChange{config, changed}
+	})
+	watcher.OnError(func(err error) {
+		errs <- err
+	})
+
+	time.Sleep(20 * time.Millisecond) // make sure the modification time changes
+	require.NoError(t, Update(dir, "", func(config Configuration) error {
+		config.Set(MyCall, "DL2ABC")
+		config.Set("new.key", true)
+		return nil
+	}))
+
+	select {
+	case change := <-changes:
+		assert.Equal(t, []Key{MyCall, "new.key"}, change.changed)
+		assert.Equal(t, "DL2ABC", change.config.Get(MyCall, nil))
+	case <-time.After(2 * time.Second):
+		t.Fatal("no change notification")
+	}
+	assert.Equal(t, "DL2ABC", watcher.Configuration().Get(MyCall, nil))
+
+	time.Sleep(20 * time.Millisecond)
+	require.NoError(t, Update(dir, "", func(config Configuration) error {
+		config.Set(MyLocator, "invalid locator")
+		return nil
+	}))
+
+	select {
+	case err := <-errs:
+		assert.Error(t, err)
+	case change := <-changes:
+		t.Fatalf("invalid configuration was swapped in: %v", change.changed)
+	case <-time.After(2 * time.Second):
+		t.Fatal("no error notification")
+	}
+	assert.Equal(t, "JN59", watcher.Configuration().Get(MyLocator, nil), "the invalid configuration must be ignored")
+}
+
+func TestWatcher_Notify(t *testing.T) {
+	n, err := newNotifier(filepath.Join(t.TempDir(), DefaultFilename))
+	if err != nil {
+		t.Skip(err)
+	}
+	n.Close()
+	testWatcher(t, true)
+}
+
+func TestWatcher_Poll(t *testing.T) {
+	testWatcher(t, false)
+}
+
+func TestWatcher_Close(t *testing.T) {
+	watcher, err := NewWatcher(t.TempDir(), "", nil, 0)
+	require.NoError(t, err)
+	assert.Equal(t, Configuration{}, watcher.Configuration())
+
+	assert.NoError(t, watcher.Close())
+	assert.NoError(t, watcher.Close())
+}
+
+func TestChangedKeys(t *testing.T) {
+	oldConfig := Configuration{
+		"a": map[string]interface{}{"b": 1.0, "c": "x"},
+		"d": []interface{}{"1", "2"},
+		"e": "removed",
+	}
+	newConfig := Configuration{
+		"a": map[string]interface{}{"b": 2.0, "c": "x"},
+		"d": []interface{}{"1", "3"},
+		"f": map[string]interface{}{"g": "added"},
+	}
+
+	assert.Equal(t, []Key{"a.b", "d", "e", "f.g"}, changedKeys(oldConfig, newConfig))
+	assert.Empty(t, changedKeys(oldConfig, oldConfig.copy()))
+}
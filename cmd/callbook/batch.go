@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ftl/hamradio/callbook"
+)
+
+// readCallsigns reads whitespace or comma separated callsigns from the given reader.
+// Empty lines and lines starting with # are ignored.
+func readCallsigns(in io.Reader) ([]string, error) {
+	result := make([]string, 0)
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.FieldsFunc(line, func(r rune) bool {
+			return r == ',' || r == ';' || r == ' ' || r == '\t'
+		})
+		result = append(result, fields...)
+	}
+	return result, scanner.Err()
+}
+
+func batch(filename string, format string, concurrency int, providers []callbook.Provider) error {
+	var in io.Reader
+	if filename == "-" {
+		in = os.Stdin
+	} else {
+		file, err := os.Open(filename)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		in = file
+	}
+
+	callsigns, err := readCallsigns(in)
+	if err != nil {
+		return err
+	}
+
+	aggregator := callbook.NewAggregator(providers...)
+	results := callbook.LookupBatch(context.Background(), aggregator, callsigns, concurrency)
+
+	switch strings.ToLower(format) {
+	case "csv":
+		return writeCSV(os.Stdout, results)
+	case "json":
+		return writeJSON(os.Stdout, results)
+	default:
+		return fmt.Errorf("unknown output format %q, use csv or json", format)
+	}
+}
+
+var csvHeader = []string{"call", "name", "address", "qth", "country", "locator", "cq", "itu", "dxcc", "email", "qsl_via", "lotw", "eqsl", "error"}
+
+func writeCSV(out io.Writer, results []callbook.BatchResult) error {
+	w := csv.NewWriter(out)
+	err := w.Write(csvHeader)
+	if err != nil {
+		return err
+	}
+	for _, result := range results {
+		info := result.Info
+		var locator, errorMessage string
+		if !info.Locator.IsZero() {
+			locator = info.Locator.String()
+		}
+		if result.Err != nil {
+			errorMessage = result.Err.Error()
+		}
+		err = w.Write([]string{
+			result.Callsign,
+			info.Name,
+			info.Address,
+			info.QTH,
+			info.Country,
+			locator,
+			strconv.Itoa(int(info.CQZone)),
+			strconv.Itoa(int(info.ITUZone)),
+			strconv.Itoa(info.DXCCEntity),
+			info.Email,
+			info.QSLManager,
+			strconv.FormatBool(info.LoTW),
+			strconv.FormatBool(info.EQSL),
+			errorMessage,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+type jsonResult struct {
+	Callsign   string `json:"call"`
+	Name       string `json:"name,omitempty"`
+	Address    string `json:"address,omitempty"`
+	QTH        string `json:"qth,omitempty"`
+	Country    string `json:"country,omitempty"`
+	Locator    string `json:"locator,omitempty"`
+	CQZone     int    `json:"cq,omitempty"`
+	ITUZone    int    `json:"itu,omitempty"`
+	DXCCEntity int    `json:"dxcc,omitempty"`
+	Email      string `json:"email,omitempty"`
+	QSLManager string `json:"qsl_via,omitempty"`
+	LoTW       bool   `json:"lotw"`
+	EQSL       bool   `json:"eqsl"`
+	Error      string `json:"error,omitempty"`
+}
+
+func writeJSON(out io.Writer, results []callbook.BatchResult) error {
+	values := make([]jsonResult, len(results))
+	for i, result := range results {
+		info := result.Info
+		values[i] = jsonResult{
+			Callsign:   result.Callsign,
+			Name:       info.Name,
+			Address:    info.Address,
+			QTH:        info.QTH,
+			Country:    info.Country,
+			CQZone:     int(info.CQZone),
+			ITUZone:    int(info.ITUZone),
+			DXCCEntity: info.DXCCEntity,
+			Email:      info.Email,
+			QSLManager: info.QSLManager,
+			LoTW:       info.LoTW,
+			EQSL:       info.EQSL,
+		}
+		if !info.Locator.IsZero() {
+			values[i].Locator = info.Locator.String()
+		}
+		if result.Err != nil {
+			values[i].Error = result.Err.Error()
+		}
+	}
+
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "\t")
+	return encoder.Encode(values)
+}
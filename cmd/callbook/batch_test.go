@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ftl/hamradio/callbook"
+	"github.com/ftl/hamradio/callsign"
+	"github.com/ftl/hamradio/locator"
+)
+
+func TestReadCallsigns(t *testing.T) {
+	input := `# calls from the contest
+dl1abc
+DL2ABC, DL3ABC;DL4ABC
+	ea8/dl1abc	dl5abc
+
+`
+	callsigns, err := readCallsigns(strings.NewReader(input))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dl1abc", "DL2ABC", "DL3ABC", "DL4ABC", "ea8/dl1abc", "dl5abc"}, callsigns)
+}
+
+func TestWriteCSV(t *testing.T) {
+	results := []callbook.BatchResult{
+		{
+			Callsign: "dl1abc",
+			Info: callbook.Info{
+				Callsign: callsign.MustParse("DL1ABC"),
+				Name:     "Fred, the operator",
+				Locator:  locator.MustParse("JN59nk"),
+				CQZone:   14,
+				LoTW:     true,
+			},
+		},
+		{Callsign: "dl2abc", Err: errors.New("not found")},
+	}
+	var buffer bytes.Buffer
+
+	err := writeCSV(&buffer, results)
+	require.NoError(t, err)
+
+	assert.Equal(t, `call,name,address,qth,country,locator,cq,itu,dxcc,email,qsl_via,lotw,eqsl,error
+dl1abc,"Fred, the operator",,,,JN59nk,14,0,0,,,true,false,
+dl2abc,,,,,,0,0,0,,,false,false,not found
+`, buffer.String())
+}
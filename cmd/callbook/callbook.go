@@ -1,11 +1,26 @@
 /*
-callbook retrieves information about a given callsign from hamqth.com and qrz.com and prints this information.
+callbook retrieves information about a given callsign from hamqth.com, qrz.com, hamcall.net, callook.info
+and a local copy of the FCC ULS database and prints this information.
 It can also calculate the distance and azimuth from an optionally given maidenhead locator
 to the retrieved location of the callsign.
 
 USAGE
 
-	callbook <callsign> [locator]
+	callbook [-m] <callsign> [locator]
+	callbook --batch <file> [--format csv|json] [--concurrency n]
+	callbook --import-uls <directory>
+
+	-p, --profile   use the station profile with the given name instead of the active one
+	-s, --set       override a configuration value, e.g. --set my.locator=JN59 (may be given several times)
+
+	-m, --merge     query all callbooks concurrently and merge their results into one dataset
+	-b, --batch     look up all callsigns from the given file (- for stdin) and write the merged results
+	                to stdout, the callsigns are separated by whitespace, commas or newlines
+	-f, --format    the output format of the batch mode: csv (default) or json
+	-c, --concurrency
+	                the number of concurrent lookups in batch mode (default 4)
+	--import-uls    import the EN.dat, HD.dat and AM.dat files of the FCC ULS database dump
+	                (https://www.fcc.gov/uls/transactions/daily-weekly) from the given directory
 
 EXAMPLE
 
@@ -39,11 +54,21 @@ EXAMPLE
 	Distance: 1309.1km
 	Azimuth: 280.9°
 
+	> callbook -m aa7bq
+
+	Merged
+	======
+	Callsign AA7BQ
+	Name: Fred (HamQTH.com)
+	...
+	Locator: DM43bq (QRZ.com)
+
 CONFIGURATION
 
 	callbook expects the hamradio configuration file (~/.config/hamradio/conf.json) to contain
-	the credentials for hamqth.com and qrz.com. If it can't find the credentials for one
-	of these sites, it will not try to query the respective site.
+	the credentials for hamqth.com, qrz.com and hamcall.net. If it can't find the credentials for one
+	of these sites, it will not try to query the respective site. callook.info and the local ULS
+	database do not need credentials, they only need to be enabled.
 
 	The expected JSON structure for the credentials is as follows:
 
@@ -57,44 +82,156 @@ CONFIGURATION
 				"username": "your qrz.com username",
 				"password": "your qrz.com password"
 			},
+			"hamcall": {
+				"username": "your hamcall.net username",
+				"password": "your hamcall.net password"
+			},
+			"callook": true,
+			"uls": true
+		}
+	}
+
+	If no locator is given, callbook uses the locator of the active station profile (see
+	cfg.Profile) or my.locator. A station profile may also contain its own callbook credentials,
+	which take precedence over the credentials above:
+
+	{
+		"station": {
+			"active": "home",
+			"profiles": {
+				"home": {
+					"call": "DL1ABC",
+					"locator": "JN59nk",
+					"callbook": {
+						"qrz": {
+							"username": "your qrz.com username",
+							"password": "your qrz.com password"
+						}
+					}
+				}
+			}
 		}
 	}
+
+	Instead of storing the passwords in plain text, you can reference them:
+
+		"password": "env:QRZ_PASSWORD"
+			reads the password from the environment variable QRZ_PASSWORD
+		"password": "file:~/.config/hamradio/qrz.secret"
+			reads the password from the given file, which must only be accessible by you (chmod 600)
+		"password": "file:~/.config/hamradio/secrets.json#qrz.password"
+			reads the password from the given key path in the given JSON file
+		"password": "cmd:pass show qrz.com"
+		"password": "cmd:secret-tool lookup service qrz.com"
+			uses the first line of the output of the given command
+
+	callbook warns if conf.json is readable by other users and contains passwords in plain text.
+
+	Every configuration value can also be set through an environment variable: the key path is
+	converted to upper case, the dots are replaced by underscores and the prefix HAMRADIO_ is added
+	(e.g. HAMRADIO_CALLBOOK_QRZ_USERNAME for callbook.qrz.username). Environment variables take
+	precedence over the configuration file, values given with --set take precedence over both.
 */
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
-	"path/filepath"
 	"strings"
 
+	flags "github.com/jessevdk/go-flags"
+
 	"github.com/ftl/hamradio/callbook"
 	"github.com/ftl/hamradio/cfg"
 	"github.com/ftl/hamradio/latlon"
 	"github.com/ftl/hamradio/locator"
 )
 
+var options struct {
+	Merge       bool     `short:"m" long:"merge" description:"query all callbooks concurrently and merge their results"`
+	Batch       string   `short:"b" long:"batch" value-name:"file" description:"look up all callsigns from the given file (- for stdin)"`
+	Format      string   `short:"f" long:"format" default:"csv" choice:"csv" choice:"json" description:"the output format of the batch mode"`
+	Concurrency int      `short:"c" long:"concurrency" default:"4" description:"the number of concurrent lookups in batch mode"`
+	ImportULS   string   `long:"import-uls" value-name:"directory" description:"import the FCC ULS database from the given directory"`
+	Profile     string   `short:"p" long:"profile" value-name:"name" description:"use the station profile with the given name"`
+	Set         []string `short:"s" long:"set" value-name:"key=value" description:"override a configuration value"`
+	Args        struct {
+		Callsign string `positional-arg-name:"callsign"`
+		Locator  string `positional-arg-name:"locator"`
+	} `positional-args:"yes"`
+}
+
 func main() {
-	if len(os.Args) < 2 || len(os.Args) > 3 {
-		fmt.Printf("usage: %s <callsign> [locator]\n", filepath.Base(os.Args[0]))
+	_, err := flags.Parse(&options)
+	if flags.WroteHelp(err) {
 		os.Exit(0)
 	}
+	if err != nil {
+		os.Exit(1)
+	}
 
-	config, err := cfg.LoadDefault()
+	if options.ImportULS != "" {
+		importULS(options.ImportULS)
+		return
+	}
+	if options.Args.Callsign == "" && options.Batch == "" {
+		log.Fatal("the required argument `callsign` was not provided")
+	}
+
+	overrides, err := cfg.ParseOverrides(options.Set)
+	if err != nil {
+		log.Fatal(err)
+	}
+	layers, err := cfg.LoadDefaultLayers(nil, overrides)
 	if err != nil {
 		log.Fatalf("cannot load configuration file: %v", err)
 	}
+	config := layers.Configuration()
+	if options.Profile != "" {
+		config.Set(cfg.ActiveStation, options.Profile)
+	}
+	profile := loadProfile(config)
+	err = cfg.CheckCredentials("", "", layers.Layer(cfg.FileLayer))
+	if err != nil {
+		log.Printf("warning: %v", err)
+	}
+
+	if options.Batch != "" {
+		err := batch(options.Batch, options.Format, options.Concurrency, loadCallbooks(config, profile))
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 
 	locator, useLocator := parseLocator()
 	if !useLocator {
-		locator, useLocator = loadLocator(config)
+		locator, useLocator = profile.Locator, !profile.Locator.IsZero()
+	}
+
+	providers := loadCallbooks(config, profile)
+	if options.Merge {
+		aggregator := callbook.NewAggregator(providers...)
+		info, sources, err := aggregator.LookupSources(context.Background(), options.Args.Callsign)
+		if err != nil {
+			log.Fatal(err)
+		}
+		printInfo("Merged", info, sources)
+		if useLocator {
+			printDistanceAzimuth(info, locator)
+		}
+		return
 	}
 
-	callbooks := loadCallbooks(config)
-	infos := lookup(os.Args[1], callbooks)
-	for name, info := range infos {
-		printInfo(name, info)
+	infos := lookup(options.Args.Callsign, providers)
+	for _, provider := range providers {
+		info, ok := infos[provider.Name]
+		if !ok {
+			continue
+		}
+		printInfo(provider.Name, info, nil)
 		if useLocator {
 			printDistanceAzimuth(info, locator)
 		}
@@ -104,11 +241,11 @@ func main() {
 }
 
 func parseLocator() (locator.Locator, bool) {
-	if len(os.Args) != 3 {
+	if options.Args.Locator == "" {
 		return locator.Locator{}, false
 	}
 
-	loc, err := locator.Parse(os.Args[2])
+	loc, err := locator.Parse(options.Args.Locator)
 	if err != nil {
 		fmt.Printf("cannot parse locator: %v\n", err)
 		return locator.Locator{}, false
@@ -116,87 +253,151 @@ func parseLocator() (locator.Locator, bool) {
 	return loc, true
 }
 
-func loadLocator(config cfg.Configuration) (locator.Locator, bool) {
-	value := config.Get(cfg.MyLocator, "").(string)
-
-	loc, err := locator.Parse(value)
+func loadProfile(config cfg.Configuration) cfg.Profile {
+	profile, err := config.ActiveProfile()
 	if err != nil {
-		fmt.Printf("cannot load locator: %v\n", err)
-		return locator.Locator{}, false
+		fmt.Printf("cannot load station profile: %v\n", err)
 	}
-	return loc, true
+	return profile
 }
 
-func loadCallbooks(config cfg.Configuration) map[string]callbook.Callbook {
+func loadCallbooks(config cfg.Configuration, profile cfg.Profile) []callbook.Provider {
 	params := []struct {
-		name      string
-		configKey cfg.Key
-		factory   callbook.Factory
+		name        string
+		id          string
+		credentials bool
 	}{
-		{"HamQTH.com", cfg.Key("callbook.hamqth"), func(username, password string) callbook.Callbook {
-			return callbook.NewHamQTH(username, password)
-		}},
-		{"QRZ.com", cfg.Key("callbook.qrz"), func(username, password string) callbook.Callbook {
-			return callbook.NewQRZ(username, password)
-		}},
-	}
-	callbooks := make(map[string]callbook.Callbook)
+		{"HamQTH.com", "hamqth", true},
+		{"QRZ.com", "qrz", true},
+		{"HamCall.net", "hamcall", true},
+		{"callook.info", "callook", false},
+		{"FCC ULS", "uls", false},
+	}
+	providers := make([]callbook.Provider, 0, len(params))
 	for _, param := range params {
-		callbook, err := newCallbook(param.configKey, config, param.factory)
+		cb, err := newCallbook(param.id, param.credentials, config, profile)
 		if err != nil {
 			panic(fmt.Errorf("cannot create callbook %s: %v", param.name, err))
 		}
-		if callbook != nil {
-			callbooks[param.name] = callbook
+		if cb != nil {
+			providers = append(providers, callbook.Provider{Name: param.name, Callbook: cb})
 		}
 	}
-	return callbooks
+	return providers
 }
 
-func newCallbook(configPath cfg.Key, config cfg.Configuration, factory callbook.Factory) (callbook.Callbook, error) {
-	useCallbook := config.Get(configPath, false) != false
-	if !useCallbook {
+func newCallbook(id string, credentials bool, config cfg.Configuration, profile cfg.Profile) (callbook.Callbook, error) {
+	configPath := cfg.Key("callbook." + id)
+	factory := callbook.Factories[id]
+	profileCredentials, hasProfileCredentials := profile.Credentials(id)
+	if hasProfileCredentials && credentials {
+		resolved, err := profileCredentials.Resolve()
+		if err != nil {
+			return nil, err
+		}
+		return factory(resolved.Username, resolved.Password), nil
+	}
+	if !enabled(config, configPath) {
 		return nil, nil
 	}
+	if !credentials {
+		return factory("", ""), nil
+	}
 
-	username := config.Get(configPath+".username", "").(string)
-	password := config.Get(configPath+".password", "").(string)
-	if username == "" || password == "" {
+	resolved, err := config.GetCredentials(configPath)
+	if err != nil {
+		return nil, err
+	}
+	if resolved.Username == "" || resolved.Password == "" {
 		return nil, fmt.Errorf("cannot read username or password for %v", configPath)
 	}
-	return factory(username, password), nil
+	return factory(resolved.Username, resolved.Password), nil
+}
+
+// enabled returns true if the given key contains an object (e.g. credentials) or a true value.
+func enabled(config cfg.Configuration, key cfg.Key) bool {
+	if _, ok := config.Get(key, nil).(map[string]interface{}); ok {
+		return true
+	}
+	result, err := config.GetBool(key, false)
+	return err == nil && result
+}
+
+func importULS(dir string) {
+	filename, err := callbook.ULSIndexFilename()
+	if err != nil {
+		log.Fatal(err)
+	}
+	_, err = cfg.PrepareDirectory("")
+	if err != nil {
+		log.Fatal(err)
+	}
+	uls, err := callbook.ImportULSDirectory(dir, filename)
+	if err != nil {
+		log.Fatalf("cannot import the ULS database: %v", err)
+	}
+	fmt.Printf("imported %d licenses into %s\n", uls.Len(), filename)
 }
 
-func lookup(callsign string, callbooks map[string]callbook.Callbook) map[string]callbook.Info {
+func lookup(callsign string, providers []callbook.Provider) map[string]callbook.Info {
 	infos := make(map[string]callbook.Info)
-	for name, callbook := range callbooks {
-		if callbook == nil {
-			panic(fmt.Errorf("callbook %s is nil", name))
+	for _, provider := range providers {
+		if provider.Callbook == nil {
+			panic(fmt.Errorf("callbook %s is nil", provider.Name))
 		}
-		info, err := callbook.Lookup(callsign)
+		info, err := provider.Callbook.Lookup(callsign)
 		if err == nil {
-			infos[name] = info
+			infos[provider.Name] = info
 		}
 	}
 	return infos
 }
 
-func printInfo(title string, info callbook.Info) {
+func printInfo(title string, info callbook.Info, sources callbook.Sources) {
+	source := func(field callbook.Field) string {
+		if name, ok := sources[field]; ok {
+			return fmt.Sprintf(" (%s)", name)
+		}
+		return ""
+	}
+
 	fmt.Println(title)
 	fmt.Println(strings.Repeat("=", len(title)))
 	fmt.Printf("Callsign %v\n", info.Callsign)
-	fmt.Printf("Name: %s\n", info.Name)
-	fmt.Printf("Address: %s\n", info.Address)
-	fmt.Printf("QTH: %s\n", info.Country)
-	fmt.Printf("Country: %s\n", info.Country)
-	fmt.Printf("CQ: %d\n", info.CQZone)
-	fmt.Printf("ITU: %d\n", info.ITUZone)
-	fmt.Printf("Time Offset: UTC%+1.1f\n", info.TimeOffset)
+	fmt.Printf("Name: %s%s\n", info.Name, source(callbook.FieldName))
+	fmt.Printf("Address: %s%s\n", info.Address, source(callbook.FieldAddress))
+	fmt.Printf("QTH: %s%s\n", info.QTH, source(callbook.FieldQTH))
+	fmt.Printf("Country: %s%s\n", info.Country, source(callbook.FieldCountry))
+	fmt.Printf("CQ: %d%s\n", info.CQZone, source(callbook.FieldCQZone))
+	fmt.Printf("ITU: %d%s\n", info.ITUZone, source(callbook.FieldITUZone))
+	fmt.Printf("Time Offset: UTC%+1.1f%s\n", info.TimeOffset, source(callbook.FieldTimeOffset))
 	if !info.Locator.IsZero() {
-		fmt.Printf("Locator: %v\n", info.Locator)
+		fmt.Printf("Locator: %v%s\n", info.Locator, source(callbook.FieldLocator))
 	}
 	if info.LatLonValid {
-		fmt.Printf("Lat/Lon: %v\n", info.LatLon)
+		fmt.Printf("Lat/Lon: %v%s\n", info.LatLon, source(callbook.FieldLatLon))
+	}
+	printOptional := func(label string, value string, field callbook.Field) {
+		if value != "" {
+			fmt.Printf("%s: %s%s\n", label, value, source(field))
+		}
+	}
+	if info.DXCCEntity != 0 {
+		fmt.Printf("DXCC: %d%s\n", info.DXCCEntity, source(callbook.FieldDXCCEntity))
+	}
+	printOptional("US State", info.USState, callbook.FieldUSState)
+	printOptional("US County", info.USCounty, callbook.FieldUSCounty)
+	printOptional("IOTA", info.IOTA, callbook.FieldIOTA)
+	printOptional("License Class", info.LicenseClass, callbook.FieldLicenseClass)
+	if info.BirthYear != 0 {
+		fmt.Printf("Born: %d%s\n", info.BirthYear, source(callbook.FieldBirthYear))
+	}
+	printOptional("Email", info.Email, callbook.FieldEmail)
+	printOptional("QSL via", info.QSLManager, callbook.FieldQSLManager)
+	fmt.Printf("LoTW: %t, eQSL: %t, Paper QSL: %t\n", info.LoTW, info.EQSL, info.PaperQSL)
+	printOptional("Image", info.ImageURL, callbook.FieldImageURL)
+	if !info.LastUpdate.IsZero() {
+		fmt.Printf("Last Update: %s%s\n", info.LastUpdate.Format("2006-01-02"), source(callbook.FieldLastUpdate))
 	}
 }
 
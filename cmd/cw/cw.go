@@ -1,14 +1,17 @@
 /*
-cw uses a cwdaemon server running locally on port 6789 to output CW.
+cw uses a cwdaemon server running locally on port 6789 or a WinKeyer connected to a serial port to output CW. It can
+also render CW into a WAV file and decode CW from a WAV file or a raw PCM stream.
 
 USAGE
 
 	cw <command> [parameters]
 
 Valid commands are:
-	send <text>
+	send [send flags] <text>
 	speed <wpm>
 	tune <duration>
+	render [render flags] <text>
+	decode [decode flags] <file>
 
 EXAMPLES
 
@@ -20,6 +23,21 @@ EXAMPLES
 
 	Key down for 5 seconds:
 	> cw tune 5
+
+	Send a contest exchange with cut numbers and the callsign of the active station profile, 5 WpM faster:
+	> cw send --wpm 28 --call dl2xyz --nr 9 "{call} {+5}{rst:cut} {nr:cut}{-5} de {mycall}"
+
+	Send "hello world" with a WinKeyer:
+	> cw --winkeyer /dev/ttyUSB0 send hello world
+
+	Render "cq de dl1abc" with 25 WpM and some noise into cq.wav:
+	> cw render -o cq.wav --wpm 25 --noise 0.2 cq de dl1abc
+
+	Decode the CW in cq.wav:
+	> cw decode cq.wav
+
+	Decode the CW from the sound card:
+	> arecord -f S16_LE -r 8000 -c 1 -t raw | cw decode --raw --rate 8000 -
 */
 package main
 
@@ -30,11 +48,33 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/ftl/hamradio/cfg"
+	"github.com/ftl/hamradio/cwaudio"
 	"github.com/ftl/hamradio/cwclient"
+	"github.com/ftl/hamradio/cwdecoder"
+	"github.com/ftl/hamradio/cwmacro"
+	"github.com/ftl/hamradio/winkeyer"
 )
 
-type commandFunc func(*cwclient.Client, string)
+type commandFunc func(cwclient.Keyer, commandLine)
+
+type commandLine struct {
+	host     string
+	port     int
+	winkeyer string
+	command  string
+	text     string
+	output   string
+	render   cwaudio.Options
+	decode   cwdecoder.Options
+	raw      bool
+	bits     int
+	channels int
+	wpm      int
+	qso      cwmacro.QSO
+}
 
 func main() {
 	commands := map[string]commandFunc{
@@ -43,13 +83,43 @@ func main() {
 		"tune":  tune,
 	}
 
-	host, port, commandName, text, err := parseCommandLine(os.Args[1:])
+	cmd, err := parseCommandLine(os.Args[1:])
 	if err != nil {
-		log.Fatal(err)
+		log.Print(err)
 		printUsage()
 	}
 
-	client, err := cwclient.New(host, port)
+	switch cmd.command {
+	case "render":
+		render(cmd)
+		return
+	case "decode":
+		decode(cmd)
+		return
+	}
+
+	command, ok := commands[cmd.command]
+	if !ok {
+		printUsage()
+	}
+
+	if cmd.winkeyer != "" {
+		port, err := winkeyer.OpenPort(cmd.winkeyer)
+		if err != nil {
+			log.Fatalf("cannot open the serial port of the WinKeyer: %v", err)
+		}
+		defer port.Close()
+		keyer, err := winkeyer.Open(port)
+		if err != nil {
+			log.Fatalf("cannot open the WinKeyer: %v", err)
+		}
+		defer keyer.Close()
+
+		command(keyer, cmd)
+		return
+	}
+
+	client, err := cwclient.New(cmd.host, cmd.port)
 	if err != nil {
 		log.Fatalf("cannot create a client for cwdaemon: %v", err)
 	}
@@ -60,52 +130,119 @@ func main() {
 	}
 	defer client.Disconnect()
 
-	if commandName == "" {
-		printUsage()
-	}
-
-	if command, ok := commands[commandName]; ok {
-		command(client, text)
-	} else {
-		printUsage()
-	}
+	command(client, cmd)
 }
 
-func parseCommandLine(args []string) (host string, port int, command string, text string, err error) {
+func parseCommandLine(args []string) (commandLine, error) {
+	result := commandLine{
+		output:   "cw.wav",
+		render:   cwaudio.DefaultOptions,
+		bits:     16,
+		channels: 1,
+	}
 	lastIndex := len(args) - 1
-	text = ""
+	value := func(i int, name string) (string, error) {
+		if i >= lastIndex {
+			return "", fmt.Errorf("missing actual %s after %s flag", name, args[i])
+		}
+		return args[i+1], nil
+	}
+	intValue := func(i int, name string) (int, error) {
+		s, err := value(i, name)
+		if err != nil {
+			return 0, err
+		}
+		result, err := strconv.Atoi(s)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s: %w", name, err)
+		}
+		return result, nil
+	}
+	floatValue := func(i int, name string) (float64, error) {
+		s, err := value(i, name)
+		if err != nil {
+			return 0, err
+		}
+		result, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s: %w", name, err)
+		}
+		return result, nil
+	}
+
+	var err error
 	for i := 0; i < len(args); i++ {
 		arg := strings.ToLower(args[i])
-		switch arg {
-		case "-h", "--host":
-			if i >= lastIndex {
-				err = fmt.Errorf("missing actual hostname after host flag")
-				return
-			}
-			i += 1
-			host = args[i]
-		case "-p", "--port":
-			if i >= lastIndex {
-				err = fmt.Errorf("missing actual hostname after host flag")
-				return
-			}
-			i += 1
-			port, err = strconv.Atoi(args[i])
-			if err != nil {
-				err = fmt.Errorf("invalid port: %w", err)
-				return
-			}
+		switch {
+		case arg == "-h" || arg == "--host":
+			result.host, err = value(i, "hostname")
+		case arg == "-p" || arg == "--port":
+			result.port, err = intValue(i, "port")
+		case arg == "-w" || arg == "--winkeyer":
+			result.winkeyer, err = value(i, "device")
+		case result.command == "send" && arg == "--wpm":
+			result.wpm, err = intValue(i, "speed")
+		case result.command == "send" && arg == "--call":
+			result.qso.Call, err = value(i, "callsign")
+		case result.command == "send" && arg == "--rst":
+			result.qso.RST, err = value(i, "RST")
+		case result.command == "send" && arg == "--nr":
+			result.qso.Number, err = intValue(i, "serial number")
+		case result.command == "send" && arg == "--exch":
+			result.qso.Exchange, err = value(i, "exchange")
+		case result.command == "render" && (arg == "-o" || arg == "--output"):
+			result.output, err = value(i, "filename")
+		case result.command == "render" && arg == "--wpm":
+			result.render.Timing.WPM, err = intValue(i, "speed")
+		case result.command == "render" && arg == "--farnsworth":
+			result.render.Timing.Farnsworth, err = intValue(i, "effective speed")
+		case result.command == "render" && arg == "--weight":
+			result.render.Timing.Weight, err = intValue(i, "weight")
+		case result.command == "render" && arg == "--tone":
+			result.render.Tone, err = floatValue(i, "tone")
+		case result.command == "render" && arg == "--rate":
+			result.render.SampleRate, err = intValue(i, "sample rate")
+		case result.command == "render" && arg == "--rise":
+			var ms int
+			ms, err = intValue(i, "rise time")
+			result.render.Rise = time.Duration(ms) * time.Millisecond
+		case result.command == "render" && arg == "--noise":
+			result.render.Noise, err = floatValue(i, "noise level")
+		case result.command == "render" && arg == "--qsb":
+			result.render.QSB, err = floatValue(i, "QSB depth")
+		case result.command == "render" && arg == "--qrm":
+			result.render.QRM, err = floatValue(i, "QRM level")
+		case result.command == "decode" && arg == "--raw":
+			result.raw = true
+			continue
+		case result.command == "decode" && arg == "--rate":
+			result.decode.SampleRate, err = intValue(i, "sample rate")
+		case result.command == "decode" && arg == "--bits":
+			result.bits, err = intValue(i, "bits per sample")
+		case result.command == "decode" && arg == "--channels":
+			result.channels, err = intValue(i, "number of channels")
+		case result.command == "decode" && arg == "--tone":
+			result.decode.Tone, err = floatValue(i, "tone")
 		default:
-			if command == "" {
-				command = arg
-			} else if text == "" {
-				text = arg
+			if result.command == "decode" {
+				// keep the case of the filename
+				arg = args[i]
+			}
+			if result.command == "" {
+				result.command = arg
+			} else if result.text == "" {
+				result.text = arg
 			} else {
-				text += " " + arg
+				result.text += " " + arg
 			}
+			continue
+		}
+		if err != nil {
+			return commandLine{}, err
 		}
+		i++
 	}
-	return
+	return result, nil
 }
 
 func printUsage() {
@@ -113,49 +250,193 @@ func printUsage() {
 	fmt.Print(`
 
 valid commands:
-	send <text>          send the given text
+	send [send flags] <text>
+	                     send the given text, the text may contain macros
 	speed <wpm>          set the given speed in WpM for the next transmissions
 	tune  <duration>     key down for the given duration in seconds for tuning
+	render [render flags] <text>
+	                     render the given text into a WAV file
+	decode [decode flags] <file>
+	                     decode the CW in the given WAV file, or in the raw PCM stream from stdin if the file is -
 
 flags:
 	-h, --host [host]    use the given host as target instead of the default host localhost
 	-p, --port [port]    use the given port as target instead of the default port 6789
+	-w, --winkeyer [device]
+	                     use the WinKeyer connected to the given serial port instead of cwdaemon
+
+send flags:
+	--wpm [wpm]          set the given speed in WpM before sending, required for relative speed changes
+	--call [callsign]    the callsign of the other station for {CALL}
+	--rst [rst]          the RST for {RST} (default 599)
+	--nr [number]        the serial number for {NR}
+	--exch [exchange]    the exchange for {EXCH}
+
+macros:
+	{MYCALL}, {OP}, {LOC}
+	                     callsign, operator and locator of the active station profile
+	{CALL}, {RST}, {NR}, {EXCH}
+	                     the values given with the send flags
+	{NR:CUT}, {NR:FULLCUT}
+	                     a value with cut numbers (0=T, 9=N) or all cut numbers
+	{+5}, {-5}           change the speed by the given WpM
+	{IF EXCH}...{ELSE}...{ENDIF}
+	                     use the enclosed text only if EXCH is not empty
+
+render flags:
+	-o, --output [file]  the name of the WAV file (default cw.wav)
+	--wpm [wpm]          the speed in WpM (default 20)
+	--farnsworth [wpm]   the effective speed in WpM with Farnsworth spacing
+	--weight [weight]    the weight [-50..50] (default 0)
+	--tone [hz]          the frequency of the tone in Hz (default 600)
+	--rate [hz]          the sample rate in Hz (default 8000)
+	--rise [ms]          the rise and fall time of the marks in ms (default 5)
+	--noise [level]      add white noise [0..1]
+	--qsb [depth]        add fading [0..1]
+	--qrm [level]        add an interfering CW signal [0..1]
+
+decode flags:
+	--raw                the file contains raw PCM samples without WAV header
+	--rate [hz]          the sample rate of the raw PCM samples in Hz (default 8000)
+	--bits [bits]        the bits per sample of the raw PCM samples, unsigned 8 or signed 16 little endian (default 16)
+	--channels [n]       the number of channels of the raw PCM samples (default 1)
+	--tone [hz]          the frequency of the tone in Hz (default: detected automatically)
 
 `)
 	os.Exit(0)
 }
 
-func send(client *cwclient.Client, text string) {
-	if text == "" {
+func send(keyer cwclient.Keyer, cmd commandLine) {
+	if cmd.text == "" {
 		printUsage()
 	}
 
-	client.Send(text)
-	client.Wait()
+	message, err := cwmacro.Expand(cmd.text, cwmacro.NewValues(loadProfile(), cmd.qso))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if message.HasSpeedChanges() && cmd.wpm == 0 {
+		log.Fatal("the text contains relative speed changes, use --wpm to set the speed")
+	}
+
+	if cmd.wpm > 0 {
+		keyer.Speed(cmd.wpm)
+	}
+	message.Send(keyer, cmd.wpm)
+	keyer.Wait()
 }
 
-func speed(client *cwclient.Client, text string) {
-	if text == "" {
+func loadProfile() cfg.Profile {
+	layers, err := cfg.LoadDefaultLayers(nil, nil)
+	if err != nil {
+		log.Printf("warning: cannot load configuration file: %v", err)
+		return cfg.Profile{}
+	}
+	profile, err := layers.Configuration().ActiveProfile()
+	if err != nil {
+		log.Printf("warning: cannot load station profile: %v", err)
+	}
+	return profile
+}
+
+func speed(keyer cwclient.Keyer, cmd commandLine) {
+	if cmd.text == "" {
 		printUsage()
 	}
 
-	speed, err := strconv.Atoi(text)
+	speed, err := strconv.Atoi(cmd.text)
 	if err != nil {
 		log.Fatalf("%v is not a valid speed value, it must be a number between 5 and 60", os.Args[2])
 	}
 
-	client.Speed(speed)
+	keyer.Speed(speed)
 }
 
-func tune(client *cwclient.Client, text string) {
-	if text == "" {
+func tune(keyer cwclient.Keyer, cmd commandLine) {
+	if cmd.text == "" {
 		printUsage()
 	}
 
-	duration, err := strconv.Atoi(text)
+	duration, err := strconv.Atoi(cmd.text)
 	if err != nil {
 		log.Fatalf("%v is not a valid duration value, it must be a number between 0 and 10", os.Args[2])
 	}
 
-	client.Tune(duration)
+	keyer.Tune(duration)
+	keyer.Wait()
+}
+
+func render(cmd commandLine) {
+	if cmd.text == "" {
+		printUsage()
+	}
+
+	signal, err := cwaudio.Render(cmd.text, cmd.render)
+	if err != nil {
+		log.Printf("warning: %v", err)
+	}
+
+	file, err := os.Create(cmd.output)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+
+	sampleRate := cmd.render.SampleRate
+	if sampleRate <= 0 {
+		sampleRate = cwaudio.DefaultOptions.SampleRate
+	}
+	err = cwaudio.WriteWAV(file, signal, sampleRate)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func decode(cmd commandLine) {
+	if cmd.text == "" {
+		printUsage()
+	}
+
+	in := os.Stdin
+	if cmd.text != "-" {
+		file, err := os.Open(cmd.text)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer file.Close()
+		in = file
+	}
+
+	var word []cwdecoder.Character
+	printWord := func() {
+		if len(word) == 0 {
+			return
+		}
+		last := word[len(word)-1]
+		fmt.Printf("%8.2fs %3.0f WpM  %s\n", word[0].Time.Seconds(), last.WPM, cwdecoder.Text(word))
+		word = word[:0]
+	}
+	handleCharacter := func(c cwdecoder.Character) {
+		if c.Text == " " {
+			printWord()
+		} else {
+			word = append(word, c)
+		}
+	}
+
+	if cmd.raw {
+		err := cwdecoder.DecodePCM(in, cmd.bits, cmd.channels, cmd.decode, handleCharacter)
+		if err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		characters, err := cwdecoder.DecodeWAV(in, cmd.decode)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, c := range characters {
+			handleCharacter(c)
+		}
+	}
+	printWord()
 }
@@ -2,8 +2,12 @@ package main
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/ftl/hamradio/cwaudio"
+	"github.com/ftl/hamradio/cwmacro"
 )
 
 func TestParseCommandLine(t *testing.T) {
@@ -45,6 +49,18 @@ func TestParseCommandLine(t *testing.T) {
 			expectedHost:    "thehost",
 			expectedText:    "cq de dk0kd",
 		},
+		{
+			desc:            "render flags are only valid for render",
+			value:           []string{"send", "--noise", "0.2"},
+			expectedCommand: "send",
+			expectedText:    "--noise 0.2",
+		},
+		{
+			desc:            "decode keeps the case of the filename",
+			value:           []string{"DECODE", "Recordings/CQ.wav"},
+			expectedCommand: "decode",
+			expectedText:    "Recordings/CQ.wav",
+		},
 		{
 			desc:    "missing host",
 			value:   []string{"tune", "-h"},
@@ -60,15 +76,20 @@ func TestParseCommandLine(t *testing.T) {
 			value:   []string{"--port", "theport", "tune"},
 			invalid: true,
 		},
+		{
+			desc:    "invalid speed",
+			value:   []string{"render", "--wpm", "fast", "cq"},
+			invalid: true,
+		},
 	}
 	for _, tc := range tt {
 		t.Run(tc.desc, func(t *testing.T) {
-			actualHost, actualPort, actualCommand, actualText, actualErr := parseCommandLine(tc.value)
+			actual, actualErr := parseCommandLine(tc.value)
 			if !tc.invalid {
-				assert.Equal(t, tc.expectedHost, actualHost)
-				assert.Equal(t, tc.expectedPort, actualPort)
-				assert.Equal(t, tc.expectedCommand, actualCommand)
-				assert.Equal(t, tc.expectedText, actualText)
+				assert.Equal(t, tc.expectedHost, actual.host)
+				assert.Equal(t, tc.expectedPort, actual.port)
+				assert.Equal(t, tc.expectedCommand, actual.command)
+				assert.Equal(t, tc.expectedText, actual.text)
 				assert.NoError(t, actualErr)
 			} else {
 				assert.Error(t, actualErr)
@@ -76,3 +97,43 @@ func TestParseCommandLine(t *testing.T) {
 		})
 	}
 }
+
+func TestParseCommandLine_Render(t *testing.T) {
+	actual, err := parseCommandLine([]string{"render", "-o", "Practice.wav", "--wpm", "25", "--farnsworth", "15", "--weight", "10",
+		"--tone", "700", "--rate", "11025", "--rise", "8", "--noise", "0.2", "--qsb", "0.5", "--qrm", "0.1", "cq", "de", "dl1abc"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "render", actual.command)
+	assert.Equal(t, "cq de dl1abc", actual.text)
+	assert.Equal(t, "Practice.wav", actual.output)
+	expected := cwaudio.DefaultOptions
+	expected.Timing.WPM = 25
+	expected.Timing.Farnsworth = 15
+	expected.Timing.Weight = 10
+	expected.Tone = 700
+	expected.SampleRate = 11025
+	expected.Rise = 8 * time.Millisecond
+	expected.Noise = 0.2
+	expected.QSB = 0.5
+	expected.QRM = 0.1
+	assert.Equal(t, expected, actual.render)
+}
+
+func TestParseCommandLine_WinKeyer(t *testing.T) {
+	actual, err := parseCommandLine([]string{"--winkeyer", "/dev/ttyUSB0", "send", "cq"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "/dev/ttyUSB0", actual.winkeyer)
+	assert.Equal(t, "send", actual.command)
+	assert.Equal(t, "cq", actual.text)
+}
+
+func TestParseCommandLine_Send(t *testing.T) {
+	actual, err := parseCommandLine([]string{"send", "--wpm", "28", "--call", "DL2xyz", "--rst", "579", "--nr", "9", "--exch", "B01", "{call}", "{rst:cut}", "{nr:cut}"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "send", actual.command)
+	assert.Equal(t, "{call} {rst:cut} {nr:cut}", actual.text)
+	assert.Equal(t, 28, actual.wpm)
+	assert.Equal(t, cwmacro.QSO{Call: "DL2xyz", RST: "579", Number: 9, Exchange: "B01"}, actual.qso)
+}
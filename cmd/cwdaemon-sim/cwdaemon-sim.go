@@ -0,0 +1,84 @@
+/*
+cwdaemon-sim emulates a cwdaemon server. Instead of keying a transmitter, it logs the received commands
+and the keyed text with its timing to stdout. It can be used to try out cw and other cwdaemon clients
+without a radio.
+
+USAGE
+
+	cwdaemon-sim [-h <host>] [-p <port>] [-s <time scale>] [--help]
+
+	-h, --host        the host address to listen on (default localhost)
+	-p, --port        the UDP port to listen on (default 6789)
+	-s, --time-scale  scale the keying time, 1 is real time, 0 keys everything immediately (default 1)
+	--help            show the usage
+
+EXAMPLE
+
+	> cwdaemon-sim
+	2006/01/02 15:04:05 listening on 127.0.0.1:6789
+	2006/01/02 15:04:07 command: h1
+	2006/01/02 15:04:09 keyed: "HELLO WORLD" at 24 WpM in 5.15s
+*/
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	flags "github.com/jessevdk/go-flags"
+
+	"github.com/ftl/hamradio/cwdaemon"
+)
+
+var options struct {
+	Host      string  `short:"h" long:"host" default:"localhost" description:"the host address to listen on"`
+	Port      int     `short:"p" long:"port" default:"6789" description:"the UDP port to listen on"`
+	TimeScale float64 `short:"s" long:"time-scale" default:"1" description:"scale the keying time, 0 keys everything immediately"`
+}
+
+func main() {
+	// -h is taken by --host, therefore the help option is only available as --help
+	var help struct {
+		Help bool `long:"help" description:"Show this help message"`
+	}
+	parser := flags.NewParser(&options, flags.PassDoubleDash|flags.PrintErrors)
+	parser.AddGroup("Help Options", "", &help)
+	_, err := parser.Parse()
+	if err != nil {
+		os.Exit(1)
+	}
+	if help.Help {
+		parser.WriteHelp(os.Stdout)
+		os.Exit(0)
+	}
+
+	server, err := cwdaemon.Listen(fmt.Sprintf("%s:%d", options.Host, options.Port))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer server.Close()
+	server.SetTimeScale(options.TimeScale)
+	server.OnEvent(logEvent)
+	log.Printf("listening on %v", server.Addr())
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	<-signals
+}
+
+func logEvent(event cwdaemon.Event) {
+	switch event.Type {
+	case cwdaemon.CommandReceived:
+		log.Printf("command: %s", event.Text)
+	case cwdaemon.TextKeyed:
+		log.Printf("keyed: %q at %d WpM in %v", event.Text, event.Speed, event.Duration.Round(10*time.Millisecond))
+	case cwdaemon.Aborted:
+		log.Printf("aborted after %q at %d WpM in %v", event.Text, event.Speed, event.Duration.Round(10*time.Millisecond))
+	case cwdaemon.Tuned:
+		log.Printf("tuned for %v", event.Duration.Round(10*time.Millisecond))
+	}
+}
@@ -40,6 +40,10 @@ CONFIGURATION
 
 	dxcc stores a cty.dat file in ~/.config/hamradio. The file is automatically updated if
 	there is a newer version available at http://www.country-files.com/cty/cty.dat.
+
+	If no locator is given, dxcc uses the locator of the active station profile or my.locator
+	from the hamradio configuration file (~/.config/hamradio/conf.json). The active profile can
+	be selected with the environment variable HAMRADIO_STATION_ACTIVE.
 */
 package main
 
@@ -78,10 +82,11 @@ func main() {
 		os.Exit(0)
 	}
 
-	config, err := cfg.LoadDefault()
+	layers, err := cfg.LoadDefaultLayers(nil, nil)
 	if err != nil {
 		log.Fatalf("cannot load configuration file: %v", err)
 	}
+	config := layers.Configuration()
 
 	foundPrefixes, _ := prefixes.Find(os.Args[1])
 	loc, useLocator := parseLocator()
@@ -111,14 +116,12 @@ func parseLocator() (locator.Locator, bool) {
 }
 
 func loadLocator(config cfg.Configuration) (locator.Locator, bool) {
-	value := config.Get(cfg.MyLocator, "").(string)
-
-	loc, err := locator.Parse(value)
+	profile, err := config.ActiveProfile()
 	if err != nil {
-		fmt.Printf("cannot load locator: %v\n", err)
+		fmt.Printf("cannot load station profile: %v\n", err)
 		return locator.Locator{}, false
 	}
-	return loc, true
+	return profile.Locator, !profile.Locator.IsZero()
 }
 
 func printPrefix(prefix dxcc.Prefix) {
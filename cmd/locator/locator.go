@@ -14,6 +14,13 @@ EXAMPLE
 	Locator KO94bx = (54.97917N, 38.12500E)
 	Distance: 9452.2km
 	Azimuth: 23.6°
+
+CONFIGURATION
+
+	If only one locator is given, locator calculates the distance and azimuth from the locator of
+	the active station profile or my.locator in the hamradio configuration file
+	(~/.config/hamradio/conf.json). The active profile can be selected with the environment
+	variable HAMRADIO_STATION_ACTIVE.
 */
 package main
 
@@ -23,6 +30,7 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/ftl/hamradio/cfg"
 	"github.com/ftl/hamradio/locator"
 )
 
@@ -37,18 +45,38 @@ func main() {
 		log.Fatal(err)
 	}
 
-	fmt.Printf("Locator %v = %v\n", locator1, locator.ToLatLon(locator1))
-	if len(os.Args) == 2 {
-		os.Exit(0)
-	}
-
-	locator2, err := locator.Parse(os.Args[2])
-	if err != nil {
-		log.Fatal(err)
+	var locator2 locator.Locator
+	if len(os.Args) == 3 {
+		locator2, err = locator.Parse(os.Args[2])
+		if err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		myLocator, ok := loadLocator()
+		if !ok {
+			fmt.Printf("Locator %v = %v\n", locator1, locator.ToLatLon(locator1))
+			os.Exit(0)
+		}
+		locator1, locator2 = myLocator, locator1
 	}
 
+	fmt.Printf("Locator %v = %v\n", locator1, locator.ToLatLon(locator1))
 	fmt.Printf("Locator %v = %v\n", locator2, locator.ToLatLon(locator2))
 	fmt.Printf("Distance: %v\nAzimuth: %v\n",
 		locator.Distance(locator1, locator2),
 		locator.Azimuth(locator1, locator2))
 }
+
+func loadLocator() (locator.Locator, bool) {
+	layers, err := cfg.LoadDefaultLayers(nil, nil)
+	if err != nil {
+		fmt.Printf("cannot load configuration file: %v\n", err)
+		return locator.Locator{}, false
+	}
+	profile, err := layers.Configuration().ActiveProfile()
+	if err != nil {
+		fmt.Printf("cannot load station profile: %v\n", err)
+		return locator.Locator{}, false
+	}
+	return profile.Locator, !profile.Locator.IsZero()
+}
@@ -0,0 +1,173 @@
+/*
+rotate turns the antenna rotator to a callsign, maidenhead locator or DXCC prefix, using rotctld
+(https://hamlib.github.io). It can also show the current position of the rotator, stop or park it.
+
+USAGE
+
+	rotate [-h <host>] [-p <port>] [-l] [-n] [--from <locator>] <target>
+	rotate [-h <host>] [-p <port>] --position | --stop | --park
+	rotate --help
+
+	-h, --host      the host of rotctld (default localhost)
+	-p, --port      the TCP port of rotctld (default 4533)
+	-l, --long-path turn to the long path instead of the short path
+	-n, --dry-run   only show the heading, do not turn the rotator
+	--from          the own maidenhead locator (default: the locator of the active station profile)
+	--position      show the current position of the rotator
+	--stop          stop the rotator
+	--park          move the rotator to its park position
+	--help          show the usage
+
+	The target is either a maidenhead locator with at least four characters, a callsign or a
+	DXCC prefix. For callsigns and prefixes, the location of the DXCC entity is used.
+
+EXAMPLE
+
+	> rotate --from jn59nk vk2abc
+	Heading to VK2ABC (Australia): 82.8° (short path, 14218.4km)
+
+	> rotate --from jn59nk -l -n jo62
+	Heading to JO62: 200.4° (long path, 39665.2km)
+
+CONFIGURATION
+
+	If no locator is given with --from, rotate uses the locator of the active station profile or
+	my.locator from the hamradio configuration file (~/.config/hamradio/conf.json). The active profile
+	can be selected with the environment variable HAMRADIO_STATION_ACTIVE.
+
+	rotate stores a cty.dat file in ~/.config/hamradio. The file is automatically updated if
+	there is a newer version available at http://www.country-files.com/cty/cty.dat.
+*/
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	flags "github.com/jessevdk/go-flags"
+
+	"github.com/ftl/hamradio/cfg"
+	"github.com/ftl/hamradio/dxcc"
+	"github.com/ftl/hamradio/locator"
+	"github.com/ftl/hamradio/rotctl"
+)
+
+var options struct {
+	Host     string `short:"h" long:"host" default:"localhost" description:"the host of rotctld"`
+	Port     int    `short:"p" long:"port" default:"4533" description:"the TCP port of rotctld"`
+	LongPath bool   `short:"l" long:"long-path" description:"turn to the long path instead of the short path"`
+	DryRun   bool   `short:"n" long:"dry-run" description:"only show the heading, do not turn the rotator"`
+	From     string `long:"from" value-name:"locator" description:"the own maidenhead locator"`
+	Position bool   `long:"position" description:"show the current position of the rotator"`
+	Stop     bool   `long:"stop" description:"stop the rotator"`
+	Park     bool   `long:"park" description:"move the rotator to its park position"`
+	Args     struct {
+		Target string `positional-arg-name:"target"`
+	} `positional-args:"yes"`
+}
+
+func main() {
+	// -h is taken by --host, therefore the help option is only available as --help
+	var help struct {
+		Help bool `long:"help" description:"Show this help message"`
+	}
+	parser := flags.NewParser(&options, flags.PassDoubleDash|flags.PrintErrors)
+	parser.AddGroup("Help Options", "", &help)
+	_, err := parser.Parse()
+	if err != nil {
+		os.Exit(1)
+	}
+	if help.Help {
+		parser.WriteHelp(os.Stdout)
+		os.Exit(0)
+	}
+
+	client := rotctl.New(options.Host, options.Port)
+	connect := func() {
+		err := client.Connect()
+		if err != nil {
+			log.Fatalf("cannot connect to rotctld: %v", err)
+		}
+	}
+	defer client.Disconnect()
+
+	switch {
+	case options.Position:
+		connect()
+		azimuth, elevation, err := client.Position()
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Azimuth: %v\nElevation: %v\n", azimuth, elevation)
+		return
+	case options.Stop:
+		connect()
+		err = client.Stop()
+	case options.Park:
+		connect()
+		err = client.Park()
+	case options.Args.Target == "":
+		parser.WriteHelp(os.Stderr)
+		os.Exit(1)
+	default:
+		err = rotate(client, connect)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func rotate(client *rotctl.Client, connect func()) error {
+	from, err := ownLocator()
+	if err != nil {
+		return err
+	}
+	path := rotctl.ShortPath
+	if options.LongPath {
+		path = rotctl.LongPath
+	}
+
+	heading, err := rotctl.FindHeading(from, options.Args.Target, loadPrefixes(), path)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Heading to %v\n", heading)
+	if options.DryRun {
+		return nil
+	}
+
+	connect()
+	return client.PointTo(heading)
+}
+
+func ownLocator() (locator.Locator, error) {
+	if options.From != "" {
+		return locator.Parse(options.From)
+	}
+
+	layers, err := cfg.LoadDefaultLayers(nil, nil)
+	if err != nil {
+		return locator.Locator{}, fmt.Errorf("cannot load configuration file: %w", err)
+	}
+	profile, err := layers.Configuration().ActiveProfile()
+	if err != nil {
+		return locator.Locator{}, fmt.Errorf("cannot load station profile: %w", err)
+	}
+	if profile.Locator.IsZero() {
+		return locator.Locator{}, fmt.Errorf("no own locator configured, use --from")
+	}
+	return profile.Locator, nil
+}
+
+func loadPrefixes() *dxcc.Prefixes {
+	if _, err := locator.Parse(options.Args.Target); err == nil && len(options.Args.Target) >= 4 {
+		return nil
+	}
+	prefixes, _, err := dxcc.DefaultPrefixes(true)
+	if err != nil {
+		fmt.Printf("cannot load DXCC prefixes: %v\n", err)
+		return nil
+	}
+	return prefixes
+}
@@ -0,0 +1,186 @@
+/*
+Package cwaudio renders Morse code as audio signal and reads and writes the signal as PCM WAV file.
+
+The signal is a sine tone that is keyed according to the timing of the morse package. The edges of the marks are
+shaped with a raised cosine to avoid key clicks. Optionally, the signal can be degraded with white noise, fading
+(QSB) and an interfering CW signal (QRM) to generate realistic practice files or test data for decoders.
+*/
+package cwaudio
+
+import (
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/ftl/hamradio/morse"
+)
+
+// Options for the rendering of CW audio.
+type Options struct {
+	// SampleRate in Hz, 0 means DefaultOptions.SampleRate.
+	SampleRate int
+	// Tone is the frequency of the CW tone in Hz, 0 means DefaultOptions.Tone.
+	Tone float64
+	// Amplitude of the CW tone [0..1], 0 means DefaultOptions.Amplitude.
+	Amplitude float64
+	// Timing defines speed, Farnsworth spacing and weight.
+	Timing morse.Timing
+	// Rise is the rise and fall time of each mark, 0 means DefaultOptions.Rise, a negative value disables the shaping.
+	Rise time.Duration
+	// Padding is the silence before and after the text.
+	Padding time.Duration
+
+	// Noise is the amplitude of white gaussian noise [0..1].
+	Noise float64
+	// QSB is the depth of the fading [0..1].
+	QSB float64
+	// QSBRate is the frequency of the fading in Hz, 0 means DefaultOptions.QSBRate.
+	QSBRate float64
+	// QRM is the amplitude of an interfering CW signal [0..1].
+	QRM float64
+	// QRMOffset is the frequency offset of the interfering signal in Hz, 0 means DefaultOptions.QRMOffset.
+	QRMOffset float64
+	// QRMText is keyed by the interfering signal repeatedly, the empty string means DefaultOptions.QRMText.
+	QRMText string
+	// Seed of the random number generator for noise and QRM, the same seed produces the same signal.
+	Seed int64
+}
+
+// DefaultOptions contains the default values for all options.
+var DefaultOptions = Options{
+	SampleRate: 8000,
+	Tone:       600,
+	Amplitude:  0.5,
+	Timing:     morse.Timing{WPM: morse.DefaultWPM},
+	Rise:       5 * time.Millisecond,
+	Padding:    200 * time.Millisecond,
+	QSBRate:    0.2,
+	QRMOffset:  250,
+	QRMText:    "CQ TEST DE DL0QRM",
+}
+
+func (o Options) withDefaults() Options {
+	if o.SampleRate <= 0 {
+		o.SampleRate = DefaultOptions.SampleRate
+	}
+	if o.Tone <= 0 {
+		o.Tone = DefaultOptions.Tone
+	}
+	if o.Amplitude <= 0 {
+		o.Amplitude = DefaultOptions.Amplitude
+	}
+	if o.Rise == 0 {
+		o.Rise = DefaultOptions.Rise
+	}
+	if o.QSBRate <= 0 {
+		o.QSBRate = DefaultOptions.QSBRate
+	}
+	if o.QRMOffset == 0 {
+		o.QRMOffset = DefaultOptions.QRMOffset
+	}
+	if o.QRMText == "" {
+		o.QRMText = DefaultOptions.QRMText
+	}
+	return o
+}
+
+// Render renders the given text as CW audio signal. The samples are in the range [-1..1]. Characters that cannot be
+// encoded as Morse code are skipped, in this case also a morse.UnknownError is returned together with the signal.
+func Render(text string, options Options) ([]float64, error) {
+	options = options.withDefaults()
+	symbols, err := morse.Encode(text)
+	timeline := options.Timing.Timeline(symbols)
+
+	rate := float64(options.SampleRate)
+	padding := 0
+	if options.Padding > 0 {
+		padding = samples(options.Padding, rate)
+	}
+	rise := 0
+	if options.Rise > 0 {
+		rise = samples(options.Rise, rate)
+	}
+	length := padding + samples(timeline.Duration, rate) + rise + padding
+	result := make([]float64, length)
+
+	addSignal(result, timeline.Marks, padding, rise, options.Tone, options.Amplitude, rate)
+
+	if options.QSB > 0 {
+		depth := math.Min(options.QSB, 1)
+		for i := range result {
+			t := float64(i) / rate
+			result[i] *= 1 - depth*(0.5-0.5*math.Cos(2*math.Pi*options.QSBRate*t))
+		}
+	}
+
+	random := rand.New(rand.NewSource(options.Seed))
+	if options.QRM > 0 {
+		addQRM(result, options, random, rise, rate)
+	}
+	if options.Noise > 0 {
+		for i := range result {
+			result[i] += options.Noise * random.NormFloat64() / 3
+		}
+	}
+
+	for i, sample := range result {
+		result[i] = math.Max(-1, math.Min(sample, 1))
+	}
+	return result, err
+}
+
+// addQRM adds an interfering signal with a slightly different speed that starts at a random time.
+func addQRM(signal []float64, options Options, random *rand.Rand, rise int, rate float64) {
+	timing := options.Timing
+	if timing.WPM <= 0 {
+		timing.WPM = morse.DefaultWPM
+	}
+	timing.WPM += random.Intn(7) - 3
+	timing.Farnsworth = 0
+	symbols, _ := morse.Encode(options.QRMText + " ")
+	timeline := timing.Timeline(symbols)
+	if timeline.Total <= 0 {
+		return
+	}
+
+	offset := -samples(time.Duration(random.Int63n(int64(timeline.Total))), rate)
+	for offset < len(signal) {
+		addSignal(signal, timeline.Marks, offset, rise, options.Tone+options.QRMOffset, options.QRM, rate)
+		offset += samples(timeline.Total, rate)
+	}
+}
+
+// addSignal adds the keyed tone for the given marks to the signal, starting at the given offset in samples.
+func addSignal(signal []float64, marks []morse.Mark, offset int, rise int, tone float64, amplitude float64, rate float64) {
+	for _, mark := range marks {
+		start := offset + samples(mark.Start, rate)
+		end := offset + samples(mark.End(), rate)
+		for i := start; i < end+rise; i++ {
+			if i < 0 {
+				continue
+			}
+			if i >= len(signal) {
+				break
+			}
+			envelope := 1.0
+			if rise > 0 {
+				if i-start < rise {
+					envelope = raisedCosine(float64(i-start) / float64(rise))
+				}
+				if i >= end {
+					envelope = math.Min(envelope, 1-raisedCosine(float64(i-end)/float64(rise)))
+				}
+			}
+			signal[i] += amplitude * envelope * math.Sin(2*math.Pi*tone*float64(i)/rate)
+		}
+	}
+}
+
+// raisedCosine rises from 0 to 1 for x in [0..1].
+func raisedCosine(x float64) float64 {
+	return 0.5 - 0.5*math.Cos(math.Pi*x)
+}
+
+func samples(d time.Duration, rate float64) int {
+	return int(math.Round(d.Seconds() * rate))
+}
@@ -0,0 +1,199 @@
+package cwaudio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ftl/hamradio/morse"
+)
+
+func rms(samples []float64) float64 {
+	var sum float64
+	for _, sample := range samples {
+		sum += sample * sample
+	}
+	return math.Sqrt(sum / float64(len(samples)))
+}
+
+func TestRender(t *testing.T) {
+	options := Options{
+		SampleRate: 8000,
+		Tone:       700,
+		Amplitude:  1,
+		Timing:     morse.Timing{WPM: 20},
+		Padding:    100 * time.Millisecond,
+	}
+
+	signal, err := Render("e e", options)
+
+	require.NoError(t, err)
+	// 100ms padding + (E, word space, E) 9 dits of 60ms + 5ms fall time + 100ms padding
+	assert.Equal(t, 800+4320+40+800, len(signal))
+
+	dit := 480
+	assert.Zero(t, rms(signal[:800]), "leading silence")
+	assert.InDelta(t, 1/math.Sqrt2, rms(signal[800+40:800+dit]), 0.02, "first mark")
+	assert.Zero(t, rms(signal[800+dit+40:800+8*dit]), "word space")
+	assert.InDelta(t, 1/math.Sqrt2, rms(signal[800+8*dit+40:800+9*dit]), 0.02, "second mark")
+	assert.Zero(t, rms(signal[len(signal)-800:]), "trailing silence")
+	for _, sample := range signal {
+		assert.True(t, sample >= -1 && sample <= 1)
+	}
+}
+
+func TestRender_Shaping(t *testing.T) {
+	signal, err := Render("t", Options{Amplitude: 1, Padding: -1, Rise: 10 * time.Millisecond})
+	require.NoError(t, err)
+
+	rise := 80
+	assert.Less(t, rms(signal[:rise/4]), 0.2, "the mark must start softly")
+	assert.Greater(t, rms(signal[rise:2*rise]), 0.6)
+	assert.Less(t, rms(signal[len(signal)-rise/4:]), 0.2, "the mark must end softly")
+}
+
+func TestRender_Degradation(t *testing.T) {
+	options := Options{Padding: time.Second, Seed: 1}
+	clean, err := Render("paris", options)
+	require.NoError(t, err)
+
+	options.Noise = 0.3
+	noisy, err := Render("paris", options)
+	require.NoError(t, err)
+	assert.Greater(t, rms(noisy[:8000]), 0.05, "noise in the silence")
+
+	again, err := Render("paris", options)
+	require.NoError(t, err)
+	assert.Equal(t, noisy, again, "the same seed produces the same signal")
+
+	options.Noise = 0
+	options.QRM = 0.3
+	qrm, err := Render("paris", options)
+	require.NoError(t, err)
+	assert.NotEqual(t, clean, qrm)
+
+	options.QRM = 0
+	options.QSB = 1
+	options.QSBRate = 1
+	faded, err := Render("paris", options)
+	require.NoError(t, err)
+	assert.Less(t, rms(faded), rms(clean))
+}
+
+func TestRender_Unknown(t *testing.T) {
+	signal, err := Render("e#", Options{})
+	var unknownErr *morse.UnknownError
+	assert.ErrorAs(t, err, &unknownErr)
+	assert.NotEmpty(t, signal)
+}
+
+func TestWAV_Roundtrip(t *testing.T) {
+	signal, err := Render("cq", Options{SampleRate: 11025})
+	require.NoError(t, err)
+
+	buffer := new(bytes.Buffer)
+	require.NoError(t, WriteWAV(buffer, signal, 11025))
+	assert.Equal(t, 44+2*len(signal), buffer.Len())
+
+	actual, sampleRate, err := ReadWAV(buffer)
+	require.NoError(t, err)
+	assert.Equal(t, 11025, sampleRate)
+	require.Equal(t, len(signal), len(actual))
+	for i := range signal {
+		assert.InDelta(t, signal[i], actual[i], 1.0/math.MaxInt16)
+	}
+}
+
+func TestReadWAV_Invalid(t *testing.T) {
+	_, _, err := ReadWAV(bytes.NewReader([]byte("RIFF0000WAVX")))
+	assert.ErrorIs(t, err, ErrInvalidWAV)
+
+	_, _, err = ReadWAV(bytes.NewReader(nil))
+	assert.ErrorIs(t, err, ErrInvalidWAV)
+}
+
+func TestReadWAV_ChunkSizes(t *testing.T) {
+	buffer := new(bytes.Buffer)
+	require.NoError(t, WriteWAV(buffer, make([]float64, 100), 8000))
+	wav := buffer.Bytes()
+	withDataSize := func(size uint32) []byte {
+		result := append([]byte{}, wav...)
+		binary.LittleEndian.PutUint32(result[40:], size)
+		return result
+	}
+	withListChunk := func(size uint32, body string) []byte {
+		result := append([]byte{}, wav[:36]...)
+		result = append(result, "LIST"...)
+		result = binary.LittleEndian.AppendUint32(result, size)
+		result = append(result, body...)
+		return append(result, wav[36:]...)
+	}
+
+	tt := []struct {
+		desc     string
+		data     []byte
+		expected int
+		invalid  bool
+	}{
+		{desc: "exact", data: wav, expected: 100},
+		{desc: "shorter data chunk", data: withDataSize(100), expected: 50},
+		{desc: "streaming", data: withDataSize(0xFFFFFFFF), expected: 100},
+		{desc: "truncated", data: withDataSize(1000000), expected: 100},
+		{desc: "odd chunk with padding", data: withListChunk(3, "abc\x00"), expected: 100},
+		{desc: "truncated chunk", data: withListChunk(0xFFFFFFF0, "abc"), invalid: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			actual, sampleRate, err := ReadWAV(bytes.NewReader(tc.data))
+			if tc.invalid {
+				assert.ErrorIs(t, err, ErrInvalidWAV)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, 8000, sampleRate)
+			assert.Equal(t, tc.expected, len(actual))
+		})
+	}
+}
+
+func TestPCMReader(t *testing.T) {
+	data := []byte{
+		0x00, 0x40, 0x00, 0x40, // 0.5, 0.5
+		0x00, 0xC0, 0xFF, 0x7F, // -0.5, 1
+		0x00, 0x00, 0x01, 0x80, // 0, -1
+		0x00, 0x40, // incomplete frame
+	}
+	reader, err := NewPCMReader(iotest.OneByteReader(bytes.NewReader(data)), 16, 2)
+	require.NoError(t, err)
+
+	var actual []float64
+	samples := make([]float64, 2)
+	for {
+		n, err := reader.Read(samples)
+		actual = append(actual, samples[:n]...)
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+	}
+
+	expected := []float64{0.5, 0.25, -0.5}
+	require.Equal(t, len(expected), len(actual))
+	for i := range expected {
+		assert.InDelta(t, expected[i], actual[i], 1.0/math.MaxInt16)
+	}
+}
+
+func TestNewPCMReader_Unsupported(t *testing.T) {
+	_, err := NewPCMReader(bytes.NewReader(nil), 24, 1)
+	assert.Error(t, err)
+	_, err = NewPCMReader(bytes.NewReader(nil), 8, 0)
+	assert.Error(t, err)
+}
@@ -0,0 +1,219 @@
+package cwaudio
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+const (
+	wavFormatPCM  = 1
+	bitsPerSample = 16
+
+	// wavStreamingSize is the size of a data chunk whose length was not known when the header was written.
+	wavStreamingSize = 0xFFFFFFFF
+)
+
+// ErrInvalidWAV is returned by ReadWAV if the data is not a supported WAV file.
+var ErrInvalidWAV = errors.New("invalid WAV file")
+
+// WriteWAV writes the given samples as 16 bit mono PCM WAV data with the given sample rate.
+// The samples are clipped to the range [-1..1].
+func WriteWAV(out io.Writer, samples []float64, sampleRate int) error {
+	dataSize := len(samples) * bitsPerSample / 8
+	header := struct {
+		RIFF          [4]byte
+		ChunkSize     uint32
+		WAVE          [4]byte
+		FMT           [4]byte
+		FMTSize       uint32
+		AudioFormat   uint16
+		Channels      uint16
+		SampleRate    uint32
+		ByteRate      uint32
+		BlockAlign    uint16
+		BitsPerSample uint16
+		DATA          [4]byte
+		DataSize      uint32
+	}{
+		RIFF:          [4]byte{'R', 'I', 'F', 'F'},
+		ChunkSize:     uint32(36 + dataSize),
+		WAVE:          [4]byte{'W', 'A', 'V', 'E'},
+		FMT:           [4]byte{'f', 'm', 't', ' '},
+		FMTSize:       16,
+		AudioFormat:   wavFormatPCM,
+		Channels:      1,
+		SampleRate:    uint32(sampleRate),
+		ByteRate:      uint32(sampleRate * bitsPerSample / 8),
+		BlockAlign:    bitsPerSample / 8,
+		BitsPerSample: bitsPerSample,
+		DATA:          [4]byte{'d', 'a', 't', 'a'},
+		DataSize:      uint32(dataSize),
+	}
+	err := binary.Write(out, binary.LittleEndian, header)
+	if err != nil {
+		return err
+	}
+
+	data := make([]int16, len(samples))
+	for i, sample := range samples {
+		data[i] = int16(math.Round(math.Max(-1, math.Min(sample, 1)) * math.MaxInt16))
+	}
+	return binary.Write(out, binary.LittleEndian, data)
+}
+
+// ReadWAV reads PCM WAV data with 8 or 16 bits per sample. Multiple channels are mixed into one. The samples are
+// returned in the range [-1..1] together with the sample rate.
+func ReadWAV(in io.Reader) ([]float64, int, error) {
+	var riff struct {
+		RIFF      [4]byte
+		ChunkSize uint32
+		WAVE      [4]byte
+	}
+	err := binary.Read(in, binary.LittleEndian, &riff)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", ErrInvalidWAV, err)
+	}
+	if string(riff.RIFF[:]) != "RIFF" || string(riff.WAVE[:]) != "WAVE" {
+		return nil, 0, fmt.Errorf("%w: missing RIFF/WAVE header", ErrInvalidWAV)
+	}
+
+	var format struct {
+		AudioFormat   uint16
+		Channels      uint16
+		SampleRate    uint32
+		ByteRate      uint32
+		BlockAlign    uint16
+		BitsPerSample uint16
+	}
+	formatFound := false
+	for {
+		var chunk struct {
+			ID   [4]byte
+			Size uint32
+		}
+		err := binary.Read(in, binary.LittleEndian, &chunk)
+		if err != nil {
+			return nil, 0, fmt.Errorf("%w: missing data chunk: %v", ErrInvalidWAV, err)
+		}
+
+		switch string(chunk.ID[:]) {
+		case "fmt ":
+			if chunk.Size < uint32(binary.Size(format)) {
+				return nil, 0, fmt.Errorf("%w: format chunk too short", ErrInvalidWAV)
+			}
+			err = binary.Read(in, binary.LittleEndian, &format)
+			if err != nil {
+				return nil, 0, fmt.Errorf("%w: %v", ErrInvalidWAV, err)
+			}
+			if format.AudioFormat != wavFormatPCM {
+				return nil, 0, fmt.Errorf("%w: unsupported audio format %d", ErrInvalidWAV, format.AudioFormat)
+			}
+			if format.Channels == 0 || (format.BitsPerSample != 8 && format.BitsPerSample != 16) {
+				return nil, 0, fmt.Errorf("%w: unsupported format with %d channels and %d bits per sample", ErrInvalidWAV, format.Channels, format.BitsPerSample)
+			}
+			formatFound = true
+			err = skipChunk(in, chunk.Size-uint32(binary.Size(format)), chunk.Size)
+		case "data":
+			if !formatFound {
+				return nil, 0, fmt.Errorf("%w: data before format", ErrInvalidWAV)
+			}
+			// Streaming writers cannot know the size in advance and leave it at the maximum, then the data continues
+			// until the end of the file. A truncated data chunk is accepted as well.
+			data := in
+			if chunk.Size != wavStreamingSize {
+				data = io.LimitReader(in, int64(chunk.Size))
+			}
+			body, err := io.ReadAll(data)
+			if err != nil {
+				return nil, 0, fmt.Errorf("%w: %v", ErrInvalidWAV, err)
+			}
+			frameSize := int(format.Channels) * int(format.BitsPerSample) / 8
+			result := make([]float64, len(body)/frameSize)
+			decodePCM(result, body, int(format.Channels), int(format.BitsPerSample))
+			return result, int(format.SampleRate), nil
+		default:
+			err = skipChunk(in, chunk.Size, chunk.Size)
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("%w: %v", ErrInvalidWAV, err)
+		}
+	}
+}
+
+// skipChunk skips the given number of remaining bytes of a chunk with the given size, including the padding byte of
+// chunks with an odd size.
+func skipChunk(in io.Reader, remaining uint32, size uint32) error {
+	_, err := io.CopyN(io.Discard, in, int64(remaining)+int64(size%2))
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// PCMReader reads the samples of a raw PCM stream without header, e.g. the output of arecord or rtl_fm. The samples
+// are encoded like in PCM WAV data: unsigned 8 bit or signed 16 bit little endian. Multiple channels are mixed into
+// one.
+type PCMReader struct {
+	in       io.Reader
+	channels int
+	bits     int
+	buffer   []byte
+	pending  int
+}
+
+// NewPCMReader returns a new PCMReader for the given stream with the given number of bits per sample (8 or 16) and
+// the given number of interleaved channels.
+func NewPCMReader(in io.Reader, bits int, channels int) (*PCMReader, error) {
+	if channels <= 0 || (bits != 8 && bits != 16) {
+		return nil, fmt.Errorf("unsupported PCM format with %d channels and %d bits per sample", channels, bits)
+	}
+	return &PCMReader{
+		in:       in,
+		channels: channels,
+		bits:     bits,
+	}, nil
+}
+
+// Read reads up to len(samples) samples in the range [-1..1] and returns the number of samples read. Like
+// io.Reader.Read, it returns the samples that are available without waiting for more. At the end of the stream,
+// Read returns io.EOF, an incomplete last frame is dropped.
+func (r *PCMReader) Read(samples []float64) (int, error) {
+	if len(samples) == 0 {
+		return 0, nil
+	}
+	frameSize := r.channels * r.bits / 8
+	size := len(samples) * frameSize
+	if len(r.buffer) < size {
+		buffer := make([]byte, size)
+		copy(buffer, r.buffer[:r.pending])
+		r.buffer = buffer
+	}
+
+	n, err := r.in.Read(r.buffer[r.pending:size])
+	n += r.pending
+	frames := n / frameSize
+	decodePCM(samples[:frames], r.buffer[:frames*frameSize], r.channels, r.bits)
+	r.pending = copy(r.buffer, r.buffer[frames*frameSize:n])
+	return frames, err
+}
+
+// decodePCM decodes the frames in the given data into the given samples. The samples must have room for all frames.
+func decodePCM(samples []float64, data []byte, channels int, bits int) {
+	bytesPerSample := bits / 8
+	frameSize := channels * bytesPerSample
+	for i := 0; i < len(data)/frameSize; i++ {
+		var sum float64
+		for c := 0; c < channels; c++ {
+			offset := i*frameSize + c*bytesPerSample
+			if bits == 8 {
+				sum += (float64(data[offset]) - 128) / 128
+			} else {
+				sum += float64(int16(binary.LittleEndian.Uint16(data[offset:]))) / math.MaxInt16
+			}
+		}
+		samples[i] = sum / float64(channels)
+	}
+}
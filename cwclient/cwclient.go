@@ -6,10 +6,20 @@ The client communicates with the cwdaemon server via UDP using the proprietary p
 To run the cwdaemon locally for testing use the following command line: "cwdaemon -yi -xs -n -d null"
 This will start the cwdaemon as foreground process listening on port 6789, its output is written to stdout and stderr.
 To kill the process, hit Ctrl+C.
+
+Without a real cwdaemon, the emulation from the cwdaemon package can be used (see also cmd/cwdaemon-sim).
+
+The progress of the output, PTT changes and the state of the connection are reported as events, see OnEvent.
+
+The client detects a lost connection through communication errors and missing replies. Optionally, it probes the
+server regularly while it is idle (see SetProbing, this is disabled by default). If the connection is lost, the
+client reconnects with an increasing backoff and applies the last speed, tone, weight and PTT delay again. How pending
+messages are handled during the outage is defined by the OutagePolicy, see SetReconnectBackoff and SetOutagePolicy.
 */
 package cwclient
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"math"
@@ -17,6 +27,8 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/ftl/hamradio/morse"
 )
 
 // Client is a client for the cwdaemon server application.
@@ -29,7 +41,27 @@ type Client struct {
 	sendBuffer     chan interface{}
 	disconnected   chan struct{}
 	sendQueue      *sendQueue
-}
+	progressLock   *sync.Mutex
+	dispatcher     *dispatcher
+	timing         morse.Timing
+	timingLock     *sync.RWMutex
+
+	healthLock     *sync.Mutex
+	connectionLost bool
+	lastReply      time.Time
+	probeID        int
+	probeSent      time.Time
+	probeInterval  time.Duration
+	probeTimeout   time.Duration
+	minBackoff     time.Duration
+	maxBackoff     time.Duration
+	outagePolicy   OutagePolicy
+	held           []*queuedMessage
+	settings       map[string]string
+}
+
+// defaultTiming reflects the default settings of the cwdaemon.
+var defaultTiming = morse.Timing{WPM: 24}
 
 // Soundsystem supported by the cwdaemon
 type Soundsystem string
@@ -61,6 +93,15 @@ func New(hostname string, port int) (*Client, error) {
 		receiveBuffer:  make([]byte, 32),
 		sendBuffer:     make(chan interface{}),
 		sendQueue:      newSendQueue(),
+		progressLock:   new(sync.Mutex),
+		dispatcher:     newDispatcher(),
+		timing:         defaultTiming,
+		timingLock:     new(sync.RWMutex),
+		healthLock:     new(sync.Mutex),
+		probeTimeout:   DefaultProbeTimeout,
+		minBackoff:     DefaultMinBackoff,
+		maxBackoff:     DefaultMaxBackoff,
+		settings:       make(map[string]string),
 	}
 
 	if port == 0 {
@@ -105,7 +146,16 @@ func (client *Client) Connect() error {
 	client.connection = connection
 	client.disconnected = make(chan struct{})
 
+	client.healthLock.Lock()
+	client.connectionLost = false
+	client.lastReply = time.Now()
+	client.probeSent = time.Time{}
+	client.held = nil
+	client.healthLock.Unlock()
+
 	go client.communicate()
+	go client.supervise(client.disconnected)
+	client.dispatcher.emit(Event{Type: Connected})
 
 	return nil
 }
@@ -114,59 +164,89 @@ func (client *Client) communicate() {
 	for {
 		select {
 		case _ = <-client.disconnected:
-			client.sendQueue.Reset()
+			client.abortAll()
 			return
 		case m := <-client.sendBuffer:
 			var err error
 			switch message := m.(type) {
 			case string:
 				err = client.send(message)
-			case syncText:
-				token := client.sendQueue.Enqueue()
-				err = client.send(fmt.Sprintf("\x1Bh%d", token), message.text)
+			case probe:
+				err = client.send(fmt.Sprintf("\x1B%s%d", probePrefix, message.id), " ")
+			case *queuedMessage:
+				if client.isConnectionLost() {
+					client.holdOrAbort(message)
+				} else {
+					client.transmit(message)
+				}
 			default:
 				panic(fmt.Errorf("unknown send message type: %T", m))
 			}
 			if err != nil {
-				log.Printf("Error sending %v: %T %v", m, err, err)
-				client.sendQueue.Reset()
-				// client.handleConnectionError()
+				log.Printf("Error sending %v: %v", m, err)
+				client.handleConnectionError(err)
 			}
 		default:
 			message, err := client.receive()
 			if err != nil {
-				log.Printf("Error receiving: %T %v", err, err)
-				// client.handleConnectionError()
+				if !client.IsConnected() {
+					continue
+				}
+				client.handleConnectionError(err)
+				time.Sleep(100 * time.Millisecond)
 				continue
 			}
 			if message == "" {
 				continue
 			}
+			client.handleReply()
 			switch {
+			case strings.HasPrefix(message, probePrefix):
+				// the reply to a probe, it only shows that the server is alive
 			case strings.HasPrefix(message, "h"):
-				var token int
-				_, err := fmt.Sscanf(message, "h%d", &token)
+				var id int
+				_, err := fmt.Sscanf(message, "h%d", &id)
 				if err != nil {
 					log.Printf("Error parsing sequence number %v: %v", message, err)
+					continue
 				}
-				client.sendQueue.Finish(token)
+				client.finish(id)
 			case message == "break":
-				finished, total := client.sendQueue.Current()
-				log.Printf("CW output aborted at %d/%d", finished, total)
-				client.sendQueue.Reset()
+				// the reply to the abort command, the pending messages were already discarded by Abort
 			}
 		}
 	}
 }
 
-type syncText struct {
-	text string
+// transmit sends the given message to the server. If this fails, the message is handled according to the outage policy.
+func (client *Client) transmit(message *queuedMessage) {
+	err := client.send(fmt.Sprintf("\x1Bh%d", message.id), message.text)
+	if err != nil {
+		log.Printf("Error sending %q: %v", message.text, err)
+		client.handleConnectionError(err)
+		client.holdOrAbort(message)
+		return
+	}
+	client.transmitted(message)
+}
+
+func (client *Client) currentConnection() *net.UDPConn {
+	client.connectionLock.Lock()
+	defer client.connectionLock.Unlock()
+	return client.connection
+}
+
+func (client *Client) isConnectionLost() bool {
+	client.healthLock.Lock()
+	defer client.healthLock.Unlock()
+	return client.connectionLost
 }
 
 func (client *Client) send(messages ...string) error {
+	connection := client.currentConnection()
 	for _, message := range messages {
 		buf := []byte(message)
-		_, err := client.connection.Write(buf)
+		_, err := connection.Write(buf)
 		if err != nil {
 			return err
 		}
@@ -175,8 +255,9 @@ func (client *Client) send(messages ...string) error {
 }
 
 func (client *Client) receive() (string, error) {
-	client.connection.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
-	n, err := client.connection.Read(client.receiveBuffer)
+	connection := client.currentConnection()
+	connection.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	n, err := connection.Read(client.receiveBuffer)
 	if err, ok := err.(net.Error); ok {
 		if err.Timeout() {
 			return "", nil
@@ -190,15 +271,7 @@ func (client *Client) receive() (string, error) {
 	return strings.TrimSpace(string(message)), nil
 }
 
-func (client *Client) handleConnectionError() {
-	select {
-	case <-client.disconnected:
-	default:
-		close(client.disconnected)
-	}
-}
-
-// Disconnect closes the connection between the client and the server.
+// Disconnect closes the connection between the client and the server. All pending messages are aborted.
 func (client *Client) Disconnect() {
 	select {
 	case <-client.disconnected:
@@ -208,12 +281,15 @@ func (client *Client) Disconnect() {
 	client.connectionLock.Lock()
 	defer client.connectionLock.Unlock()
 
-	client.connection.Close()
 	select {
 	case <-client.disconnected:
+		return
 	default:
 		close(client.disconnected)
 	}
+	client.connection.Close()
+	client.abortAll()
+	client.dispatcher.emit(Event{Type: Disconnected})
 }
 
 // IsConnected indicates if the client has an active connection to the server.
@@ -233,18 +309,41 @@ func (client *Client) IsIdle() bool {
 
 // Wait waits for all pending text to be output as CW.
 func (client *Client) Wait() {
-	for !client.IsIdle() && client.IsConnected() {
-		time.Sleep(100 * time.Millisecond)
+	client.WaitContext(context.Background())
+}
+
+// WaitContext waits for all pending text to be output as CW, until the given context is done or the client is
+// disconnected. If not all text was output, the error of the context or ErrNotConnected is returned.
+func (client *Client) WaitContext(ctx context.Context) error {
+	for {
+		idle, changed := client.sendQueue.State()
+		if idle {
+			return nil
+		}
+		select {
+		case <-changed:
+			if !client.IsConnected() {
+				return ErrNotConnected
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-client.disconnected:
+			return ErrNotConnected
+		}
 	}
 }
 
 func (client *Client) command(format string, values ...interface{}) {
 	command := fmt.Sprintf("\x1B%s", fmt.Sprintf(format, values...))
+	client.rememberSetting(command)
 	if !client.IsConnected() {
 		log.Printf("Cannot send command %q, the client is not connected. Reconnect and try again.", command)
 		return
 	}
-	client.sendBuffer <- command
+	select {
+	case client.sendBuffer <- command:
+	case <-client.disconnected:
+	}
 }
 
 // Reset resets the server to the default values:
@@ -258,12 +357,15 @@ func (client *Client) command(format string, values ...interface{}) {
 // device = parport0
 // sound device = console buzzer
 func (client *Client) Reset() {
+	client.setTiming(func(timing *morse.Timing) { *timing = defaultTiming })
+	client.forgetSettings()
 	client.command("0")
 }
 
 // Speed sets the speed to the given speed in WpM [5..60]
 func (client *Client) Speed(speed int) {
 	normalizedSpeed := int(math.Max(5, math.Min(float64(speed), 60)))
+	client.setTiming(func(timing *morse.Timing) { timing.WPM = normalizedSpeed })
 	client.command("2%d", normalizedSpeed)
 }
 
@@ -280,6 +382,7 @@ func (client *Client) ToneOff() {
 
 // Abort aborts the output of CW and discards all pending texts.
 func (client *Client) Abort() {
+	client.abortAll()
 	client.command("4")
 }
 
@@ -291,6 +394,7 @@ func (client *Client) Wordmode() {
 // Weight sets the weighting between dit and dah [-50..50].
 func (client *Client) Weight(weight int) {
 	normalizedWeight := int(math.Max(-50, math.Min(float64(weight), 50)))
+	client.setTiming(func(timing *morse.Timing) { timing.Weight = normalizedWeight })
 	client.command("7%d", normalizedWeight)
 }
 
@@ -306,6 +410,7 @@ func (client *Client) PTT(on bool) {
 		onAsInt = 1
 	}
 	client.command("a%d", onAsInt)
+	client.dispatcher.emit(Event{Type: PTTChanged, PTT: on})
 }
 
 // SSBSource sets the source for the SSB signal either to microphone or soundcard.
@@ -344,61 +449,335 @@ func (client *Client) Volume(volume int) {
 
 // Send sends the given text to the server to be output it as CW.
 func (client *Client) Send(text string) {
+	client.SendMessage(text)
+}
+
+// SendMessage sends the given text to the server to be output as CW and returns the ID of the message that is used in
+// the events. If the client is not connected, the text is discarded and 0 is returned.
+func (client *Client) SendMessage(text string) int {
 	if !client.IsConnected() {
 		log.Printf("Cannot send %q, the client is not connected. Reconnect and try again.", text)
+		return 0
 	}
 	if strings.HasPrefix(text, "\x1B") {
 		log.Panicf("Cannot send escape sequence %s, use the dedicated methods for that.", text[1:])
 	}
 
-	client.sendBuffer <- syncText{text}
+	message := client.sendQueue.Enqueue(text)
+	select {
+	case client.sendBuffer <- message:
+	case <-client.disconnected:
+		client.abortAll()
+	}
+	return message.id
+}
+
+func (client *Client) setTiming(set func(*morse.Timing)) {
+	client.timingLock.Lock()
+	defer client.timingLock.Unlock()
+	set(&client.timing)
+}
+
+// Estimate returns the time the server needs to output the given text as CW with the current speed and weight,
+// from the first key-down until the last key-up.
+func (client *Client) Estimate(text string) time.Duration {
+	client.timingLock.RLock()
+	defer client.timingLock.RUnlock()
+	return client.timing.Duration(text)
+}
+
+// transmitted starts the given message if it is the next one the server outputs.
+func (client *Client) transmitted(message *queuedMessage) {
+	client.progressLock.Lock()
+	defer client.progressLock.Unlock()
+
+	message.transmitted = true
+	client.startNext()
+}
+
+// startNext starts the next pending message. The progressLock must be held.
+func (client *Client) startNext() {
+	message := client.sendQueue.Head()
+	if message == nil || !message.transmitted || message.started {
+		return
+	}
+	client.start(message)
+}
+
+// start reports the start of the given message and estimates the progress of its output. The progressLock must be held.
+func (client *Client) start(message *queuedMessage) {
+	message.started = true
+	client.dispatcher.emit(Event{Type: MessageStarted, MessageID: message.id, Text: message.text})
+
+	symbols, _ := morse.Encode(message.text)
+	client.timingLock.RLock()
+	timeline := client.timing.Timeline(symbols)
+	client.timingLock.RUnlock()
+	message.symbols = timeline.Symbols
+	message.estimate = timeline.Duration
+	message.startTime = time.Now()
+	message.cancel = make(chan struct{})
+	go client.estimateProgress(message, message.startTime)
+}
+
+// estimateProgress reports the characters of the message according to the estimated timing of the output.
+func (client *Client) estimateProgress(message *queuedMessage, start time.Time) {
+	for i, symbol := range message.symbols {
+		timer := time.NewTimer(time.Until(start.Add(symbol.Start)))
+		select {
+		case <-timer.C:
+		case <-message.cancel:
+			timer.Stop()
+			return
+		}
+
+		client.progressLock.Lock()
+		client.echo(message, i+1)
+		client.progressLock.Unlock()
+	}
+}
+
+// echo reports the characters of the message up to the given count. The progressLock must be held.
+func (client *Client) echo(message *queuedMessage, count int) {
+	upperText := strings.ToUpper(message.text)
+	for ; message.echoed < count && message.echoed < len(message.symbols); message.echoed++ {
+		text := message.symbols[message.echoed].Symbol.Text
+		index := strings.Index(upperText[message.position:], text)
+		if index == -1 {
+			index = message.position
+		} else {
+			index += message.position
+			message.position = index + len(text)
+		}
+		client.dispatcher.emit(Event{Type: CharacterSent, MessageID: message.id, Text: text, Index: index})
+	}
 }
 
+// finish reports that the server finished the output of the message with the given ID, and all messages before.
+func (client *Client) finish(id int) {
+	client.progressLock.Lock()
+	defer client.progressLock.Unlock()
+
+	for _, message := range client.sendQueue.Finish(id) {
+		if !message.started {
+			client.start(message)
+		}
+		close(message.cancel)
+		client.echo(message, len(message.symbols))
+		client.dispatcher.emit(Event{Type: MessageFinished, MessageID: message.id, Text: message.text})
+	}
+	client.startNext()
+}
+
+// abortAll discards all pending messages.
+func (client *Client) abortAll() {
+	client.progressLock.Lock()
+	defer client.progressLock.Unlock()
+
+	for _, message := range client.sendQueue.Reset() {
+		if message.cancel != nil {
+			close(message.cancel)
+		}
+		client.dispatcher.emit(Event{Type: MessageAborted, MessageID: message.id, Text: message.text})
+	}
+}
+
+// abort discards the given message if it is still pending.
+func (client *Client) abort(message *queuedMessage) {
+	client.progressLock.Lock()
+	defer client.progressLock.Unlock()
+
+	if !client.sendQueue.Remove(message.id) {
+		return
+	}
+	if message.cancel != nil {
+		close(message.cancel)
+	}
+	client.dispatcher.emit(Event{Type: MessageAborted, MessageID: message.id, Text: message.text})
+}
+
+// abortTransmitted discards all pending messages that were already transmitted to the server.
+func (client *Client) abortTransmitted() {
+	client.progressLock.Lock()
+	defer client.progressLock.Unlock()
+
+	for _, message := range client.sendQueue.RemoveTransmitted() {
+		if message.cancel != nil {
+			close(message.cancel)
+		}
+		client.dispatcher.emit(Event{Type: MessageAborted, MessageID: message.id, Text: message.text})
+	}
+	client.startNext()
+}
+
+// holdTransmitted resets all pending messages that were already transmitted to the server and holds them for
+// transmission after the connection is recovered.
+func (client *Client) holdTransmitted() {
+	client.progressLock.Lock()
+	var resend []*queuedMessage
+	for _, message := range client.sendQueue.Pending() {
+		if !message.transmitted {
+			continue
+		}
+		if message.cancel != nil {
+			close(message.cancel)
+		}
+		message.transmitted = false
+		message.started = false
+		message.cancel = nil
+		message.symbols = nil
+		message.echoed = 0
+		message.position = 0
+		resend = append(resend, message)
+	}
+	client.progressLock.Unlock()
+
+	client.healthLock.Lock()
+	defer client.healthLock.Unlock()
+	client.held = append(resend, client.held...)
+}
+
+// queuedMessage is a text that is sent to the server for output as CW.
+type queuedMessage struct {
+	id          int
+	text        string
+	transmitted bool
+	started     bool
+	cancel      chan struct{}
+	symbols     []morse.SymbolTiming
+	echoed      int
+	position    int
+	startTime   time.Time
+	estimate    time.Duration
+}
+
+// sendQueue contains all messages that were not yet output by the server. The IDs of the messages are also the
+// sequence numbers that are reported by the server when a message is finished.
 type sendQueue struct {
-	queued, finished int
-	lock             *sync.RWMutex
+	lastID  int
+	pending []*queuedMessage
+	changed chan struct{}
+	lock    *sync.Mutex
 }
 
 func newSendQueue() *sendQueue {
 	return &sendQueue{
-		lock: new(sync.RWMutex),
+		changed: make(chan struct{}),
+		lock:    new(sync.Mutex),
 	}
 }
 
-func (q *sendQueue) Enqueue() int {
+// notifyChange wakes up all goroutines that wait for a change. The lock must be held.
+func (q *sendQueue) notifyChange() {
+	close(q.changed)
+	q.changed = make(chan struct{})
+}
+
+func (q *sendQueue) Enqueue(text string) *queuedMessage {
 	q.lock.Lock()
 	defer q.lock.Unlock()
 
-	q.queued++
-	return q.queued
+	q.lastID++
+	result := &queuedMessage{id: q.lastID, text: text}
+	q.pending = append(q.pending, result)
+	q.notifyChange()
+	return result
 }
 
-func (q *sendQueue) Finish(token int) {
+func (q *sendQueue) Head() *queuedMessage {
 	q.lock.Lock()
 	defer q.lock.Unlock()
 
-	if token > q.queued {
-		return
+	if len(q.pending) == 0 {
+		return nil
 	}
-	q.finished = token
+	return q.pending[0]
 }
 
-func (q *sendQueue) Reset() {
+// Finish removes the message with the given ID and all messages before from the queue and returns them.
+func (q *sendQueue) Finish(id int) []*queuedMessage {
 	q.lock.Lock()
 	defer q.lock.Unlock()
 
-	q.queued = 0
-	q.finished = 0
+	i := 0
+	for i < len(q.pending) && q.pending[i].id <= id {
+		i++
+	}
+	if i == 0 {
+		return nil
+	}
+	result := q.pending[:i]
+	q.pending = q.pending[i:]
+	q.notifyChange()
+	return result
 }
 
-func (q *sendQueue) Current() (int, int) {
-	q.lock.RLock()
-	defer q.lock.RUnlock()
-	return q.finished, q.queued
+// Reset removes all messages from the queue and returns them.
+func (q *sendQueue) Reset() []*queuedMessage {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	result := q.pending
+	q.pending = nil
+	if len(result) > 0 {
+		q.notifyChange()
+	}
+	return result
+}
+
+// Remove removes the message with the given ID from the queue and indicates if the message was pending.
+func (q *sendQueue) Remove(id int) bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	for i, message := range q.pending {
+		if message.id == id {
+			q.pending = append(q.pending[:i:i], q.pending[i+1:]...)
+			q.notifyChange()
+			return true
+		}
+	}
+	return false
+}
+
+// RemoveTransmitted removes all messages that were transmitted to the server from the queue and returns them.
+func (q *sendQueue) RemoveTransmitted() []*queuedMessage {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	var result []*queuedMessage
+	remaining := make([]*queuedMessage, 0, len(q.pending))
+	for _, message := range q.pending {
+		if message.transmitted {
+			result = append(result, message)
+		} else {
+			remaining = append(remaining, message)
+		}
+	}
+	q.pending = remaining
+	if len(result) > 0 {
+		q.notifyChange()
+	}
+	return result
+}
+
+// Pending returns a copy of the pending messages.
+func (q *sendQueue) Pending() []*queuedMessage {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	return append([]*queuedMessage(nil), q.pending...)
 }
 
 func (q *sendQueue) Idle() bool {
-	q.lock.RLock()
-	defer q.lock.RUnlock()
-	return q.queued == q.finished
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return len(q.pending) == 0
+}
+
+// State returns if the queue is idle and a channel that is closed on the next change of the queue.
+func (q *sendQueue) State() (bool, <-chan struct{}) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return len(q.pending) == 0, q.changed
 }
@@ -0,0 +1,388 @@
+package cwclient
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ftl/hamradio/cwdaemon"
+	"github.com/ftl/hamradio/morse"
+)
+
+func setupClient(t *testing.T, timeScale float64) (*Client, *cwdaemon.Server) {
+	t.Helper()
+	server, err := cwdaemon.Listen("127.0.0.1:0")
+	require.NoError(t, err)
+	server.SetTimeScale(timeScale)
+	t.Cleanup(func() { server.Close() })
+
+	client, err := New("127.0.0.1", server.Port())
+	require.NoError(t, err)
+	require.NoError(t, client.Connect())
+	t.Cleanup(client.Disconnect)
+
+	return client, server
+}
+
+func TestClient_SendAndWait(t *testing.T) {
+	client, server := setupClient(t, 0.05)
+
+	client.Speed(40)
+	client.Send("cq cq de dl1abc")
+	client.Send("test")
+	assert.False(t, client.IsIdle())
+
+	client.Wait()
+
+	assert.True(t, client.IsIdle())
+	assert.Equal(t, "CQ CQ DE DL1ABCTEST", server.Keyed())
+	assert.Equal(t, 40, server.Settings().Speed)
+}
+
+func TestClient_Settings(t *testing.T) {
+	client, server := setupClient(t, 0)
+
+	client.Speed(100)
+	client.Tone(600)
+	client.Weight(10)
+	client.PTT(true)
+	client.Send("e")
+	client.Wait()
+
+	settings := server.Settings()
+	assert.Equal(t, 60, settings.Speed)
+	assert.Equal(t, 600, settings.Tone)
+	assert.Equal(t, 10, settings.Weight)
+	assert.True(t, settings.PTT)
+
+	client.Reset()
+	client.Send("e")
+	client.Wait()
+	assert.Equal(t, cwdaemon.DefaultSettings, server.Settings())
+}
+
+func TestClient_Abort(t *testing.T) {
+	client, server := setupClient(t, 1)
+
+	client.Speed(60)
+	client.Send("paris paris paris paris paris")
+	time.Sleep(500 * time.Millisecond)
+	assert.False(t, client.IsIdle())
+
+	client.Abort()
+
+	assert.Eventually(t, client.IsIdle, time.Second, 10*time.Millisecond)
+	assert.Eventually(t, server.IsIdle, time.Second, 10*time.Millisecond)
+	assert.Less(t, len(server.Keyed()), len("PARIS PARIS PARIS PARIS PARIS"))
+}
+
+func TestClient_Estimate(t *testing.T) {
+	client, _ := setupClient(t, 1)
+	assert.Equal(t, 43*50*time.Millisecond, client.Estimate("paris"), "the default speed is 24 WpM")
+
+	client.Speed(60)
+	client.Weight(20)
+	estimate := client.Estimate("cq test")
+	assert.Equal(t, morse.Timing{WPM: 60, Weight: 20}.Duration("cq test"), estimate)
+
+	start := time.Now()
+	client.Send("cq test")
+	client.Wait()
+
+	assert.InDelta(t, float64(estimate), float64(time.Since(start)), float64(250*time.Millisecond))
+}
+
+func collectEvents(client *Client) <-chan Event {
+	result := make(chan Event, 100)
+	client.OnEvent(func(event Event) {
+		result <- event
+	})
+	return result
+}
+
+func nextEvent(t *testing.T, events <-chan Event) Event {
+	t.Helper()
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "no event")
+		return Event{}
+	}
+}
+
+func TestClient_MessageEvents(t *testing.T) {
+	client, _ := setupClient(t, 0.05)
+	events := collectEvents(client)
+
+	first := client.SendMessage("cq")
+	second := client.SendMessage("de <sk>")
+	client.Wait()
+
+	type expectedEvent struct {
+		Type      EventType
+		MessageID int
+		Text      string
+		Index     int
+	}
+	expected := []expectedEvent{
+		{MessageStarted, first, "cq", 0},
+		{CharacterSent, first, "C", 0},
+		{CharacterSent, first, "Q", 1},
+		{MessageFinished, first, "cq", 0},
+		{MessageStarted, second, "de <sk>", 0},
+		{CharacterSent, second, "D", 0},
+		{CharacterSent, second, "E", 1},
+		{CharacterSent, second, " ", 2},
+		{CharacterSent, second, "<SK>", 3},
+		{MessageFinished, second, "de <sk>", 0},
+	}
+	for _, e := range expected {
+		event := nextEvent(t, events)
+		assert.Equal(t, e, expectedEvent{event.Type, event.MessageID, event.Text, event.Index})
+		assert.False(t, event.Time.IsZero())
+	}
+}
+
+func TestClient_AbortEvents(t *testing.T) {
+	client, _ := setupClient(t, 1)
+	events := collectEvents(client)
+
+	client.Speed(60)
+	first := client.SendMessage("paris paris")
+	second := client.SendMessage("paris")
+	event := nextEvent(t, events)
+	assert.Equal(t, MessageStarted, event.Type)
+
+	client.Abort()
+
+	for event.Type != MessageAborted {
+		event = nextEvent(t, events)
+	}
+	assert.Equal(t, first, event.MessageID)
+	event = nextEvent(t, events)
+	assert.Equal(t, MessageAborted, event.Type)
+	assert.Equal(t, second, event.MessageID)
+	assert.True(t, client.IsIdle())
+}
+
+func TestClient_PTTEvent(t *testing.T) {
+	client, _ := setupClient(t, 0)
+	events := collectEvents(client)
+
+	client.PTT(true)
+	client.PTT(false)
+
+	event := nextEvent(t, events)
+	assert.Equal(t, PTTChanged, event.Type)
+	assert.True(t, event.PTT)
+	event = nextEvent(t, events)
+	assert.Equal(t, PTTChanged, event.Type)
+	assert.False(t, event.PTT)
+}
+
+func TestDispatcher_StopsWhenIdle(t *testing.T) {
+	d := newDispatcher()
+	events := make(chan Event, 10)
+	d.subscribe(func(event Event) { events <- event })
+
+	for i := 0; i < 3; i++ {
+		d.emit(Event{Type: MessageStarted, MessageID: i})
+		d.emit(Event{Type: MessageFinished, MessageID: i})
+		assert.Equal(t, MessageStarted, nextEvent(t, events).Type)
+		assert.Equal(t, MessageFinished, nextEvent(t, events).Type)
+
+		assert.Eventually(t, func() bool {
+			d.lock.Lock()
+			defer d.lock.Unlock()
+			return !d.running
+		}, time.Second, 10*time.Millisecond, "the dispatcher should stop when the queue is empty")
+	}
+}
+
+func TestClient_WaitContext(t *testing.T) {
+	client, _ := setupClient(t, 1)
+
+	client.Send("paris paris paris")
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err := client.WaitContext(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	client.Abort()
+	err = client.WaitContext(context.Background())
+	assert.NoError(t, err)
+
+	client.Send("paris paris paris")
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		client.Disconnect()
+	}()
+	err = client.WaitContext(context.Background())
+	assert.ErrorIs(t, err, ErrNotConnected)
+	assert.Eventually(t, client.IsIdle, time.Second, 10*time.Millisecond)
+}
+
+func TestClient_ConnectionEvents(t *testing.T) {
+	server, err := cwdaemon.Listen("127.0.0.1:0")
+	require.NoError(t, err)
+	port := server.Port()
+	client, err := New("127.0.0.1", port)
+	require.NoError(t, err)
+	events := collectEvents(client)
+
+	require.NoError(t, client.Connect())
+	t.Cleanup(client.Disconnect)
+	assert.Equal(t, Connected, nextEvent(t, events).Type)
+
+	server.Close()
+	client.Send("e")
+	event := nextEvent(t, events)
+	for event.Type != ConnectionLost {
+		event = nextEvent(t, events)
+	}
+	assert.Error(t, event.Err)
+	client.Abort()
+
+	server, err = cwdaemon.Listen(fmt.Sprintf("127.0.0.1:%d", port))
+	require.NoError(t, err)
+	t.Cleanup(func() { server.Close() })
+	server.SetTimeScale(0)
+	// the first datagrams may still fail because of the previous outage
+	assert.Eventually(t, func() bool {
+		client.Send("e")
+		for {
+			select {
+			case event := <-events:
+				if event.Type == Connected {
+					return true
+				}
+			default:
+				return false
+			}
+		}
+	}, 5*time.Second, 100*time.Millisecond)
+	client.Wait()
+
+	client.Disconnect()
+	for event.Type != Disconnected {
+		event = nextEvent(t, events)
+	}
+}
+
+func setupSupervisedClient(t *testing.T, policy OutagePolicy) (*Client, *cwdaemon.Server, <-chan Event) {
+	t.Helper()
+	client, server := setupClient(t, 0)
+	client.SetProbing(50*time.Millisecond, 200*time.Millisecond)
+	client.SetReconnectBackoff(20*time.Millisecond, 100*time.Millisecond)
+	client.SetOutagePolicy(policy)
+	events := collectEvents(client)
+	return client, server, events
+}
+
+func restartServer(t *testing.T, server *cwdaemon.Server) *cwdaemon.Server {
+	t.Helper()
+	server, err := cwdaemon.Listen(fmt.Sprintf("127.0.0.1:%d", server.Port()))
+	require.NoError(t, err)
+	server.SetTimeScale(0)
+	t.Cleanup(func() { server.Close() })
+	return server
+}
+
+func waitForEvent(t *testing.T, events <-chan Event, eventType EventType) Event {
+	t.Helper()
+	event := nextEvent(t, events)
+	for event.Type != eventType {
+		event = nextEvent(t, events)
+	}
+	return event
+}
+
+func TestClient_ProbeDetectsConnectionLoss(t *testing.T) {
+	client, server, events := setupSupervisedClient(t, AbortMessages)
+	assert.Eventually(t, func() bool { return !server.IsIdle() || server.Keyed() == " " }, time.Second, 10*time.Millisecond)
+	assert.True(t, client.IsHealthy())
+
+	server.Close()
+
+	event := waitForEvent(t, events, ConnectionLost)
+	assert.Error(t, event.Err)
+	assert.False(t, client.IsHealthy())
+	assert.True(t, client.IsConnected())
+}
+
+func TestClient_ReconnectAppliesSettings(t *testing.T) {
+	client, server, events := setupSupervisedClient(t, AbortMessages)
+	client.Speed(30)
+	client.Tone(700)
+	client.Weight(-10)
+	client.PTTDelay(20)
+	client.Volume(50)
+	client.Send("e")
+	client.Wait()
+
+	server.Close()
+	waitForEvent(t, events, ConnectionLost)
+	server = restartServer(t, server)
+	waitForEvent(t, events, Connected)
+
+	client.Send("e")
+	client.Wait()
+	settings := server.Settings()
+	assert.Equal(t, 30, settings.Speed)
+	assert.Equal(t, 700, settings.Tone)
+	assert.Equal(t, -10, settings.Weight)
+	assert.Equal(t, 20, settings.PTTDelay)
+	assert.Equal(t, cwdaemon.DefaultSettings.Volume, settings.Volume)
+	assert.True(t, client.IsHealthy())
+}
+
+func TestClient_OutagePolicy(t *testing.T) {
+	t.Run("abort", func(t *testing.T) {
+		client, server, events := setupSupervisedClient(t, AbortMessages)
+		server.Close()
+		waitForEvent(t, events, ConnectionLost)
+
+		id := client.SendMessage("test")
+
+		event := waitForEvent(t, events, MessageAborted)
+		assert.Equal(t, id, event.MessageID)
+		assert.True(t, client.IsIdle())
+	})
+	t.Run("hold", func(t *testing.T) {
+		client, server, events := setupSupervisedClient(t, HoldMessages)
+		server.Close()
+		waitForEvent(t, events, ConnectionLost)
+
+		id := client.SendMessage("test")
+		assert.False(t, client.IsIdle())
+		server = restartServer(t, server)
+		client.Wait()
+
+		event := waitForEvent(t, events, MessageFinished)
+		assert.Equal(t, id, event.MessageID)
+		assert.Contains(t, server.Keyed(), "TEST")
+	})
+	t.Run("resend", func(t *testing.T) {
+		client, server, events := setupSupervisedClient(t, ResendMessages)
+		server.SetTimeScale(1)
+		// without traffic, the loss is only detected when the reply to the message is overdue
+		id := client.SendMessage("test")
+		waitForEvent(t, events, MessageStarted)
+		server.Close()
+		waitForEvent(t, events, ConnectionLost)
+
+		server = restartServer(t, server)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		require.NoError(t, client.WaitContext(ctx))
+
+		event := waitForEvent(t, events, MessageFinished)
+		assert.Equal(t, id, event.MessageID)
+		assert.Contains(t, server.Keyed(), "TEST")
+	})
+}
@@ -0,0 +1,139 @@
+package cwclient
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrNotConnected is returned by WaitContext if the client is not connected to the server.
+var ErrNotConnected = errors.New("not connected to cwdaemon")
+
+// EventType describes what happened.
+type EventType int
+
+// The types of events.
+const (
+	// MessageStarted: the server started to output the message as CW.
+	MessageStarted EventType = iota
+	// CharacterSent: the server outputs the next character of the message. The cwdaemon does not report the single
+	// characters, they are estimated from the current speed and weight.
+	CharacterSent
+	// MessageFinished: the server finished the output of the message.
+	MessageFinished
+	// MessageAborted: the message was discarded, either because of Abort or because the connection was closed.
+	MessageAborted
+	// PTTChanged: the PTT was switched on or off.
+	PTTChanged
+	// Connected: the connection to the server was established or recovered after a loss.
+	Connected
+	// ConnectionLost: the communication with the server failed.
+	ConnectionLost
+	// Disconnected: the connection was closed.
+	Disconnected
+)
+
+func (t EventType) String() string {
+	switch t {
+	case MessageStarted:
+		return "message started"
+	case CharacterSent:
+		return "character sent"
+	case MessageFinished:
+		return "message finished"
+	case MessageAborted:
+		return "message aborted"
+	case PTTChanged:
+		return "PTT changed"
+	case Connected:
+		return "connected"
+	case ConnectionLost:
+		return "connection lost"
+	case Disconnected:
+		return "disconnected"
+	default:
+		return "unknown"
+	}
+}
+
+// Event is reported to the registered event handlers.
+type Event struct {
+	Type EventType
+	Time time.Time
+	// MessageID identifies the message, as returned by SendMessage.
+	MessageID int
+	// Text is the message, or the character for CharacterSent.
+	Text string
+	// Index is the byte offset of the character in the message for CharacterSent.
+	Index int
+	// PTT is the new state of the PTT for PTTChanged.
+	PTT bool
+	// Err is the cause of a ConnectionLost event.
+	Err error
+}
+
+// EventHandler is notified about events of the client.
+type EventHandler func(Event)
+
+// dispatcher delivers the events to the handlers in order, on a separate goroutine. This way the handlers may call
+// the client without blocking its communication. The goroutine only runs while events are pending, so nothing is left
+// behind when the client is not used anymore.
+type dispatcher struct {
+	lock     *sync.Mutex
+	handlers []EventHandler
+	queue    []Event
+	running  bool
+}
+
+func newDispatcher() *dispatcher {
+	return &dispatcher{
+		lock: new(sync.Mutex),
+	}
+}
+
+func (d *dispatcher) subscribe(handler EventHandler) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.handlers = append(d.handlers, handler)
+}
+
+func (d *dispatcher) emit(event Event) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if len(d.handlers) == 0 {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	d.queue = append(d.queue, event)
+	if !d.running {
+		d.running = true
+		go d.run()
+	}
+}
+
+func (d *dispatcher) run() {
+	for {
+		d.lock.Lock()
+		if len(d.queue) == 0 {
+			d.running = false
+			d.lock.Unlock()
+			return
+		}
+		event := d.queue[0]
+		d.queue = d.queue[1:]
+		handlers := d.handlers
+		d.lock.Unlock()
+
+		for _, handler := range handlers {
+			handler(event)
+		}
+	}
+}
+
+// OnEvent registers the given handler to be notified about the events of the client. The handlers are called
+// one after the other on a separate goroutine, in the order of the events.
+func (client *Client) OnEvent(handler EventHandler) {
+	client.dispatcher.subscribe(handler)
+}
@@ -0,0 +1,283 @@
+package cwclient
+
+import (
+	"errors"
+	"log"
+	"net"
+	"strings"
+	"time"
+)
+
+// OutagePolicy defines how the client handles messages while the connection to the server is lost.
+type OutagePolicy int
+
+// The outage policies.
+const (
+	// AbortMessages discards all pending messages when the connection is lost, and all messages that are sent
+	// during the outage.
+	AbortMessages OutagePolicy = iota
+	// HoldMessages discards the messages that were already transmitted to the server, but keeps the messages that are
+	// sent during the outage and transmits them after the connection is recovered.
+	HoldMessages
+	// ResendMessages keeps all unfinished messages and the messages that are sent during the outage and transmits
+	// them after the connection is recovered. Messages may be output twice if the server received them before.
+	ResendMessages
+)
+
+// Default values of the health checking. Probing is disabled by default, see SetProbing.
+const (
+	DefaultProbeTimeout = 2 * time.Second
+	DefaultMinBackoff   = 500 * time.Millisecond
+	DefaultMaxBackoff   = 30 * time.Second
+)
+
+// ErrNoReply is reported with ConnectionLost if the server does not reply in time.
+var ErrNoReply = errors.New("no reply from cwdaemon")
+
+// supervisionTick is the interval in which the supervisor checks the health of the connection.
+const supervisionTick = 100 * time.Millisecond
+
+// probePrefix identifies the replies to probes, the replies to messages contain only the ID of the message.
+const probePrefix = "hp"
+
+// settingCommands are the commands whose values are applied again after the connection was recovered.
+var settingCommands = []string{"2", "3", "7", "d"}
+
+type probe struct {
+	id int
+}
+
+// SetProbing enables the liveness probes with the given interval and sets the time to wait for a reply. If the
+// timeout is 0, DefaultProbeTimeout is used. The probes are only sent while the client is idle and did not receive
+// anything from the server within the interval. An interval of 0 disables the probes, which is the default; then only
+// communication errors and missing replies to messages are detected.
+//
+// A probe is a request for a reply followed by a single space. cwdaemon handles it like any other message: it may
+// key the PTT of the transmitter, including the PTT delay, although nothing audible is sent. Only enable probing if
+// this is acceptable for the connected transmitter.
+func (client *Client) SetProbing(interval, timeout time.Duration) {
+	if timeout <= 0 {
+		timeout = DefaultProbeTimeout
+	}
+	client.healthLock.Lock()
+	defer client.healthLock.Unlock()
+	client.probeInterval = interval
+	client.probeTimeout = timeout
+}
+
+// SetReconnectBackoff sets the minimum and maximum time between two attempts to recover a lost connection. The time
+// is doubled after each failed attempt.
+func (client *Client) SetReconnectBackoff(min, max time.Duration) {
+	client.healthLock.Lock()
+	defer client.healthLock.Unlock()
+	client.minBackoff = min
+	client.maxBackoff = max
+}
+
+// SetOutagePolicy defines how the client handles messages while the connection to the server is lost.
+func (client *Client) SetOutagePolicy(policy OutagePolicy) {
+	client.healthLock.Lock()
+	defer client.healthLock.Unlock()
+	client.outagePolicy = policy
+}
+
+// IsHealthy indicates if the client is connected and the server replies.
+func (client *Client) IsHealthy() bool {
+	client.healthLock.Lock()
+	defer client.healthLock.Unlock()
+	return client.IsConnected() && !client.connectionLost
+}
+
+// supervise checks the health of the connection regularly and tries to recover a lost connection.
+func (client *Client) supervise(disconnected chan struct{}) {
+	var backoff time.Duration
+	wait := supervisionTick
+	for {
+		timer := time.NewTimer(wait)
+		select {
+		case <-disconnected:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		client.healthLock.Lock()
+		lost := client.connectionLost
+		probeSent := client.probeSent
+		lastReply := client.lastReply
+		interval := client.probeInterval
+		timeout := client.probeTimeout
+		minBackoff := client.minBackoff
+		maxBackoff := client.maxBackoff
+		client.healthLock.Unlock()
+
+		if lost {
+			err := client.redial()
+			if err != nil {
+				log.Printf("Cannot reconnect to cwdaemon: %v", err)
+			}
+			client.probe(disconnected)
+			if backoff == 0 {
+				backoff = minBackoff
+			}
+			wait = backoff
+			backoff *= 2
+			if backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+			continue
+		}
+		backoff = 0
+		wait = supervisionTick
+
+		switch {
+		case timeout > 0 && !probeSent.IsZero() && time.Since(probeSent) > timeout:
+			client.handleConnectionError(ErrNoReply)
+			wait = 0
+		case timeout > 0 && client.replyOverdue(timeout):
+			client.handleConnectionError(ErrNoReply)
+			wait = 0
+		case interval > 0 && probeSent.IsZero() && time.Since(lastReply) >= interval && client.IsIdle():
+			client.probe(disconnected)
+		}
+	}
+}
+
+// replyOverdue indicates if the server should have finished the current message long ago.
+func (client *Client) replyOverdue(timeout time.Duration) bool {
+	client.progressLock.Lock()
+	defer client.progressLock.Unlock()
+	message := client.sendQueue.Head()
+	if message == nil || !message.started {
+		return false
+	}
+	return time.Since(message.startTime) > 2*message.estimate+timeout
+}
+
+// probe asks the server for a reply.
+func (client *Client) probe(disconnected chan struct{}) {
+	client.healthLock.Lock()
+	client.probeID++
+	p := probe{id: client.probeID}
+	client.probeSent = time.Now()
+	client.healthLock.Unlock()
+
+	select {
+	case client.sendBuffer <- p:
+	case <-disconnected:
+	}
+}
+
+// redial replaces the connection with a new one.
+func (client *Client) redial() error {
+	client.connectionLock.Lock()
+	defer client.connectionLock.Unlock()
+	if !client.IsConnected() {
+		return nil
+	}
+
+	connection, err := net.DialUDP("udp", client.localAddr, client.remoteAddr)
+	if err != nil {
+		return err
+	}
+	client.connection.Close()
+	client.connection = connection
+	return nil
+}
+
+// handleConnectionError reports the first of a series of communication errors and handles the pending messages
+// according to the outage policy.
+func (client *Client) handleConnectionError(err error) {
+	client.healthLock.Lock()
+	if client.connectionLost {
+		client.healthLock.Unlock()
+		return
+	}
+	log.Printf("Error communicating with cwdaemon: %v", err)
+	client.connectionLost = true
+	client.probeSent = time.Time{}
+	policy := client.outagePolicy
+	client.healthLock.Unlock()
+
+	client.dispatcher.emit(Event{Type: ConnectionLost, Err: err})
+
+	switch policy {
+	case AbortMessages:
+		client.abortAll()
+	case HoldMessages:
+		client.abortTransmitted()
+	case ResendMessages:
+		client.holdTransmitted()
+	}
+}
+
+// handleReply notes that the server is alive. If the connection was lost before, the last settings and the held
+// messages are transmitted again. handleReply is only called by the communicate goroutine.
+func (client *Client) handleReply() {
+	client.healthLock.Lock()
+	client.lastReply = time.Now()
+	client.probeSent = time.Time{}
+	if !client.connectionLost {
+		client.healthLock.Unlock()
+		return
+	}
+	client.connectionLost = false
+	held := client.held
+	client.held = nil
+	settings := make([]string, 0, len(settingCommands))
+	for _, command := range settingCommands {
+		if value, ok := client.settings[command]; ok {
+			settings = append(settings, value)
+		}
+	}
+	client.healthLock.Unlock()
+
+	client.dispatcher.emit(Event{Type: Connected})
+
+	for _, setting := range settings {
+		err := client.send(setting)
+		if err != nil {
+			client.handleConnectionError(err)
+			for _, message := range held {
+				client.holdOrAbort(message)
+			}
+			return
+		}
+	}
+	for _, message := range held {
+		client.transmit(message)
+	}
+}
+
+// holdOrAbort handles a message that is sent while the connection is lost.
+func (client *Client) holdOrAbort(message *queuedMessage) {
+	client.healthLock.Lock()
+	hold := client.outagePolicy != AbortMessages
+	if hold {
+		client.held = append(client.held, message)
+	}
+	client.healthLock.Unlock()
+
+	if !hold {
+		client.abort(message)
+	}
+}
+
+// rememberSetting stores the given command if it changes one of the settings that are applied again after the
+// connection was recovered.
+func (client *Client) rememberSetting(command string) {
+	client.healthLock.Lock()
+	defer client.healthLock.Unlock()
+	for _, prefix := range settingCommands {
+		if strings.HasPrefix(command[1:], prefix) {
+			client.settings[prefix] = command
+			return
+		}
+	}
+}
+
+func (client *Client) forgetSettings() {
+	client.healthLock.Lock()
+	defer client.healthLock.Unlock()
+	client.settings = make(map[string]string)
+}
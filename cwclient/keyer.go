@@ -0,0 +1,22 @@
+package cwclient
+
+// Keyer outputs text as CW. It is implemented by the Client for the cwdaemon and by other keyers like the WinKeyer
+// (see package winkeyer).
+type Keyer interface {
+	// Send queues the given text for output as CW.
+	Send(text string)
+	// Speed sets the speed in WpM for the following output.
+	Speed(speed int)
+	// Abort aborts the output of CW and discards all pending texts.
+	Abort()
+	// PTT enables or disables the PTT keying.
+	PTT(on bool)
+	// Tune keys down for the given duration in seconds.
+	Tune(seconds int)
+	// Wait waits for all pending text to be output as CW.
+	Wait()
+	// IsIdle returns true if there are no texts waiting for output as CW.
+	IsIdle() bool
+}
+
+var _ Keyer = (*Client)(nil)
@@ -0,0 +1,490 @@
+/*
+Package cwdaemon implements an emulation of the cwdaemon (https://github.com/acerion/cwdaemon) server application.
+
+The Server speaks the UDP protocol of the cwdaemon: datagrams that start with an escape character (0x1B) are commands,
+all other datagrams contain text that is output as CW. Instead of keying a transmitter, the Server only simulates the
+timing of the CW output and reports what it would have keyed. It can be used in-process as test double for the
+cwclient package or as a standalone simulator (see cmd/cwdaemon-sim). The timing follows the morse package, text
+may contain prosigns like <AR> or <SK>.
+
+The following commands are supported:
+
+	<ESC>0          reset all settings to their default values
+	<ESC>2<wpm>     speed in WpM [5..60]
+	<ESC>3<hz>      sidetone frequency in Hz [0..4000], 0 turns the sidetone off
+	<ESC>4          abort the current output and discard all pending texts, an outstanding reply is answered with "break"
+	<ESC>6          word mode
+	<ESC>7<weight>  weight [-50..50]
+	<ESC>8<device>  keying device
+	<ESC>a<0|1>     PTT off/on
+	<ESC>b<0|1>     SSB source
+	<ESC>c<seconds> tune for the given duration [0..10]
+	<ESC>d<ms>      PTT delay [0..50]
+	<ESC>e<index>   band index
+	<ESC>f<system>  sound system
+	<ESC>g<volume>  volume [0..100]
+	<ESC>h<text>    reply with "h<text>" when the next text is completely keyed
+
+The exit command (<ESC>5) is ignored.
+*/
+package cwdaemon
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ftl/hamradio/morse"
+)
+
+// DefaultPort is the default UDP port of the cwdaemon.
+const DefaultPort = 6789
+
+// receiveRetryDelay is the time to wait before receiving again after a failed read from the UDP connection.
+const receiveRetryDelay = 100 * time.Millisecond
+
+// Settings contains the current settings of the emulated cwdaemon.
+type Settings struct {
+	Speed       int
+	Tone        int
+	Weight      int
+	Volume      int
+	WordMode    bool
+	PTT         bool
+	PTTDelay    int
+	Device      string
+	Soundsystem string
+	SSBSource   int
+	BandIndex   int
+}
+
+// DefaultSettings are the settings of the cwdaemon after start and after a reset.
+var DefaultSettings = Settings{
+	Speed:       24,
+	Tone:        800,
+	Volume:      70,
+	Device:      "parport0",
+	Soundsystem: "c",
+}
+
+// EventType describes what happened in the emulated cwdaemon.
+type EventType int
+
+// The event types.
+const (
+	// CommandReceived is reported for every command, Event.Text contains the command without the escape character.
+	CommandReceived EventType = iota
+	// TextKeyed is reported when a text is completely keyed, Event.Duration contains the keying time.
+	TextKeyed
+	// Aborted is reported when the output is aborted, Event.Text contains the part of the text that was keyed.
+	Aborted
+	// Tuned is reported when the tuning is finished, Event.Duration contains the tuning time.
+	Tuned
+)
+
+func (t EventType) String() string {
+	switch t {
+	case CommandReceived:
+		return "command"
+	case TextKeyed:
+		return "keyed"
+	case Aborted:
+		return "aborted"
+	case Tuned:
+		return "tuned"
+	default:
+		return fmt.Sprintf("EventType(%d)", int(t))
+	}
+}
+
+// Event describes something that happened in the emulated cwdaemon.
+type Event struct {
+	Time     time.Time
+	Type     EventType
+	Text     string
+	Speed    int
+	Duration time.Duration
+}
+
+// EventHandler is notified about events of the emulated cwdaemon.
+type EventHandler func(Event)
+
+type job struct {
+	text  string
+	reply string
+	tune  time.Duration
+}
+
+// Server emulates a cwdaemon server.
+type Server struct {
+	connection *net.UDPConn
+
+	lock         sync.Mutex
+	settings     Settings
+	timeScale    float64
+	pendingReply string
+	currentReply string
+	queue        []job
+	wakeup       chan struct{}
+	aborted      chan struct{}
+	keying       bool
+	keyed        strings.Builder
+	remoteAddr   *net.UDPAddr
+	handlers     []EventHandler
+
+	closed    chan struct{}
+	closeOnce sync.Once
+	waiter    sync.WaitGroup
+}
+
+// Listen starts a new emulated cwdaemon server that listens on the given UDP address (e.g. "localhost:6789").
+// Use port 0 to listen on a random free port.
+func Listen(address string) (*Server, error) {
+	udpAddr, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		return nil, err
+	}
+	connection, err := net.ListenUDP("udp", udpAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Server{
+		connection: connection,
+		settings:   DefaultSettings,
+		timeScale:  1,
+		wakeup:     make(chan struct{}, 1),
+		aborted:    make(chan struct{}),
+		closed:     make(chan struct{}),
+	}
+
+	result.waiter.Add(2)
+	go result.receive()
+	go result.key()
+
+	return result, nil
+}
+
+// Addr returns the local address of the server.
+func (s *Server) Addr() *net.UDPAddr {
+	return s.connection.LocalAddr().(*net.UDPAddr)
+}
+
+// Port returns the local UDP port of the server.
+func (s *Server) Port() int {
+	return s.Addr().Port
+}
+
+// Close stops the server.
+func (s *Server) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.closed)
+		err = s.connection.Close()
+	})
+	s.waiter.Wait()
+	return err
+}
+
+// SetTimeScale scales the simulated keying time: 1 is real time, 0.5 is twice as fast, 0 outputs everything
+// immediately.
+func (s *Server) SetTimeScale(timeScale float64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if timeScale < 0 {
+		timeScale = 0
+	}
+	s.timeScale = timeScale
+}
+
+// OnEvent registers the given handler to be notified about events of the server. The handlers are called
+// synchronously from the goroutines of the server.
+func (s *Server) OnEvent(handler EventHandler) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.handlers = append(s.handlers, handler)
+}
+
+// Settings returns the current settings of the server.
+func (s *Server) Settings() Settings {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.settings
+}
+
+// Keyed returns all text that was keyed so far.
+func (s *Server) Keyed() string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.keyed.String()
+}
+
+// IsIdle returns true if the server does not key and has no pending texts.
+func (s *Server) IsIdle() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return !s.keying && len(s.queue) == 0
+}
+
+func (s *Server) emit(event Event) {
+	s.lock.Lock()
+	handlers := make([]EventHandler, len(s.handlers))
+	copy(handlers, s.handlers)
+	s.lock.Unlock()
+
+	event.Time = time.Now()
+	for _, handler := range handlers {
+		handler(event)
+	}
+}
+
+func (s *Server) receive() {
+	defer s.waiter.Done()
+	buf := make([]byte, 1024)
+	for {
+		n, addr, err := s.connection.ReadFromUDP(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			log.Printf("Error receiving from UDP: %v", err)
+			select {
+			case <-s.closed:
+				return
+			case <-time.After(receiveRetryDelay):
+				continue
+			}
+		}
+		s.lock.Lock()
+		s.remoteAddr = addr
+		s.lock.Unlock()
+
+		message := string(buf[:n])
+		if strings.HasPrefix(message, "\x1B") {
+			s.command(strings.TrimSpace(message[1:]))
+		} else {
+			s.enqueue(job{text: message})
+		}
+	}
+}
+
+func (s *Server) reply(message string) {
+	s.lock.Lock()
+	addr := s.remoteAddr
+	s.lock.Unlock()
+	if addr == nil {
+		return
+	}
+	s.connection.WriteToUDP([]byte(message+"\r\n"), addr)
+}
+
+func (s *Server) command(command string) {
+	if command == "" {
+		return
+	}
+	s.emit(Event{Type: CommandReceived, Text: command})
+
+	name := command[0]
+	value := command[1:]
+	switch name {
+	case '0':
+		s.abort()
+		s.lock.Lock()
+		s.settings = DefaultSettings
+		s.lock.Unlock()
+	case '2':
+		s.setInt(value, 5, 60, func(settings *Settings, v int) { settings.Speed = v })
+	case '3':
+		s.setInt(value, 0, 4000, func(settings *Settings, v int) { settings.Tone = v })
+	case '4':
+		s.abort()
+	case '6':
+		s.lock.Lock()
+		s.settings.WordMode = true
+		s.lock.Unlock()
+	case '7':
+		s.setInt(value, -50, 50, func(settings *Settings, v int) { settings.Weight = v })
+	case '8':
+		s.lock.Lock()
+		s.settings.Device = value
+		s.lock.Unlock()
+	case 'a':
+		s.setInt(value, 0, 1, func(settings *Settings, v int) { settings.PTT = v == 1 })
+	case 'b':
+		s.setInt(value, 0, 1, func(settings *Settings, v int) { settings.SSBSource = v })
+	case 'c':
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds < 0 || seconds > 10 {
+			return
+		}
+		s.enqueue(job{tune: time.Duration(seconds) * time.Second})
+	case 'd':
+		s.setInt(value, 0, 50, func(settings *Settings, v int) { settings.PTTDelay = v })
+	case 'e':
+		s.setInt(value, 0, 64, func(settings *Settings, v int) { settings.BandIndex = v })
+	case 'f':
+		s.lock.Lock()
+		s.settings.Soundsystem = value
+		s.lock.Unlock()
+	case 'g':
+		s.setInt(value, 0, 100, func(settings *Settings, v int) { settings.Volume = v })
+	case 'h':
+		s.lock.Lock()
+		s.pendingReply = "h" + value
+		s.lock.Unlock()
+	}
+}
+
+func (s *Server) setInt(value string, min, max int, set func(*Settings, int)) {
+	v, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || v < min || v > max {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	set(&s.settings, v)
+}
+
+func (s *Server) enqueue(j job) {
+	s.lock.Lock()
+	if j.tune == 0 {
+		j.reply = s.pendingReply
+		s.pendingReply = ""
+	}
+	s.queue = append(s.queue, j)
+	s.lock.Unlock()
+
+	select {
+	case s.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+// abort discards the current and all pending texts. Like the real cwdaemon, it answers with "break" only if a reply
+// was requested and not yet sent.
+func (s *Server) abort() {
+	s.lock.Lock()
+	replyOutstanding := s.pendingReply != "" || s.currentReply != ""
+	for _, j := range s.queue {
+		replyOutstanding = replyOutstanding || j.reply != ""
+	}
+	s.queue = nil
+	s.pendingReply = ""
+	s.currentReply = ""
+	close(s.aborted)
+	s.aborted = make(chan struct{})
+	s.lock.Unlock()
+
+	if replyOutstanding {
+		s.reply("break")
+	}
+}
+
+func (s *Server) next() (job, chan struct{}, bool) {
+	for {
+		s.lock.Lock()
+		if len(s.queue) > 0 {
+			j := s.queue[0]
+			s.queue = s.queue[1:]
+			s.keying = true
+			s.currentReply = j.reply
+			aborted := s.aborted
+			s.lock.Unlock()
+			return j, aborted, true
+		}
+		s.lock.Unlock()
+
+		select {
+		case <-s.closed:
+			return job{}, nil, false
+		case <-s.wakeup:
+		}
+	}
+}
+
+func (s *Server) finish() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.keying = false
+	s.currentReply = ""
+}
+
+func (s *Server) key() {
+	defer s.waiter.Done()
+	for {
+		j, aborted, ok := s.next()
+		if !ok {
+			return
+		}
+
+		if j.tune > 0 {
+			start := time.Now()
+			s.wait(j.tune, aborted)
+			s.finish()
+			s.emit(Event{Type: Tuned, Duration: time.Since(start)})
+			continue
+		}
+
+		s.keyText(j, aborted)
+	}
+}
+
+func (s *Server) keyText(j job, aborted chan struct{}) {
+	start := time.Now()
+	var keyed strings.Builder
+	settings := s.Settings()
+	timing := morse.Timing{WPM: settings.Speed, Weight: settings.Weight}
+	symbols, _ := morse.Encode(j.text)
+	for _, symbol := range timing.Timeline(symbols).Symbols {
+		if !s.wait(symbol.End-symbol.Start, aborted) {
+			s.finish()
+			s.emit(Event{Type: Aborted, Text: keyed.String(), Speed: settings.Speed, Duration: time.Since(start)})
+			return
+		}
+		keyed.WriteString(symbol.Symbol.Text)
+		s.lock.Lock()
+		s.keyed.WriteString(symbol.Symbol.Text)
+		s.lock.Unlock()
+	}
+	s.finish()
+
+	if j.reply != "" {
+		s.reply(j.reply)
+	}
+	s.emit(Event{Type: TextKeyed, Text: keyed.String(), Speed: settings.Speed, Duration: time.Since(start)})
+}
+
+// wait waits for the given duration, scaled by the time scale. It returns false if the output was aborted or the
+// server was closed in the meantime.
+func (s *Server) wait(duration time.Duration, aborted chan struct{}) bool {
+	s.lock.Lock()
+	scaled := time.Duration(float64(duration) * s.timeScale)
+	s.lock.Unlock()
+
+	select {
+	case <-aborted:
+		return false
+	case <-s.closed:
+		return false
+	default:
+	}
+	if scaled <= 0 {
+		return true
+	}
+
+	timer := time.NewTimer(scaled)
+	defer timer.Stop()
+	select {
+	case <-aborted:
+		return false
+	case <-s.closed:
+		return false
+	case <-timer.C:
+		return true
+	}
+}
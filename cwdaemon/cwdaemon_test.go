@@ -0,0 +1,142 @@
+package cwdaemon
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupServer(t *testing.T, timeScale float64) (*Server, *net.UDPConn) {
+	t.Helper()
+	server, err := Listen("127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { server.Close() })
+	server.SetTimeScale(timeScale)
+
+	connection, err := net.DialUDP("udp", nil, server.Addr())
+	require.NoError(t, err)
+	t.Cleanup(func() { connection.Close() })
+	return server, connection
+}
+
+func sendMessages(t *testing.T, connection *net.UDPConn, messages ...string) {
+	t.Helper()
+	for _, message := range messages {
+		_, err := connection.Write([]byte(message))
+		require.NoError(t, err)
+	}
+}
+
+func readReply(t *testing.T, connection *net.UDPConn, timeout time.Duration) string {
+	t.Helper()
+	buf := make([]byte, 64)
+	connection.SetReadDeadline(time.Now().Add(timeout))
+	n, err := connection.Read(buf)
+	require.NoError(t, err)
+	return string(buf[:n])
+}
+
+func TestServer_Settings(t *testing.T) {
+	server, connection := setupServer(t, 0)
+	commands := make(chan string, 20)
+	server.OnEvent(func(event Event) {
+		if event.Type == CommandReceived {
+			commands <- event.Text
+		}
+	})
+
+	sendMessages(t, connection, "\x1B230", "\x1B3600", "\x1B7-10", "\x1Ba1", "\x1Bd20", "\x1Bg50", "\x1B8ttyS0", "\x1B299")
+	for i := 0; i < 8; i++ {
+		<-commands
+	}
+
+	settings := server.Settings()
+	assert.Equal(t, 30, settings.Speed, "invalid values are ignored")
+	assert.Equal(t, 600, settings.Tone)
+	assert.Equal(t, -10, settings.Weight)
+	assert.True(t, settings.PTT)
+	assert.Equal(t, 20, settings.PTTDelay)
+	assert.Equal(t, 50, settings.Volume)
+	assert.Equal(t, "ttyS0", settings.Device)
+
+	sendMessages(t, connection, "\x1B0")
+	<-commands
+	assert.Equal(t, DefaultSettings, server.Settings())
+}
+
+func TestServer_Reply(t *testing.T) {
+	server, connection := setupServer(t, 0.1)
+	events := make(chan Event, 10)
+	server.OnEvent(func(event Event) {
+		if event.Type == TextKeyed {
+			events <- event
+		}
+	})
+
+	sendMessages(t, connection, "\x1B260", "\x1Bh1", "cq test", "\x1Bh2", "de dl1abc")
+
+	assert.Equal(t, "h1\r\n", readReply(t, connection, time.Second))
+	assert.Equal(t, "h2\r\n", readReply(t, connection, time.Second))
+	assert.Equal(t, "CQ TESTDE DL1ABC", server.Keyed())
+	assert.True(t, server.IsIdle())
+
+	event := <-events
+	assert.Equal(t, "CQ TEST", event.Text)
+	assert.Equal(t, 60, event.Speed)
+}
+
+func TestServer_Abort(t *testing.T) {
+	server, connection := setupServer(t, 1)
+
+	sendMessages(t, connection, "\x1B260", "\x1Bh1", strings.Repeat("paris ", 10))
+	time.Sleep(500 * time.Millisecond)
+	assert.False(t, server.IsIdle())
+
+	sendMessages(t, connection, "\x1B4")
+
+	assert.Equal(t, "break\r\n", readReply(t, connection, time.Second))
+	assert.Eventually(t, server.IsIdle, time.Second, 10*time.Millisecond)
+	keyed := server.Keyed()
+	assert.NotEmpty(t, keyed)
+	assert.Less(t, len(keyed), 60)
+}
+
+func TestServer_AbortWithoutReply(t *testing.T) {
+	server, connection := setupServer(t, 1)
+
+	sendMessages(t, connection, "\x1B260", strings.Repeat("paris ", 10))
+	time.Sleep(500 * time.Millisecond)
+	assert.False(t, server.IsIdle())
+
+	sendMessages(t, connection, "\x1B4")
+
+	assert.Eventually(t, server.IsIdle, time.Second, 10*time.Millisecond)
+	connection.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	_, err := connection.Read(make([]byte, 64))
+	assert.Error(t, err, "no reply was requested, so there must be no break")
+}
+
+func TestServer_Timing(t *testing.T) {
+	server, connection := setupServer(t, 1)
+	events := make(chan Event, 1)
+	server.OnEvent(func(event Event) {
+		if event.Type == TextKeyed {
+			events <- event
+		}
+	})
+
+	// "PARIS " at 60 WpM takes one second, without the word space 46 dits of 20ms
+	sendMessages(t, connection, "\x1B260", "paris")
+
+	select {
+	case event := <-events:
+		assert.Equal(t, "PARIS", event.Text)
+		assert.InDelta(t, 920*time.Millisecond, event.Duration, float64(150*time.Millisecond))
+	case <-time.After(2 * time.Second):
+		t.Fatal("text was not keyed")
+	}
+}
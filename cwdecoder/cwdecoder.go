@@ -0,0 +1,532 @@
+/*
+Package cwdecoder decodes CW from audio samples.
+
+The decoder detects the CW tone with the Goertzel algorithm, separates marks from spaces with an adaptive threshold
+that follows the signal and the noise level, tracks the speed of the signal and decodes the elements into characters
+using the morse package. The decoder works on streams: feed the samples with Write as they arrive and call Flush at
+the end of the stream. DecodeWAV and DecodePCM decode WAV files and raw PCM streams from an io.Reader.
+*/
+package cwdecoder
+
+import (
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ftl/hamradio/cwaudio"
+	"github.com/ftl/hamradio/morse"
+)
+
+// Options of the decoder.
+type Options struct {
+	// SampleRate of the audio samples in Hz, 0 means DefaultOptions.SampleRate.
+	SampleRate int
+	// Tone is the frequency of the CW signal in Hz. 0 means the tone is detected automatically in the range
+	// between MinTone and MaxTone.
+	Tone float64
+	// MinTone and MaxTone limit the automatic tone detection, 0 means DefaultOptions.MinTone and
+	// DefaultOptions.MaxTone.
+	MinTone float64
+	MaxTone float64
+	// WPM is the initial estimate of the speed, 0 means DefaultOptions.WPM.
+	WPM int
+	// Resolution is the time resolution of the decoder, 0 means DefaultOptions.Resolution.
+	Resolution time.Duration
+}
+
+// DefaultOptions contains the default values of all options.
+var DefaultOptions = Options{
+	SampleRate: 8000,
+	MinTone:    300,
+	MaxTone:    1200,
+	WPM:        20,
+	Resolution: 5 * time.Millisecond,
+}
+
+// UnknownCharacter is used for element sequences that cannot be decoded.
+const UnknownCharacter = "*"
+
+const (
+	// levelWindow is the time span that is used to determine the signal and noise level.
+	levelWindow = 2 * time.Second
+	// lookahead is the delay of the decoder, the signal and noise level of a block is determined also from the
+	// following blocks.
+	lookahead = 500 * time.Millisecond
+	// toneDetectionTime is the length of the signal that is used to detect the tone.
+	toneDetectionTime = time.Second
+	// pcmBlockSize is the number of samples that DecodePCM reads at once.
+	pcmBlockSize = 1024
+	// minSNR is the minimum ratio between signal and noise level to detect marks at all.
+	minSNR = 6
+	// debounceBlocks is the number of blocks a new state must persist to be accepted.
+	debounceBlocks = 2
+	// recentMarks is the number of marks that are used to track the speed.
+	recentMarks = 30
+	// recentGaps is the number of gaps between characters that are used to detect word spaces.
+	recentGaps = 20
+)
+
+// Character is a decoded character.
+type Character struct {
+	// Text is the decoded character, a prosign in angle brackets, a space between words or UnknownCharacter.
+	Text string
+	// Time is the start of the character relative to the start of the stream.
+	Time time.Duration
+	// WPM is the estimated speed when the character was decoded.
+	WPM float64
+}
+
+// Text returns the text of the given characters.
+func Text(characters []Character) string {
+	var result strings.Builder
+	for _, c := range characters {
+		result.WriteString(c.Text)
+	}
+	return result.String()
+}
+
+// Decoder decodes CW from a stream of audio samples.
+type Decoder struct {
+	options Options
+	rate    float64
+	hop     int
+	window  []float64
+	coeff   float64
+
+	toneBuffer []float64
+	buffer     []float64
+	block      int
+
+	levels    []float64
+	levelSize int
+	pending   []float64
+	delay     int
+
+	on         bool
+	candidate  bool
+	candidates int
+	stateStart int
+
+	marks     []time.Duration
+	charStart time.Duration
+	recent    []time.Duration
+	gaps      []time.Duration
+	dit       time.Duration
+	threshold time.Duration
+	wordOpen  bool
+
+	output []Character
+}
+
+// New returns a new decoder with the given options.
+func New(options Options) *Decoder {
+	if options.SampleRate <= 0 {
+		options.SampleRate = DefaultOptions.SampleRate
+	}
+	if options.MinTone <= 0 {
+		options.MinTone = DefaultOptions.MinTone
+	}
+	if options.MaxTone <= 0 {
+		options.MaxTone = DefaultOptions.MaxTone
+	}
+	if options.WPM <= 0 {
+		options.WPM = DefaultOptions.WPM
+	}
+	if options.Resolution <= 0 {
+		options.Resolution = DefaultOptions.Resolution
+	}
+
+	rate := float64(options.SampleRate)
+	hop := int(math.Max(1, math.Round(options.Resolution.Seconds()*rate)))
+	result := &Decoder{
+		options:   options,
+		rate:      rate,
+		hop:       hop,
+		window:    hannWindow(2 * hop),
+		levelSize: int(levelWindow / options.Resolution),
+		delay:     int(lookahead / options.Resolution),
+		dit:       morse.Timing{WPM: options.WPM}.Dit(),
+	}
+	result.threshold = 2 * result.dit
+	if options.Tone > 0 {
+		result.setTone(options.Tone)
+	}
+	return result
+}
+
+// Decode decodes the given samples.
+func Decode(samples []float64, options Options) []Character {
+	decoder := New(options)
+	result := decoder.Write(samples)
+	return append(result, decoder.Flush()...)
+}
+
+// DecodeWAV decodes the PCM WAV data from the given reader. The sample rate is taken from the WAV data.
+func DecodeWAV(in io.Reader, options Options) ([]Character, error) {
+	samples, sampleRate, err := cwaudio.ReadWAV(in)
+	if err != nil {
+		return nil, err
+	}
+	options.SampleRate = sampleRate
+	return Decode(samples, options), nil
+}
+
+// DecodePCM decodes the raw PCM stream from the given reader until the end of the stream, e.g. the output of arecord
+// or rtl_fm. The format of the samples is given by bits and channels, see cwaudio.NewPCMReader, the sample rate is
+// taken from the options. The handler is notified about every character as soon as it is decoded.
+func DecodePCM(in io.Reader, bits int, channels int, options Options, handler func(Character)) error {
+	reader, err := cwaudio.NewPCMReader(in, bits, channels)
+	if err != nil {
+		return err
+	}
+	decoder := New(options)
+	emit := func(characters []Character) {
+		for _, c := range characters {
+			handler(c)
+		}
+	}
+
+	samples := make([]float64, pcmBlockSize)
+	for {
+		n, err := reader.Read(samples)
+		emit(decoder.Write(samples[:n]))
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	emit(decoder.Flush())
+	return nil
+}
+
+// Tone returns the frequency of the CW signal, or 0 if the tone is not yet detected.
+func (d *Decoder) Tone() float64 {
+	return d.options.Tone
+}
+
+// WPM returns the current estimate of the speed.
+func (d *Decoder) WPM() float64 {
+	return float64(1200*time.Millisecond) / float64(d.dit)
+}
+
+// Write processes the given samples and returns the characters that were decoded.
+func (d *Decoder) Write(samples []float64) []Character {
+	if d.options.Tone <= 0 {
+		d.toneBuffer = append(d.toneBuffer, samples...)
+		if len(d.toneBuffer) < int(toneDetectionTime.Seconds()*d.rate) {
+			return nil
+		}
+		d.detectTone()
+		samples = d.toneBuffer
+		d.toneBuffer = nil
+	}
+
+	d.buffer = append(d.buffer, samples...)
+	for len(d.buffer) >= len(d.window) {
+		magnitude := d.magnitude(d.buffer[:len(d.window)])
+		d.buffer = d.buffer[d.hop:]
+
+		d.levels = append(d.levels, magnitude)
+		if len(d.levels) > d.levelSize {
+			d.levels = d.levels[1:]
+		}
+		d.pending = append(d.pending, magnitude)
+		if len(d.pending) > d.delay {
+			d.process(d.pending[0])
+			d.pending = d.pending[1:]
+		}
+	}
+	return d.takeOutput()
+}
+
+// Flush decodes the remaining elements at the end of the stream.
+func (d *Decoder) Flush() []Character {
+	if d.options.Tone <= 0 && len(d.toneBuffer) > 0 {
+		d.detectTone()
+		samples := d.toneBuffer
+		d.toneBuffer = nil
+		d.output = append(d.output, d.Write(samples)...)
+	}
+	for _, magnitude := range d.pending {
+		d.process(magnitude)
+	}
+	d.pending = nil
+	if d.on {
+		d.endMark(d.block)
+	}
+	d.endCharacter()
+	return d.takeOutput()
+}
+
+func (d *Decoder) takeOutput() []Character {
+	result := d.output
+	d.output = nil
+	return result
+}
+
+func (d *Decoder) setTone(tone float64) {
+	d.options.Tone = tone
+	d.coeff = 2 * math.Cos(2*math.Pi*tone/d.rate)
+}
+
+func (d *Decoder) detectTone() {
+	bestTone := d.options.MinTone
+	bestPower := -1.0
+	for tone := d.options.MinTone; tone <= d.options.MaxTone; tone += 5 {
+		power := goertzel(d.toneBuffer, 2*math.Cos(2*math.Pi*tone/d.rate), nil)
+		if power > bestPower {
+			bestTone = tone
+			bestPower = power
+		}
+	}
+	d.setTone(bestTone)
+}
+
+func (d *Decoder) magnitude(samples []float64) float64 {
+	return math.Sqrt(goertzel(samples, d.coeff, d.window)) / float64(len(samples))
+}
+
+// goertzel returns the power of the frequency that belongs to the given coefficient.
+func goertzel(samples []float64, coeff float64, window []float64) float64 {
+	var s1, s2 float64
+	for i, sample := range samples {
+		if window != nil {
+			sample *= window[i]
+		}
+		s0 := sample + coeff*s1 - s2
+		s2 = s1
+		s1 = s0
+	}
+	return s1*s1 + s2*s2 - coeff*s1*s2
+}
+
+func hannWindow(size int) []float64 {
+	result := make([]float64, size)
+	for i := range result {
+		result[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(size-1))
+	}
+	return result
+}
+
+// process handles the magnitude of the next block.
+func (d *Decoder) process(magnitude float64) {
+	block := d.block
+	d.block++
+
+	noise, peak := d.signalLevels()
+	high := noise + 0.55*(peak-noise)
+	low := noise + 0.4*(peak-noise)
+
+	state := d.on
+	switch {
+	case peak <= minSNR*noise:
+		state = false
+	case magnitude > high:
+		state = true
+	case magnitude < low:
+		state = false
+	}
+
+	if state == d.on {
+		d.candidates = 0
+		if !d.on {
+			d.checkGap(block)
+		}
+		return
+	}
+	if state != d.candidate || d.candidates == 0 {
+		d.candidate = state
+		d.candidates = 0
+	}
+	d.candidates++
+	if d.candidates < debounceBlocks {
+		if !d.on {
+			d.checkGap(block)
+		}
+		return
+	}
+
+	changeBlock := block - debounceBlocks + 1
+	d.candidates = 0
+	if state {
+		d.startMark(changeBlock)
+	} else {
+		d.endMark(changeBlock)
+	}
+}
+
+// signalLevels returns the noise level of the recent and the pending blocks and the peak level around the current
+// block. The peak level follows the signal more closely to handle fading.
+func (d *Decoder) signalLevels() (float64, float64) {
+	sorted := make([]float64, len(d.levels))
+	copy(sorted, d.levels)
+	sort.Float64s(sorted)
+	noise := sorted[len(sorted)/4]
+
+	local := d.levels
+	if len(local) > 2*d.delay+1 {
+		local = local[len(local)-2*d.delay-1:]
+	}
+	peak := 0.0
+	for _, level := range local {
+		peak = math.Max(peak, level)
+	}
+	return noise, peak
+}
+
+func (d *Decoder) blockTime(block int) time.Duration {
+	return time.Duration(float64(block*d.hop) / d.rate * float64(time.Second))
+}
+
+func (d *Decoder) startMark(block int) {
+	gap := d.blockTime(block - d.stateStart)
+	if len(d.marks) > 0 && gap >= 2*d.dit {
+		d.endCharacter()
+	}
+	if d.wordOpen && len(d.marks) == 0 {
+		d.gaps = appendRecent(d.gaps, gap, recentGaps)
+		if gap >= d.wordThreshold() {
+			d.addWordSpace()
+		}
+	}
+	if len(d.marks) == 0 {
+		d.charStart = d.blockTime(block)
+	}
+	d.on = true
+	d.stateStart = block
+}
+
+func (d *Decoder) endMark(block int) {
+	length := d.blockTime(block - d.stateStart)
+	d.marks = append(d.marks, length)
+	d.recent = appendRecent(d.recent, length, recentMarks)
+	d.updateSpeed()
+	d.on = false
+	d.stateStart = block
+}
+
+// checkGap decodes the current character when the current gap is long enough. A word space is added only if the
+// silence lasts much longer than a usual word space, otherwise the decision is made when the next mark starts.
+func (d *Decoder) checkGap(block int) {
+	gap := d.blockTime(block - d.stateStart)
+	if len(d.marks) > 0 && gap >= 2*d.dit {
+		d.endCharacter()
+	}
+	if d.wordOpen && gap >= 2*d.wordThreshold() {
+		d.addWordSpace()
+	}
+}
+
+func (d *Decoder) addWordSpace() {
+	d.output = append(d.output, Character{Text: " ", Time: d.blockTime(d.stateStart), WPM: d.WPM()})
+	d.wordOpen = false
+}
+
+// wordThreshold returns the threshold between the gaps between characters and the gaps between words. The gaps are
+// clustered like the marks, this also handles Farnsworth spacing.
+func (d *Decoder) wordThreshold() time.Duration {
+	if len(d.gaps) == 0 {
+		return 5 * d.dit
+	}
+	threshold, clustered := cluster(d.gaps, 1.8)
+	if !clustered {
+		// only gaps between characters (word gaps are 7/3 of them)
+		return mean(d.gaps) * 5 / 3
+	}
+	return threshold
+}
+
+// endCharacter decodes the marks of the current character.
+func (d *Decoder) endCharacter() {
+	if len(d.marks) == 0 {
+		return
+	}
+
+	var code strings.Builder
+	for _, mark := range d.marks {
+		if mark < d.threshold {
+			code.WriteByte(byte(morse.Dit))
+		} else {
+			code.WriteByte(byte(morse.Dah))
+		}
+	}
+	text, ok := morse.Decode(code.String())
+	if !ok {
+		text = UnknownCharacter
+	}
+	d.output = append(d.output, Character{Text: text, Time: d.charStart, WPM: d.WPM()})
+	d.marks = d.marks[:0]
+	d.wordOpen = true
+}
+
+// updateSpeed updates the length of a dit and the threshold between dits and dahs from the recent marks.
+func (d *Decoder) updateSpeed() {
+	threshold, clustered := cluster(d.recent, 2)
+	if !clustered {
+		// only one kind of elements, keep the current speed
+		d.threshold = 2 * d.dit
+		return
+	}
+	d.threshold = threshold
+	// a dit and a dah are four dits long, this also compensates the weight
+	d.dit = threshold / 2
+}
+
+// cluster separates the given durations into two clusters using the 2-means algorithm and returns the threshold
+// between both clusters. If the longest duration is less than minRatio times the shortest duration, there is only one
+// cluster.
+func cluster(durations []time.Duration, minRatio float64) (time.Duration, bool) {
+	shortest, longest := durations[0], durations[0]
+	for _, duration := range durations {
+		if duration < shortest {
+			shortest = duration
+		}
+		if duration > longest {
+			longest = duration
+		}
+	}
+	if float64(longest) < minRatio*float64(shortest) {
+		return 0, false
+	}
+
+	threshold := (shortest + longest) / 2
+	for i := 0; i < 10; i++ {
+		var shortSum, longSum time.Duration
+		var shortCount, longCount int
+		for _, duration := range durations {
+			if duration < threshold {
+				shortSum += duration
+				shortCount++
+			} else {
+				longSum += duration
+				longCount++
+			}
+		}
+		newThreshold := (shortSum/time.Duration(shortCount) + longSum/time.Duration(longCount)) / 2
+		if newThreshold == threshold {
+			break
+		}
+		threshold = newThreshold
+	}
+	return threshold, true
+}
+
+func mean(durations []time.Duration) time.Duration {
+	var sum time.Duration
+	for _, duration := range durations {
+		sum += duration
+	}
+	return sum / time.Duration(len(durations))
+}
+
+func appendRecent(durations []time.Duration, duration time.Duration, size int) []time.Duration {
+	durations = append(durations, duration)
+	if len(durations) > size {
+		durations = durations[1:]
+	}
+	return durations
+}
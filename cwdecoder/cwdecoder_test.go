@@ -0,0 +1,143 @@
+package cwdecoder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ftl/hamradio/cwaudio"
+	"github.com/ftl/hamradio/morse"
+)
+
+const testText = "CQ CQ DE DL1ABC DL1ABC PSE K"
+
+func render(t *testing.T, text string, options cwaudio.Options) []float64 {
+	t.Helper()
+	signal, err := cwaudio.Render(text, options)
+	require.NoError(t, err)
+	return signal
+}
+
+func TestDecode_Roundtrip(t *testing.T) {
+	testCases := []struct {
+		desc    string
+		options cwaudio.Options
+	}{
+		{desc: "20 WpM", options: cwaudio.Options{Timing: morse.Timing{WPM: 20}}},
+		{desc: "12 WpM", options: cwaudio.Options{Timing: morse.Timing{WPM: 12}}},
+		{desc: "35 WpM", options: cwaudio.Options{Timing: morse.Timing{WPM: 35}}},
+		{desc: "heavy weight", options: cwaudio.Options{Timing: morse.Timing{WPM: 25, Weight: 30}}},
+		{desc: "Farnsworth", options: cwaudio.Options{Timing: morse.Timing{WPM: 20, Farnsworth: 12}}},
+		{desc: "high tone", options: cwaudio.Options{Tone: 950, Timing: morse.Timing{WPM: 25}}},
+		{desc: "noise", options: cwaudio.Options{Timing: morse.Timing{WPM: 25}, Noise: 0.5, Seed: 1}},
+		{desc: "QSB", options: cwaudio.Options{Timing: morse.Timing{WPM: 25}, QSB: 0.5}},
+		{desc: "QRM", options: cwaudio.Options{Timing: morse.Timing{WPM: 25}, QRM: 0.2, Seed: 2}},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			options := tC.options
+			options.Padding = 500 * time.Millisecond
+			signal := render(t, testText, options)
+
+			characters := Decode(signal, Options{})
+
+			assert.Equal(t, testText, strings.TrimSpace(Text(characters)))
+			require.NotEmpty(t, characters)
+			last := characters[len(characters)-1]
+			assert.InDelta(t, tC.options.Timing.WPM, last.WPM, float64(tC.options.Timing.WPM)*0.15)
+		})
+	}
+}
+
+func TestDecode_Timestamps(t *testing.T) {
+	timing := morse.Timing{WPM: 20}
+	signal := render(t, "ab c", cwaudio.Options{Timing: timing, Padding: time.Second})
+
+	characters := Decode(signal, Options{Tone: 600})
+
+	require.Len(t, characters, 5)
+	dit := timing.Dit()
+	expected := []time.Duration{time.Second, time.Second + 8*dit, time.Second + 17*dit, time.Second + 24*dit, time.Second + 35*dit}
+	for i, c := range characters {
+		assert.InDelta(t, expected[i], c.Time, float64(10*time.Millisecond), "%d: %q", i, c.Text)
+	}
+	assert.Equal(t, "AB C ", Text(characters))
+}
+
+func TestDecoder_Streaming(t *testing.T) {
+	signal := render(t, testText, cwaudio.Options{Tone: 700, Timing: morse.Timing{WPM: 28}, Padding: 500 * time.Millisecond})
+	decoder := New(Options{SampleRate: cwaudio.DefaultOptions.SampleRate})
+
+	var characters []Character
+	for len(signal) > 0 {
+		n := 333
+		if n > len(signal) {
+			n = len(signal)
+		}
+		characters = append(characters, decoder.Write(signal[:n])...)
+		signal = signal[n:]
+	}
+	characters = append(characters, decoder.Flush()...)
+
+	assert.Equal(t, testText, strings.TrimSpace(Text(characters)))
+	assert.InDelta(t, 700, decoder.Tone(), 10)
+	assert.InDelta(t, 28, decoder.WPM(), 3)
+}
+
+func TestDecode_Prosigns(t *testing.T) {
+	signal := render(t, "TU <SK>", cwaudio.Options{})
+	characters := Decode(signal, Options{})
+	assert.Equal(t, "TU <SK>", strings.TrimSpace(Text(characters)))
+
+	// nine dits cannot be decoded
+	timing := morse.Timing{WPM: 20}
+	signal = render(t, "<HH>", cwaudio.Options{Timing: timing, Rise: -1})
+	signal = append(signal, make([]float64, int(timing.Dit().Seconds()*8000))...)
+	signal = append(signal, render(t, "E", cwaudio.Options{Timing: timing, Rise: -1})...)
+	characters = Decode(signal, Options{Tone: 600})
+	assert.Equal(t, UnknownCharacter, Text(characters))
+}
+
+func TestDecode_Silence(t *testing.T) {
+	characters := Decode(make([]float64, 16000), Options{})
+	assert.Empty(t, characters)
+
+	signal := render(t, "", cwaudio.Options{Noise: 0.3, Padding: 2 * time.Second})
+	characters = Decode(signal, Options{})
+	assert.Empty(t, strings.TrimSpace(Text(characters)))
+}
+
+func TestDecodeWAV(t *testing.T) {
+	options := cwaudio.Options{SampleRate: 11025, Timing: morse.Timing{WPM: 22}}
+	signal := render(t, "5NN 001", options)
+	buffer := bytes.NewBuffer(nil)
+	require.NoError(t, cwaudio.WriteWAV(buffer, signal, options.SampleRate))
+
+	characters, err := DecodeWAV(buffer, Options{})
+
+	require.NoError(t, err)
+	assert.Equal(t, "5NN 001", strings.TrimSpace(Text(characters)))
+}
+
+func TestDecodePCM(t *testing.T) {
+	options := cwaudio.Options{SampleRate: 11025, Timing: morse.Timing{WPM: 22}}
+	signal := render(t, "5NN 001", options)
+	buffer := bytes.NewBuffer(nil)
+	for _, sample := range signal {
+		require.NoError(t, binary.Write(buffer, binary.LittleEndian, int16(sample*math.MaxInt16)))
+	}
+
+	var characters []Character
+	err := DecodePCM(buffer, 16, 1, Options{SampleRate: options.SampleRate}, func(c Character) {
+		characters = append(characters, c)
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, "5NN 001", strings.TrimSpace(Text(characters)))
+}
@@ -0,0 +1,336 @@
+/*
+Package cwmacro expands macros for CW messages, as they are used in contests and for standard QSOs.
+
+A macro is a text with placeholders in curly braces:
+
+	{CALL}              the value of CALL
+	{NR:CUT}            the value of NR with cut numbers (0→T, 9→N)
+	{NR:FULLCUT}        the value of NR with all cut numbers (0→T, 1→A, 2→U, 3→V, 5→E, 7→B, 8→D, 9→N)
+	{+5}, {-5}          change the speed by the given WpM, relative to the current speed
+	{IF EXCH}...{ENDIF} the enclosed part is only used if EXCH is not empty, {IF !EXCH} negates the condition
+	{ELSE}              the alternative part of a condition
+
+The names of the placeholders are case insensitive. The values are provided as Values, NewValues fills the standard
+placeholders MYCALL, OP, LOC, CALL, RST, NR and EXCH from the station profile and the current QSO.
+
+Example:
+
+	{CALL} {RST:CUT} {NR:CUT}{IF EXCH} {EXCH}{ENDIF} {+5}TU{-5}
+*/
+package cwmacro
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ftl/hamradio/cfg"
+	"github.com/ftl/hamradio/cwclient"
+)
+
+// The standard placeholders.
+const (
+	MyCall   = "MYCALL"
+	Operator = "OP"
+	Locator  = "LOC"
+	Call     = "CALL"
+	RST      = "RST"
+	Number   = "NR"
+	Exchange = "EXCH"
+)
+
+// DefaultRST is used by NewValues if the QSO has no RST.
+const DefaultRST = "599"
+
+// Values contains the values of the placeholders by their name in upper case.
+type Values map[string]string
+
+// QSO contains the information about the current QSO that is used in the macros.
+type QSO struct {
+	Call     string
+	RST      string
+	Number   int
+	Exchange string
+}
+
+// NewValues returns the values of the standard placeholders for the given station profile and QSO.
+// The serial number is formatted with at least three digits, 0 means no number.
+func NewValues(profile cfg.Profile, qso QSO) Values {
+	result := Values{
+		MyCall:   strings.ToUpper(profile.Call.String()),
+		Operator: strings.ToUpper(profile.Operator),
+		Locator:  strings.ToUpper(profile.Locator.String()),
+		Call:     strings.ToUpper(qso.Call),
+		RST:      qso.RST,
+		Exchange: strings.ToUpper(qso.Exchange),
+	}
+	if result[Operator] == "" {
+		result[Operator] = result[MyCall]
+	}
+	if result[RST] == "" {
+		result[RST] = DefaultRST
+	}
+	if qso.Number > 0 {
+		result[Number] = fmt.Sprintf("%03d", qso.Number)
+	} else {
+		result[Number] = ""
+	}
+	return result
+}
+
+// Part is a part of a message that is sent with the same speed. SpeedOffset is the difference to the base speed in WpM.
+type Part struct {
+	Text        string
+	SpeedOffset int
+}
+
+// Message is the result of the expansion of a macro.
+type Message []Part
+
+// String returns the text of the message without the speed changes.
+func (m Message) String() string {
+	var result strings.Builder
+	for _, part := range m {
+		result.WriteString(part.Text)
+	}
+	return result.String()
+}
+
+// Send sends the message with the given keyer, using the given base speed in WpM. Before each speed change, Send
+// waits until the keyer is idle, at the end the base speed is restored.
+func (m Message) Send(keyer cwclient.Keyer, speed int) {
+	offset := 0
+	for _, part := range m {
+		if part.SpeedOffset != offset {
+			keyer.Wait()
+			keyer.Speed(speed + part.SpeedOffset)
+			offset = part.SpeedOffset
+		}
+		keyer.Send(part.Text)
+	}
+	if offset != 0 {
+		keyer.Wait()
+		keyer.Speed(speed)
+	}
+}
+
+// HasSpeedChanges indicates if the message contains relative speed changes.
+func (m Message) HasSpeedChanges() bool {
+	for _, part := range m {
+		if part.SpeedOffset != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Expand parses and expands the given macro with the given values.
+func Expand(macro string, values Values) (Message, error) {
+	template, err := Parse(macro)
+	if err != nil {
+		return nil, err
+	}
+	return template.Expand(values)
+}
+
+// Template is a parsed macro.
+type Template struct {
+	nodes []node
+}
+
+type node interface{}
+
+type textNode string
+
+type placeholderNode struct {
+	name     string
+	modifier string
+}
+
+type speedNode int
+
+type conditionNode struct {
+	name      string
+	negate    bool
+	then      []node
+	otherwise []node
+	hasElse   bool
+}
+
+// The modifiers of placeholders.
+const (
+	cutModifier     = "CUT"
+	fullCutModifier = "FULLCUT"
+)
+
+var cutNumbers = map[string]*strings.Replacer{
+	cutModifier:     strings.NewReplacer("0", "T", "9", "N"),
+	fullCutModifier: strings.NewReplacer("0", "T", "1", "A", "2", "U", "3", "V", "5", "E", "7", "B", "8", "D", "9", "N"),
+}
+
+var (
+	nameExpression  = regexp.MustCompile(`^[A-Z0-9_]+$`)
+	speedExpression = regexp.MustCompile(`^[+-][0-9]+$`)
+)
+
+// Parse parses the given macro.
+func Parse(macro string) (*Template, error) {
+	root := &conditionNode{}
+	stack := []*conditionNode{root}
+	appendNode := func(n node) {
+		top := stack[len(stack)-1]
+		if top.hasElse {
+			top.otherwise = append(top.otherwise, n)
+		} else {
+			top.then = append(top.then, n)
+		}
+	}
+
+	rest := macro
+	for len(rest) > 0 {
+		open := strings.IndexAny(rest, "{}")
+		if open == -1 {
+			appendNode(textNode(rest))
+			break
+		}
+		if rest[open] == '}' {
+			return nil, fmt.Errorf("unexpected } in %q", macro)
+		}
+		if open > 0 {
+			appendNode(textNode(rest[:open]))
+		}
+		length := strings.IndexAny(rest[open+1:], "{}")
+		if length == -1 || rest[open+1+length] != '}' {
+			return nil, fmt.Errorf("missing } in %q", macro)
+		}
+		content := strings.ToUpper(strings.TrimSpace(rest[open+1 : open+1+length]))
+		rest = rest[open+length+2:]
+
+		keyword, argument, _ := strings.Cut(content, " ")
+		argument = strings.TrimSpace(argument)
+		switch {
+		case speedExpression.MatchString(content):
+			delta, err := strconv.Atoi(content)
+			if err != nil {
+				return nil, fmt.Errorf("invalid speed change {%s}: %w", content, err)
+			}
+			appendNode(speedNode(delta))
+		case keyword == "IF":
+			condition := &conditionNode{}
+			condition.name = strings.TrimPrefix(argument, "!")
+			condition.negate = strings.HasPrefix(argument, "!")
+			if !nameExpression.MatchString(condition.name) {
+				return nil, fmt.Errorf("invalid condition {%s}", content)
+			}
+			appendNode(condition)
+			stack = append(stack, condition)
+		case content == "ELSE":
+			top := stack[len(stack)-1]
+			if len(stack) == 1 || top.hasElse {
+				return nil, fmt.Errorf("unexpected {ELSE} in %q", macro)
+			}
+			top.hasElse = true
+		case content == "ENDIF":
+			if len(stack) == 1 {
+				return nil, fmt.Errorf("unexpected {ENDIF} in %q", macro)
+			}
+			stack = stack[:len(stack)-1]
+		default:
+			name, modifier, _ := strings.Cut(content, ":")
+			if !nameExpression.MatchString(name) {
+				return nil, fmt.Errorf("invalid placeholder {%s}", content)
+			}
+			if _, ok := cutNumbers[modifier]; modifier != "" && !ok {
+				return nil, fmt.Errorf("unknown modifier %s in {%s}", modifier, content)
+			}
+			appendNode(placeholderNode{name: name, modifier: modifier})
+		}
+	}
+	if len(stack) > 1 {
+		return nil, fmt.Errorf("missing {ENDIF} in %q", macro)
+	}
+
+	return &Template{nodes: root.then}, nil
+}
+
+// Expand expands the template with the given values. Every placeholder must have a value, conditions on names
+// without a value are false.
+func (t *Template) Expand(values Values) (Message, error) {
+	var parts Message
+	offset := 0
+	var text strings.Builder
+	flush := func() {
+		if text.Len() > 0 {
+			parts = append(parts, Part{Text: text.String(), SpeedOffset: offset})
+			text.Reset()
+		}
+	}
+
+	var expand func([]node) error
+	expand = func(nodes []node) error {
+		for _, n := range nodes {
+			switch n := n.(type) {
+			case textNode:
+				text.WriteString(string(n))
+			case speedNode:
+				flush()
+				offset += int(n)
+			case placeholderNode:
+				value, ok := values[n.name]
+				if !ok {
+					return fmt.Errorf("no value for {%s}", n.name)
+				}
+				if n.modifier != "" {
+					value = cutNumbers[n.modifier].Replace(value)
+				}
+				text.WriteString(value)
+			case *conditionNode:
+				branch := n.then
+				if (strings.TrimSpace(values[n.name]) != "") == n.negate {
+					branch = n.otherwise
+				}
+				err := expand(branch)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	err := expand(t.nodes)
+	if err != nil {
+		return nil, err
+	}
+	flush()
+
+	return normalizeSpaces(parts), nil
+}
+
+// normalizeSpaces reduces multiple spaces to one space and removes leading and trailing spaces of the message.
+func normalizeSpaces(parts Message) Message {
+	result := make(Message, 0, len(parts))
+	pendingSpace := false
+	for _, part := range parts {
+		fields := strings.Fields(part.Text)
+		if len(fields) == 0 {
+			pendingSpace = pendingSpace || part.Text != ""
+			continue
+		}
+		text := strings.Join(fields, " ")
+		if len(result) > 0 && (pendingSpace || startsWithSpace(part.Text)) {
+			text = " " + text
+		}
+		pendingSpace = endsWithSpace(part.Text)
+		result = append(result, Part{Text: text, SpeedOffset: part.SpeedOffset})
+	}
+	return result
+}
+
+func startsWithSpace(s string) bool {
+	return strings.TrimLeft(s, " \t\n") != s
+}
+
+func endsWithSpace(s string) bool {
+	return strings.TrimRight(s, " \t\n") != s
+}
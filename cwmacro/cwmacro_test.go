@@ -0,0 +1,145 @@
+package cwmacro
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ftl/hamradio/callsign"
+	"github.com/ftl/hamradio/cfg"
+	"github.com/ftl/hamradio/locator"
+)
+
+var testValues = Values{
+	MyCall:   "DL1ABC",
+	Call:     "DL2XYZ",
+	RST:      "599",
+	Number:   "009",
+	Exchange: "",
+	Locator:  "JN59NK",
+}
+
+func TestExpand(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		macro    string
+		expected string
+	}{
+		{desc: "plain text", macro: "cq test", expected: "cq test"},
+		{desc: "placeholders", macro: "{CALL} de {MYCALL}", expected: "DL2XYZ de DL1ABC"},
+		{desc: "case insensitive", macro: "{call} {Loc}", expected: "DL2XYZ JN59NK"},
+		{desc: "cut numbers", macro: "{RST:CUT} {NR:cut}", expected: "5NN TTN"},
+		{desc: "full cut numbers", macro: "{nr:fullcut} 1234567890", expected: "TTN 1234567890"},
+		{desc: "condition true", macro: "{CALL} {IF NR}{NR}{ELSE}{LOC}{ENDIF} TU", expected: "DL2XYZ 009 TU"},
+		{desc: "condition false", macro: "{CALL} {IF EXCH}{EXCH}{ELSE}{LOC}{ENDIF} TU", expected: "DL2XYZ JN59NK TU"},
+		{desc: "condition without value", macro: "{IF NAME}{NAME}{ENDIF} TU", expected: "TU"},
+		{desc: "negated condition", macro: "{IF !EXCH}no exchange{ENDIF}", expected: "no exchange"},
+		{desc: "nested conditions", macro: "{IF CALL}{IF EXCH}a{ELSE}b{ENDIF}{ELSE}c{ENDIF}", expected: "b"},
+		{desc: "multiple spaces", macro: "  {CALL}  {IF EXCH} {EXCH} {ENDIF}  TU ", expected: "DL2XYZ TU"},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			message, err := Expand(tC.macro, testValues)
+			require.NoError(t, err)
+			assert.Equal(t, tC.expected, message.String())
+			assert.False(t, message.HasSpeedChanges())
+		})
+	}
+}
+
+func TestExpand_Speed(t *testing.T) {
+	message, err := Expand("{CALL} {+5}5NN {NR:CUT}{-10} TU{+5}", testValues)
+
+	require.NoError(t, err)
+	assert.Equal(t, Message{
+		{Text: "DL2XYZ"},
+		{Text: " 5NN TTN", SpeedOffset: 5},
+		{Text: " TU", SpeedOffset: -5},
+	}, message)
+	assert.True(t, message.HasSpeedChanges())
+}
+
+func TestParse_Invalid(t *testing.T) {
+	testCases := []string{
+		"{CALL",
+		"CALL}",
+		"{CA{LL}",
+		"{IF CALL}",
+		"{ENDIF}",
+		"{ELSE}",
+		"{IF CALL}{ELSE}{ELSE}{ENDIF}",
+		"{IF}{ENDIF}",
+		"{NR:UNKNOWN}",
+		"{MY CALL}",
+	}
+	for _, macro := range testCases {
+		t.Run(macro, func(t *testing.T) {
+			_, err := Parse(macro)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestExpand_MissingValue(t *testing.T) {
+	_, err := Expand("{CALL} {NAME}", testValues)
+	assert.Error(t, err)
+}
+
+func TestNewValues(t *testing.T) {
+	call, err := callsign.Parse("DL1ABC")
+	require.NoError(t, err)
+	loc, err := locator.Parse("JN59nk")
+	require.NoError(t, err)
+	profile := cfg.Profile{Call: call, Locator: loc}
+
+	values := NewValues(profile, QSO{Call: "dl2xyz", Number: 12, Exchange: "dok b01"})
+
+	assert.Equal(t, Values{
+		MyCall:   "DL1ABC",
+		Operator: "DL1ABC",
+		Locator:  "JN59NK",
+		Call:     "DL2XYZ",
+		RST:      "599",
+		Number:   "012",
+		Exchange: "DOK B01",
+	}, values)
+
+	values = NewValues(cfg.Profile{Call: call, Operator: "dl9zzz"}, QSO{RST: "579"})
+	assert.Equal(t, "DL9ZZZ", values[Operator])
+	assert.Equal(t, "579", values[RST])
+	assert.Equal(t, "", values[Number])
+}
+
+type recordingKeyer struct {
+	calls []string
+}
+
+func (k *recordingKeyer) Send(text string) { k.calls = append(k.calls, "send "+text) }
+func (k *recordingKeyer) Speed(speed int)  { k.calls = append(k.calls, fmt.Sprintf("speed %d", speed)) }
+func (k *recordingKeyer) Abort()           { k.calls = append(k.calls, "abort") }
+func (k *recordingKeyer) PTT(on bool)      { k.calls = append(k.calls, fmt.Sprintf("ptt %t", on)) }
+func (k *recordingKeyer) Tune(seconds int) {
+	k.calls = append(k.calls, fmt.Sprintf("tune %d", seconds))
+}
+func (k *recordingKeyer) Wait()        { k.calls = append(k.calls, "wait") }
+func (k *recordingKeyer) IsIdle() bool { return true }
+
+func TestMessage_Send(t *testing.T) {
+	message, err := Expand("{CALL} {+6}5NN{-6} TU", testValues)
+	require.NoError(t, err)
+	keyer := &recordingKeyer{}
+
+	message.Send(keyer, 24)
+
+	assert.Equal(t, []string{"send DL2XYZ", "wait", "speed 30", "send  5NN", "wait", "speed 24", "send  TU"}, keyer.calls)
+
+	keyer = &recordingKeyer{}
+	message, err = Expand("{+6}TU", testValues)
+	require.NoError(t, err)
+
+	message.Send(keyer, 24)
+
+	assert.Equal(t, []string{"wait", "speed 30", "send TU", "wait", "speed 24"}, keyer.calls)
+}
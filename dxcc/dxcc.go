@@ -4,6 +4,7 @@ import (
 	"strings"
 	"unicode/utf8"
 
+	"github.com/ftl/hamradio/callsign"
 	"github.com/ftl/hamradio/latlon"
 )
 
@@ -81,6 +82,23 @@ func (prefixes Prefixes) Find(s string) ([]Prefix, bool) {
 	return prefixes.find(s, false)
 }
 
+// FindCallsign returns the best matching prefix for the given callsign. The prefix of the callsign (e.g. DL in
+// DL/VK2ABC) is preferred over the base call. If the match is ambiguous, the first matching prefix is returned.
+func (prefixes Prefixes) FindCallsign(call callsign.Callsign) (Prefix, bool) {
+	key := call.BaseCall
+	if call.Prefix != "" {
+		key = call.Prefix
+	}
+	if key == "" {
+		return Prefix{}, false
+	}
+	found, ok := prefixes.Find(key)
+	if !ok {
+		return Prefix{}, false
+	}
+	return found[0], true
+}
+
 // FindARRLCompliant returns the best matching ARRL compliant prefixes for a given string.
 // Since a prefix might be ambiguous, a slice of prefixes that match is returned.
 func (prefixes Prefixes) FindARRLCompliant(s string) ([]Prefix, bool) {
@@ -2,6 +2,8 @@ package dxcc
 
 import (
 	"testing"
+
+	"github.com/ftl/hamradio/callsign"
 )
 
 func TestPrefixes_add(t *testing.T) {
@@ -59,3 +61,31 @@ func TestPrefixes_Find(t *testing.T) {
 		}
 	}
 }
+
+func TestPrefixes_FindCallsign(t *testing.T) {
+	prefixes := NewPrefixes()
+	prefixes.Add(
+		Prefix{Prefix: "DL", Name: "Germany"},
+		Prefix{Prefix: "VK", Name: "Australia"},
+		Prefix{Prefix: "VK9X", Name: "Christmas Island"},
+	)
+
+	testCases := []struct {
+		call  callsign.Callsign
+		name  string
+		found bool
+	}{
+		{callsign.MustParse("DL1ABC"), "Germany", true},
+		{callsign.MustParse("VK9XY"), "Christmas Island", true},
+		{callsign.MustParse("DL/VK2ABC"), "Germany", true},
+		{callsign.MustParse("VK2ABC/P"), "Australia", true},
+		{callsign.MustParse("K1ABC"), "", false},
+		{callsign.NoCallsign, "", false},
+	}
+	for _, testCase := range testCases {
+		prefix, found := prefixes.FindCallsign(testCase.call)
+		if found != testCase.found || prefix.Name != testCase.name {
+			t.Errorf("%v: expected %q %t, but got %q %t", testCase.call, testCase.name, testCase.found, prefix.Name, found)
+		}
+	}
+}
@@ -0,0 +1,428 @@
+package dxcluster
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ftl/hamradio/bandplan"
+	"github.com/ftl/hamradio/callsign"
+	"github.com/ftl/hamradio/cfg"
+	"github.com/ftl/hamradio/dxcc"
+)
+
+// DefaultLoginTimeout is the default time to wait for the login prompt of the node.
+const DefaultLoginTimeout = 10 * time.Second
+
+// Errors returned by the client.
+var (
+	ErrNotConnected  = errors.New("not connected to the DX cluster")
+	ErrNoCallsign    = errors.New("no callsign to log in")
+	ErrNoLoginPrompt = errors.New("no login prompt received")
+)
+
+// SpotHandler is notified about every spot that passes the filters.
+type SpotHandler func(Spot)
+
+// MessageHandler is notified about every message, including the spots that pass the filters.
+type MessageHandler func(Message)
+
+// ErrorHandler is notified about lines that cannot be parsed and about the loss of the connection.
+type ErrorHandler func(err error)
+
+// Client is a client for a DX cluster node. It is safe for concurrent use.
+type Client struct {
+	address string
+	call    callsign.Callsign
+	// LoginTimeout is the time to wait for the login prompt.
+	LoginTimeout time.Duration
+	now          func() time.Time
+
+	connectLock sync.Mutex
+
+	lock            sync.RWMutex
+	conn            net.Conn
+	closing         bool
+	closeCount      int
+	loginConn       net.Conn
+	done            chan struct{}
+	dispatching     bool
+	prefixes        *dxcc.Prefixes
+	plan            bandplan.Bandplan
+	filters         []Filter
+	spotHandlers    []SpotHandler
+	messageHandlers []MessageHandler
+	errorHandlers   []ErrorHandler
+}
+
+// New returns a new client for the node at the given address (host:port) that logs in with the given callsign.
+// The connection is opened with Connect.
+func New(address string, call callsign.Callsign) *Client {
+	return &Client{
+		address:      address,
+		call:         call,
+		LoginTimeout: DefaultLoginTimeout,
+		now:          time.Now,
+		plan:         bandplan.IARURegion1,
+	}
+}
+
+// NewFromConfiguration returns a new client for the node at the given address that logs in with my.call from the
+// given configuration.
+func NewFromConfiguration(address string, config cfg.Configuration) (*Client, error) {
+	call, err := config.GetCallsign(cfg.MyCall, callsign.NoCallsign)
+	if err != nil {
+		return nil, err
+	}
+	if call == callsign.NoCallsign {
+		return nil, fmt.Errorf("%w: %s is not configured", ErrNoCallsign, cfg.MyCall)
+	}
+	return New(address, call), nil
+}
+
+// SetPrefixes sets the DXCC prefixes that are used to find the DXCC entities of the spotted callsigns.
+func (c *Client) SetPrefixes(prefixes *dxcc.Prefixes) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.prefixes = prefixes
+}
+
+// SetBandplan sets the bandplan that is used to find the band and mode of the spotted frequencies. The default is
+// bandplan.IARURegion1.
+func (c *Client) SetBandplan(plan bandplan.Bandplan) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.plan = plan
+}
+
+// AddFilter adds the given filter. Only the spots that pass all filters are reported.
+func (c *Client) AddFilter(filter Filter) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.filters = append(c.filters, filter)
+}
+
+// OnSpot registers the given handler to be notified about every spot that passes the filters. The handlers are
+// called on the goroutine of the client.
+func (c *Client) OnSpot(handler SpotHandler) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.spotHandlers = append(c.spotHandlers, handler)
+}
+
+// OnMessage registers the given handler to be notified about every message. The handlers are called on the
+// goroutine of the client.
+func (c *Client) OnMessage(handler MessageHandler) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.messageHandlers = append(c.messageHandlers, handler)
+}
+
+// OnError registers the given handler to be notified about lines that cannot be parsed and about the loss of the
+// connection.
+func (c *Client) OnError(handler ErrorHandler) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.errorHandlers = append(c.errorHandlers, handler)
+}
+
+// Connect opens the connection to the node and logs in. Then the client receives the messages from the node until
+// the connection is closed. If Close is called while the client logs in, the login is cancelled and Connect fails
+// with ErrNotConnected.
+func (c *Client) Connect() error {
+	c.connectLock.Lock()
+	defer c.connectLock.Unlock()
+
+	c.lock.RLock()
+	connected := c.conn != nil
+	closeCount := c.closeCount
+	c.lock.RUnlock()
+	if connected {
+		return nil
+	}
+	if c.call == callsign.NoCallsign {
+		return ErrNoCallsign
+	}
+
+	// dial and log in without holding the lock, this may take a while
+	conn, err := net.DialTimeout("tcp", c.address, c.LoginTimeout)
+	if err != nil {
+		return err
+	}
+	c.lock.Lock()
+	if c.closeCount != closeCount {
+		c.lock.Unlock()
+		conn.Close()
+		return fmt.Errorf("%w: closed while logging in", ErrNotConnected)
+	}
+	c.loginConn = conn
+	c.lock.Unlock()
+	reader := &telnetReader{reader: bufio.NewReader(conn)}
+	err = c.login(conn, reader)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.loginConn = nil
+	if c.closeCount != closeCount {
+		conn.Close()
+		return fmt.Errorf("%w: closed while logging in", ErrNotConnected)
+	}
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	c.conn = conn
+	c.closing = false
+	c.done = make(chan struct{})
+	go c.run(conn, reader, c.done)
+	return nil
+}
+
+func (c *Client) login(conn net.Conn, reader *telnetReader) error {
+	conn.SetReadDeadline(time.Now().Add(c.LoginTimeout))
+	defer conn.SetReadDeadline(time.Time{})
+
+	var line strings.Builder
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrNoLoginPrompt, err)
+		}
+		if b == '\n' {
+			line.Reset()
+			continue
+		}
+		line.WriteByte(b)
+		if isLoginPrompt(line.String()) {
+			break
+		}
+	}
+
+	_, err := fmt.Fprintf(conn, "%s\r\n", c.call)
+	return err
+}
+
+// isLoginPrompt indicates if the given text is the prompt for the callsign, e.g. "login: " or "Please enter your
+// call: ".
+func isLoginPrompt(text string) bool {
+	text = strings.ToLower(strings.TrimSpace(text))
+	if !strings.HasSuffix(text, ":") && !strings.HasSuffix(text, ">") {
+		return false
+	}
+	return strings.Contains(text, "login") || strings.Contains(text, "call")
+}
+
+// IsConnected indicates if the client is connected to the node.
+func (c *Client) IsConnected() bool {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.conn != nil
+}
+
+// Done returns a channel that is closed when the connection to the node is closed or lost. It returns nil if the
+// client was never connected.
+func (c *Client) Done() <-chan struct{} {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.done
+}
+
+// Send sends the given command to the node, e.g. "sh/dx 10".
+func (c *Client) Send(command string) error {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	if c.conn == nil {
+		return ErrNotConnected
+	}
+	_, err := fmt.Fprintf(c.conn, "%s\r\n", command)
+	return err
+}
+
+// Close logs out and closes the connection to the node. It waits until the client stopped receiving, unless a handler
+// is running at the same time, which allows to call Close from a handler. Use Done to wait for the client in this case.
+func (c *Client) Close() error {
+	c.lock.Lock()
+	conn := c.conn
+	done := c.done
+	dispatching := c.dispatching
+	c.closing = true
+	c.closeCount++
+	if c.loginConn != nil {
+		c.loginConn.Close()
+	}
+	c.lock.Unlock()
+	if conn == nil {
+		return nil
+	}
+
+	fmt.Fprint(conn, "bye\r\n")
+	err := conn.Close()
+	if !dispatching {
+		<-done
+	}
+	return err
+}
+
+func (c *Client) run(conn net.Conn, reader *telnetReader, done chan struct{}) {
+	defer close(done)
+	defer func() {
+		c.lock.Lock()
+		defer c.lock.Unlock()
+		if c.conn == conn {
+			c.conn = nil
+		}
+	}()
+
+	for {
+		line, err := reader.ReadLine()
+		if err != nil {
+			c.lock.RLock()
+			closing := c.closing
+			c.lock.RUnlock()
+			if !closing {
+				c.emitError(fmt.Errorf("connection to %s lost: %w", c.address, err))
+			}
+			return
+		}
+		c.handleLine(line)
+	}
+}
+
+func (c *Client) handleLine(line string) {
+	message, err := Parse(strings.Trim(line, "\a\r "), c.now())
+	if errors.Is(err, ErrUnknownLine) {
+		return
+	}
+	if err != nil {
+		c.emitError(err)
+		return
+	}
+
+	c.lock.RLock()
+	prefixes := c.prefixes
+	plan := c.plan
+	filters := make([]Filter, len(c.filters))
+	copy(filters, c.filters)
+	spotHandlers := make([]SpotHandler, len(c.spotHandlers))
+	copy(spotHandlers, c.spotHandlers)
+	messageHandlers := make([]MessageHandler, len(c.messageHandlers))
+	copy(messageHandlers, c.messageHandlers)
+	c.lock.RUnlock()
+
+	spot, isSpot := message.(Spot)
+	if isSpot {
+		Enrich(&spot, prefixes, plan)
+		if !All(filters...)(spot) {
+			return
+		}
+		message = spot
+	}
+
+	c.setDispatching(true)
+	defer c.setDispatching(false)
+	for _, handler := range messageHandlers {
+		handler(message)
+	}
+	if isSpot {
+		for _, handler := range spotHandlers {
+			handler(spot)
+		}
+	}
+}
+
+func (c *Client) emitError(err error) {
+	c.lock.RLock()
+	errorHandlers := make([]ErrorHandler, len(c.errorHandlers))
+	copy(errorHandlers, c.errorHandlers)
+	c.lock.RUnlock()
+
+	c.setDispatching(true)
+	defer c.setDispatching(false)
+	for _, handler := range errorHandlers {
+		handler(err)
+	}
+}
+
+// setDispatching indicates that the handlers are running. Close must not wait for run to finish in the meantime,
+// because it may be called from a handler.
+func (c *Client) setDispatching(dispatching bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.dispatching = dispatching
+}
+
+// The telnet commands that are relevant to skip the option negotiation.
+const (
+	telnetSE   = 240
+	telnetSB   = 250
+	telnetWILL = 251
+	telnetDONT = 254
+	telnetIAC  = 255
+)
+
+// telnetReader reads the text from a telnet connection. It skips the telnet commands, options are not negotiated.
+type telnetReader struct {
+	reader *bufio.Reader
+}
+
+func (r *telnetReader) ReadByte() (byte, error) {
+	for {
+		b, err := r.reader.ReadByte()
+		if err != nil || b != telnetIAC {
+			return b, err
+		}
+
+		command, err := r.reader.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case command == telnetIAC:
+			return telnetIAC, nil
+		case command >= telnetWILL && command <= telnetDONT:
+			_, err = r.reader.ReadByte()
+		case command == telnetSB:
+			err = r.skipSubnegotiation()
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+}
+
+func (r *telnetReader) skipSubnegotiation() error {
+	previous := byte(0)
+	for {
+		b, err := r.reader.ReadByte()
+		if err != nil {
+			return err
+		}
+		if previous == telnetIAC && b == telnetSE {
+			return nil
+		}
+		previous = b
+	}
+}
+
+// ReadLine reads the next line without the line ending. A last line without line ending is returned before io.EOF.
+func (r *telnetReader) ReadLine() (string, error) {
+	var line strings.Builder
+	for {
+		b, err := r.ReadByte()
+		if err == io.EOF && line.Len() > 0 {
+			return line.String(), nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if b == '\n' {
+			return strings.TrimRight(line.String(), "\r"), nil
+		}
+		line.WriteByte(b)
+	}
+}
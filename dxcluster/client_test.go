@@ -0,0 +1,303 @@
+package dxcluster
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ftl/hamradio/bandplan"
+	"github.com/ftl/hamradio/callsign"
+	"github.com/ftl/hamradio/cfg"
+	"github.com/ftl/hamradio/dxcc/dxcctest"
+)
+
+// fakeNode is a DX cluster node that accepts one user. It sends the given prompt, waits for the callsign and then
+// sends the given lines. All commands received from the user are reported through the commands channel.
+type fakeNode struct {
+	listener net.Listener
+	login    chan string
+	commands chan string
+	conn     chan net.Conn
+}
+
+func startFakeNode(t *testing.T, prompt string, lines ...string) *fakeNode {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	result := &fakeNode{
+		listener: listener,
+		login:    make(chan string, 1),
+		commands: make(chan string, 10),
+		conn:     make(chan net.Conn, 1),
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		result.conn <- conn
+		// IAC WILL ECHO, as sent by some nodes
+		conn.Write([]byte{telnetIAC, telnetWILL, 1})
+		conn.Write([]byte("Welcome to the fake node\r\n" + prompt))
+
+		reader := bufio.NewReader(conn)
+		call, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		result.login <- strings.TrimSpace(call)
+		conn.Write([]byte("Hello " + strings.TrimSpace(call) + ", this is FAKE\r\n"))
+		for _, line := range lines {
+			conn.Write([]byte(line + "\r\n"))
+		}
+		for {
+			command, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			result.commands <- strings.TrimSpace(command)
+		}
+	}()
+	return result
+}
+
+func (n *fakeNode) address() string {
+	return n.listener.Addr().String()
+}
+
+func setupClient(t *testing.T, node *fakeNode) *Client {
+	t.Helper()
+	client := New(node.address(), callsign.MustParse("DL1ABC"))
+	client.LoginTimeout = time.Second
+	client.now = func() time.Time { return testNow }
+	client.SetPrefixes(dxcctest.Prefixes())
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+const receiveTimeout = time.Second
+
+func receiveLine(t *testing.T, lines <-chan string) string {
+	t.Helper()
+	select {
+	case line := <-lines:
+		return line
+	case <-time.After(receiveTimeout):
+		t.Fatal("no line received")
+		return ""
+	}
+}
+
+func receiveSpot(t *testing.T, spots <-chan Spot) Spot {
+	t.Helper()
+	select {
+	case spot := <-spots:
+		return spot
+	case <-time.After(receiveTimeout):
+		t.Fatal("no spot received")
+		return Spot{}
+	}
+}
+
+func receiveMessage(t *testing.T, messages <-chan Message) Message {
+	t.Helper()
+	select {
+	case message := <-messages:
+		return message
+	case <-time.After(receiveTimeout):
+		t.Fatal("no message received")
+		return nil
+	}
+}
+
+func TestClient_Login(t *testing.T) {
+	for _, prompt := range []string{"login: ", "Please enter your call: "} {
+		t.Run(prompt, func(t *testing.T) {
+			node := startFakeNode(t, prompt)
+			client := setupClient(t, node)
+
+			require.NoError(t, client.Connect())
+
+			assert.Equal(t, "DL1ABC", receiveLine(t, node.login))
+			assert.True(t, client.IsConnected())
+		})
+	}
+}
+
+func TestClient_NoLoginPrompt(t *testing.T) {
+	node := startFakeNode(t, "")
+	client := setupClient(t, node)
+	client.LoginTimeout = 100 * time.Millisecond
+
+	err := client.Connect()
+
+	assert.ErrorIs(t, err, ErrNoLoginPrompt)
+	assert.False(t, client.IsConnected())
+}
+
+func TestClient_NotBlockedDuringLogin(t *testing.T) {
+	node := startFakeNode(t, "")
+	client := setupClient(t, node)
+	connectErr := make(chan error, 1)
+	go func() {
+		connectErr <- client.Connect()
+	}()
+	<-node.conn
+
+	calls := make(chan struct{})
+	go func() {
+		client.IsConnected()
+		client.AddFilter(Skimmers(false))
+		client.OnSpot(func(Spot) {})
+		client.Send("sh/dx")
+		calls <- struct{}{}
+	}()
+	select {
+	case <-calls:
+	case <-time.After(client.LoginTimeout / 2):
+		t.Fatal("the client is blocked while logging in")
+	}
+
+	require.NoError(t, client.Close())
+	select {
+	case err := <-connectErr:
+		assert.ErrorIs(t, err, ErrNotConnected)
+	case <-time.After(client.LoginTimeout / 2):
+		t.Fatal("Close did not cancel the login")
+	}
+	assert.False(t, client.IsConnected())
+}
+
+func TestClient_InvalidSpotter(t *testing.T) {
+	node := startFakeNode(t, "login: ",
+		"DX de 1234-@:     14025.0  VK2ABC       CW 599                         1234Z",
+	)
+	client := setupClient(t, node)
+	spots := make(chan Spot, 1)
+	client.OnSpot(func(spot Spot) { spots <- spot })
+
+	require.NoError(t, client.Connect())
+
+	spot := receiveSpot(t, spots)
+	assert.Equal(t, "VK2ABC", spot.DX.String())
+	assert.Equal(t, callsign.NoCallsign, spot.Spotter)
+}
+
+func TestClient_Messages(t *testing.T) {
+	node := startFakeNode(t, "login: ",
+		"DX de DL8LAS-#:   7012.3  JA1ABC       CW 12 dB 24 WPM CQ             1238Z",
+		"\aDX de W3LPL:     14025.0  VK2ABC       CW 599                         1234Z FN20\a\a",
+		"DX de K1ABC:     14200.0  K1XYZ        cq cq                          1235Z",
+		"WWV de W0MU <18>:   SFI=172, A=10, K=2, No Storms -> No Storms",
+		"To ALL de SV5FRI: QSL via LoTW",
+		"DL1ABC de FAKE  4-Mar-2023 1240Z dxspider >",
+	)
+	client := setupClient(t, node)
+	client.AddFilter(Any(Continents("OC", "AS"), Skimmers(true)))
+	client.AddFilter(Bands(bandplan.Band20m, bandplan.Band40m))
+	spots := make(chan Spot, 10)
+	client.OnSpot(func(spot Spot) { spots <- spot })
+	messages := make(chan Message, 10)
+	client.OnMessage(func(message Message) { messages <- message })
+
+	require.NoError(t, client.Connect())
+
+	spot := receiveSpot(t, spots)
+	assert.Equal(t, "JA1ABC", spot.DX.String())
+	assert.Equal(t, "Japan", spot.DXCC.Name)
+	assert.Equal(t, bandplan.Band40m, spot.Band)
+	assert.Equal(t, bandplan.ModeCW, spot.Mode)
+	spot = receiveSpot(t, spots)
+	assert.Equal(t, "VK2ABC", spot.DX.String())
+	assert.Equal(t, "Australia", spot.DXCC.Name)
+
+	expected := []MessageType{SpotType, SpotType, WWVType, AnnouncementType}
+	for _, messageType := range expected {
+		assert.Equal(t, messageType, receiveMessage(t, messages).Type())
+	}
+	select {
+	case spot := <-spots:
+		t.Errorf("unexpected spot %v", spot.DX)
+	default:
+	}
+}
+
+func TestClient_SendAndClose(t *testing.T) {
+	node := startFakeNode(t, "login: ")
+	client := setupClient(t, node)
+	errs := make(chan error, 1)
+	client.OnError(func(err error) { errs <- err })
+
+	assert.ErrorIs(t, client.Send("sh/dx"), ErrNotConnected)
+	require.NoError(t, client.Connect())
+	receiveLine(t, node.login)
+
+	require.NoError(t, client.Send("sh/dx 5"))
+	assert.Equal(t, "sh/dx 5", receiveLine(t, node.commands))
+
+	require.NoError(t, client.Close())
+	assert.Equal(t, "bye", receiveLine(t, node.commands))
+	assert.False(t, client.IsConnected())
+	select {
+	case err := <-errs:
+		t.Errorf("unexpected error %v", err)
+	default:
+	}
+}
+
+func TestClient_ConnectionLost(t *testing.T) {
+	node := startFakeNode(t, "login: ")
+	client := setupClient(t, node)
+	errs := make(chan error, 1)
+	client.OnError(func(err error) { errs <- err })
+
+	require.NoError(t, client.Connect())
+	receiveLine(t, node.login)
+	(<-node.conn).Close()
+
+	select {
+	case err := <-errs:
+		assert.Error(t, err)
+	case <-time.After(receiveTimeout):
+		t.Fatal("no error received")
+	}
+	<-client.Done()
+	assert.False(t, client.IsConnected())
+}
+
+func TestClient_CloseFromHandler(t *testing.T) {
+	node := startFakeNode(t, "login: ",
+		"DX de W3LPL:     14025.0  VK2ABC       CW 599                         1234Z FN20",
+	)
+	client := setupClient(t, node)
+	closed := make(chan error, 1)
+	client.OnSpot(func(Spot) { closed <- client.Close() })
+
+	require.NoError(t, client.Connect())
+
+	select {
+	case err := <-closed:
+		assert.NoError(t, err)
+	case <-time.After(receiveTimeout):
+		t.Fatal("Close called from a handler is blocked")
+	}
+	assert.Equal(t, "bye", receiveLine(t, node.commands))
+	<-client.Done()
+	assert.False(t, client.IsConnected())
+}
+
+func TestNewFromConfiguration(t *testing.T) {
+	client, err := NewFromConfiguration("localhost:7300", cfg.Configuration{"my": map[string]interface{}{"call": "dl1abc"}})
+	require.NoError(t, err)
+	assert.Equal(t, callsign.MustParse("DL1ABC"), client.call)
+
+	_, err = NewFromConfiguration("localhost:7300", cfg.Configuration{})
+	assert.ErrorIs(t, err, ErrNoCallsign)
+}
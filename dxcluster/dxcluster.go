@@ -0,0 +1,312 @@
+/*
+Package dxcluster provides a client for DX cluster nodes (DX Spider, AR-Cluster, CC Cluster) and a parser for the
+lines that the nodes send to their users.
+
+The client connects to the node over telnet, logs in with the given callsign (usually my.call from the hamradio
+configuration file) and parses every received line. Parse understands DX spots ("DX de ..."), WWV and WCY
+propagation reports and announcements ("To ALL de ..."), all other lines are ignored. Spots are enriched with the
+DXCC entity of the DX station and the band and mode of the spotted frequency, and can be filtered using these
+values.
+*/
+package dxcluster
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ftl/hamradio"
+	"github.com/ftl/hamradio/bandplan"
+	"github.com/ftl/hamradio/callsign"
+	"github.com/ftl/hamradio/dxcc"
+	"github.com/ftl/hamradio/locator"
+)
+
+// ErrUnknownLine is returned by Parse if the line is neither a spot, a WWV or WCY report nor an announcement.
+var ErrUnknownLine = errors.New("unknown line")
+
+// MessageType identifies the type of a message.
+type MessageType int
+
+// The message types.
+const (
+	SpotType MessageType = iota
+	WWVType
+	WCYType
+	AnnouncementType
+)
+
+func (t MessageType) String() string {
+	switch t {
+	case SpotType:
+		return "Spot"
+	case WWVType:
+		return "WWV"
+	case WCYType:
+		return "WCY"
+	case AnnouncementType:
+		return "Announcement"
+	default:
+		return "Unknown"
+	}
+}
+
+// Message is a message received from a DX cluster node.
+type Message interface {
+	// Type returns the type of the message.
+	Type() MessageType
+}
+
+// Spot is a DX spot: "DX de DL1ABC:     14025.0  VK2ABC       CW 599 up 2                    1234Z JO62"
+type Spot struct {
+	// Spotter is the callsign of the spotter. It is callsign.NoCallsign if the node sent a spotter that is not a
+	// valid callsign.
+	Spotter callsign.Callsign
+	// Skimmer is true if the spot was generated by a CW or RTTY skimmer, i.e. the spotter ends with "-#".
+	Skimmer   bool
+	Frequency hamradio.Frequency
+	DX        callsign.Callsign
+	Comment   string
+	Time      time.Time
+	// SpotterLocator is the locator of the spotter, some nodes send it after the time. It is the zero value if the
+	// locator is missing or invalid.
+	SpotterLocator locator.Locator
+
+	// The following fields are filled by Enrich.
+
+	// DXCC is the DXCC entity of the DX station. It is the zero value if the entity is unknown.
+	DXCC dxcc.Prefix
+	Band bandplan.BandName
+	// Mode is the mode of the bandplan portion that contains the frequency. It is empty if the frequency is outside
+	// of the bandplan.
+	Mode bandplan.Mode
+}
+
+// WWV is a report of the solar and geomagnetic indices: "WWV de W0MU <18>:   SFI=172, A=10, K=2, No Storms -> No Storms"
+type WWV struct {
+	Spotter  callsign.Callsign
+	Time     time.Time
+	SFI      int
+	A        int
+	K        int
+	Forecast string
+}
+
+// WCY is the report of the solar and geomagnetic conditions from DK0WCY:
+// "WCY de DK0WCY-1 <10> : K=3 expK=0 A=12 R=85 SFI=147 SA=qui GMF=qui Au=no"
+type WCY struct {
+	Spotter callsign.Callsign
+	Time    time.Time
+	K       int
+	ExpK    int
+	A       int
+	R       int
+	SFI     int
+	SA      string
+	GMF     string
+	Aurora  string
+}
+
+// Announcement is a message to all users of the cluster or the local node: "To ALL de DL1ABC: QRV on 6m"
+type Announcement struct {
+	// To is the addressee of the announcement, e.g. "ALL" or "LOCAL".
+	To   string
+	From callsign.Callsign
+	// Time is the zero value if the node does not send the time of the announcement.
+	Time time.Time
+	Text string
+}
+
+func (Spot) Type() MessageType         { return SpotType }
+func (WWV) Type() MessageType          { return WWVType }
+func (WCY) Type() MessageType          { return WCYType }
+func (Announcement) Type() MessageType { return AnnouncementType }
+
+var (
+	spotExpression         = regexp.MustCompile(`^DX DE ([^\s:]+):\s*(\d+(?:\.\d+)?)\s+([A-Z0-9/]+)\s+(.*?)\s*(\d{4})Z(?:\s+([A-R]{2}\d{2}(?:[A-X]{2})?))?\s*$`)
+	wwvExpression          = regexp.MustCompile(`^WWV DE ([A-Z0-9/\-#]+)\s+<(\d{2})>\s*:\s*SFI=(\d+),\s*A=(\d+),\s*K=(\d+),\s*(.*?)\s*$`)
+	wcyExpression          = regexp.MustCompile(`^WCY DE ([A-Z0-9/\-#]+)\s+<(\d{2})>\s*:\s*(.*?)\s*$`)
+	announcementExpression = regexp.MustCompile(`^TO ([A-Z0-9/]+) DE ([A-Z0-9/\-#]+)(?:\s+<(\d{4})Z>)?\s*:\s*(.*?)\s*$`)
+)
+
+// Parse parses a line received from a DX cluster node. The nodes only send the time of day, now is used to complete
+// the date. Parse does not enrich spots, use Enrich for that.
+func Parse(line string, now time.Time) (Message, error) {
+	line = strings.TrimRight(line, "\r\n\a ")
+	upperLine := strings.ToUpper(line)
+
+	if matches, original := submatches(spotExpression, line, upperLine); matches != nil {
+		return parseSpot(line, matches, original, now)
+	}
+	if matches, original := submatches(wwvExpression, line, upperLine); matches != nil {
+		return parseWWV(matches, original, now)
+	}
+	if matches, original := submatches(wcyExpression, line, upperLine); matches != nil {
+		return parseWCY(line, matches, original, now)
+	}
+	if matches, original := submatches(announcementExpression, line, upperLine); matches != nil {
+		return parseAnnouncement(matches, original, now)
+	}
+	return nil, ErrUnknownLine
+}
+
+func parseSpot(line string, matches, original []string, now time.Time) (Spot, error) {
+	var result Spot
+	// an invalid spotter or locator does not make the spot itself useless
+	result.Spotter, result.Skimmer, _ = parseSpotter(matches[1])
+	kHz, err := strconv.ParseFloat(matches[2], 64)
+	if err != nil {
+		return Spot{}, fmt.Errorf("invalid frequency in %q: %w", line, err)
+	}
+	result.Frequency = hamradio.Frequency(math.Round(kHz * 1000))
+	result.DX, err = callsign.Parse(matches[3])
+	if err != nil {
+		return Spot{}, err
+	}
+	result.Comment = original[4]
+	result.Time = timeOfDay(matches[5], now)
+	if matches[6] != "" {
+		result.SpotterLocator, _ = locator.Parse(matches[6])
+	}
+	return result, nil
+}
+
+func parseWWV(matches, original []string, now time.Time) (WWV, error) {
+	var result WWV
+	var err error
+	result.Spotter, _, err = parseSpotter(matches[1])
+	if err != nil {
+		return WWV{}, err
+	}
+	result.Time = timeOfDay(matches[2]+"00", now)
+	result.SFI, _ = strconv.Atoi(matches[3])
+	result.A, _ = strconv.Atoi(matches[4])
+	result.K, _ = strconv.Atoi(matches[5])
+	result.Forecast = original[6]
+	return result, nil
+}
+
+func parseWCY(line string, matches, original []string, now time.Time) (WCY, error) {
+	var result WCY
+	var err error
+	result.Spotter, _, err = parseSpotter(matches[1])
+	if err != nil {
+		return WCY{}, err
+	}
+	result.Time = timeOfDay(matches[2]+"00", now)
+	for _, field := range strings.Fields(original[3]) {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			continue
+		}
+		switch strings.ToUpper(key) {
+		case "K":
+			result.K, err = strconv.Atoi(value)
+		case "EXPK":
+			result.ExpK, err = strconv.Atoi(value)
+		case "A":
+			result.A, err = strconv.Atoi(value)
+		case "R":
+			result.R, err = strconv.Atoi(value)
+		case "SFI":
+			result.SFI, err = strconv.Atoi(value)
+		case "SA":
+			result.SA = value
+		case "GMF":
+			result.GMF = value
+		case "AU":
+			result.Aurora = value
+		}
+		if err != nil {
+			return WCY{}, fmt.Errorf("invalid value in %q: %w", line, err)
+		}
+	}
+	return result, nil
+}
+
+func parseAnnouncement(matches, original []string, now time.Time) (Announcement, error) {
+	var result Announcement
+	var err error
+	result.To = matches[1]
+	result.From, _, err = parseSpotter(matches[2])
+	if err != nil {
+		return Announcement{}, err
+	}
+	if matches[3] != "" {
+		result.Time = timeOfDay(matches[3], now)
+	}
+	result.Text = original[4]
+	return result, nil
+}
+
+// parseSpotter parses the callsign of a spotter. Nodes and skimmers add a suffix separated by a dash, e.g. "DK0WCY-1"
+// or "DL8LAS-#".
+func parseSpotter(s string) (callsign.Callsign, bool, error) {
+	call, _, _ := strings.Cut(s, "-")
+	result, err := callsign.Parse(call)
+	if err != nil {
+		return callsign.NoCallsign, strings.HasSuffix(s, "-#"), err
+	}
+	return result, strings.HasSuffix(s, "-#"), nil
+}
+
+// submatches matches the given expression against the upper case line. It returns the upper case submatches and the
+// same submatches in the original case of the line, e.g. for comments.
+func submatches(expression *regexp.Regexp, line, upperLine string) ([]string, []string) {
+	indexes := expression.FindStringSubmatchIndex(upperLine)
+	if indexes == nil {
+		return nil, nil
+	}
+	sameLength := len(line) == len(upperLine)
+	upper := make([]string, len(indexes)/2)
+	original := make([]string, len(indexes)/2)
+	for i := range upper {
+		start, end := indexes[2*i], indexes[2*i+1]
+		if start < 0 {
+			continue
+		}
+		upper[i] = upperLine[start:end]
+		original[i] = upper[i]
+		if sameLength {
+			original[i] = line[start:end]
+		}
+	}
+	return upper, original
+}
+
+// timeOfDay returns the given time of day (HHMM) on the date of now. Times that are more than one hour after now are
+// considered to be on the previous day.
+func timeOfDay(hhmm string, now time.Time) time.Time {
+	hours, _ := strconv.Atoi(hhmm[:2])
+	minutes, _ := strconv.Atoi(hhmm[2:])
+	now = now.UTC()
+	result := time.Date(now.Year(), now.Month(), now.Day(), hours, minutes, 0, 0, time.UTC)
+	if result.After(now.Add(time.Hour)) {
+		result = result.AddDate(0, 0, -1)
+	}
+	return result
+}
+
+// Enrich fills the DXCC entity, band and mode of the given spot, using the given prefixes and bandplan. If prefixes
+// is nil, the DXCC entity is not filled.
+func Enrich(spot *Spot, prefixes *dxcc.Prefixes, plan bandplan.Bandplan) {
+	if prefixes != nil {
+		if found, ok := prefixes.FindCallsign(spot.DX); ok {
+			spot.DXCC = found
+		}
+	}
+
+	band := plan.ByFrequency(spot.Frequency)
+	spot.Band = band.Name
+	spot.Mode = ""
+	for _, portion := range band.Portions {
+		if portion.Contains(spot.Frequency) {
+			spot.Mode = portion.Mode
+			break
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package dxcluster
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ftl/hamradio"
+	"github.com/ftl/hamradio/bandplan"
+	"github.com/ftl/hamradio/callsign"
+	"github.com/ftl/hamradio/dxcc/dxcctest"
+	"github.com/ftl/hamradio/locator"
+)
+
+var testNow = time.Date(2023, time.March, 4, 12, 40, 0, 0, time.UTC)
+
+func TestParse_Spot(t *testing.T) {
+	tt := []struct {
+		desc     string
+		line     string
+		expected Spot
+	}{
+		{
+			desc: "DX Spider",
+			line: "DX de DL1ABC:     14025.0  VK2ABC       CW 599 up 2                    1234Z",
+			expected: Spot{Spotter: callsign.MustParse("DL1ABC"), Frequency: 14025000, DX: callsign.MustParse("VK2ABC"),
+				Comment: "CW 599 up 2", Time: time.Date(2023, time.March, 4, 12, 34, 0, 0, time.UTC)},
+		},
+		{
+			desc: "skimmer",
+			line: "DX de DL8LAS-#:   7012.3  JA1ABC       CW 12 dB 24 WPM CQ             1238Z",
+			expected: Spot{Spotter: callsign.MustParse("DL8LAS"), Skimmer: true, Frequency: 7012300, DX: callsign.MustParse("JA1ABC"),
+				Comment: "CW 12 dB 24 WPM CQ", Time: time.Date(2023, time.March, 4, 12, 38, 0, 0, time.UTC)},
+		},
+		{
+			desc: "CC Cluster with locator and lower case comment",
+			line: "DX de W3LPL:     3795.5  DL/VK2ABC    tnx qso 73                     2350Z FN20\a\a\r\n",
+			expected: Spot{Spotter: callsign.MustParse("W3LPL"), Frequency: 3795500, DX: callsign.MustParse("DL/VK2ABC"),
+				Comment: "tnx qso 73", Time: time.Date(2023, time.March, 3, 23, 50, 0, 0, time.UTC),
+				SpotterLocator: locator.MustParse("FN20")},
+		},
+		{
+			desc: "invalid spotter",
+			line: "DX de 1234-@:     14025.0  VK2ABC       CW 599                         1234Z",
+			expected: Spot{Frequency: 14025000, DX: callsign.MustParse("VK2ABC"),
+				Comment: "CW 599", Time: time.Date(2023, time.March, 4, 12, 34, 0, 0, time.UTC)},
+		},
+		{
+			desc: "skimmer with node suffix",
+			line: "DX de DL8LAS-2-#:   7012.3  JA1ABC       CW 12 dB 24 WPM CQ             1238Z",
+			expected: Spot{Spotter: callsign.MustParse("DL8LAS"), Skimmer: true, Frequency: 7012300, DX: callsign.MustParse("JA1ABC"),
+				Comment: "CW 12 dB 24 WPM CQ", Time: time.Date(2023, time.March, 4, 12, 38, 0, 0, time.UTC)},
+		},
+		{
+			desc: "no comment",
+			line: "DX de DL1ABC: 50313.0 VK2ABC 1240Z",
+			expected: Spot{Spotter: callsign.MustParse("DL1ABC"), Frequency: 50313000, DX: callsign.MustParse("VK2ABC"),
+				Time: time.Date(2023, time.March, 4, 12, 40, 0, 0, time.UTC)},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			actual, err := Parse(tc.line, testNow)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestParse_WWV(t *testing.T) {
+	actual, err := Parse("WWV de W0MU <18>:   SFI=172, A=10, K=2, No Storms -> No Storms", testNow)
+	require.NoError(t, err)
+
+	assert.Equal(t, WWV{
+		Spotter:  callsign.MustParse("W0MU"),
+		Time:     time.Date(2023, time.March, 3, 18, 0, 0, 0, time.UTC),
+		SFI:      172,
+		A:        10,
+		K:        2,
+		Forecast: "No Storms -> No Storms",
+	}, actual)
+}
+
+func TestParse_WCY(t *testing.T) {
+	actual, err := Parse("WCY de DK0WCY-1 <10> : K=3 expK=0 A=12 R=85 SFI=147 SA=qui GMF=qui Au=no", testNow)
+	require.NoError(t, err)
+
+	assert.Equal(t, WCY{
+		Spotter: callsign.MustParse("DK0WCY"),
+		Time:    time.Date(2023, time.March, 4, 10, 0, 0, 0, time.UTC),
+		K:       3,
+		ExpK:    0,
+		A:       12,
+		R:       85,
+		SFI:     147,
+		SA:      "qui",
+		GMF:     "qui",
+		Aurora:  "no",
+	}, actual)
+}
+
+func TestParse_Announcement(t *testing.T) {
+	actual, err := Parse("To ALL de SV5FRI: QSL via LoTW", testNow)
+	require.NoError(t, err)
+	assert.Equal(t, Announcement{To: "ALL", From: callsign.MustParse("SV5FRI"), Text: "QSL via LoTW"}, actual)
+
+	actual, err = Parse("To LOCAL de DL1ABC-2 <1215Z> : Node restarts at 1300z", testNow)
+	require.NoError(t, err)
+	assert.Equal(t, Announcement{To: "LOCAL", From: callsign.MustParse("DL1ABC"), Time: time.Date(2023, time.March, 4, 12, 15, 0, 0, time.UTC),
+		Text: "Node restarts at 1300z"}, actual)
+}
+
+func TestParse_Invalid(t *testing.T) {
+	_, err := Parse("Hello DL1ABC, this is DB0SDX", testNow)
+	assert.ErrorIs(t, err, ErrUnknownLine)
+
+	_, err = Parse("DL1ABC de DB0SDX  4-Mar-2023 1240Z dxspider >", testNow)
+	assert.ErrorIs(t, err, ErrUnknownLine)
+
+	_, err = Parse("DX de DL1ABC:     14025.0  1234       CW                             1234Z", testNow)
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrUnknownLine)
+}
+
+func TestEnrich(t *testing.T) {
+	prefixes := dxcctest.Prefixes()
+	tt := []struct {
+		dx        string
+		frequency hamradio.Frequency
+		entity    string
+		band      bandplan.BandName
+		mode      bandplan.Mode
+	}{
+		{"VK2ABC", 14025000, "Australia", bandplan.Band20m, bandplan.ModeCW},
+		{"DL/VK2ABC", 3795500, "Fed. Rep. of Germany", bandplan.Band80m, bandplan.ModePhone},
+		{"JA1ABC", 7045000, "Japan", bandplan.Band40m, bandplan.ModeDigital},
+		{"K1ABC", 144300000, "", bandplan.BandUnknown, ""},
+	}
+	for _, tc := range tt {
+		t.Run(tc.dx, func(t *testing.T) {
+			spot := Spot{DX: callsign.MustParse(tc.dx), Frequency: tc.frequency}
+			Enrich(&spot, prefixes, bandplan.IARURegion1)
+
+			assert.Equal(t, tc.entity, spot.DXCC.Name)
+			assert.Equal(t, tc.band, spot.Band)
+			assert.Equal(t, tc.mode, spot.Mode)
+		})
+	}
+}
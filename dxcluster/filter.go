@@ -0,0 +1,98 @@
+package dxcluster
+
+import (
+	"strings"
+
+	"github.com/ftl/hamradio/bandplan"
+)
+
+// Filter selects the spots that are reported. It returns true if the given spot should be reported. Filters are
+// applied to enriched spots.
+type Filter func(Spot) bool
+
+// All returns a filter that accepts a spot if all the given filters accept it.
+func All(filters ...Filter) Filter {
+	return func(spot Spot) bool {
+		for _, filter := range filters {
+			if !filter(spot) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Any returns a filter that accepts a spot if at least one of the given filters accepts it.
+func Any(filters ...Filter) Filter {
+	return func(spot Spot) bool {
+		for _, filter := range filters {
+			if filter(spot) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Not returns a filter that accepts a spot if the given filter rejects it.
+func Not(filter Filter) Filter {
+	return func(spot Spot) bool {
+		return !filter(spot)
+	}
+}
+
+// Bands returns a filter that accepts the spots on the given bands.
+func Bands(bands ...bandplan.BandName) Filter {
+	return func(spot Spot) bool {
+		for _, band := range bands {
+			if spot.Band == band {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Modes returns a filter that accepts the spots in the bandplan portions of the given modes.
+func Modes(modes ...bandplan.Mode) Filter {
+	return func(spot Spot) bool {
+		for _, mode := range modes {
+			if spot.Mode == mode {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Continents returns a filter that accepts the spots of DX stations on the given continents, e.g. "EU" or "OC".
+func Continents(continents ...string) Filter {
+	return func(spot Spot) bool {
+		for _, continent := range continents {
+			if strings.EqualFold(spot.DXCC.Continent, continent) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Entities returns a filter that accepts the spots of DX stations in the DXCC entities with the given primary
+// prefixes, e.g. "DL" or "VK".
+func Entities(primaryPrefixes ...string) Filter {
+	return func(spot Spot) bool {
+		for _, prefix := range primaryPrefixes {
+			if spot.DXCC.PrimaryPrefix != "" && strings.EqualFold(spot.DXCC.PrimaryPrefix, prefix) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Skimmers returns a filter that accepts only spots from skimmers, or only spots from humans.
+func Skimmers(skimmer bool) Filter {
+	return func(spot Spot) bool {
+		return spot.Skimmer == skimmer
+	}
+}
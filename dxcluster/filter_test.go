@@ -0,0 +1,37 @@
+package dxcluster
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ftl/hamradio/bandplan"
+	"github.com/ftl/hamradio/dxcc"
+)
+
+func TestFilters(t *testing.T) {
+	vk := Spot{DXCC: dxcc.Prefix{PrimaryPrefix: "VK", Continent: "OC"}, Band: bandplan.Band20m, Mode: bandplan.ModeCW, Skimmer: true}
+	dl := Spot{DXCC: dxcc.Prefix{PrimaryPrefix: "DL", Continent: "EU"}, Band: bandplan.Band40m, Mode: bandplan.ModePhone}
+	unknown := Spot{Band: bandplan.BandUnknown}
+
+	tt := []struct {
+		desc     string
+		filter   Filter
+		expected []bool
+	}{
+		{"bands", Bands(bandplan.Band20m, bandplan.Band40m), []bool{true, true, false}},
+		{"modes", Modes(bandplan.ModeCW), []bool{true, false, false}},
+		{"continents", Continents("oc", "AS"), []bool{true, false, false}},
+		{"entities", Entities("DL"), []bool{false, true, false}},
+		{"skimmers", Skimmers(false), []bool{false, true, true}},
+		{"all", All(Bands(bandplan.Band20m), Continents("EU")), []bool{false, false, false}},
+		{"any", Any(Bands(bandplan.Band20m), Continents("EU")), []bool{true, true, false}},
+		{"not", Not(Entities("VK")), []bool{false, true, true}},
+		{"empty all", All(), []bool{true, true, true}},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			assert.Equal(t, tc.expected, []bool{tc.filter(vk), tc.filter(dl), tc.filter(unknown)})
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package morse
+
+// preferredDecodings resolves codes that are used by several characters or prosigns.
+var preferredDecodings = map[string]string{
+	".-.-":   "Ä",
+	".--.-":  "À",
+	"-.-..":  "Ç",
+	"..-..":  "É",
+	"---.":   "Ö",
+	"..--":   "Ü",
+	"--.--":  "Ñ",
+	"----":   "Ĥ",
+	"-.-.-":  "<KA>",
+	"...-.":  "<SN>",
+	".-.-.":  "+",
+	".-...":  "&",
+	"-...-":  "=",
+	"-.--.":  "(",
+	"--..-.": "Ź",
+}
+
+var decodings = buildDecodings()
+
+func buildDecodings() map[string]string {
+	result := make(map[string]string, len(characters)+len(prosigns))
+	for name, code := range prosigns {
+		result[code] = "<" + name + ">"
+	}
+	for r, code := range characters {
+		result[code] = string(r)
+	}
+	for code, text := range preferredDecodings {
+		result[code] = text
+	}
+	return result
+}
+
+// Decode returns the character or prosign (in angle brackets) for the given code of dots and dashes.
+func Decode(code string) (string, bool) {
+	result, ok := decodings[code]
+	return result, ok
+}
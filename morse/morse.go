@@ -0,0 +1,178 @@
+/*
+Package morse encodes text into Morse code and calculates the timing of the keyed signal.
+
+The text may contain letters, digits, punctuation, some non-ASCII characters (e.g. Ä, Ö, Ü, É, Ñ) and
+prosigns in angle brackets (e.g. <AR>, <SK>, <BT>, <KN>). Lower case letters are converted to upper case.
+*/
+package morse
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Element is a single element of a Morse code, either a Dit or a Dah.
+type Element byte
+
+// The elements of a Morse code.
+const (
+	Dit Element = '.'
+	Dah Element = '-'
+)
+
+// Symbol is a character, a prosign or a word space together with its Morse code.
+type Symbol struct {
+	// Text is the encoded character, the prosign in angle brackets (e.g. <AR>) or a single space.
+	Text string
+	// Code contains the elements as dots and dashes, it is empty for a word space.
+	Code string
+}
+
+// IsSpace returns true if this symbol is a word space.
+func (s Symbol) IsSpace() bool {
+	return s.Code == ""
+}
+
+// Elements returns the elements of this symbol.
+func (s Symbol) Elements() []Element {
+	return []Element(s.Code)
+}
+
+var characters = map[rune]string{
+	'A': ".-", 'B': "-...", 'C': "-.-.", 'D': "-..", 'E': ".", 'F': "..-.", 'G': "--.", 'H': "....",
+	'I': "..", 'J': ".---", 'K': "-.-", 'L': ".-..", 'M': "--", 'N': "-.", 'O': "---", 'P': ".--.",
+	'Q': "--.-", 'R': ".-.", 'S': "...", 'T': "-", 'U': "..-", 'V': "...-", 'W': ".--", 'X': "-..-",
+	'Y': "-.--", 'Z': "--..",
+
+	'0': "-----", '1': ".----", '2': "..---", '3': "...--", '4': "....-",
+	'5': ".....", '6': "-....", '7': "--...", '8': "---..", '9': "----.",
+
+	'.': ".-.-.-", ',': "--..--", '?': "..--..", '\'': ".----.", '!': "-.-.--", '/': "-..-.",
+	'(': "-.--.", ')': "-.--.-", '&': ".-...", ':': "---...", ';': "-.-.-.", '=': "-...-",
+	'+': ".-.-.", '-': "-....-", '_': "..--.-", '"': ".-..-.", '$': "...-..-", '@': ".--.-.",
+
+	'Ä': ".-.-", 'Æ': ".-.-", 'Ą': ".-.-", 'À': ".--.-", 'Å': ".--.-", 'Ç': "-.-..", 'Ĉ': "-.-..",
+	'Ð': "..--.", 'É': "..-..", 'Ę': "..-..", 'È': ".-..-", 'Ĝ': "--.-.", 'Ĥ': "----", 'Ĵ': ".---.",
+	'Ñ': "--.--", 'Ń': "--.--", 'Ö': "---.", 'Ø': "---.", 'Ó': "---.", 'Ŝ': "...-.", 'Š': "----",
+	'Þ': ".--..", 'Ü': "..--", 'Ŭ': "..--", 'Ź': "--..-.", 'Ż': "--..-",
+}
+
+var prosigns = map[string]string{
+	"AR":  ".-.-.",
+	"AS":  ".-...",
+	"BK":  "-...-.-",
+	"BT":  "-...-",
+	"CL":  "-.-..-..",
+	"CT":  "-.-.-",
+	"HH":  "........",
+	"KA":  "-.-.-",
+	"KN":  "-.--.",
+	"SK":  "...-.-",
+	"SN":  "...-.",
+	"SOS": "...---...",
+	"VE":  "...-.",
+}
+
+// Lookup returns the Morse code of the given character.
+func Lookup(r rune) (string, bool) {
+	result, ok := characters[unicode.ToUpper(r)]
+	return result, ok
+}
+
+// Prosign returns the Morse code of the given prosign (e.g. AR or <AR>).
+func Prosign(name string) (string, bool) {
+	name = strings.TrimSuffix(strings.TrimPrefix(strings.ToUpper(name), "<"), ">")
+	result, ok := prosigns[name]
+	return result, ok
+}
+
+// Prosigns returns the names of all known prosigns in alphabetical order.
+func Prosigns() []string {
+	result := make([]string, 0, len(prosigns))
+	for name := range prosigns {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// UnknownError is returned by Encode if the text contains characters or prosigns that cannot be encoded.
+type UnknownError struct {
+	Unknown []string
+}
+
+func (e *UnknownError) Error() string {
+	return fmt.Sprintf("cannot encode %s", strings.Join(e.Unknown, ", "))
+}
+
+// Encode encodes the given text into a sequence of symbols. Whitespace is encoded as word space, consecutive
+// whitespace is kept. Prosigns are given in angle brackets, e.g. <AR>. If the text contains characters or
+// prosigns that cannot be encoded, they are skipped and an UnknownError is returned together with the symbols of
+// all other characters.
+func Encode(text string) ([]Symbol, error) {
+	result := make([]Symbol, 0, len(text))
+	var unknown []string
+	runes := []rune(text)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			result = append(result, Symbol{Text: " "})
+		case r == '<':
+			end := -1
+			for j := i + 1; j < len(runes); j++ {
+				if runes[j] == '>' {
+					end = j
+					break
+				}
+			}
+			if end == -1 {
+				unknown = append(unknown, string(runes[i:]))
+				i = len(runes)
+				continue
+			}
+			name := string(runes[i : end+1])
+			i = end
+			code, ok := Prosign(name)
+			if !ok {
+				unknown = append(unknown, name)
+				continue
+			}
+			result = append(result, Symbol{Text: strings.ToUpper(name), Code: code})
+		default:
+			code, ok := Lookup(r)
+			if !ok {
+				unknown = append(unknown, string(r))
+				continue
+			}
+			result = append(result, Symbol{Text: string(unicode.ToUpper(r)), Code: code})
+		}
+	}
+	if len(unknown) > 0 {
+		return result, &UnknownError{Unknown: unknown}
+	}
+	return result, nil
+}
+
+// Code returns the given text as readable Morse code: the characters are separated by a space, the words by " / ".
+func Code(text string) (string, error) {
+	symbols, err := Encode(text)
+	words := make([]string, 0)
+	word := make([]string, 0)
+	for _, symbol := range symbols {
+		if !symbol.IsSpace() {
+			word = append(word, symbol.Code)
+			continue
+		}
+		if len(word) > 0 {
+			words = append(words, strings.Join(word, " "))
+			word = word[:0]
+		}
+	}
+	if len(word) > 0 {
+		words = append(words, strings.Join(word, " "))
+	}
+	return strings.Join(words, " / "), err
+}
@@ -0,0 +1,100 @@
+package morse
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncode(t *testing.T) {
+	testCases := []struct {
+		desc    string
+		text    string
+		code    string
+		invalid bool
+	}{
+		{desc: "letters", text: "cq de dl1abc", code: "-.-. --.- / -.. . / -.. .-.. .---- .- -... -.-."},
+		{desc: "prosigns", text: "tu <sk>", code: "- ..- / ...-.-"},
+		{desc: "prosign in word", text: "5nn<BT>", code: "..... -. -. -...-"},
+		{desc: "non-ASCII", text: "Äöü", code: ".-.- ---. ..--"},
+		{desc: "multiple spaces", text: " a  b ", code: ".- / -..."},
+		{desc: "unknown character", text: "a#b", code: ".- -...", invalid: true},
+		{desc: "unknown prosign", text: "a<XY>b", code: ".- -...", invalid: true},
+		{desc: "open prosign", text: "a<AR", code: ".-", invalid: true},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			code, err := Code(tC.text)
+			if tC.invalid {
+				var unknownErr *UnknownError
+				assert.ErrorAs(t, err, &unknownErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tC.code, code)
+		})
+	}
+}
+
+func TestEncode_Symbols(t *testing.T) {
+	symbols, err := Encode("r <ar>")
+	require.NoError(t, err)
+	assert.Equal(t, []Symbol{
+		{Text: "R", Code: ".-."},
+		{Text: " "},
+		{Text: "<AR>", Code: ".-.-."},
+	}, symbols)
+	assert.Equal(t, []Element{Dit, Dah, Dit}, symbols[0].Elements())
+}
+
+func TestLookup(t *testing.T) {
+	code, ok := Lookup('q')
+	assert.True(t, ok)
+	assert.Equal(t, "--.-", code)
+
+	code, ok = Prosign("<kn>")
+	assert.True(t, ok)
+	assert.Equal(t, "-.--.", code)
+
+	assert.Contains(t, Prosigns(), "SK")
+}
+
+func TestDecode(t *testing.T) {
+	testCases := []struct {
+		code  string
+		text  string
+		valid bool
+	}{
+		{code: ".-", text: "A", valid: true},
+		{code: "-----", text: "0", valid: true},
+		{code: "..--..", text: "?", valid: true},
+		{code: "...-.-", text: "<SK>", valid: true},
+		{code: "-...-", text: "=", valid: true},
+		{code: ".-.-", text: "Ä", valid: true},
+		{code: "........", text: "<HH>", valid: true},
+		{code: ".-.-.-.-.-", valid: false},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.code, func(t *testing.T) {
+			text, ok := Decode(tC.code)
+			assert.Equal(t, tC.valid, ok)
+			assert.Equal(t, tC.text, text)
+		})
+	}
+}
+
+func TestDecode_Roundtrip(t *testing.T) {
+	for r, code := range characters {
+		text, ok := Decode(code)
+		require.True(t, ok, string(r))
+		var actual string
+		if strings.HasPrefix(text, "<") {
+			actual, _ = Prosign(text)
+		} else {
+			actual, _ = Lookup([]rune(text)[0])
+		}
+		assert.Equal(t, code, actual, string(r))
+	}
+}
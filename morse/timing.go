@@ -0,0 +1,146 @@
+package morse
+
+import (
+	"time"
+)
+
+// DefaultWPM is used if the speed of a Timing is not set.
+const DefaultWPM = 20
+
+// Timing describes how Morse code is keyed.
+type Timing struct {
+	// WPM is the character speed in words per minute, based on the word PARIS (50 dits incl. the word space).
+	WPM int
+	// Farnsworth is the effective speed in words per minute. If it is lower than WPM, the characters are keyed
+	// with WPM, but the spaces between characters and words are stretched to reach the effective speed.
+	// 0 disables the Farnsworth spacing.
+	Farnsworth int
+	// Weight changes the ratio between the marks and the spaces in percent of a dit [-50..50], without changing
+	// the overall speed. Positive values make the marks longer, negative values make them shorter.
+	Weight int
+}
+
+// Mark is a key-down interval in a timeline.
+type Mark struct {
+	Start    time.Duration
+	Duration time.Duration
+}
+
+// End returns the time of the key-up.
+func (m Mark) End() time.Duration {
+	return m.Start + m.Duration
+}
+
+// SymbolTiming contains the position of a symbol within a timeline.
+type SymbolTiming struct {
+	Symbol Symbol
+	// Start is the time of the first key-down, or the start of the space.
+	Start time.Duration
+	// End is the end of the following space, i.e. the earliest start of the next symbol.
+	End time.Duration
+}
+
+// Timeline contains the exact keying times of a sequence of symbols.
+type Timeline struct {
+	Marks   []Mark
+	Symbols []SymbolTiming
+	// Duration is the time from the start until the last key-up.
+	Duration time.Duration
+	// Total is the time from the start until the end of the spacing after the last symbol.
+	Total time.Duration
+}
+
+// Dit returns the length of a dit.
+func (t Timing) Dit() time.Duration {
+	wpm := t.WPM
+	if wpm <= 0 {
+		wpm = DefaultWPM
+	}
+	return 1200 * time.Millisecond / time.Duration(wpm)
+}
+
+// spaces returns the length of the space between characters and between words.
+func (t Timing) spaces() (character time.Duration, word time.Duration) {
+	dit := t.Dit()
+	wpm := t.WPM
+	if wpm <= 0 {
+		wpm = DefaultWPM
+	}
+	if t.Farnsworth <= 0 || t.Farnsworth >= wpm {
+		return 3 * dit, 7 * dit
+	}
+
+	// see "A Standard for Morse Timing Using the Farnsworth Technique", ARRL
+	c := float64(wpm)
+	s := float64(t.Farnsworth)
+	totalDelay := time.Duration((60*c - 37.2*s) / (s * c) * float64(time.Second))
+	return 3 * totalDelay / 19, 7 * totalDelay / 19
+}
+
+func (t Timing) weight() int {
+	switch {
+	case t.Weight < -50:
+		return -50
+	case t.Weight > 50:
+		return 50
+	default:
+		return t.Weight
+	}
+}
+
+// Timeline calculates the keying times of the given symbols.
+func (t Timing) Timeline(symbols []Symbol) Timeline {
+	dit := t.Dit()
+	characterSpace, wordSpace := t.spaces()
+	extension := dit * time.Duration(t.weight()) / 100
+
+	result := Timeline{
+		Marks:   make([]Mark, 0, len(symbols)*4),
+		Symbols: make([]SymbolTiming, 0, len(symbols)),
+	}
+	var now time.Duration
+	afterCharacter := false
+	for _, symbol := range symbols {
+		timing := SymbolTiming{Symbol: symbol, Start: now}
+		if symbol.IsSpace() {
+			if afterCharacter {
+				now += wordSpace - characterSpace
+			} else {
+				now += wordSpace
+			}
+			afterCharacter = false
+			timing.End = now
+			result.Symbols = append(result.Symbols, timing)
+			continue
+		}
+
+		for i, element := range symbol.Elements() {
+			length := dit
+			if element == Dah {
+				length = 3 * dit
+			}
+			mark := Mark{Start: now, Duration: length + extension}
+			result.Marks = append(result.Marks, mark)
+			now = mark.End()
+			result.Duration = now
+			if i < len(symbol.Code)-1 {
+				now += dit - extension
+			} else {
+				now += characterSpace - extension
+			}
+		}
+		afterCharacter = true
+		timing.End = now
+		result.Symbols = append(result.Symbols, timing)
+	}
+	result.Total = now
+
+	return result
+}
+
+// Duration returns the time from the start until the last key-up when the given text is keyed. Characters that
+// cannot be encoded are ignored.
+func (t Timing) Duration(text string) time.Duration {
+	symbols, _ := Encode(text)
+	return t.Timeline(symbols).Duration
+}
@@ -0,0 +1,76 @@
+package morse
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTiming_Paris(t *testing.T) {
+	for _, wpm := range []int{5, 12, 20, 25, 40} {
+		timing := Timing{WPM: wpm}
+		symbols, err := Encode("PARIS ")
+		require.NoError(t, err)
+
+		timeline := timing.Timeline(symbols)
+
+		assert.Equal(t, time.Minute/time.Duration(wpm), timeline.Total, "%d WpM", wpm)
+		assert.Equal(t, timeline.Total-7*timing.Dit(), timeline.Duration, "%d WpM", wpm)
+	}
+}
+
+func TestTiming_Timeline(t *testing.T) {
+	timing := Timing{WPM: 20}
+	symbols, err := Encode("A E")
+	require.NoError(t, err)
+
+	timeline := timing.Timeline(symbols)
+
+	dit := 60 * time.Millisecond
+	assert.Equal(t, dit, timing.Dit())
+	assert.Equal(t, []Mark{
+		{Start: 0, Duration: dit},
+		{Start: 2 * dit, Duration: 3 * dit},
+		{Start: 12 * dit, Duration: dit},
+	}, timeline.Marks)
+	assert.Equal(t, []SymbolTiming{
+		{Symbol: symbols[0], Start: 0, End: 8 * dit},
+		{Symbol: symbols[1], Start: 8 * dit, End: 12 * dit},
+		{Symbol: symbols[2], Start: 12 * dit, End: 16 * dit},
+	}, timeline.Symbols)
+	assert.Equal(t, 13*dit, timeline.Duration)
+	assert.Equal(t, 16*dit, timeline.Total)
+}
+
+func TestTiming_Weight(t *testing.T) {
+	symbols, err := Encode("PARIS ")
+	require.NoError(t, err)
+	normal := Timing{WPM: 20}.Timeline(symbols)
+	heavy := Timing{WPM: 20, Weight: 50}.Timeline(symbols)
+
+	assert.Equal(t, normal.Total, heavy.Total, "the weight does not change the speed")
+	assert.Equal(t, 90*time.Millisecond, heavy.Marks[0].Duration)
+	assert.Equal(t, normal.Marks[1].Start, heavy.Marks[1].Start)
+}
+
+func TestTiming_Farnsworth(t *testing.T) {
+	symbols, err := Encode("PARIS ")
+	require.NoError(t, err)
+	timing := Timing{WPM: 18, Farnsworth: 10}
+
+	timeline := timing.Timeline(symbols)
+
+	assert.InDelta(t, float64(6*time.Second), float64(timeline.Total), float64(5*time.Millisecond), "PARIS must be keyed with the effective speed")
+	assert.Equal(t, timing.Dit(), timeline.Marks[0].Duration, "the characters must be keyed with the character speed")
+}
+
+func TestTiming_Duration(t *testing.T) {
+	timing := Timing{WPM: 60}
+
+	assert.Equal(t, time.Duration(0), timing.Duration(""))
+	assert.Equal(t, 20*time.Millisecond, timing.Duration("e"))
+	assert.Equal(t, timing.Duration("e"), timing.Duration("e#"), "unknown characters are ignored")
+	assert.Equal(t, 1000*time.Millisecond-7*20*time.Millisecond, timing.Duration("paris"))
+}
@@ -0,0 +1,163 @@
+package n1mm
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+)
+
+// maxDatagramSize is the maximum size of a UDP datagram.
+const maxDatagramSize = 65535
+
+// MessageHandler is notified about every supported message that is received.
+type MessageHandler func(Message)
+
+// ErrorHandler is notified about datagrams that cannot be parsed and about errors while forwarding.
+type ErrorHandler func(err error)
+
+// Listener receives the broadcasts of N1MM Logger+ on a UDP address and optionally forwards them to other addresses.
+type Listener struct {
+	conn *net.UDPConn
+
+	lock          sync.RWMutex
+	forwards      []*net.UDPAddr
+	handlers      []MessageHandler
+	errorHandlers []ErrorHandler
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// Listen starts to receive the broadcasts on the given UDP address. If the address is empty, port 12060 is used on
+// all interfaces.
+func Listen(address string) (*Listener, error) {
+	if address == "" {
+		address = fmt.Sprintf(":%d", DefaultPort)
+	}
+	udpAddr, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", udpAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Listener{
+		conn: conn,
+		done: make(chan struct{}),
+	}
+	go result.run()
+	return result, nil
+}
+
+// Addr returns the local address of the listener.
+func (l *Listener) Addr() net.Addr {
+	return l.conn.LocalAddr()
+}
+
+// ForwardTo forwards every received datagram unchanged to the given UDP address, including the datagrams of
+// unsupported types.
+func (l *Listener) ForwardTo(address string) error {
+	udpAddr, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		return err
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.forwards = append(l.forwards, udpAddr)
+	return nil
+}
+
+// OnMessage registers the given handler to be notified about every supported message. The handlers are called on the
+// goroutine of the listener.
+func (l *Listener) OnMessage(handler MessageHandler) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.handlers = append(l.handlers, handler)
+}
+
+// OnError registers the given handler to be notified about datagrams that cannot be parsed and about errors while
+// forwarding. Datagrams of unsupported types are ignored silently.
+func (l *Listener) OnError(handler ErrorHandler) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.errorHandlers = append(l.errorHandlers, handler)
+}
+
+// Close stops receiving and forwarding broadcasts and closes the UDP connection.
+func (l *Listener) Close() error {
+	var err error
+	l.closeOnce.Do(func() {
+		err = l.conn.Close()
+	})
+	<-l.done
+	return err
+}
+
+func (l *Listener) run() {
+	defer close(l.done)
+
+	buffer := make([]byte, maxDatagramSize)
+	for {
+		n, addr, err := l.conn.ReadFromUDP(buffer)
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+		if err != nil {
+			l.emitError(err)
+			continue
+		}
+
+		datagram := buffer[:n]
+		l.forward(datagram)
+
+		message, err := Parse(datagram)
+		if errors.Is(err, ErrUnknownType) {
+			continue
+		}
+		if err != nil {
+			l.emitError(fmt.Errorf("invalid datagram from %v: %w", addr, err))
+			continue
+		}
+		l.handle(message)
+	}
+}
+
+func (l *Listener) forward(datagram []byte) {
+	l.lock.RLock()
+	forwards := make([]*net.UDPAddr, len(l.forwards))
+	copy(forwards, l.forwards)
+	l.lock.RUnlock()
+
+	for _, addr := range forwards {
+		_, err := l.conn.WriteToUDP(datagram, addr)
+		if err != nil {
+			l.emitError(fmt.Errorf("cannot forward to %v: %w", addr, err))
+		}
+	}
+}
+
+func (l *Listener) handle(message Message) {
+	l.lock.RLock()
+	handlers := make([]MessageHandler, len(l.handlers))
+	copy(handlers, l.handlers)
+	l.lock.RUnlock()
+
+	for _, handler := range handlers {
+		handler(message)
+	}
+}
+
+func (l *Listener) emitError(err error) {
+	l.lock.RLock()
+	errorHandlers := make([]ErrorHandler, len(l.errorHandlers))
+	copy(errorHandlers, l.errorHandlers)
+	l.lock.RUnlock()
+
+	for _, handler := range errorHandlers {
+		handler(err)
+	}
+}
@@ -0,0 +1,84 @@
+package n1mm
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupListener(t *testing.T) *Listener {
+	t.Helper()
+	listener, err := Listen("127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	return listener
+}
+
+// broadcast sends the given datagram to the listener like N1MM Logger+.
+func broadcast(t *testing.T, listener *Listener, datagram []byte) {
+	t.Helper()
+	conn, err := net.DialUDP("udp", nil, listener.Addr().(*net.UDPAddr))
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write(datagram)
+	require.NoError(t, err)
+}
+
+func TestListener_Messages(t *testing.T) {
+	listener := setupListener(t)
+	messages := make(chan Message, 10)
+	listener.OnMessage(func(m Message) { messages <- m })
+
+	broadcast(t, listener, []byte("<AppInfo><app>N1MM</app></AppInfo>"))
+	broadcast(t, listener, readTestdata(t, "radioinfo.xml"))
+	broadcast(t, listener, readTestdata(t, "spot.xml"))
+
+	for _, expected := range []MessageType{RadioInfoType, SpotType} {
+		select {
+		case message := <-messages:
+			assert.Equal(t, expected, message.Type())
+		case <-time.After(time.Second):
+			t.Fatalf("no %s received", expected)
+		}
+	}
+}
+
+func TestListener_InvalidDatagram(t *testing.T) {
+	listener := setupListener(t)
+	errs := make(chan error, 1)
+	listener.OnError(func(err error) { errs <- err })
+
+	broadcast(t, listener, []byte("<RadioInfo><RadioNr>one</RadioNr></RadioInfo>"))
+
+	select {
+	case err := <-errs:
+		assert.Contains(t, err.Error(), "RadioInfo")
+	case <-time.After(time.Second):
+		t.Fatal("no error received")
+	}
+}
+
+func TestListener_Forward(t *testing.T) {
+	listener := setupListener(t)
+	target, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer target.Close()
+	require.NoError(t, listener.ForwardTo(target.LocalAddr().String()))
+
+	datagrams := [][]byte{
+		[]byte("<AppInfo><app>N1MM</app></AppInfo>"),
+		readTestdata(t, "contactinfo.xml"),
+	}
+	buffer := make([]byte, maxDatagramSize)
+	for _, datagram := range datagrams {
+		broadcast(t, listener, datagram)
+
+		target.SetReadDeadline(time.Now().Add(time.Second))
+		n, err := target.Read(buffer)
+		require.NoError(t, err)
+		assert.Equal(t, datagram, buffer[:n])
+	}
+}
@@ -0,0 +1,546 @@
+/*
+Package n1mm parses the UDP broadcasts of N1MM Logger+ (https://n1mmwp.hamdocs.com/appendices/external-udp-broadcasts/).
+
+N1MM Logger+ broadcasts XML datagrams about the logged contacts, the state of the radios and the spots from the
+packet window, by default to 127.0.0.1:12060. Parse converts the supported datagrams (contactinfo, contactreplace,
+contactdelete, RadioInfo and spot) into the message types of this package. A Listener receives the datagrams and
+optionally forwards them unchanged to other addresses, as N1MM Logger+ can only broadcast to a limited number of
+destinations.
+
+The frequencies of contacts and radios are sent in units of 10 Hz, spot frequencies in kHz. All frequencies are
+converted to Hz, the band is derived from the frequency using the IARU region 1 bandplan.
+*/
+package n1mm
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ftl/hamradio"
+	"github.com/ftl/hamradio/bandplan"
+	"github.com/ftl/hamradio/callsign"
+)
+
+// DefaultPort is the default UDP port that N1MM Logger+ broadcasts to.
+const DefaultPort = 12060
+
+// ErrUnknownType is returned by Parse for valid XML datagrams that are not supported.
+var ErrUnknownType = errors.New("unknown message type")
+
+// MessageType identifies the type of a message, it is the name of the XML root element.
+type MessageType string
+
+// The supported message types.
+const (
+	ContactInfoType    MessageType = "contactinfo"
+	ContactReplaceType MessageType = "contactreplace"
+	ContactDeleteType  MessageType = "contactdelete"
+	RadioInfoType      MessageType = "RadioInfo"
+	SpotType           MessageType = "spot"
+)
+
+// Message is a message broadcasted by N1MM Logger+.
+type Message interface {
+	// Type returns the type of the message.
+	Type() MessageType
+}
+
+// SpotAction is the action of a spot message.
+type SpotAction string
+
+// The spot actions.
+const (
+	AddSpot    SpotAction = "add"
+	DeleteSpot SpotAction = "delete"
+)
+
+// Contact contains the data of a logged contact.
+type Contact struct {
+	App             string
+	ContestName     string
+	ContestNr       int
+	Timestamp       time.Time
+	MyCall          callsign.Callsign
+	Band            bandplan.BandName
+	RxFrequency     hamradio.Frequency
+	TxFrequency     hamradio.Frequency
+	Operator        callsign.Callsign
+	Mode            string
+	Call            callsign.Callsign
+	CountryPrefix   string
+	WPXPrefix       string
+	StationPrefix   string
+	Continent       string
+	Sent            string
+	SentNumber      int
+	Received        string
+	ReceivedNumber  int
+	GridSquare      string
+	Exchange1       string
+	Section         string
+	Comment         string
+	QTH             string
+	Name            string
+	Power           string
+	MiscText        string
+	Zone            int
+	Precedence      string
+	Check           int
+	IsMultiplier1   bool
+	IsMultiplier2   bool
+	IsMultiplier3   bool
+	Points          int
+	RadioNr         int
+	Run1Run2        int
+	RoverLocation   string
+	RadioInterfaced bool
+	NetworkedCompNr int
+	IsOriginal      bool
+	NetBIOSName     string
+	IsRunQSO        bool
+	StationName     string
+	ID              string
+	IsClaimedQSO    bool
+}
+
+// ContactInfo is broadcasted when a contact is logged.
+type ContactInfo struct {
+	Contact
+}
+
+// ContactReplace is broadcasted when a logged contact is edited. The contact with the given ID is replaced.
+type ContactReplace struct {
+	Contact
+	OldTimestamp time.Time
+	OldCall      callsign.Callsign
+}
+
+// ContactDelete is broadcasted when a logged contact is deleted.
+type ContactDelete struct {
+	App         string
+	Timestamp   time.Time
+	Call        callsign.Callsign
+	ContestNr   int
+	StationName string
+	ID          string
+}
+
+// RadioInfo is broadcasted regularly for every radio, and whenever the state of a radio changes.
+type RadioInfo struct {
+	App                string
+	StationName        string
+	RadioNr            int
+	Frequency          hamradio.Frequency
+	TxFrequency        hamradio.Frequency
+	Band               bandplan.BandName
+	Mode               string
+	OpCall             callsign.Callsign
+	IsRunning          bool
+	FocusEntry         string
+	EntryWindowHwnd    string
+	Antenna            int
+	Rotors             string
+	FocusRadioNr       int
+	IsStereo           bool
+	IsSplit            bool
+	ActiveRadioNr      int
+	IsTransmitting     bool
+	FunctionKeyCaption string
+	RadioName          string
+	AuxAntSelected     int
+	AuxAntSelectedName string
+	IsConnected        bool
+}
+
+// Spot is broadcasted when a spot is added to or deleted from the bandmap.
+type Spot struct {
+	App         string
+	StationName string
+	DXCall      callsign.Callsign
+	Frequency   hamradio.Frequency
+	Band        bandplan.BandName
+	Spotter     callsign.Callsign
+	Comment     string
+	Action      SpotAction
+	Mode        string
+	Timestamp   time.Time
+	Status      string
+	StatusList  string
+}
+
+func (ContactInfo) Type() MessageType    { return ContactInfoType }
+func (ContactReplace) Type() MessageType { return ContactReplaceType }
+func (ContactDelete) Type() MessageType  { return ContactDeleteType }
+func (RadioInfo) Type() MessageType      { return RadioInfoType }
+func (Spot) Type() MessageType           { return SpotType }
+
+// Parse parses the given datagram into a message. Valid XML datagrams of other types return an error that wraps
+// ErrUnknownType.
+func Parse(data []byte) (Message, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	root, err := rootElement(decoder)
+	if err != nil {
+		return nil, err
+	}
+
+	messageType := MessageType(root.Name.Local)
+	var raw interface{}
+	switch messageType {
+	case ContactInfoType, ContactReplaceType:
+		raw = &rawContact{}
+	case ContactDeleteType:
+		raw = &rawContactDelete{}
+	case RadioInfoType:
+		raw = &rawRadioInfo{}
+	case SpotType:
+		raw = &rawSpot{}
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownType, messageType)
+	}
+	err = decoder.DecodeElement(raw, &root)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse %s: %w", messageType, err)
+	}
+
+	c := &converter{}
+	var result Message
+	switch r := raw.(type) {
+	case *rawContact:
+		contact := r.convert(c)
+		if messageType == ContactInfoType {
+			result = ContactInfo{Contact: contact}
+		} else {
+			result = ContactReplace{Contact: contact, OldTimestamp: c.timestamp(r.OldTimestamp), OldCall: c.callsign(r.OldCall)}
+		}
+	case *rawContactDelete:
+		result = r.convert(c)
+	case *rawRadioInfo:
+		result = r.convert(c)
+	case *rawSpot:
+		result = r.convert(c)
+	}
+	if c.err != nil {
+		return nil, fmt.Errorf("cannot parse %s: %w", messageType, c.err)
+	}
+	return result, nil
+}
+
+func rootElement(decoder *xml.Decoder) (xml.StartElement, error) {
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return xml.StartElement{}, fmt.Errorf("no XML message: %w", err)
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			return start, nil
+		}
+	}
+}
+
+type rawContact struct {
+	App             string `xml:"app"`
+	ContestName     string `xml:"contestname"`
+	ContestNr       string `xml:"contestnr"`
+	Timestamp       string `xml:"timestamp"`
+	MyCall          string `xml:"mycall"`
+	Band            string `xml:"band"`
+	RxFreq          string `xml:"rxfreq"`
+	TxFreq          string `xml:"txfreq"`
+	Operator        string `xml:"operator"`
+	Mode            string `xml:"mode"`
+	Call            string `xml:"call"`
+	CountryPrefix   string `xml:"countryprefix"`
+	WPXPrefix       string `xml:"wpxprefix"`
+	StationPrefix   string `xml:"stationprefix"`
+	Continent       string `xml:"continent"`
+	Snt             string `xml:"snt"`
+	SntNr           string `xml:"sntnr"`
+	Rcv             string `xml:"rcv"`
+	RcvNr           string `xml:"rcvnr"`
+	GridSquare      string `xml:"gridsquare"`
+	Exchange1       string `xml:"exchange1"`
+	Section         string `xml:"section"`
+	Comment         string `xml:"comment"`
+	QTH             string `xml:"qth"`
+	Name            string `xml:"name"`
+	Power           string `xml:"power"`
+	MiscText        string `xml:"misctext"`
+	Zone            string `xml:"zone"`
+	Prec            string `xml:"prec"`
+	Ck              string `xml:"ck"`
+	IsMultiplier1   string `xml:"ismultiplier1"`
+	IsMultiplier2   string `xml:"ismultiplier2"`
+	IsMultiplier3   string `xml:"ismultiplier3"`
+	Points          string `xml:"points"`
+	RadioNr         string `xml:"radionr"`
+	Run1Run2        string `xml:"run1run2"`
+	RoverLocation   string `xml:"RoverLocation"`
+	RadioInterfaced string `xml:"RadioInterfaced"`
+	NetworkedCompNr string `xml:"NetworkedCompNr"`
+	IsOriginal      string `xml:"IsOriginal"`
+	NetBiosName     string `xml:"NetBiosName"`
+	IsRunQSO        string `xml:"IsRunQSO"`
+	StationName     string `xml:"StationName"`
+	ID              string `xml:"ID"`
+	IsClaimedQso    string `xml:"IsClaimedQso"`
+	OldTimestamp    string `xml:"oldtimestamp"`
+	OldCall         string `xml:"oldcall"`
+}
+
+func (r *rawContact) convert(c *converter) Contact {
+	result := Contact{
+		App:             r.App,
+		ContestName:     r.ContestName,
+		ContestNr:       c.int(r.ContestNr),
+		Timestamp:       c.timestamp(r.Timestamp),
+		MyCall:          c.callsign(r.MyCall),
+		RxFrequency:     c.frequency(r.RxFreq),
+		TxFrequency:     c.frequency(r.TxFreq),
+		Operator:        c.callsign(r.Operator),
+		Mode:            r.Mode,
+		Call:            c.callsign(r.Call),
+		CountryPrefix:   r.CountryPrefix,
+		WPXPrefix:       r.WPXPrefix,
+		StationPrefix:   r.StationPrefix,
+		Continent:       r.Continent,
+		Sent:            r.Snt,
+		SentNumber:      c.int(r.SntNr),
+		Received:        r.Rcv,
+		ReceivedNumber:  c.int(r.RcvNr),
+		GridSquare:      r.GridSquare,
+		Exchange1:       r.Exchange1,
+		Section:         r.Section,
+		Comment:         r.Comment,
+		QTH:             r.QTH,
+		Name:            r.Name,
+		Power:           r.Power,
+		MiscText:        r.MiscText,
+		Zone:            c.int(r.Zone),
+		Precedence:      r.Prec,
+		Check:           c.int(r.Ck),
+		IsMultiplier1:   c.bool(r.IsMultiplier1),
+		IsMultiplier2:   c.bool(r.IsMultiplier2),
+		IsMultiplier3:   c.bool(r.IsMultiplier3),
+		Points:          c.int(r.Points),
+		RadioNr:         c.int(r.RadioNr),
+		Run1Run2:        c.int(r.Run1Run2),
+		RoverLocation:   r.RoverLocation,
+		RadioInterfaced: c.bool(r.RadioInterfaced),
+		NetworkedCompNr: c.int(r.NetworkedCompNr),
+		IsOriginal:      c.bool(r.IsOriginal),
+		NetBIOSName:     r.NetBiosName,
+		IsRunQSO:        c.bool(r.IsRunQSO),
+		StationName:     r.StationName,
+		ID:              r.ID,
+		IsClaimedQSO:    c.bool(r.IsClaimedQso),
+	}
+	result.Band = band(result.RxFrequency, result.TxFrequency)
+	return result
+}
+
+type rawContactDelete struct {
+	App         string `xml:"app"`
+	Timestamp   string `xml:"timestamp"`
+	Call        string `xml:"call"`
+	ContestNr   string `xml:"contestnr"`
+	StationName string `xml:"StationName"`
+	ID          string `xml:"ID"`
+}
+
+func (r *rawContactDelete) convert(c *converter) ContactDelete {
+	return ContactDelete{
+		App:         r.App,
+		Timestamp:   c.timestamp(r.Timestamp),
+		Call:        c.callsign(r.Call),
+		ContestNr:   c.int(r.ContestNr),
+		StationName: r.StationName,
+		ID:          r.ID,
+	}
+}
+
+type rawRadioInfo struct {
+	App                string `xml:"app"`
+	StationName        string `xml:"StationName"`
+	RadioNr            string `xml:"RadioNr"`
+	Freq               string `xml:"Freq"`
+	TXFreq             string `xml:"TXFreq"`
+	Mode               string `xml:"Mode"`
+	OpCall             string `xml:"OpCall"`
+	IsRunning          string `xml:"IsRunning"`
+	FocusEntry         string `xml:"FocusEntry"`
+	EntryWindowHwnd    string `xml:"EntryWindowHwnd"`
+	Antenna            string `xml:"Antenna"`
+	Rotors             string `xml:"Rotors"`
+	FocusRadioNr       string `xml:"FocusRadioNr"`
+	IsStereo           string `xml:"IsStereo"`
+	IsSplit            string `xml:"IsSplit"`
+	ActiveRadioNr      string `xml:"ActiveRadioNr"`
+	IsTransmitting     string `xml:"IsTransmitting"`
+	FunctionKeyCaption string `xml:"FunctionKeyCaption"`
+	RadioName          string `xml:"RadioName"`
+	AuxAntSelected     string `xml:"AuxAntSelected"`
+	AuxAntSelectedName string `xml:"AuxAntSelectedName"`
+	IsConnected        string `xml:"IsConnected"`
+}
+
+func (r *rawRadioInfo) convert(c *converter) RadioInfo {
+	result := RadioInfo{
+		App:                r.App,
+		StationName:        r.StationName,
+		RadioNr:            c.int(r.RadioNr),
+		Frequency:          c.frequency(r.Freq),
+		TxFrequency:        c.frequency(r.TXFreq),
+		Mode:               r.Mode,
+		OpCall:             c.callsign(r.OpCall),
+		IsRunning:          c.bool(r.IsRunning),
+		FocusEntry:         r.FocusEntry,
+		EntryWindowHwnd:    r.EntryWindowHwnd,
+		Antenna:            c.int(r.Antenna),
+		Rotors:             r.Rotors,
+		FocusRadioNr:       c.int(r.FocusRadioNr),
+		IsStereo:           c.bool(r.IsStereo),
+		IsSplit:            c.bool(r.IsSplit),
+		ActiveRadioNr:      c.int(r.ActiveRadioNr),
+		IsTransmitting:     c.bool(r.IsTransmitting),
+		FunctionKeyCaption: r.FunctionKeyCaption,
+		RadioName:          r.RadioName,
+		AuxAntSelected:     c.int(r.AuxAntSelected),
+		AuxAntSelectedName: r.AuxAntSelectedName,
+		IsConnected:        c.bool(r.IsConnected),
+	}
+	result.Band = band(result.Frequency, result.TxFrequency)
+	return result
+}
+
+type rawSpot struct {
+	App         string `xml:"app"`
+	StationName string `xml:"StationName"`
+	DXCall      string `xml:"dxcall"`
+	Frequency   string `xml:"frequency"`
+	SpotterCall string `xml:"spottercall"`
+	Comment     string `xml:"comment"`
+	Action      string `xml:"action"`
+	Mode        string `xml:"mode"`
+	Timestamp   string `xml:"tstamp"`
+	Status      string `xml:"status"`
+	StatusList  string `xml:"statuslist"`
+}
+
+func (r *rawSpot) convert(c *converter) Spot {
+	result := Spot{
+		App:         r.App,
+		StationName: r.StationName,
+		DXCall:      c.callsign(r.DXCall),
+		Frequency:   c.kilohertz(r.Frequency),
+		Spotter:     c.callsign(r.SpotterCall),
+		Comment:     r.Comment,
+		Action:      SpotAction(strings.ToLower(r.Action)),
+		Mode:        r.Mode,
+		Timestamp:   c.spotTimestamp(r.Timestamp),
+		Status:      r.Status,
+		StatusList:  r.StatusList,
+	}
+	result.Band = band(result.Frequency, 0)
+	return result
+}
+
+// band returns the band of the given frequency, or of the alternative frequency if the first one is zero.
+func band(frequency, alternative hamradio.Frequency) bandplan.BandName {
+	if frequency == 0 {
+		frequency = alternative
+	}
+	return bandplan.IARURegion1.ByFrequency(frequency).Name
+}
+
+// The layouts of the timestamps used by N1MM Logger+, all timestamps are in UTC.
+const (
+	timestampLayout     = "2006-01-02 15:04:05"
+	spotTimestampLayout = "1/2/2006 3:04:05 PM"
+)
+
+// converter converts the text values of the XML elements. Empty values are converted to zero values, the first error
+// is kept.
+type converter struct {
+	err error
+}
+
+func (c *converter) fail(err error) {
+	if c.err == nil {
+		c.err = err
+	}
+}
+
+func (c *converter) int(s string) int {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0
+	}
+	result, err := strconv.Atoi(s)
+	if err != nil {
+		c.fail(err)
+	}
+	return result
+}
+
+func (c *converter) bool(s string) bool {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return false
+	}
+	result, err := strconv.ParseBool(s)
+	if err != nil {
+		c.fail(err)
+	}
+	return result
+}
+
+// frequency converts a frequency in units of 10 Hz.
+func (c *converter) frequency(s string) hamradio.Frequency {
+	return hamradio.Frequency(c.int(s) * 10)
+}
+
+// kilohertz converts a frequency in kHz.
+func (c *converter) kilohertz(s string) hamradio.Frequency {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0
+	}
+	result, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		c.fail(err)
+	}
+	return hamradio.Frequency(math.Round(result * 1000))
+}
+
+// callsign parses a callsign. Invalid callsigns are converted to the zero value, N1MM Logger+ also logs contacts
+// with incomplete callsigns.
+func (c *converter) callsign(s string) callsign.Callsign {
+	result, _ := callsign.Parse(s)
+	return result
+}
+
+func (c *converter) timestamp(s string) time.Time {
+	return c.time(timestampLayout, s)
+}
+
+func (c *converter) spotTimestamp(s string) time.Time {
+	return c.time(spotTimestampLayout, s)
+}
+
+func (c *converter) time(layout, s string) time.Time {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}
+	}
+	result, err := time.Parse(layout, s)
+	if err != nil {
+		c.fail(err)
+	}
+	return result
+}
@@ -0,0 +1,131 @@
+package n1mm
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ftl/hamradio/bandplan"
+	"github.com/ftl/hamradio/callsign"
+)
+
+func readTestdata(t *testing.T, filename string) []byte {
+	t.Helper()
+	data, err := os.ReadFile("testdata/" + filename)
+	require.NoError(t, err)
+	return data
+}
+
+func TestParse_ContactInfo(t *testing.T) {
+	message, err := Parse(readTestdata(t, "contactinfo.xml"))
+	require.NoError(t, err)
+	require.Equal(t, ContactInfoType, message.Type())
+	contact := message.(ContactInfo)
+
+	assert.Equal(t, "CWOPS", contact.ContestName)
+	assert.Equal(t, 73, contact.ContestNr)
+	assert.Equal(t, time.Date(2020, time.January, 17, 16, 43, 38, 0, time.UTC), contact.Timestamp)
+	assert.Equal(t, callsign.MustParse("W2XYZ"), contact.MyCall)
+	assert.Equal(t, callsign.Callsign{}, contact.Operator)
+	assert.Equal(t, callsign.MustParse("W1AW"), contact.Call)
+	assert.Equal(t, 3525190.0, float64(contact.RxFrequency))
+	assert.Equal(t, 3525190.0, float64(contact.TxFrequency))
+	assert.Equal(t, bandplan.Band80m, contact.Band)
+	assert.Equal(t, "599", contact.Sent)
+	assert.Equal(t, 5, contact.SentNumber)
+	assert.Equal(t, "HIRAM", contact.Name)
+	assert.True(t, contact.IsMultiplier1)
+	assert.False(t, contact.IsMultiplier2)
+	assert.True(t, contact.RadioInterfaced)
+	assert.True(t, contact.IsOriginal)
+	assert.False(t, contact.IsRunQSO)
+	assert.Equal(t, "CONTEST-PC", contact.StationName)
+	assert.Equal(t, "f9ffac4fcd3e479ca86e137df1338531", contact.ID)
+	assert.True(t, contact.IsClaimedQSO)
+}
+
+func TestParse_ContactReplace(t *testing.T) {
+	message, err := Parse(readTestdata(t, "contactreplace.xml"))
+	require.NoError(t, err)
+	require.Equal(t, ContactReplaceType, message.Type())
+	contact := message.(ContactReplace)
+
+	assert.Equal(t, callsign.MustParse("W1AW/p"), contact.Call)
+	assert.Equal(t, callsign.MustParse("W1AX"), contact.OldCall)
+	assert.Equal(t, time.Date(2020, time.January, 17, 16, 43, 30, 0, time.UTC), contact.OldTimestamp)
+	assert.Equal(t, "f9ffac4fcd3e479ca86e137df1338531", contact.ID)
+}
+
+func TestParse_ContactDelete(t *testing.T) {
+	message, err := Parse(readTestdata(t, "contactdelete.xml"))
+	require.NoError(t, err)
+
+	assert.Equal(t, ContactDelete{
+		App:         "N1MM",
+		Timestamp:   time.Date(2020, time.January, 17, 16, 43, 38, 0, time.UTC),
+		Call:        callsign.MustParse("W1AW"),
+		ContestNr:   73,
+		StationName: "CONTEST-PC",
+		ID:          "f9ffac4fcd3e479ca86e137df1338531",
+	}, message)
+}
+
+func TestParse_RadioInfo(t *testing.T) {
+	message, err := Parse(readTestdata(t, "radioinfo.xml"))
+	require.NoError(t, err)
+	require.Equal(t, RadioInfoType, message.Type())
+	radio := message.(RadioInfo)
+
+	assert.Equal(t, 2, radio.RadioNr)
+	assert.Equal(t, 14025100.0, float64(radio.Frequency))
+	assert.Equal(t, 14027100.0, float64(radio.TxFrequency))
+	assert.Equal(t, bandplan.Band20m, radio.Band)
+	assert.Equal(t, callsign.MustParse("W1AW"), radio.OpCall)
+	assert.True(t, radio.IsSplit)
+	assert.False(t, radio.IsRunning)
+	assert.Equal(t, -1, radio.AuxAntSelected)
+	assert.Equal(t, "K3", radio.RadioName)
+	assert.True(t, radio.IsConnected)
+}
+
+func TestParse_Spot(t *testing.T) {
+	message, err := Parse(readTestdata(t, "spot.xml"))
+	require.NoError(t, err)
+
+	assert.Equal(t, Spot{
+		App:         "N1MM",
+		StationName: "CONTEST-PC",
+		DXCall:      callsign.MustParse("KD4QMY"),
+		Frequency:   14027100,
+		Band:        bandplan.Band20m,
+		Spotter:     callsign.MustParse("N4ZR"),
+		Comment:     "CW 22 dB 26 WPM CQ",
+		Action:      AddSpot,
+		Mode:        "CW",
+		Timestamp:   time.Date(2016, time.May, 22, 19, 9, 55, 0, time.UTC),
+	}, message)
+}
+
+func TestParse_Invalid(t *testing.T) {
+	tt := []struct {
+		desc    string
+		data    string
+		unknown bool
+	}{
+		{"unknown type", "<AppInfo><app>N1MM</app></AppInfo>", true},
+		{"no XML", "hello", false},
+		{"invalid number", "<RadioInfo><RadioNr>one</RadioNr></RadioInfo>", false},
+		{"invalid timestamp", "<contactdelete><timestamp>yesterday</timestamp></contactdelete>", false},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			_, err := Parse([]byte(tc.data))
+			assert.Error(t, err)
+			assert.Equal(t, tc.unknown, errors.Is(err, ErrUnknownType))
+		})
+	}
+}
@@ -0,0 +1,269 @@
+package rigctl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ftl/hamradio"
+)
+
+// DefaultTimeout is the default time to wait for a response.
+const DefaultTimeout = 2 * time.Second
+
+// ErrNotConnected is returned if the connection is not open.
+var ErrNotConnected = errors.New("not connected to the Hamlib daemon")
+
+// Error is an error code reported by rigctld or rotctld.
+type Error int
+
+// The error codes of Hamlib.
+const (
+	ErrInvalidParameter   Error = -1
+	ErrConfiguration      Error = -2
+	ErrMemory             Error = -3
+	ErrNotImplemented     Error = -4
+	ErrTimeout            Error = -5
+	ErrIO                 Error = -6
+	ErrInternal           Error = -7
+	ErrProtocol           Error = -8
+	ErrRejected           Error = -9
+	ErrTruncated          Error = -10
+	ErrNotAvailable       Error = -11
+	ErrVFONotTargetable   Error = -12
+	ErrBus                Error = -13
+	ErrBusBusy            Error = -14
+	ErrInvalidArgument    Error = -15
+	ErrInvalidVFO         Error = -16
+	ErrArgumentOutOfRange Error = -17
+)
+
+var errorTexts = map[Error]string{
+	ErrInvalidParameter:   "invalid parameter",
+	ErrConfiguration:      "invalid configuration",
+	ErrMemory:             "memory shortage",
+	ErrNotImplemented:     "function not implemented",
+	ErrTimeout:            "communication timed out",
+	ErrIO:                 "IO error",
+	ErrInternal:           "internal Hamlib error",
+	ErrProtocol:           "protocol error",
+	ErrRejected:           "command rejected by the device",
+	ErrTruncated:          "command performed, but arg truncated",
+	ErrNotAvailable:       "function not available",
+	ErrVFONotTargetable:   "VFO not targetable",
+	ErrBus:                "error talking on the bus",
+	ErrBusBusy:            "collision on the bus",
+	ErrInvalidArgument:    "NULL RIG handle or invalid pointer parameter",
+	ErrInvalidVFO:         "invalid VFO",
+	ErrArgumentOutOfRange: "argument out of domain of function",
+}
+
+func (e Error) Error() string {
+	text, ok := errorTexts[e]
+	if !ok {
+		text = "unknown error"
+	}
+	return fmt.Sprintf("hamlib: %s (%d)", text, int(e))
+}
+
+// IsUnsupported indicates if the given error means that the device does not support the requested function.
+func IsUnsupported(err error) bool {
+	var rigErr Error
+	if !errors.As(err, &rigErr) {
+		return false
+	}
+	return rigErr == ErrNotImplemented || rigErr == ErrNotAvailable
+}
+
+// Conn is a connection to rigctld or rotctld, using the extended response mode. It is safe for concurrent use, the
+// commands are sent one after the other.
+type Conn struct {
+	address string
+	// Timeout is the time to wait for the response.
+	Timeout time.Duration
+
+	lock       sync.Mutex
+	connected  bool
+	connection net.Conn
+	reader     *bufio.Reader
+}
+
+// NewConn returns a new connection to the given address. The connection is opened with Connect.
+func NewConn(address string) *Conn {
+	return &Conn{
+		address: address,
+		Timeout: DefaultTimeout,
+	}
+}
+
+// Connect opens the connection. If the connection breaks later, it is opened again with the next command.
+func (c *Conn) Connect() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.connection != nil {
+		return nil
+	}
+	err := c.dial()
+	if err != nil {
+		return err
+	}
+	c.connected = true
+	return nil
+}
+
+func (c *Conn) dial() error {
+	connection, err := net.DialTimeout("tcp", c.address, c.Timeout)
+	if err != nil {
+		return err
+	}
+	c.connection = connection
+	c.reader = bufio.NewReader(connection)
+	return nil
+}
+
+// Disconnect closes the connection.
+func (c *Conn) Disconnect() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.connected = false
+	if c.connection == nil {
+		return nil
+	}
+	c.connection.Write([]byte("q\n"))
+	err := c.connection.Close()
+	c.connection = nil
+	c.reader = nil
+	return err
+}
+
+// IsConnected indicates if the connection is open.
+func (c *Conn) IsConnected() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.connected
+}
+
+// Response contains the values of an extended response, by their key.
+type Response struct {
+	Command string
+	Values  map[string]string
+}
+
+// Value returns the value with the given key.
+func (r Response) Value(key string) (string, error) {
+	value, ok := r.Values[key]
+	if !ok {
+		return "", fmt.Errorf("missing %q in the response to %s", key, r.Command)
+	}
+	return value, nil
+}
+
+// Int returns the value with the given key as integer.
+func (r Response) Int(key string) (int, error) {
+	value, err := r.Value(key)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(value)
+}
+
+// Frequency returns the value with the given key as frequency in Hz.
+func (r Response) Frequency(key string) (hamradio.Frequency, error) {
+	value, err := r.Value(key)
+	if err != nil {
+		return 0, err
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", key, value, err)
+	}
+	return hamradio.Frequency(f), nil
+}
+
+// Request sends the given command in the extended response mode and reads the response. If the communication fails,
+// the connection is closed and opened again with the next request.
+func (c *Conn) Request(command string, args ...string) (Response, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if !c.connected {
+		return Response{}, ErrNotConnected
+	}
+	if c.connection == nil {
+		err := c.dial()
+		if err != nil {
+			return Response{}, err
+		}
+	}
+
+	result, err := c.roundtrip(command, args)
+	var rigErr Error
+	if err != nil && !errors.As(err, &rigErr) {
+		c.connection.Close()
+		c.connection = nil
+		c.reader = nil
+	}
+	return result, err
+}
+
+func (c *Conn) roundtrip(command string, args []string) (Response, error) {
+	result := Response{Command: command, Values: make(map[string]string)}
+	c.connection.SetDeadline(time.Now().Add(c.Timeout))
+
+	line := "+\\" + strings.Join(append([]string{command}, args...), " ") + "\n"
+	_, err := c.connection.Write([]byte(line))
+	if err != nil {
+		return result, err
+	}
+
+	header, err := c.readLine()
+	if err != nil {
+		return result, err
+	}
+	if name, _, _ := strings.Cut(header, ":"); name != command {
+		return result, fmt.Errorf("unexpected response to %s: %q", command, header)
+	}
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return result, err
+		}
+		if strings.HasPrefix(line, "RPRT ") {
+			return result, parseReport(strings.TrimPrefix(line, "RPRT "))
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return result, fmt.Errorf("invalid line in the response to %s: %q", command, line)
+		}
+		result.Values[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+}
+
+func (c *Conn) readLine() (string, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func parseReport(code string) error {
+	value, err := strconv.Atoi(strings.TrimSpace(code))
+	if err != nil {
+		return fmt.Errorf("invalid report %q: %w", code, err)
+	}
+	if value == 0 {
+		return nil
+	}
+	if value > 0 {
+		value = -value
+	}
+	return Error(value)
+}
@@ -0,0 +1,247 @@
+/*
+Package rigctl provides a client for the network protocol of rigctld, the rig control daemon of Hamlib
+(https://hamlib.github.io).
+
+The client uses the extended response mode of rigctld: every command is echoed in the first line of the response, the
+values are returned as "Key: Value" lines and the response ends with the "RPRT <code>" line. This way the responses of
+all commands can be parsed in the same way and the errors are reported reliably.
+
+To run rigctld locally for testing use the following command line: "rigctld -m 1"
+This starts rigctld with the dummy rig, listening on port 4532.
+
+The current state of the rig can be watched by polling, see Watcher. The protocol itself is implemented by Conn, which
+is also used for rotctld, the rotator control daemon of Hamlib (see package rotctl).
+*/
+package rigctl
+
+import (
+	"math"
+	"net"
+	"strconv"
+
+	"github.com/ftl/hamradio"
+)
+
+// DefaultPort is the default TCP port of rigctld.
+const DefaultPort = 4532
+
+// Mode is an operating mode of the rig, as named by Hamlib.
+type Mode string
+
+// The common modes.
+const (
+	ModeUSB    Mode = "USB"
+	ModeLSB    Mode = "LSB"
+	ModeCW     Mode = "CW"
+	ModeCWR    Mode = "CWR"
+	ModeRTTY   Mode = "RTTY"
+	ModeRTTYR  Mode = "RTTYR"
+	ModeAM     Mode = "AM"
+	ModeFM     Mode = "FM"
+	ModeWFM    Mode = "WFM"
+	ModePKTUSB Mode = "PKTUSB"
+	ModePKTLSB Mode = "PKTLSB"
+	ModePKTFM  Mode = "PKTFM"
+)
+
+// Special values for the passband.
+const (
+	// PassbandNormal selects the normal passband of the mode.
+	PassbandNormal hamradio.Frequency = 0
+	// PassbandNoChange keeps the current passband.
+	PassbandNoChange hamradio.Frequency = -1
+)
+
+// VFO identifies a VFO of the rig, as named by Hamlib.
+type VFO string
+
+// The common VFOs.
+const (
+	VFOA       VFO = "VFOA"
+	VFOB       VFO = "VFOB"
+	VFOC       VFO = "VFOC"
+	CurrentVFO VFO = "currVFO"
+	MainVFO    VFO = "Main"
+	SubVFO     VFO = "Sub"
+	TXVFO      VFO = "TX"
+	RXVFO      VFO = "RX"
+)
+
+// Level is a level setting or meter of the rig, as named by Hamlib.
+type Level string
+
+// The common levels.
+const (
+	LevelAF       Level = "AF"
+	LevelRF       Level = "RF"
+	LevelSquelch  Level = "SQL"
+	LevelRFPower  Level = "RFPOWER"
+	LevelMicGain  Level = "MICGAIN"
+	LevelKeySpeed Level = "KEYSPD"
+	LevelPreamp   Level = "PREAMP"
+	LevelAtt      Level = "ATT"
+	LevelComp     Level = "COMP"
+	LevelNR       Level = "NR"
+	LevelStrength Level = "STRENGTH"
+	LevelSWR      Level = "SWR"
+	LevelALC      Level = "ALC"
+)
+
+// Client is a client for rigctld. It is safe for concurrent use, the commands are sent one after the other.
+type Client struct {
+	*Conn
+}
+
+// New creates a new Client for rigctld running on the given hostname and port.
+// If the hostname is empty, localhost will be used. If the port is 0, the default port 4532 will be used.
+func New(hostname string, port int) *Client {
+	if port == 0 {
+		port = DefaultPort
+	}
+	return &Client{NewConn(net.JoinHostPort(hostname, strconv.Itoa(port)))}
+}
+
+// NewDefault returns a Client for rigctld running on localhost:4532.
+func NewDefault() *Client {
+	return New("", 0)
+}
+
+// Frequency returns the frequency of the current VFO.
+func (c *Client) Frequency() (hamradio.Frequency, error) {
+	r, err := c.Request("get_freq")
+	if err != nil {
+		return 0, err
+	}
+	return r.Frequency("Frequency")
+}
+
+// SetFrequency sets the frequency of the current VFO.
+func (c *Client) SetFrequency(frequency hamradio.Frequency) error {
+	_, err := c.Request("set_freq", formatFrequency(frequency))
+	return err
+}
+
+// Mode returns the mode and the passband of the current VFO.
+func (c *Client) Mode() (Mode, hamradio.Frequency, error) {
+	r, err := c.Request("get_mode")
+	if err != nil {
+		return "", 0, err
+	}
+	mode, err := r.Value("Mode")
+	if err != nil {
+		return "", 0, err
+	}
+	passband, err := r.Frequency("Passband")
+	if err != nil {
+		return "", 0, err
+	}
+	return Mode(mode), passband, nil
+}
+
+// SetMode sets the mode and the passband of the current VFO, see also PassbandNormal and PassbandNoChange.
+func (c *Client) SetMode(mode Mode, passband hamradio.Frequency) error {
+	_, err := c.Request("set_mode", string(mode), formatFrequency(passband))
+	return err
+}
+
+// VFO returns the current VFO.
+func (c *Client) VFO() (VFO, error) {
+	r, err := c.Request("get_vfo")
+	if err != nil {
+		return "", err
+	}
+	vfo, err := r.Value("VFO")
+	return VFO(vfo), err
+}
+
+// SetVFO selects the given VFO.
+func (c *Client) SetVFO(vfo VFO) error {
+	_, err := c.Request("set_vfo", string(vfo))
+	return err
+}
+
+// PTT indicates if the rig is transmitting.
+func (c *Client) PTT() (bool, error) {
+	r, err := c.Request("get_ptt")
+	if err != nil {
+		return false, err
+	}
+	ptt, err := r.Int("PTT")
+	return ptt != 0, err
+}
+
+// SetPTT switches the rig to transmit or receive.
+func (c *Client) SetPTT(on bool) error {
+	_, err := c.Request("set_ptt", formatBool(on))
+	return err
+}
+
+// Split indicates if the split operation is active and returns the VFO that is used for transmitting.
+func (c *Client) Split() (bool, VFO, error) {
+	r, err := c.Request("get_split_vfo")
+	if err != nil {
+		return false, "", err
+	}
+	split, err := r.Int("Split")
+	if err != nil {
+		return false, "", err
+	}
+	txVFO, err := r.Value("TX VFO")
+	if err != nil {
+		return false, "", err
+	}
+	return split != 0, VFO(txVFO), nil
+}
+
+// SetSplit switches the split operation on or off, using the given VFO for transmitting.
+func (c *Client) SetSplit(on bool, txVFO VFO) error {
+	_, err := c.Request("set_split_vfo", formatBool(on), string(txVFO))
+	return err
+}
+
+// SplitFrequency returns the transmit frequency for the split operation.
+func (c *Client) SplitFrequency() (hamradio.Frequency, error) {
+	r, err := c.Request("get_split_freq")
+	if err != nil {
+		return 0, err
+	}
+	return r.Frequency("TX Frequency")
+}
+
+// SetSplitFrequency sets the transmit frequency for the split operation.
+func (c *Client) SetSplitFrequency(frequency hamradio.Frequency) error {
+	_, err := c.Request("set_split_freq", formatFrequency(frequency))
+	return err
+}
+
+// Level returns the value of the given level. Depending on the level, the value is either a fraction [0..1] or an
+// absolute value, e.g. the speed in WpM for LevelKeySpeed or the signal strength in dB relative to S9 for
+// LevelStrength.
+func (c *Client) Level(level Level) (float64, error) {
+	r, err := c.Request("get_level", string(level))
+	if err != nil {
+		return 0, err
+	}
+	value, err := r.Value("Level Value")
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(value, 64)
+}
+
+// SetLevel sets the given level to the given value.
+func (c *Client) SetLevel(level Level, value float64) error {
+	_, err := c.Request("set_level", string(level), strconv.FormatFloat(value, 'f', -1, 64))
+	return err
+}
+
+func formatFrequency(f hamradio.Frequency) string {
+	return strconv.FormatInt(int64(math.Round(float64(f))), 10)
+}
+
+func formatBool(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
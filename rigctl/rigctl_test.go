@@ -0,0 +1,287 @@
+package rigctl
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ftl/hamradio"
+)
+
+// fakeRig emulates rigctld in the extended response mode.
+type fakeRig struct {
+	listener net.Listener
+
+	lock        sync.Mutex
+	frequency   int
+	mode        string
+	passband    int
+	vfo         string
+	ptt         int
+	split       int
+	txVFO       string
+	txFrequency int
+	levels      map[string]string
+	unsupported map[string]bool
+	commands    []string
+}
+
+func newFakeRig(t *testing.T) *fakeRig {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	rig := &fakeRig{
+		listener:    listener,
+		frequency:   14074000,
+		mode:        "USB",
+		passband:    2400,
+		vfo:         "VFOA",
+		txVFO:       "VFOB",
+		levels:      map[string]string{"RFPOWER": "0.500000", "KEYSPD": "24"},
+		unsupported: make(map[string]bool),
+	}
+	t.Cleanup(func() { listener.Close() })
+	go rig.serve()
+	return rig
+}
+
+func (r *fakeRig) port() int {
+	return r.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (r *fakeRig) serve() {
+	for {
+		conn, err := r.listener.Accept()
+		if err != nil {
+			return
+		}
+		go r.handle(conn)
+	}
+}
+
+func (r *fakeRig) handle(conn net.Conn) {
+	defer conn.Close()
+	in := bufio.NewScanner(conn)
+	for in.Scan() {
+		line := in.Text()
+		if line == "q" {
+			return
+		}
+		if !strings.HasPrefix(line, "+\\") {
+			fmt.Fprintf(conn, "RPRT %d\n", ErrProtocol)
+			continue
+		}
+		fields := strings.Fields(line[2:])
+		command, args := fields[0], fields[1:]
+		header := command + ":"
+		if len(args) > 0 {
+			header += " " + strings.Join(args, " ")
+		}
+		values, code := r.execute(command, args)
+		fmt.Fprintf(conn, "%s\n%sRPRT %d\n", header, values, code)
+	}
+}
+
+func (r *fakeRig) execute(command string, args []string) (string, int) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.commands = append(r.commands, strings.Join(append([]string{command}, args...), " "))
+	if r.unsupported[command] {
+		return "", int(ErrNotAvailable)
+	}
+
+	argInt := func(i int) int {
+		if i >= len(args) {
+			return 0
+		}
+		value, _ := strconv.Atoi(args[i])
+		return value
+	}
+	switch command {
+	case "get_freq":
+		return fmt.Sprintf("Frequency: %d\n", r.frequency), 0
+	case "set_freq":
+		r.frequency = argInt(0)
+	case "get_mode":
+		return fmt.Sprintf("Mode: %s\nPassband: %d\n", r.mode, r.passband), 0
+	case "set_mode":
+		r.mode = args[0]
+		if passband := argInt(1); passband >= 0 {
+			r.passband = passband
+		}
+	case "get_vfo":
+		return fmt.Sprintf("VFO: %s\n", r.vfo), 0
+	case "set_vfo":
+		r.vfo = args[0]
+	case "get_ptt":
+		return fmt.Sprintf("PTT: %d\n", r.ptt), 0
+	case "set_ptt":
+		r.ptt = argInt(0)
+	case "get_split_vfo":
+		return fmt.Sprintf("Split: %d\nTX VFO: %s\n", r.split, r.txVFO), 0
+	case "set_split_vfo":
+		r.split = argInt(0)
+		r.txVFO = args[1]
+	case "get_split_freq":
+		return fmt.Sprintf("TX Frequency: %d\n", r.txFrequency), 0
+	case "set_split_freq":
+		r.txFrequency = argInt(0)
+	case "get_level":
+		value, ok := r.levels[args[0]]
+		if !ok {
+			return "", int(ErrInvalidParameter)
+		}
+		return fmt.Sprintf("Level Value: %s\n", value), 0
+	case "set_level":
+		r.levels[args[0]] = args[1]
+	default:
+		return "", int(ErrInvalidParameter)
+	}
+	return "", 0
+}
+
+func (r *fakeRig) set(f func()) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	f()
+}
+
+func setupClient(t *testing.T) (*Client, *fakeRig) {
+	t.Helper()
+	rig := newFakeRig(t)
+	client := New("127.0.0.1", rig.port())
+	require.NoError(t, client.Connect())
+	t.Cleanup(func() { client.Disconnect() })
+	return client, rig
+}
+
+func TestClient_Frequency(t *testing.T) {
+	client, rig := setupClient(t)
+
+	frequency, err := client.Frequency()
+	require.NoError(t, err)
+	assert.Equal(t, hamradio.Frequency(14074000), frequency)
+
+	require.NoError(t, client.SetFrequency(7030000.4))
+	frequency, err = client.Frequency()
+	require.NoError(t, err)
+	assert.Equal(t, hamradio.Frequency(7030000), frequency)
+	rig.set(func() {
+		assert.Contains(t, rig.commands, "set_freq 7030000")
+	})
+}
+
+func TestClient_Mode(t *testing.T) {
+	client, _ := setupClient(t)
+
+	mode, passband, err := client.Mode()
+	require.NoError(t, err)
+	assert.Equal(t, ModeUSB, mode)
+	assert.Equal(t, hamradio.Frequency(2400), passband)
+
+	require.NoError(t, client.SetMode(ModeCW, 500))
+	require.NoError(t, client.SetMode(ModeCWR, PassbandNoChange))
+	mode, passband, err = client.Mode()
+	require.NoError(t, err)
+	assert.Equal(t, ModeCWR, mode)
+	assert.Equal(t, hamradio.Frequency(500), passband)
+}
+
+func TestClient_VFOAndSplit(t *testing.T) {
+	client, _ := setupClient(t)
+
+	require.NoError(t, client.SetVFO(VFOB))
+	vfo, err := client.VFO()
+	require.NoError(t, err)
+	assert.Equal(t, VFOB, vfo)
+
+	require.NoError(t, client.SetSplit(true, VFOA))
+	require.NoError(t, client.SetSplitFrequency(14075000))
+	split, txVFO, err := client.Split()
+	require.NoError(t, err)
+	assert.True(t, split)
+	assert.Equal(t, VFOA, txVFO)
+	txFrequency, err := client.SplitFrequency()
+	require.NoError(t, err)
+	assert.Equal(t, hamradio.Frequency(14075000), txFrequency)
+}
+
+func TestClient_PTT(t *testing.T) {
+	client, _ := setupClient(t)
+
+	require.NoError(t, client.SetPTT(true))
+	ptt, err := client.PTT()
+	require.NoError(t, err)
+	assert.True(t, ptt)
+
+	require.NoError(t, client.SetPTT(false))
+	ptt, err = client.PTT()
+	require.NoError(t, err)
+	assert.False(t, ptt)
+}
+
+func TestClient_Level(t *testing.T) {
+	client, _ := setupClient(t)
+
+	power, err := client.Level(LevelRFPower)
+	require.NoError(t, err)
+	assert.Equal(t, 0.5, power)
+
+	require.NoError(t, client.SetLevel(LevelKeySpeed, 30))
+	speed, err := client.Level(LevelKeySpeed)
+	require.NoError(t, err)
+	assert.Equal(t, 30.0, speed)
+
+	_, err = client.Level(LevelSWR)
+	assert.ErrorIs(t, err, ErrInvalidParameter)
+}
+
+func TestClient_Errors(t *testing.T) {
+	client, rig := setupClient(t)
+	rig.set(func() { rig.unsupported["get_vfo"] = true })
+
+	_, err := client.VFO()
+	assert.ErrorIs(t, err, ErrNotAvailable)
+	assert.True(t, IsUnsupported(err))
+	assert.Equal(t, "hamlib: function not available (-11)", err.Error())
+
+	// the connection is still usable after an error reported by rigctld
+	_, err = client.Frequency()
+	assert.NoError(t, err)
+	assert.False(t, IsUnsupported(nil))
+}
+
+func TestClient_NotConnected(t *testing.T) {
+	rig := newFakeRig(t)
+	client := New("127.0.0.1", rig.port())
+
+	_, err := client.Frequency()
+	assert.ErrorIs(t, err, ErrNotConnected)
+
+	require.NoError(t, client.Connect())
+	assert.True(t, client.IsConnected())
+	require.NoError(t, client.Disconnect())
+	assert.False(t, client.IsConnected())
+	_, err = client.Frequency()
+	assert.ErrorIs(t, err, ErrNotConnected)
+}
+
+func TestClient_Reconnect(t *testing.T) {
+	client, _ := setupClient(t)
+	client.lock.Lock()
+	client.connection.Close()
+	client.lock.Unlock()
+
+	_, err := client.Frequency()
+	assert.Error(t, err)
+	frequency, err := client.Frequency()
+	require.NoError(t, err)
+	assert.Equal(t, hamradio.Frequency(14074000), frequency)
+}
@@ -0,0 +1,253 @@
+package rigctl
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ftl/hamradio"
+)
+
+// DefaultPollInterval is the interval in which a Watcher polls the state of the rig.
+const DefaultPollInterval = 500 * time.Millisecond
+
+// State is the state of the rig as seen by the Watcher. Values that are not supported by the rig remain empty.
+type State struct {
+	Frequency hamradio.Frequency
+	Mode      Mode
+	Passband  hamradio.Frequency
+	VFO       VFO
+	PTT       bool
+	Split     bool
+	TXVFO     VFO
+}
+
+// EventType describes what changed.
+type EventType int
+
+// The types of events.
+const (
+	FrequencyChanged EventType = iota
+	ModeChanged
+	VFOChanged
+	PTTChanged
+	SplitChanged
+)
+
+func (t EventType) String() string {
+	switch t {
+	case FrequencyChanged:
+		return "frequency changed"
+	case ModeChanged:
+		return "mode changed"
+	case VFOChanged:
+		return "VFO changed"
+	case PTTChanged:
+		return "PTT changed"
+	case SplitChanged:
+		return "split changed"
+	default:
+		return "unknown"
+	}
+}
+
+// Event is reported to the registered event handlers when the state of the rig changed.
+type Event struct {
+	Type     EventType
+	State    State
+	Previous State
+}
+
+// EventHandler is notified about the changes of the rig's state.
+type EventHandler func(Event)
+
+// ErrorHandler is called if the state of the rig cannot be polled.
+type ErrorHandler func(err error)
+
+// Watcher polls the state of the rig in a regular interval and reports the changes. The first successful poll
+// reports all values as changed. Functions that are not supported by the rig are not polled again.
+type Watcher struct {
+	client       *Client
+	pollInterval time.Duration
+	pollLock     sync.Mutex
+
+	lock          sync.RWMutex
+	state         State
+	valid         bool
+	unsupported   map[EventType]bool
+	handlers      []EventHandler
+	errorHandlers []ErrorHandler
+
+	closed    chan struct{}
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// NewWatcher starts to poll the state of the rig using the given client. The first poll is done after the given
+// interval, use Poll to get the state immediately. If the poll interval is zero, DefaultPollInterval is used.
+func NewWatcher(client *Client, pollInterval time.Duration) *Watcher {
+	if pollInterval <= 0 {
+		pollInterval = DefaultPollInterval
+	}
+	result := &Watcher{
+		client:       client,
+		pollInterval: pollInterval,
+		unsupported:  make(map[EventType]bool),
+		closed:       make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+	go result.run()
+	return result
+}
+
+// State returns the last known state of the rig and indicates if the state was polled successfully at least once.
+func (w *Watcher) State() (State, bool) {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+	return w.state, w.valid
+}
+
+// OnEvent registers the given handler to be notified about changes of the rig's state. The handlers are called
+// on the goroutine of the watcher.
+func (w *Watcher) OnEvent(handler EventHandler) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.handlers = append(w.handlers, handler)
+}
+
+// OnError registers the given handler to be notified about errors while polling the state of the rig.
+func (w *Watcher) OnError(handler ErrorHandler) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.errorHandlers = append(w.errorHandlers, handler)
+}
+
+// Close stops polling the state of the rig. The client is not closed.
+func (w *Watcher) Close() error {
+	w.closeOnce.Do(func() {
+		close(w.closed)
+	})
+	<-w.done
+	return nil
+}
+
+// Poll polls the state of the rig immediately and reports the changes.
+func (w *Watcher) Poll() error {
+	w.pollLock.Lock()
+	defer w.pollLock.Unlock()
+
+	w.lock.RLock()
+	state := w.state
+	unsupported := make(map[EventType]bool, len(w.unsupported))
+	for t := range w.unsupported {
+		unsupported[t] = true
+	}
+	w.lock.RUnlock()
+
+	var err error
+	poll := func(t EventType, f func() error) {
+		if err != nil || unsupported[t] {
+			return
+		}
+		pollErr := f()
+		if IsUnsupported(pollErr) {
+			unsupported[t] = true
+		} else {
+			err = pollErr
+		}
+	}
+	poll(FrequencyChanged, func() (err error) {
+		state.Frequency, err = w.client.Frequency()
+		return err
+	})
+	poll(ModeChanged, func() (err error) {
+		state.Mode, state.Passband, err = w.client.Mode()
+		return err
+	})
+	poll(VFOChanged, func() (err error) {
+		state.VFO, err = w.client.VFO()
+		return err
+	})
+	poll(PTTChanged, func() (err error) {
+		state.PTT, err = w.client.PTT()
+		return err
+	})
+	poll(SplitChanged, func() (err error) {
+		state.Split, state.TXVFO, err = w.client.Split()
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	w.lock.Lock()
+	previous := w.state
+	wasValid := w.valid
+	w.state = state
+	w.valid = true
+	w.unsupported = unsupported
+	handlers := make([]EventHandler, len(w.handlers))
+	copy(handlers, w.handlers)
+	w.lock.Unlock()
+
+	for _, t := range []EventType{FrequencyChanged, ModeChanged, VFOChanged, PTTChanged, SplitChanged} {
+		if unsupported[t] {
+			continue
+		}
+		if wasValid && !changed(t, previous, state) {
+			continue
+		}
+		event := Event{Type: t, State: state, Previous: previous}
+		for _, handler := range handlers {
+			handler(event)
+		}
+	}
+	return nil
+}
+
+func changed(t EventType, previous, state State) bool {
+	switch t {
+	case FrequencyChanged:
+		return previous.Frequency != state.Frequency
+	case ModeChanged:
+		return previous.Mode != state.Mode || previous.Passband != state.Passband
+	case VFOChanged:
+		return previous.VFO != state.VFO
+	case PTTChanged:
+		return previous.PTT != state.PTT
+	case SplitChanged:
+		return previous.Split != state.Split || previous.TXVFO != state.TXVFO
+	default:
+		return false
+	}
+}
+
+func (w *Watcher) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.closed:
+			return
+		case <-ticker.C:
+			w.poll()
+		}
+	}
+}
+
+func (w *Watcher) poll() {
+	err := w.Poll()
+	if err == nil {
+		return
+	}
+
+	w.lock.RLock()
+	errorHandlers := make([]ErrorHandler, len(w.errorHandlers))
+	copy(errorHandlers, w.errorHandlers)
+	w.lock.RUnlock()
+
+	for _, handler := range errorHandlers {
+		handler(err)
+	}
+}
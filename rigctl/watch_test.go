@@ -0,0 +1,81 @@
+package rigctl
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ftl/hamradio"
+)
+
+func TestWatcher_Poll(t *testing.T) {
+	client, rig := setupClient(t)
+	rig.set(func() { rig.unsupported["get_split_vfo"] = true })
+	watcher := NewWatcher(client, time.Hour)
+	t.Cleanup(func() { watcher.Close() })
+	var events []Event
+	watcher.OnEvent(func(event Event) { events = append(events, event) })
+
+	require.NoError(t, watcher.Poll())
+	types := make([]EventType, 0, len(events))
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+	assert.Equal(t, []EventType{FrequencyChanged, ModeChanged, VFOChanged, PTTChanged}, types)
+	state, valid := watcher.State()
+	assert.True(t, valid)
+	assert.Equal(t, State{Frequency: 14074000, Mode: ModeUSB, Passband: 2400, VFO: VFOA}, state)
+
+	events = nil
+	rig.set(func() {
+		rig.frequency = 7030000
+		rig.commands = nil
+	})
+	require.NoError(t, watcher.Poll())
+	require.Len(t, events, 1)
+	assert.Equal(t, FrequencyChanged, events[0].Type)
+	assert.Equal(t, hamradio.Frequency(14074000), events[0].Previous.Frequency)
+	assert.Equal(t, hamradio.Frequency(7030000), events[0].State.Frequency)
+	rig.set(func() {
+		assert.NotContains(t, rig.commands, "get_split_vfo")
+	})
+
+	events = nil
+	require.NoError(t, watcher.Poll())
+	assert.Empty(t, events)
+}
+
+func TestWatcher_Run(t *testing.T) {
+	client, rig := setupClient(t)
+	watcher := NewWatcher(client, 10*time.Millisecond)
+	t.Cleanup(func() { watcher.Close() })
+	events := make(chan Event, 10)
+	watcher.OnEvent(func(event Event) {
+		if event.Type == PTTChanged {
+			events <- event
+		}
+	})
+	errors := make(chan error, 10)
+	watcher.OnError(func(err error) { errors <- err })
+
+	event := <-events
+	assert.False(t, event.State.PTT)
+	rig.set(func() { rig.ptt = 1 })
+	event = <-events
+	assert.True(t, event.State.PTT)
+
+	rig.listener.Close()
+	client.lock.Lock()
+	client.connection.Close()
+	client.lock.Unlock()
+	select {
+	case err := <-errors:
+		assert.Error(t, err)
+	case <-time.After(time.Second):
+		assert.Fail(t, "no error reported")
+	}
+
+	require.NoError(t, watcher.Close())
+}
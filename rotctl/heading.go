@@ -0,0 +1,104 @@
+package rotctl
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/ftl/hamradio/callsign"
+	"github.com/ftl/hamradio/dxcc"
+	"github.com/ftl/hamradio/latlon"
+	"github.com/ftl/hamradio/locator"
+)
+
+// ErrNoPrefixes is returned by FindHeading if the target is a callsign or DXCC prefix, but no prefixes are given.
+var ErrNoPrefixes = errors.New("no DXCC prefixes available")
+
+// Path selects the short or the long path along the great circle.
+type Path int
+
+// The paths.
+const (
+	ShortPath Path = iota
+	LongPath
+)
+
+func (p Path) String() string {
+	switch p {
+	case ShortPath:
+		return "short path"
+	case LongPath:
+		return "long path"
+	default:
+		return "unknown path"
+	}
+}
+
+// earthCircumference is the length of a great circle, using the same radius as latlon.Distance.
+const earthCircumference = latlon.Km(2 * math.Pi * 6371)
+
+// Heading describes the direction to a target.
+type Heading struct {
+	// Target describes the target, e.g. "JO62qm", "DL1ABC (Fed. Rep. of Germany)" or "VK (Australia)".
+	Target   string
+	LatLon   latlon.LatLon
+	Azimuth  latlon.Degrees
+	Distance latlon.Km
+	Path     Path
+}
+
+func (h Heading) String() string {
+	return fmt.Sprintf("%s: %v (%v, %v)", h.Target, h.Azimuth, h.Path, h.Distance)
+}
+
+// HeadingTo returns the heading from one coordinate to another one on the given path.
+func HeadingTo(from, to latlon.LatLon, path Path) Heading {
+	result := Heading{
+		LatLon:   to,
+		Azimuth:  latlon.Azimuth(from, to),
+		Distance: latlon.Distance(from, to),
+		Path:     path,
+	}
+	if path == LongPath {
+		result.Azimuth = latlon.Degrees(math.Mod(float64(result.Azimuth)+180, 360))
+		result.Distance = earthCircumference - result.Distance
+	}
+	return result
+}
+
+// FindHeading returns the heading from the given locator to the given target on the given path. The target is
+// either a maidenhead locator with at least four characters, a callsign or a DXCC prefix. Locators take precedence
+// over callsigns. For callsigns and prefixes, the location of the DXCC entity is used. If a prefix is ambiguous, the
+// first matching DXCC entity is used.
+func FindHeading(from locator.Locator, target string, prefixes *dxcc.Prefixes, path Path) (Heading, error) {
+	fromLatLon := locator.ToLatLon(from)
+	normalTarget := strings.ToUpper(strings.TrimSpace(target))
+
+	if len(normalTarget) >= 4 {
+		loc, err := locator.Parse(normalTarget)
+		if err == nil {
+			result := HeadingTo(fromLatLon, locator.ToLatLon(loc), path)
+			result.Target = loc.String()
+			return result, nil
+		}
+	}
+
+	if prefixes == nil {
+		return Heading{}, ErrNoPrefixes
+	}
+	var prefix dxcc.Prefix
+	var found bool
+	if call, err := callsign.Parse(normalTarget); err == nil {
+		normalTarget = call.String()
+		prefix, found = prefixes.FindCallsign(call)
+	} else if matches, ok := prefixes.Find(normalTarget); ok {
+		prefix, found = matches[0], true
+	}
+	if !found {
+		return Heading{}, fmt.Errorf("no DXCC entity found for %s", target)
+	}
+	result := HeadingTo(fromLatLon, prefix.LatLon, path)
+	result.Target = fmt.Sprintf("%s (%s)", normalTarget, prefix.Name)
+	return result, nil
+}
@@ -0,0 +1,67 @@
+package rotctl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ftl/hamradio/dxcc/dxcctest"
+	"github.com/ftl/hamradio/latlon"
+	"github.com/ftl/hamradio/locator"
+)
+
+func TestHeadingTo(t *testing.T) {
+	from := locator.ToLatLon(locator.MustParse("JN59nk"))
+	to := locator.ToLatLon(locator.MustParse("KO94bx"))
+
+	short := HeadingTo(from, to, ShortPath)
+	long := HeadingTo(from, to, LongPath)
+
+	assert.Equal(t, latlon.Azimuth(from, to), short.Azimuth)
+	assert.Equal(t, latlon.Distance(from, to), short.Distance)
+	assert.InDelta(t, float64(short.Azimuth)+180, float64(long.Azimuth), 0.001)
+	assert.InDelta(t, 40030.2, float64(short.Distance+long.Distance), 0.1)
+	assert.Equal(t, LongPath, long.Path)
+}
+
+func TestFindHeading(t *testing.T) {
+	from := locator.MustParse("JN59nk")
+	fromLatLon := locator.ToLatLon(from)
+	prefixes := dxcctest.Prefixes()
+	tt := []struct {
+		target   string
+		path     Path
+		expected string
+		latLon   latlon.LatLon
+	}{
+		{"ko94bx", ShortPath, "KO94bx", locator.ToLatLon(locator.MustParse("KO94bx"))},
+		{"JO62", LongPath, "JO62", locator.ToLatLon(locator.MustParse("JO62"))},
+		{"vk", ShortPath, "VK (Australia)", latlon.NewLatLon(-23.7, 132.33)},
+		{"vk2abc", LongPath, "VK2ABC (Australia)", latlon.NewLatLon(-23.7, 132.33)},
+		{"ea8/dl1abc/p", ShortPath, "EA8/DL1ABC/p (Canary Islands)", latlon.NewLatLon(28.32, -15.85)},
+	}
+	for _, tc := range tt {
+		t.Run(tc.target, func(t *testing.T) {
+			heading, err := FindHeading(from, tc.target, prefixes, tc.path)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, heading.Target)
+			assert.Equal(t, tc.latLon, heading.LatLon)
+			assert.Equal(t, HeadingTo(fromLatLon, tc.latLon, tc.path), Heading{LatLon: heading.LatLon, Azimuth: heading.Azimuth, Distance: heading.Distance, Path: heading.Path})
+		})
+	}
+}
+
+func TestFindHeading_Errors(t *testing.T) {
+	from := locator.MustParse("JN59nk")
+
+	_, err := FindHeading(from, "dl1abc", nil, ShortPath)
+	assert.ErrorIs(t, err, ErrNoPrefixes)
+
+	_, err = FindHeading(from, "xx", dxcctest.Prefixes(), ShortPath)
+	assert.Error(t, err)
+
+	heading, err := FindHeading(from, "JN59", nil, ShortPath)
+	require.NoError(t, err)
+	assert.Equal(t, "JN59", heading.Target)
+}
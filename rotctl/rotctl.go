@@ -0,0 +1,94 @@
+/*
+Package rotctl provides a client for the network protocol of rotctld, the rotator control daemon of Hamlib
+(https://hamlib.github.io), and helpers to point the antenna to a callsign, locator or DXCC prefix.
+
+To run rotctld locally for testing use the following command line: "rotctld -m 1"
+This starts rotctld with the dummy rotator, listening on port 4533.
+
+The protocol is the same as for rigctld, see package rigctl. Errors reported by rotctld are returned as rigctl.Error.
+*/
+package rotctl
+
+import (
+	"net"
+	"strconv"
+
+	"github.com/ftl/hamradio/latlon"
+	"github.com/ftl/hamradio/rigctl"
+)
+
+// DefaultPort is the default TCP port of rotctld.
+const DefaultPort = 4533
+
+// Client is a client for rotctld. It is safe for concurrent use, the commands are sent one after the other.
+type Client struct {
+	*rigctl.Conn
+}
+
+// New creates a new Client for rotctld running on the given hostname and port.
+// If the hostname is empty, localhost will be used. If the port is 0, the default port 4533 will be used.
+func New(hostname string, port int) *Client {
+	if port == 0 {
+		port = DefaultPort
+	}
+	return &Client{rigctl.NewConn(net.JoinHostPort(hostname, strconv.Itoa(port)))}
+}
+
+// NewDefault returns a Client for rotctld running on localhost:4533.
+func NewDefault() *Client {
+	return New("", 0)
+}
+
+// Position returns the current azimuth and elevation of the rotator.
+func (c *Client) Position() (azimuth, elevation latlon.Degrees, err error) {
+	r, err := c.Request("get_pos")
+	if err != nil {
+		return 0, 0, err
+	}
+	azimuth, err = degrees(r, "Azimuth")
+	if err != nil {
+		return 0, 0, err
+	}
+	elevation, err = degrees(r, "Elevation")
+	if err != nil {
+		return 0, 0, err
+	}
+	return azimuth, elevation, nil
+}
+
+// SetPosition turns the rotator to the given azimuth and elevation. The rotator is still moving when SetPosition
+// returns, use Position to follow the movement.
+func (c *Client) SetPosition(azimuth, elevation latlon.Degrees) error {
+	_, err := c.Request("set_pos", formatDegrees(azimuth), formatDegrees(elevation))
+	return err
+}
+
+// PointTo turns the rotator to the azimuth of the given heading, with an elevation of 0°.
+func (c *Client) PointTo(heading Heading) error {
+	return c.SetPosition(heading.Azimuth, 0)
+}
+
+// Stop stops the movement of the rotator.
+func (c *Client) Stop() error {
+	_, err := c.Request("stop")
+	return err
+}
+
+// Park moves the rotator to its park position.
+func (c *Client) Park() error {
+	_, err := c.Request("park")
+	return err
+}
+
+func degrees(r rigctl.Response, key string) (latlon.Degrees, error) {
+	value, err := r.Value(key)
+	if err != nil {
+		return 0, err
+	}
+	result, err := strconv.ParseFloat(value, 64)
+	return latlon.Degrees(result), err
+}
+
+func formatDegrees(d latlon.Degrees) string {
+	return strconv.FormatFloat(float64(d), 'f', 1, 64)
+}
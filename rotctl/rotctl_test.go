@@ -0,0 +1,130 @@
+package rotctl
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ftl/hamradio/latlon"
+	"github.com/ftl/hamradio/rigctl"
+)
+
+// fakeRotator emulates rotctld in the extended response mode.
+type fakeRotator struct {
+	listener net.Listener
+
+	lock      sync.Mutex
+	azimuth   float64
+	elevation float64
+	commands  []string
+}
+
+func newFakeRotator(t *testing.T) *fakeRotator {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	rotator := &fakeRotator{listener: listener, azimuth: 90}
+	t.Cleanup(func() { listener.Close() })
+	go rotator.serve()
+	return rotator
+}
+
+func (r *fakeRotator) serve() {
+	for {
+		conn, err := r.listener.Accept()
+		if err != nil {
+			return
+		}
+		go r.handle(conn)
+	}
+}
+
+func (r *fakeRotator) handle(conn net.Conn) {
+	defer conn.Close()
+	in := bufio.NewScanner(conn)
+	for in.Scan() {
+		line := in.Text()
+		if line == "q" {
+			return
+		}
+		fields := strings.Fields(strings.TrimPrefix(line, "+\\"))
+		command, args := fields[0], fields[1:]
+		header := strings.TrimSpace(command + ": " + strings.Join(args, " "))
+
+		r.lock.Lock()
+		r.commands = append(r.commands, strings.Join(fields, " "))
+		var values string
+		code := 0
+		switch command {
+		case "get_pos":
+			values = fmt.Sprintf("Azimuth: %f\nElevation: %f\n", r.azimuth, r.elevation)
+		case "set_pos":
+			azimuth, _ := strconv.ParseFloat(args[0], 64)
+			elevation, _ := strconv.ParseFloat(args[1], 64)
+			if azimuth < 0 || azimuth > 360 {
+				code = int(rigctl.ErrInvalidArgument)
+				break
+			}
+			r.azimuth, r.elevation = azimuth, elevation
+		case "stop":
+		case "park":
+			r.azimuth, r.elevation = 0, 0
+		default:
+			code = int(rigctl.ErrInvalidParameter)
+		}
+		r.lock.Unlock()
+
+		fmt.Fprintf(conn, "%s\n%sRPRT %d\n", header, values, code)
+	}
+}
+
+func setupClient(t *testing.T) (*Client, *fakeRotator) {
+	t.Helper()
+	rotator := newFakeRotator(t)
+	client := New("127.0.0.1", rotator.listener.Addr().(*net.TCPAddr).Port)
+	require.NoError(t, client.Connect())
+	t.Cleanup(func() { client.Disconnect() })
+	return client, rotator
+}
+
+func TestClient_Position(t *testing.T) {
+	client, rotator := setupClient(t)
+
+	azimuth, elevation, err := client.Position()
+	require.NoError(t, err)
+	assert.Equal(t, latlon.Degrees(90), azimuth)
+	assert.Equal(t, latlon.Degrees(0), elevation)
+
+	require.NoError(t, client.SetPosition(123.45, 10))
+	azimuth, elevation, err = client.Position()
+	require.NoError(t, err)
+	assert.InDelta(t, 123.5, float64(azimuth), 0.01)
+	assert.Equal(t, latlon.Degrees(10), elevation)
+
+	err = client.SetPosition(400, 0)
+	assert.ErrorIs(t, err, rigctl.ErrInvalidArgument)
+
+	require.NoError(t, client.PointTo(Heading{Azimuth: 270}))
+	require.NoError(t, client.Stop())
+	rotator.lock.Lock()
+	assert.Equal(t, 270.0, rotator.azimuth)
+	assert.Equal(t, []string{"get_pos", "set_pos 123.5 10.0", "get_pos", "set_pos 400.0 0.0", "set_pos 270.0 0.0", "stop"}, rotator.commands)
+	rotator.lock.Unlock()
+}
+
+func TestClient_Park(t *testing.T) {
+	client, _ := setupClient(t)
+
+	require.NoError(t, client.Park())
+
+	azimuth, _, err := client.Position()
+	require.NoError(t, err)
+	assert.Equal(t, latlon.Degrees(0), azimuth)
+}
@@ -0,0 +1,24 @@
+package winkeyer
+
+import (
+	"errors"
+	"os"
+)
+
+// ErrSerialNotSupported is returned by OpenPort on platforms where serial ports cannot be configured.
+var ErrSerialNotSupported = errors.New("serial ports are not supported on this platform")
+
+// OpenPort opens the serial port with the given name and configures it for the WinKeyer: 1200 baud, 8 data bits,
+// no parity, 2 stop bits, raw mode.
+func OpenPort(name string) (*os.File, error) {
+	file, err := os.OpenFile(name, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	err = configurePort(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
@@ -0,0 +1,25 @@
+//go:build linux
+
+package winkeyer
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+func configurePort(file *os.File) error {
+	termios := syscall.Termios{
+		Cflag:  syscall.B1200 | syscall.CS8 | syscall.CSTOPB | syscall.CREAD | syscall.CLOCAL,
+		Ispeed: syscall.B1200,
+		Ospeed: syscall.B1200,
+	}
+	termios.Cc[syscall.VMIN] = 1
+	termios.Cc[syscall.VTIME] = 0
+
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, file.Fd(), uintptr(syscall.TCSETS), uintptr(unsafe.Pointer(&termios)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package winkeyer
+
+import "os"
+
+func configurePort(*os.File) error {
+	return ErrSerialNotSupported
+}
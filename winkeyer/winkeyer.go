@@ -0,0 +1,544 @@
+/*
+Package winkeyer provides a client for the K1EL WinKeyer 2 and 3 (https://www.k1elsystems.com).
+
+The WinKeyer is connected through a serial port with 1200 baud, 8 data bits, no parity and 2 stop bits. OpenPort
+opens and configures such a serial port on Linux, but the Keyer works with any io.ReadWriter, e.g. a pseudo-terminal
+or the connection to a serial device server.
+
+The Keyer implements cwclient.Keyer. It enables the serial echo back of the WinKeyer to track the output, respects the
+flow control of the WinKeyer's input buffer and reports the status bytes and the position of the speed pot.
+*/
+package winkeyer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	adminCommand   = 0x00
+	adminHostOpen  = 0x02
+	adminHostClose = 0x03
+
+	commandSpeed        = 0x02
+	commandWeight       = 0x03
+	commandSetupPot     = 0x05
+	commandGetSpeedPot  = 0x07
+	commandClearBuffer  = 0x0A
+	commandKeyImmediate = 0x0B
+	commandMode         = 0x0E
+	commandStatus       = 0x15
+	commandBufferedPTT  = 0x18
+	commandMerge        = 0x1B
+
+	// modeSerialEcho enables the echo of each character when it is sent as CW.
+	modeSerialEcho = 0x04
+
+	typeMask     = 0xC0
+	statusType   = 0xC0
+	speedPotType = 0x80
+	speedPotMask = 0x3F
+)
+
+// maxInFlight is the maximum number of characters that are written to the WinKeyer and not yet echoed. This keeps the
+// input buffer of the WinKeyer (128 bytes) well below its limit, the XOFF status is respected additionally.
+const maxInFlight = 32
+
+// supportedCharacters contains all characters the WinKeyer can output as CW.
+const supportedCharacters = " ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789\"$'()+,-./:;=?@"
+
+// Default settings of the speed pot.
+const (
+	DefaultSpeedPotMin   = 10
+	DefaultSpeedPotRange = 25
+)
+
+// OpenTimeout is the time to wait for the WinKeyer to respond to the host open command.
+var OpenTimeout = 2 * time.Second
+
+// ErrNoResponse is returned by Open if the WinKeyer does not respond in time.
+var ErrNoResponse = errors.New("no response from WinKeyer")
+
+// Status of the WinKeyer, as reported by the status byte.
+type Status byte
+
+// The flags of the status byte.
+const (
+	// XOFF indicates that the input buffer is more than 2/3 full.
+	XOFF Status = 0x01
+	// BreakIn indicates that the paddle was used.
+	BreakIn Status = 0x02
+	// Busy indicates that the WinKeyer is keying.
+	Busy Status = 0x04
+	// KeyDown indicates that the key is down for tuning.
+	KeyDown Status = 0x08
+	// Waiting indicates that the WinKeyer executes a wait command.
+	Waiting Status = 0x10
+)
+
+// Has indicates if the given flag is set.
+func (s Status) Has(flag Status) bool {
+	return s&flag == flag
+}
+
+func (s Status) String() string {
+	names := []string{"xoff", "breakin", "busy", "keydown", "wait"}
+	var flags []string
+	for i, name := range names {
+		if s.Has(Status(1 << i)) {
+			flags = append(flags, name)
+		}
+	}
+	if len(flags) == 0 {
+		return "idle"
+	}
+	return strings.Join(flags, ",")
+}
+
+// EchoHandler is notified about each character the WinKeyer sends as CW.
+type EchoHandler func(rune)
+
+// StatusHandler is notified about each status byte the WinKeyer reports.
+type StatusHandler func(Status)
+
+// SpeedPotHandler is notified about the speed in WpM the speed pot of the WinKeyer is set to.
+type SpeedPotHandler func(int)
+
+// Keyer is a client for the WinKeyer.
+type Keyer struct {
+	device       io.ReadWriter
+	version      int
+	received     chan byte
+	disconnected chan struct{}
+	closeOnce    *sync.Once
+	writeLock    *sync.Mutex
+	wakeup       chan struct{}
+
+	lock             *sync.Mutex
+	queue            []token
+	inFlight         int
+	status           Status
+	tuning           *time.Timer
+	speedPotMin      int
+	speedPot         int
+	echoHandlers     []EchoHandler
+	statusHandlers   []StatusHandler
+	speedPotHandlers []SpeedPotHandler
+}
+
+// token is a sequence of bytes that is written to the WinKeyer at once, echoes is the number of characters the
+// WinKeyer echoes when the token is sent as CW.
+type token struct {
+	bytes  []byte
+	echoes int
+}
+
+// Open opens the host mode of the WinKeyer connected to the given device. The serial echo back is enabled and the
+// speed pot is set up with the default range. The caller is responsible to close the device after closing the Keyer.
+func Open(device io.ReadWriter) (*Keyer, error) {
+	k := &Keyer{
+		device:       device,
+		received:     make(chan byte, 256),
+		disconnected: make(chan struct{}),
+		closeOnce:    new(sync.Once),
+		writeLock:    new(sync.Mutex),
+		wakeup:       make(chan struct{}, 1),
+		lock:         new(sync.Mutex),
+		speedPotMin:  DefaultSpeedPotMin,
+	}
+	go k.read()
+
+	err := k.write(adminCommand, adminHostOpen)
+	if err != nil {
+		k.disconnect()
+		return nil, fmt.Errorf("cannot open host mode: %w", err)
+	}
+	select {
+	case version, ok := <-k.received:
+		if !ok {
+			k.disconnect()
+			return nil, fmt.Errorf("cannot open host mode: %w", io.ErrUnexpectedEOF)
+		}
+		k.version = int(version)
+	case <-time.After(OpenTimeout):
+		k.disconnect()
+		return nil, ErrNoResponse
+	}
+
+	go k.handle()
+	go k.transmit()
+
+	err = k.write(commandMode, modeSerialEcho)
+	if err == nil {
+		err = k.write(commandSetupPot, DefaultSpeedPotMin, DefaultSpeedPotRange, 0)
+	}
+	if err == nil {
+		err = k.write(commandGetSpeedPot)
+	}
+	if err == nil {
+		err = k.write(commandStatus)
+	}
+	if err != nil {
+		k.disconnect()
+		return nil, err
+	}
+	return k, nil
+}
+
+// Close closes the host mode of the WinKeyer. It does not close the underlying device.
+func (k *Keyer) Close() error {
+	if !k.IsConnected() {
+		return nil
+	}
+	k.stopTuning()
+	err := k.write(adminCommand, adminHostClose)
+	k.disconnect()
+	return err
+}
+
+func (k *Keyer) disconnect() {
+	k.closeOnce.Do(func() {
+		close(k.disconnected)
+	})
+}
+
+// IsConnected indicates if the host mode of the WinKeyer is open.
+func (k *Keyer) IsConnected() bool {
+	select {
+	case <-k.disconnected:
+		return false
+	default:
+		return true
+	}
+}
+
+// Version returns the firmware version reported by the WinKeyer, e.g. 23 for version 2.3.
+func (k *Keyer) Version() int {
+	return k.version
+}
+
+// OnEcho registers the given handler to be notified about each character the WinKeyer sends as CW.
+func (k *Keyer) OnEcho(handler EchoHandler) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	k.echoHandlers = append(k.echoHandlers, handler)
+}
+
+// OnStatus registers the given handler to be notified about each status byte.
+func (k *Keyer) OnStatus(handler StatusHandler) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	k.statusHandlers = append(k.statusHandlers, handler)
+}
+
+// OnSpeedPot registers the given handler to be notified about changes of the speed pot.
+func (k *Keyer) OnSpeedPot(handler SpeedPotHandler) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	k.speedPotHandlers = append(k.speedPotHandlers, handler)
+}
+
+// Status returns the last status reported by the WinKeyer.
+func (k *Keyer) Status() Status {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	return k.status
+}
+
+// SpeedPot returns the speed in WpM the speed pot is set to, or 0 if the WinKeyer did not report it yet.
+func (k *Keyer) SpeedPot() int {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	return k.speedPot
+}
+
+// SetupSpeedPot sets the range of the speed pot to [min..min+range] WpM.
+func (k *Keyer) SetupSpeedPot(min int, speedRange int) {
+	normalizedMin := int(math.Max(5, math.Min(float64(min), 99)))
+	normalizedRange := int(math.Max(0, math.Min(float64(speedRange), 99-float64(normalizedMin))))
+	k.lock.Lock()
+	k.speedPotMin = normalizedMin
+	k.lock.Unlock()
+	k.command(commandSetupPot, byte(normalizedMin), byte(normalizedRange), 0)
+	k.command(commandGetSpeedPot)
+}
+
+// IsIdle returns true if there are no texts waiting for output as CW.
+func (k *Keyer) IsIdle() bool {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	return len(k.queue) == 0 && k.inFlight == 0 && !k.status.Has(Busy) && k.tuning == nil
+}
+
+// Wait waits for all pending text to be output as CW.
+func (k *Keyer) Wait() {
+	for !k.IsIdle() && k.IsConnected() {
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// Speed sets the speed to the given speed in WpM [5..99].
+func (k *Keyer) Speed(speed int) {
+	normalizedSpeed := int(math.Max(5, math.Min(float64(speed), 99)))
+	k.command(commandSpeed, byte(normalizedSpeed))
+}
+
+// Weight sets the weighting between dit and dah [-40..40].
+func (k *Keyer) Weight(weight int) {
+	normalizedWeight := int(math.Max(-40, math.Min(float64(weight), 40)))
+	k.command(commandWeight, byte(50+normalizedWeight))
+}
+
+// Abort aborts the output of CW and discards all pending texts.
+func (k *Keyer) Abort() {
+	k.lock.Lock()
+	k.queue = nil
+	k.inFlight = 0
+	k.lock.Unlock()
+	k.stopTuning()
+	k.command(commandClearBuffer)
+}
+
+// PTT enables or disables the PTT. The command is queued in order with the pending texts.
+func (k *Keyer) PTT(on bool) {
+	var onAsByte byte
+	if on {
+		onAsByte = 1
+	}
+	k.enqueue(token{bytes: []byte{commandBufferedPTT, onAsByte}})
+}
+
+// Tune keys down for the given duration in seconds [0..10]. 0 ends the tuning.
+func (k *Keyer) Tune(seconds int) {
+	normalizedSeconds := int(math.Max(0, math.Min(float64(seconds), 10)))
+	k.stopTuning()
+	if normalizedSeconds == 0 {
+		return
+	}
+
+	k.lock.Lock()
+	var timer *time.Timer
+	timer = time.AfterFunc(time.Duration(normalizedSeconds)*time.Second, func() {
+		k.lock.Lock()
+		if k.tuning != timer {
+			k.lock.Unlock()
+			return
+		}
+		k.tuning = nil
+		k.lock.Unlock()
+		k.command(commandKeyImmediate, 0)
+	})
+	k.tuning = timer
+	k.lock.Unlock()
+	k.command(commandKeyImmediate, 1)
+}
+
+func (k *Keyer) stopTuning() {
+	k.lock.Lock()
+	timer := k.tuning
+	k.tuning = nil
+	k.lock.Unlock()
+	if timer == nil {
+		return
+	}
+	timer.Stop()
+	k.command(commandKeyImmediate, 0)
+}
+
+// Send queues the given text for output as CW. Prosigns are written in angle brackets (e.g. <AR>) and must consist
+// of two letters. Characters the WinKeyer does not support are skipped.
+func (k *Keyer) Send(text string) {
+	if !k.IsConnected() {
+		log.Printf("Cannot send %q, the WinKeyer is not connected.", text)
+		return
+	}
+	tokens, unsupported := encode(text)
+	if len(unsupported) > 0 {
+		log.Printf("Cannot send %q as CW with the WinKeyer, skipping.", unsupported)
+	}
+	k.enqueue(tokens...)
+}
+
+// encode converts the given text into tokens for the WinKeyer and returns also the unsupported parts of the text.
+func encode(text string) ([]token, []string) {
+	text = strings.ToUpper(text)
+	var result []token
+	var unsupported []string
+	for len(text) > 0 {
+		if strings.HasPrefix(text, "<") {
+			end := strings.Index(text, ">")
+			if end == 3 && strings.Contains(supportedCharacters, text[1:2]) && strings.Contains(supportedCharacters, text[2:3]) && text[1] != ' ' && text[2] != ' ' {
+				result = append(result, token{bytes: []byte{commandMerge, text[1], text[2]}, echoes: 2})
+				text = text[4:]
+				continue
+			}
+			if end == -1 {
+				end = len(text) - 1
+			}
+			unsupported = append(unsupported, text[:end+1])
+			text = text[end+1:]
+			continue
+		}
+
+		c := text[0]
+		if c < 0x80 && strings.IndexByte(supportedCharacters, c) != -1 {
+			result = append(result, token{bytes: []byte{c}, echoes: 1})
+			text = text[1:]
+			continue
+		}
+		r := []rune(text)[0]
+		unsupported = append(unsupported, string(r))
+		text = text[len(string(r)):]
+	}
+	return result, unsupported
+}
+
+func (k *Keyer) enqueue(tokens ...token) {
+	if len(tokens) == 0 {
+		return
+	}
+	k.lock.Lock()
+	k.queue = append(k.queue, tokens...)
+	k.lock.Unlock()
+	k.wake()
+}
+
+func (k *Keyer) wake() {
+	select {
+	case k.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+// transmit writes the queued tokens to the WinKeyer as long as its input buffer has room for them.
+func (k *Keyer) transmit() {
+	for {
+		select {
+		case <-k.disconnected:
+			return
+		case <-k.wakeup:
+		}
+
+		for {
+			k.lock.Lock()
+			if len(k.queue) == 0 || k.status.Has(XOFF) || k.inFlight >= maxInFlight {
+				k.lock.Unlock()
+				break
+			}
+			next := k.queue[0]
+			k.queue = k.queue[1:]
+			k.inFlight += next.echoes
+			k.lock.Unlock()
+
+			err := k.write(next.bytes...)
+			if err != nil {
+				log.Printf("Error sending %q: %v", next.bytes, err)
+			}
+		}
+	}
+}
+
+func (k *Keyer) command(bytes ...byte) {
+	if !k.IsConnected() {
+		log.Printf("Cannot send command %x, the WinKeyer is not connected.", bytes)
+		return
+	}
+	err := k.write(bytes...)
+	if err != nil {
+		log.Printf("Error sending command %x: %v", bytes, err)
+	}
+}
+
+func (k *Keyer) write(bytes ...byte) error {
+	k.writeLock.Lock()
+	defer k.writeLock.Unlock()
+	_, err := k.device.Write(bytes)
+	return err
+}
+
+// read forwards the bytes received from the WinKeyer until the device returns an error.
+func (k *Keyer) read() {
+	defer close(k.received)
+	buffer := make([]byte, 64)
+	for {
+		n, err := k.device.Read(buffer)
+		for _, b := range buffer[:n] {
+			select {
+			case k.received <- b:
+			case <-k.disconnected:
+				return
+			}
+		}
+		if err != nil {
+			if k.IsConnected() {
+				log.Printf("Error receiving from the WinKeyer: %v", err)
+			}
+			return
+		}
+	}
+}
+
+// handle dispatches the received bytes to the status, speed pot and echo handling.
+func (k *Keyer) handle() {
+	defer k.disconnect()
+	for b := range k.received {
+		switch b & typeMask {
+		case statusType:
+			k.handleStatus(Status(b &^ typeMask))
+		case speedPotType:
+			k.handleSpeedPot(int(b & speedPotMask))
+		default:
+			k.handleEcho(rune(b))
+		}
+	}
+}
+
+func (k *Keyer) handleStatus(status Status) {
+	k.lock.Lock()
+	k.status = status
+	handlers := k.statusHandlers
+	k.lock.Unlock()
+
+	if !status.Has(XOFF) {
+		k.wake()
+	}
+	for _, handler := range handlers {
+		handler(status)
+	}
+}
+
+func (k *Keyer) handleSpeedPot(value int) {
+	k.lock.Lock()
+	speed := k.speedPotMin + value
+	changed := speed != k.speedPot
+	k.speedPot = speed
+	handlers := k.speedPotHandlers
+	k.lock.Unlock()
+
+	if !changed {
+		return
+	}
+	for _, handler := range handlers {
+		handler(speed)
+	}
+}
+
+func (k *Keyer) handleEcho(r rune) {
+	k.lock.Lock()
+	if k.inFlight > 0 {
+		k.inFlight--
+	}
+	handlers := k.echoHandlers
+	k.lock.Unlock()
+
+	k.wake()
+	for _, handler := range handlers {
+		handler(r)
+	}
+}
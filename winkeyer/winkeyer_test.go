@@ -0,0 +1,482 @@
+package winkeyer
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ftl/hamradio/cwclient"
+)
+
+var _ cwclient.Keyer = (*Keyer)(nil)
+
+// fakeDevice emulates a WinKeyer 3 on the other end of a pipe.
+type fakeDevice struct {
+	conn         net.Conn
+	charDuration time.Duration
+	writeLock    sync.Mutex
+	wakeup       chan struct{}
+
+	lock        sync.Mutex
+	buffer      []byte
+	keying      bool
+	keyed       strings.Builder
+	status      Status
+	maxBuffered int
+	xoffLevel   int
+	halted      bool
+	mode        byte
+	speed       int
+	weight      int
+	potMin      int
+	potRange    int
+	potValue    int
+	ptt         []bool
+	keyDown     bool
+	open        bool
+}
+
+const (
+	fakeBufferSize = 128
+	fakeXOFFLevel  = 85
+)
+
+func newFakeDevice(t *testing.T, charDuration time.Duration) (*fakeDevice, net.Conn) {
+	t.Helper()
+	hostSide, deviceSide := net.Pipe()
+	d := &fakeDevice{
+		conn:         deviceSide,
+		charDuration: charDuration,
+		wakeup:       make(chan struct{}, 1),
+		xoffLevel:    fakeXOFFLevel,
+	}
+	done := make(chan struct{})
+	t.Cleanup(func() {
+		hostSide.Close()
+		deviceSide.Close()
+		close(done)
+	})
+	go d.receive()
+	go d.key(done)
+	return d, hostSide
+}
+
+func (d *fakeDevice) write(bytes ...byte) {
+	d.writeLock.Lock()
+	defer d.writeLock.Unlock()
+	d.conn.Write(bytes)
+}
+
+func (d *fakeDevice) receive() {
+	in := bufio.NewReader(d.conn)
+	next := func() byte {
+		b, err := in.ReadByte()
+		if err != nil {
+			panic(err)
+		}
+		return b
+	}
+	defer func() { recover() }()
+
+	for {
+		b := next()
+		d.lock.Lock()
+		switch b {
+		case adminCommand:
+			switch next() {
+			case adminHostOpen:
+				d.open = true
+				d.lock.Unlock()
+				d.write(31)
+				d.lock.Lock()
+			case adminHostClose:
+				d.open = false
+			}
+		case commandSpeed:
+			d.speed = int(next())
+		case commandWeight:
+			d.weight = int(next())
+		case commandSetupPot:
+			d.potMin = int(next())
+			d.potRange = int(next())
+			next()
+		case commandGetSpeedPot:
+			value := d.potValue
+			d.lock.Unlock()
+			d.write(speedPotType | byte(value))
+			d.lock.Lock()
+		case commandClearBuffer:
+			d.buffer = nil
+		case commandKeyImmediate:
+			d.keyDown = next() == 1
+		case commandMode:
+			d.mode = next()
+		case commandStatus:
+			status := d.status
+			d.lock.Unlock()
+			d.write(statusType | byte(status))
+			d.lock.Lock()
+		default:
+			d.buffer = append(d.buffer, b)
+			if b == commandBufferedPTT {
+				d.buffer = append(d.buffer, next())
+			}
+			if b == commandMerge {
+				d.buffer = append(d.buffer, next(), next())
+			}
+			if len(d.buffer) > d.maxBuffered {
+				d.maxBuffered = len(d.buffer)
+			}
+		}
+		d.lock.Unlock()
+		d.updateStatus()
+		select {
+		case d.wakeup <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// updateStatus reports the status if it changed. The write lock is held while the status is determined, otherwise
+// concurrent updates may be reported in the wrong order.
+func (d *fakeDevice) updateStatus() {
+	d.writeLock.Lock()
+	defer d.writeLock.Unlock()
+	d.lock.Lock()
+	var status Status
+	if len(d.buffer) > d.xoffLevel {
+		status |= XOFF
+	}
+	if d.keying || len(d.buffer) > 0 {
+		status |= Busy
+	}
+	if d.keyDown {
+		status |= KeyDown
+	}
+	changed := status != d.status
+	d.status = status
+	d.lock.Unlock()
+
+	if changed {
+		d.conn.Write([]byte{statusType | byte(status)})
+	}
+}
+
+func (d *fakeDevice) key(done chan struct{}) {
+	for {
+		select {
+		case <-done:
+			return
+		case <-d.wakeup:
+		}
+
+		for {
+			d.lock.Lock()
+			if len(d.buffer) == 0 || d.halted {
+				d.keying = false
+				d.lock.Unlock()
+				d.updateStatus()
+				break
+			}
+			d.keying = true
+			b := d.buffer[0]
+			d.buffer = d.buffer[1:]
+			var echo []byte
+			switch b {
+			case commandBufferedPTT:
+				d.ptt = append(d.ptt, d.buffer[0] == 1)
+				d.buffer = d.buffer[1:]
+			case commandMerge:
+				echo = d.buffer[:2]
+				d.buffer = d.buffer[2:]
+				d.keyed.WriteString("<" + string(echo) + ">")
+			default:
+				echo = []byte{b}
+				d.keyed.WriteByte(b)
+			}
+			echoEnabled := d.mode&modeSerialEcho != 0
+			d.lock.Unlock()
+			d.updateStatus()
+
+			if len(echo) > 0 {
+				time.Sleep(d.charDuration)
+			}
+			if echoEnabled {
+				for _, e := range echo {
+					d.write(e)
+				}
+			}
+		}
+	}
+}
+
+func (d *fakeDevice) Keyed() string {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.keyed.String()
+}
+
+func (d *fakeDevice) turnSpeedPot(value int) {
+	d.lock.Lock()
+	d.potValue = value
+	d.lock.Unlock()
+	d.write(speedPotType | byte(value))
+}
+
+// halt stops or continues the keying, the received bytes remain in the buffer while the keying is halted.
+func (d *fakeDevice) halt(halted bool) {
+	d.lock.Lock()
+	d.halted = halted
+	d.lock.Unlock()
+	select {
+	case d.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+func (d *fakeDevice) get(f func()) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	f()
+}
+
+func setupKeyer(t *testing.T, charDuration time.Duration) (*Keyer, *fakeDevice) {
+	t.Helper()
+	device, conn := newFakeDevice(t, charDuration)
+	keyer, err := Open(conn)
+	require.NoError(t, err)
+	t.Cleanup(func() { keyer.Close() })
+	return keyer, device
+}
+
+func TestOpen(t *testing.T) {
+	keyer, device := setupKeyer(t, time.Millisecond)
+
+	assert.Equal(t, 31, keyer.Version())
+	assert.True(t, keyer.IsConnected())
+	assert.Eventually(t, func() bool { return keyer.SpeedPot() == DefaultSpeedPotMin }, time.Second, time.Millisecond)
+	device.get(func() {
+		assert.True(t, device.open)
+		assert.Equal(t, byte(modeSerialEcho), device.mode)
+		assert.Equal(t, DefaultSpeedPotMin, device.potMin)
+		assert.Equal(t, DefaultSpeedPotRange, device.potRange)
+	})
+
+	require.NoError(t, keyer.Close())
+	assert.False(t, keyer.IsConnected())
+	assert.Eventually(t, func() bool {
+		var open bool
+		device.get(func() { open = device.open })
+		return !open
+	}, time.Second, time.Millisecond)
+}
+
+func TestOpen_NoResponse(t *testing.T) {
+	defaultTimeout := OpenTimeout
+	OpenTimeout = 50 * time.Millisecond
+	t.Cleanup(func() { OpenTimeout = defaultTimeout })
+	hostSide, deviceSide := net.Pipe()
+	t.Cleanup(func() {
+		hostSide.Close()
+		deviceSide.Close()
+	})
+	go func() {
+		buffer := make([]byte, 16)
+		for {
+			_, err := deviceSide.Read(buffer)
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	_, err := Open(hostSide)
+
+	assert.ErrorIs(t, err, ErrNoResponse)
+}
+
+func TestKeyer_SendAndWait(t *testing.T) {
+	keyer, device := setupKeyer(t, time.Millisecond)
+	var echoLock sync.Mutex
+	var echo strings.Builder
+	keyer.OnEcho(func(r rune) {
+		echoLock.Lock()
+		defer echoLock.Unlock()
+		echo.WriteRune(r)
+	})
+
+	keyer.Send("cq cq de dl1abc")
+	keyer.Send(" tu <sk>")
+	assert.False(t, keyer.IsIdle())
+	keyer.Wait()
+
+	assert.True(t, keyer.IsIdle())
+	assert.Equal(t, "CQ CQ DE DL1ABC TU <SK>", device.Keyed())
+	echoLock.Lock()
+	defer echoLock.Unlock()
+	assert.Equal(t, "CQ CQ DE DL1ABC TU SK", echo.String())
+}
+
+func TestKeyer_FlowControl(t *testing.T) {
+	keyer, device := setupKeyer(t, 0)
+	// the XOFF level must be below maxInFlight, otherwise the keyer never sees XOFF
+	const xoffLevel = 8
+	device.get(func() {
+		device.xoffLevel = xoffLevel
+	})
+	device.halt(true)
+	xoff := make(chan struct{}, 1)
+	xon := make(chan struct{}, 1)
+	keyer.OnStatus(func(status Status) {
+		signal := xon
+		if status.Has(XOFF) {
+			signal = xoff
+		}
+		select {
+		case signal <- struct{}{}:
+		default:
+		}
+	})
+	text := strings.Repeat("TEST DE DL1ABC ", 40)
+
+	keyer.Send(text)
+	select {
+	case <-xoff:
+	case <-time.After(time.Second):
+		t.Fatal("no XOFF received")
+	}
+	select {
+	case <-xon:
+	default:
+	}
+
+	// the transmit loop pauses while XOFF is set
+	time.Sleep(20 * time.Millisecond)
+	var buffered int
+	device.get(func() {
+		buffered = len(device.buffer)
+	})
+	assert.Greater(t, buffered, xoffLevel)
+	assert.LessOrEqual(t, buffered, maxInFlight)
+	time.Sleep(50 * time.Millisecond)
+	device.get(func() {
+		assert.Equal(t, buffered, len(device.buffer), "the keyer must not send while XOFF is set")
+	})
+
+	// and resumes when XOFF is cleared
+	device.halt(false)
+	select {
+	case <-xon:
+	case <-time.After(time.Second):
+		t.Fatal("XOFF not cleared")
+	}
+	keyer.Wait()
+
+	assert.Equal(t, text, device.Keyed())
+	device.get(func() {
+		assert.LessOrEqual(t, device.maxBuffered, maxInFlight)
+	})
+}
+
+func TestKeyer_Abort(t *testing.T) {
+	keyer, device := setupKeyer(t, 20*time.Millisecond)
+
+	keyer.Send("cq cq cq de dl1abc dl1abc dl1abc k")
+	time.Sleep(100 * time.Millisecond)
+	keyer.Abort()
+	keyer.Wait()
+
+	assert.True(t, keyer.IsIdle())
+	assert.Less(t, len(device.Keyed()), 20)
+}
+
+func TestKeyer_Settings(t *testing.T) {
+	keyer, device := setupKeyer(t, time.Millisecond)
+
+	keyer.Speed(120)
+	keyer.Weight(10)
+	keyer.PTT(true)
+	keyer.Send("e")
+	keyer.PTT(false)
+	keyer.Wait()
+
+	device.get(func() {
+		assert.Equal(t, 99, device.speed)
+		assert.Equal(t, 60, device.weight)
+		assert.Equal(t, []bool{true, false}, device.ptt)
+	})
+}
+
+func TestKeyer_Tune(t *testing.T) {
+	keyer, device := setupKeyer(t, time.Millisecond)
+	keyDown := func() bool {
+		var result bool
+		device.get(func() { result = device.keyDown })
+		return result
+	}
+
+	keyer.Tune(1)
+	assert.Eventually(t, keyDown, time.Second, time.Millisecond)
+	assert.False(t, keyer.IsIdle())
+	keyer.Wait()
+	assert.Eventually(t, func() bool { return !keyDown() }, time.Second, time.Millisecond)
+
+	keyer.Tune(10)
+	assert.Eventually(t, keyDown, time.Second, time.Millisecond)
+	keyer.Abort()
+	assert.Eventually(t, func() bool { return !keyDown() }, time.Second, time.Millisecond)
+	assert.True(t, keyer.IsIdle())
+}
+
+func TestKeyer_SpeedPot(t *testing.T) {
+	keyer, device := setupKeyer(t, time.Millisecond)
+	speeds := make(chan int, 10)
+	keyer.OnSpeedPot(func(speed int) { speeds <- speed })
+
+	keyer.SetupSpeedPot(15, 20)
+	device.turnSpeedPot(7)
+
+	timeout := time.After(time.Second)
+	for speed := 0; speed != 22; {
+		select {
+		case speed = <-speeds:
+		case <-timeout:
+			require.Fail(t, "no speed pot notification")
+		}
+	}
+	assert.Equal(t, 22, keyer.SpeedPot())
+	device.get(func() {
+		assert.Equal(t, 15, device.potMin)
+		assert.Equal(t, 20, device.potRange)
+	})
+}
+
+func TestStatus(t *testing.T) {
+	status := Status(0xC5) &^ typeMask
+
+	assert.True(t, status.Has(XOFF))
+	assert.True(t, status.Has(Busy))
+	assert.False(t, status.Has(KeyDown))
+	assert.Equal(t, "xoff,busy", status.String())
+	assert.Equal(t, "idle", Status(0).String())
+}
+
+func TestEncode(t *testing.T) {
+	tokens, unsupported := encode("a#b <ar> <sos> ä")
+
+	var bytes []byte
+	echoes := 0
+	for _, token := range tokens {
+		bytes = append(bytes, token.bytes...)
+		echoes += token.echoes
+	}
+	assert.Equal(t, []byte("AB \x1BAR  "), bytes)
+	assert.Equal(t, 7, echoes)
+	assert.Equal(t, []string{"#", "<SOS>", "Ä"}, unsupported)
+}
@@ -0,0 +1,521 @@
+package wsjtx
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Errors returned by Unmarshal and Marshal.
+var (
+	ErrInvalidMagic = errors.New("not a WSJT-X message")
+	ErrUnknownType  = errors.New("unknown message type")
+	ErrTruncated    = errors.New("truncated message")
+)
+
+// julianDayOfUnixEpoch is the julian day of 1970-01-01, QDate counts the days since the beginning of the julian period.
+const julianDayOfUnixEpoch = 2440588
+
+// The time specs of QDateTime.
+const (
+	localTime uint8 = iota
+	utc
+	offsetFromUTC
+	timeZone
+)
+
+const nullLength uint32 = 0xffffffff
+
+// Unmarshal decodes the given datagram into a message. Fields that were added to the schema later are optional, if they
+// are missing they keep their zero values.
+func Unmarshal(data []byte) (Message, error) {
+	r := &reader{data: data}
+	if r.uint32() != Magic {
+		return nil, ErrInvalidMagic
+	}
+	schema := r.uint32()
+	messageType := MessageType(r.uint32())
+	header := Header{ID: r.string()}
+	if r.err != nil {
+		return nil, r.err
+	}
+	if schema < 2 {
+		return nil, fmt.Errorf("unsupported schema %d", schema)
+	}
+
+	var result Message
+	switch messageType {
+	case HeartbeatType:
+		m := Heartbeat{Header: header}
+		m.MaxSchema = r.uint32()
+		m.Version = r.string()
+		m.Revision = r.string()
+		result = m
+	case StatusType:
+		m := Status{Header: header}
+		m.DialFrequency = r.uint64()
+		m.Mode = r.string()
+		m.DXCall = r.string()
+		m.Report = r.string()
+		m.TxMode = r.string()
+		m.TxEnabled = r.bool()
+		m.Transmitting = r.bool()
+		m.Decoding = r.bool()
+		m.RxDF = r.uint32()
+		m.TxDF = r.uint32()
+		m.DECall = r.string()
+		m.DEGrid = r.string()
+		m.DXGrid = r.string()
+		if r.more() {
+			m.TxWatchdog = r.bool()
+			m.SubMode = r.string()
+			m.FastMode = r.bool()
+		}
+		if r.more() {
+			m.SpecialOperationMode = SpecialOperationMode(r.uint8())
+			m.FrequencyTolerance = r.uint32()
+			m.TRPeriod = r.uint32()
+			m.ConfigurationName = r.string()
+		}
+		if r.more() {
+			m.TxMessage = r.string()
+		}
+		result = m
+	case DecodeType:
+		m := Decode{Header: header}
+		m.New = r.bool()
+		m.Time = r.time()
+		m.SNR = r.int32()
+		m.DeltaTime = r.float64()
+		m.DeltaFrequency = r.uint32()
+		m.Mode = r.string()
+		m.Message = r.string()
+		if r.more() {
+			m.LowConfidence = r.bool()
+			m.OffAir = r.bool()
+		}
+		result = m
+	case ClearType:
+		m := Clear{Header: header}
+		if r.more() {
+			m.Window = r.uint8()
+		}
+		result = m
+	case ReplyType:
+		m := Reply{Header: header}
+		m.Time = r.time()
+		m.SNR = r.int32()
+		m.DeltaTime = r.float64()
+		m.DeltaFrequency = r.uint32()
+		m.Mode = r.string()
+		m.Message = r.string()
+		m.LowConfidence = r.bool()
+		if r.more() {
+			m.Modifiers = Modifiers(r.uint8())
+		}
+		result = m
+	case QSOLoggedType:
+		m := QSOLogged{Header: header}
+		m.TimeOff = r.dateTime()
+		m.DXCall = r.string()
+		m.DXGrid = r.string()
+		m.TxFrequency = r.uint64()
+		m.Mode = r.string()
+		m.ReportSent = r.string()
+		m.ReportReceived = r.string()
+		m.TxPower = r.string()
+		m.Comments = r.string()
+		m.Name = r.string()
+		m.TimeOn = r.dateTime()
+		if r.more() {
+			m.OperatorCall = r.string()
+			m.MyCall = r.string()
+			m.MyGrid = r.string()
+		}
+		if r.more() {
+			m.ExchangeSent = r.string()
+			m.ExchangeReceived = r.string()
+		}
+		if r.more() {
+			m.PropagationMode = r.string()
+		}
+		result = m
+	case CloseType:
+		result = Close{Header: header}
+	case ReplayType:
+		result = Replay{Header: header}
+	case HaltTxType:
+		result = HaltTx{Header: header, AutoTxOnly: r.bool()}
+	case FreeTextType:
+		m := FreeText{Header: header}
+		m.Text = r.string()
+		m.Send = r.bool()
+		result = m
+	case WSPRDecodeType:
+		m := WSPRDecode{Header: header}
+		m.New = r.bool()
+		m.Time = r.time()
+		m.SNR = r.int32()
+		m.DeltaTime = r.float64()
+		m.Frequency = r.uint64()
+		m.Drift = r.int32()
+		m.Callsign = r.string()
+		m.Grid = r.string()
+		m.Power = r.int32()
+		if r.more() {
+			m.OffAir = r.bool()
+		}
+		result = m
+	case LocationType:
+		result = Location{Header: header, Location: r.string()}
+	case LoggedADIFType:
+		result = LoggedADIF{Header: header, ADIF: r.string()}
+	case HighlightCallsignType:
+		m := HighlightCallsign{Header: header}
+		m.Callsign = r.string()
+		m.Background = r.color()
+		m.Foreground = r.color()
+		if r.more() {
+			m.HighlightLast = r.bool()
+		}
+		result = m
+	case SwitchConfigurationType:
+		result = SwitchConfiguration{Header: header, ConfigurationName: r.string()}
+	case ConfigureType:
+		m := Configure{Header: header}
+		m.Mode = r.string()
+		m.FrequencyTolerance = r.uint32()
+		m.SubMode = r.string()
+		m.FastMode = r.bool()
+		m.TRPeriod = r.uint32()
+		m.RxDF = r.uint32()
+		m.DXCall = r.string()
+		m.DXGrid = r.string()
+		m.GenerateMessages = r.bool()
+		result = m
+	default:
+		return nil, fmt.Errorf("%w: %d", ErrUnknownType, messageType)
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("cannot decode %v: %w", messageType, r.err)
+	}
+	return result, nil
+}
+
+// Marshal encodes the given message into a datagram.
+func Marshal(message Message) ([]byte, error) {
+	w := &writer{}
+	w.uint32(Magic)
+	w.uint32(Schema)
+	w.uint32(uint32(message.Type()))
+	w.string(message.Instance())
+
+	switch m := message.(type) {
+	case Heartbeat:
+		w.uint32(m.MaxSchema)
+		w.string(m.Version)
+		w.string(m.Revision)
+	case Status:
+		w.uint64(m.DialFrequency)
+		w.string(m.Mode)
+		w.string(m.DXCall)
+		w.string(m.Report)
+		w.string(m.TxMode)
+		w.bool(m.TxEnabled)
+		w.bool(m.Transmitting)
+		w.bool(m.Decoding)
+		w.uint32(m.RxDF)
+		w.uint32(m.TxDF)
+		w.string(m.DECall)
+		w.string(m.DEGrid)
+		w.string(m.DXGrid)
+		w.bool(m.TxWatchdog)
+		w.string(m.SubMode)
+		w.bool(m.FastMode)
+		w.uint8(uint8(m.SpecialOperationMode))
+		w.uint32(m.FrequencyTolerance)
+		w.uint32(m.TRPeriod)
+		w.string(m.ConfigurationName)
+		w.string(m.TxMessage)
+	case Decode:
+		w.bool(m.New)
+		w.time(m.Time)
+		w.int32(m.SNR)
+		w.float64(m.DeltaTime)
+		w.uint32(m.DeltaFrequency)
+		w.string(m.Mode)
+		w.string(m.Message)
+		w.bool(m.LowConfidence)
+		w.bool(m.OffAir)
+	case Clear:
+		w.uint8(m.Window)
+	case Reply:
+		w.time(m.Time)
+		w.int32(m.SNR)
+		w.float64(m.DeltaTime)
+		w.uint32(m.DeltaFrequency)
+		w.string(m.Mode)
+		w.string(m.Message)
+		w.bool(m.LowConfidence)
+		w.uint8(uint8(m.Modifiers))
+	case QSOLogged:
+		w.dateTime(m.TimeOff)
+		w.string(m.DXCall)
+		w.string(m.DXGrid)
+		w.uint64(m.TxFrequency)
+		w.string(m.Mode)
+		w.string(m.ReportSent)
+		w.string(m.ReportReceived)
+		w.string(m.TxPower)
+		w.string(m.Comments)
+		w.string(m.Name)
+		w.dateTime(m.TimeOn)
+		w.string(m.OperatorCall)
+		w.string(m.MyCall)
+		w.string(m.MyGrid)
+		w.string(m.ExchangeSent)
+		w.string(m.ExchangeReceived)
+		w.string(m.PropagationMode)
+	case Close, Replay:
+	case HaltTx:
+		w.bool(m.AutoTxOnly)
+	case FreeText:
+		w.string(m.Text)
+		w.bool(m.Send)
+	case WSPRDecode:
+		w.bool(m.New)
+		w.time(m.Time)
+		w.int32(m.SNR)
+		w.float64(m.DeltaTime)
+		w.uint64(m.Frequency)
+		w.int32(m.Drift)
+		w.string(m.Callsign)
+		w.string(m.Grid)
+		w.int32(m.Power)
+		w.bool(m.OffAir)
+	case Location:
+		w.string(m.Location)
+	case LoggedADIF:
+		w.string(m.ADIF)
+	case HighlightCallsign:
+		w.string(m.Callsign)
+		w.color(m.Background)
+		w.color(m.Foreground)
+		w.bool(m.HighlightLast)
+	case SwitchConfiguration:
+		w.string(m.ConfigurationName)
+	case Configure:
+		w.string(m.Mode)
+		w.uint32(m.FrequencyTolerance)
+		w.string(m.SubMode)
+		w.bool(m.FastMode)
+		w.uint32(m.TRPeriod)
+		w.uint32(m.RxDF)
+		w.string(m.DXCall)
+		w.string(m.DXGrid)
+		w.bool(m.GenerateMessages)
+	default:
+		return nil, fmt.Errorf("%w: %T", ErrUnknownType, message)
+	}
+	return w.buffer.Bytes(), nil
+}
+
+// reader reads the values in the format of QDataStream. After the first error, all values are zero.
+type reader struct {
+	data []byte
+	err  error
+}
+
+func (r *reader) more() bool {
+	return r.err == nil && len(r.data) > 0
+}
+
+func (r *reader) next(n int) []byte {
+	if r.err != nil {
+		return make([]byte, n)
+	}
+	if len(r.data) < n {
+		r.err = ErrTruncated
+		return make([]byte, n)
+	}
+	result := r.data[:n]
+	r.data = r.data[n:]
+	return result
+}
+
+func (r *reader) uint8() uint8 {
+	return r.next(1)[0]
+}
+
+func (r *reader) bool() bool {
+	return r.uint8() != 0
+}
+
+func (r *reader) uint16() uint16 {
+	return binary.BigEndian.Uint16(r.next(2))
+}
+
+func (r *reader) uint32() uint32 {
+	return binary.BigEndian.Uint32(r.next(4))
+}
+
+func (r *reader) int32() int32 {
+	return int32(r.uint32())
+}
+
+func (r *reader) uint64() uint64 {
+	return binary.BigEndian.Uint64(r.next(8))
+}
+
+func (r *reader) float64() float64 {
+	return math.Float64frombits(r.uint64())
+}
+
+// string reads a QByteArray with UTF-8 content, a null array is returned as empty string.
+func (r *reader) string() string {
+	length := r.uint32()
+	if length == nullLength || r.err != nil {
+		return ""
+	}
+	if uint64(length) > uint64(len(r.data)) {
+		r.err = ErrTruncated
+		return ""
+	}
+	return string(r.next(int(length)))
+}
+
+// time reads a QTime as the duration since midnight, a null time is returned as 0.
+func (r *reader) time() time.Duration {
+	milliseconds := r.uint32()
+	if milliseconds == nullLength {
+		return 0
+	}
+	return time.Duration(milliseconds) * time.Millisecond
+}
+
+// dateTime reads a QDateTime, a null date is returned as zero time.
+func (r *reader) dateTime() time.Time {
+	julianDay := int64(r.uint64())
+	timeOfDay := r.time()
+	spec := r.uint8()
+	location := time.UTC
+	switch spec {
+	case localTime:
+		location = time.Local
+	case utc:
+	case offsetFromUTC:
+		offset := r.int32()
+		location = time.FixedZone("", int(offset))
+	case timeZone:
+		// the serialization of QTimeZone is not supported, WSJT-X uses only UTC
+		if r.err == nil {
+			r.err = fmt.Errorf("unsupported time spec %d", spec)
+		}
+	}
+	if r.err != nil || julianDay == math.MinInt64 || julianDay == 0 {
+		return time.Time{}
+	}
+	date := time.Unix((julianDay-julianDayOfUnixEpoch)*24*60*60, 0).UTC()
+	return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, location).Add(timeOfDay)
+}
+
+// color reads a QColor, only RGB colors are supported.
+func (r *reader) color() Color {
+	spec := r.uint8()
+	alpha := r.uint16()
+	red := r.uint16()
+	green := r.uint16()
+	blue := r.uint16()
+	r.uint16() // padding
+	if spec == 0 {
+		return Color{}
+	}
+	return Color{Valid: true, R: uint8(red >> 8), G: uint8(green >> 8), B: uint8(blue >> 8), A: uint8(alpha >> 8)}
+}
+
+// writer writes the values in the format of QDataStream.
+type writer struct {
+	buffer bytes.Buffer
+}
+
+func (w *writer) uint8(value uint8) {
+	w.buffer.WriteByte(value)
+}
+
+func (w *writer) bool(value bool) {
+	if value {
+		w.uint8(1)
+	} else {
+		w.uint8(0)
+	}
+}
+
+func (w *writer) uint16(value uint16) {
+	w.buffer.Write(binary.BigEndian.AppendUint16(nil, value))
+}
+
+func (w *writer) uint32(value uint32) {
+	w.buffer.Write(binary.BigEndian.AppendUint32(nil, value))
+}
+
+func (w *writer) int32(value int32) {
+	w.uint32(uint32(value))
+}
+
+func (w *writer) uint64(value uint64) {
+	w.buffer.Write(binary.BigEndian.AppendUint64(nil, value))
+}
+
+func (w *writer) int64(value int64) {
+	w.uint64(uint64(value))
+}
+
+func (w *writer) float64(value float64) {
+	w.uint64(math.Float64bits(value))
+}
+
+func (w *writer) string(value string) {
+	w.uint32(uint32(len(value)))
+	w.buffer.WriteString(value)
+}
+
+func (w *writer) time(value time.Duration) {
+	w.uint32(uint32(value / time.Millisecond))
+}
+
+// dateTime writes the given time as QDateTime in UTC, the zero time is written as null date and time.
+func (w *writer) dateTime(value time.Time) {
+	if value.IsZero() {
+		w.int64(math.MinInt64)
+		w.uint32(nullLength)
+		w.uint8(utc)
+		return
+	}
+	value = value.UTC()
+	midnight := time.Date(value.Year(), value.Month(), value.Day(), 0, 0, 0, 0, time.UTC)
+	julianDay := midnight.Unix()/(24*60*60) + julianDayOfUnixEpoch
+	w.int64(julianDay)
+	w.time(value.Sub(midnight))
+	w.uint8(utc)
+}
+
+func (w *writer) color(value Color) {
+	if !value.Valid {
+		w.uint8(0)
+		w.uint16(0xffff)
+		w.uint16(0)
+		w.uint16(0)
+		w.uint16(0)
+		w.uint16(0)
+		return
+	}
+	w.uint8(1)
+	w.uint16(uint16(value.A) * 0x101)
+	w.uint16(uint16(value.R) * 0x101)
+	w.uint16(uint16(value.G) * 0x101)
+	w.uint16(uint16(value.B) * 0x101)
+	w.uint16(0)
+}
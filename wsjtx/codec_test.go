@@ -0,0 +1,128 @@
+package wsjtx
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var heartbeatDatagram = []byte{
+	0xad, 0xbc, 0xcb, 0xda, // magic
+	0x00, 0x00, 0x00, 0x02, // schema
+	0x00, 0x00, 0x00, 0x00, // type
+	0x00, 0x00, 0x00, 0x06, 'W', 'S', 'J', 'T', '-', 'X', // id
+	0x00, 0x00, 0x00, 0x03, // max schema
+	0x00, 0x00, 0x00, 0x05, '2', '.', '6', '.', '1', // version
+	0xff, 0xff, 0xff, 0xff, // revision (null)
+}
+
+func TestUnmarshal_Heartbeat(t *testing.T) {
+	message, err := Unmarshal(heartbeatDatagram)
+	require.NoError(t, err)
+
+	assert.Equal(t, Heartbeat{Header: Header{ID: "WSJT-X"}, MaxSchema: 3, Version: "2.6.1"}, message)
+	assert.Equal(t, HeartbeatType, message.Type())
+	assert.Equal(t, "WSJT-X", message.Instance())
+}
+
+func TestMarshal_Heartbeat(t *testing.T) {
+	data, err := Marshal(Heartbeat{Header: Header{ID: "WSJT-X"}, MaxSchema: 3, Version: "2.6.1"})
+	require.NoError(t, err)
+
+	expected := append(append([]byte{}, heartbeatDatagram[:len(heartbeatDatagram)-4]...), 0, 0, 0, 0)
+	assert.Equal(t, expected, data)
+}
+
+func TestUnmarshal_QDateTime(t *testing.T) {
+	data := []byte{
+		0xad, 0xbc, 0xcb, 0xda, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x05, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x25, 0x89, 0x60, // julian day 2460000
+		0x02, 0xde, 0xc1, 0xa8, // 13:22:33.000
+		0x01, // UTC
+	}
+	data = append(data, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00) // dx call, dx grid
+	data = append(data, 0x00, 0x00, 0x00, 0x00, 0x00, 0xd6, 0xc0, 0x90) // tx frequency
+	for i := 0; i < 6; i++ {
+		data = append(data, 0xff, 0xff, 0xff, 0xff) // mode, reports, power, comments, name
+	}
+	data = append(data, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xff, 0xff, 0xff, 0xff, 0x01) // time on (null)
+
+	message, err := Unmarshal(data)
+	require.NoError(t, err)
+	qso := message.(QSOLogged)
+
+	assert.Equal(t, time.Date(2023, time.February, 24, 13, 22, 33, 0, time.UTC), qso.TimeOff)
+	assert.Equal(t, uint64(14074000), qso.TxFrequency)
+	assert.True(t, qso.TimeOn.IsZero())
+}
+
+func TestUnmarshal_OptionalFields(t *testing.T) {
+	data, err := Marshal(Decode{Header: Header{ID: "WSJT-X"}, New: true, Message: "CQ DL1ABC JO62", LowConfidence: true})
+	require.NoError(t, err)
+
+	message, err := Unmarshal(data[:len(data)-2])
+	require.NoError(t, err)
+	assert.Equal(t, Decode{Header: Header{ID: "WSJT-X"}, New: true, Message: "CQ DL1ABC JO62"}, message)
+}
+
+func TestUnmarshal_Invalid(t *testing.T) {
+	data, err := Marshal(Status{Header: Header{ID: "WSJT-X"}, Mode: "FT8"})
+	require.NoError(t, err)
+
+	_, err = Unmarshal(data[:30])
+	assert.ErrorIs(t, err, ErrTruncated)
+
+	_, err = Unmarshal([]byte{1, 2, 3, 4, 5, 6, 7, 8})
+	assert.ErrorIs(t, err, ErrInvalidMagic)
+
+	unknown := append([]byte{}, heartbeatDatagram...)
+	unknown[11] = 99
+	_, err = Unmarshal(unknown)
+	assert.ErrorIs(t, err, ErrUnknownType)
+}
+
+func TestMarshalUnmarshal(t *testing.T) {
+	header := Header{ID: "WSJT-X - IC-7300"}
+	timeOn := time.Date(2023, time.July, 14, 18, 3, 15, 0, time.UTC)
+	tt := []Message{
+		Heartbeat{Header: header, MaxSchema: 3, Version: "2.6.1", Revision: "d6f5e3"},
+		Status{Header: header, DialFrequency: 14074000, Mode: "FT8", DXCall: "VK2ABC", Report: "-12", TxMode: "FT8", TxEnabled: true,
+			Transmitting: true, RxDF: 1200, TxDF: 1500, DECall: "DL1ABC", DEGrid: "JO62", DXGrid: "QF56", TxWatchdog: true,
+			SubMode: "A", SpecialOperationMode: Hound, FrequencyTolerance: NoChange, TRPeriod: 15, ConfigurationName: "Default",
+			TxMessage: "VK2ABC DL1ABC JO62"},
+		Decode{Header: header, New: true, Time: 18*time.Hour + 3*time.Minute + 15*time.Second, SNR: -12, DeltaTime: 0.2,
+			DeltaFrequency: 1234, Mode: "~", Message: "CQ VK2ABC QF56", LowConfidence: true},
+		Clear{Header: header, Window: 2},
+		Reply{Header: header, Time: 15 * time.Second, SNR: 3, DeltaTime: -0.5, DeltaFrequency: 800, Mode: "~",
+			Message: "CQ DX K1ABC FN42", Modifiers: Shift | Alt},
+		QSOLogged{Header: header, TimeOff: timeOn.Add(90 * time.Second), DXCall: "VK2ABC", DXGrid: "QF56", TxFrequency: 14074000,
+			Mode: "FT8", ReportSent: "-12", ReportReceived: "-08", TxPower: "100", Comments: "long path", Name: "Bob",
+			TimeOn: timeOn, OperatorCall: "DL1ABC", MyCall: "DL1ABC", MyGrid: "JO62qm", PropagationMode: "F2"},
+		QSOLogged{Header: header, DXCall: "K1ABC"},
+		Close{Header: header},
+		Replay{Header: header},
+		HaltTx{Header: header, AutoTxOnly: true},
+		FreeText{Header: header, Text: "TNX 73 GL", Send: true},
+		WSPRDecode{Header: header, New: true, Time: time.Hour, SNR: -25, DeltaTime: 1.1, Frequency: 14097012, Drift: -1,
+			Callsign: "DL1ABC", Grid: "JO62", Power: 37},
+		Location{Header: header, Location: "JO62qm"},
+		LoggedADIF{Header: header, ADIF: "<call:6>VK2ABC <eor>"},
+		HighlightCallsign{Header: header, Callsign: "VK2ABC", Background: RGB(255, 0, 0), Foreground: RGB(255, 255, 255), HighlightLast: true},
+		HighlightCallsign{Header: header, Callsign: "VK2ABC"},
+		SwitchConfiguration{Header: header, ConfigurationName: "Contest"},
+		Configure{Header: header, Mode: "FT4", FrequencyTolerance: NoChange, TRPeriod: NoChange, RxDF: 1500, DXCall: "VK2ABC",
+			GenerateMessages: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.Type().String(), func(t *testing.T) {
+			data, err := Marshal(tc)
+			require.NoError(t, err)
+
+			actual, err := Unmarshal(data)
+			require.NoError(t, err)
+			assert.Equal(t, tc, actual)
+		})
+	}
+}
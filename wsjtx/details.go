@@ -0,0 +1,106 @@
+package wsjtx
+
+import (
+	"strings"
+
+	"github.com/ftl/hamradio/callsign"
+	"github.com/ftl/hamradio/dxcc"
+	"github.com/ftl/hamradio/locator"
+)
+
+// Details contains the information that is extracted from the text of a decoded message.
+type Details struct {
+	// Callsigns contains all callsigns in the message, in the order of their appearance.
+	Callsigns []callsign.Callsign
+	// CQ is true if the message is a CQ call.
+	CQ bool
+	// From is the sender of the message. It is empty if the sender cannot be determined.
+	From callsign.Callsign
+	// To is the addressee of a directed message. It is empty for CQ calls.
+	To callsign.Callsign
+	// Locator is the grid square in the message. It is zero if the message does not contain a grid square.
+	Locator locator.Locator
+	// DXCC is the DXCC entity of the sender. It is the zero value if no prefixes are available or no matching
+	// entity is found.
+	DXCC dxcc.Prefix
+}
+
+// Analyze extracts the details from the text of a decoded message in the usual format of the FT8 and FT4 messages:
+// "CQ [modifier] <call> [grid]" or "<to> <from> [grid|report|RR73|73]". Hashed callsigns in angle brackets are
+// supported. The DXCC entity of the sender is only resolved if prefixes are given.
+func Analyze(text string, prefixes *dxcc.Prefixes) Details {
+	result := Details{
+		Callsigns: callsign.FindAll(text),
+	}
+
+	words := strings.Fields(strings.ToUpper(text))
+	for i, word := range words {
+		words[i] = strings.Trim(word, "<>")
+	}
+	if len(words) == 0 {
+		return result
+	}
+
+	var last string
+	if words[0] == "CQ" {
+		result.CQ = true
+		words = words[1:]
+		if len(words) > 1 && !isCallsign(words[0]) {
+			words = words[1:]
+		}
+		if len(words) > 0 {
+			result.From, _ = callsign.Parse(words[0])
+		}
+		if len(words) > 1 {
+			last = words[len(words)-1]
+		}
+	} else {
+		result.To, _ = callsign.Parse(words[0])
+		if len(words) > 1 {
+			result.From, _ = callsign.Parse(words[1])
+		}
+		if len(words) > 2 {
+			last = words[len(words)-1]
+		}
+	}
+
+	result.Locator = parseGrid(last)
+	if prefixes != nil {
+		result.DXCC, _ = prefixes.FindCallsign(result.From)
+	}
+
+	return result
+}
+
+// AnalyzeQSO returns the details of the given logged QSO: the DX callsign, its grid and DXCC entity.
+func AnalyzeQSO(qso QSOLogged, prefixes *dxcc.Prefixes) Details {
+	var result Details
+	call, err := callsign.Parse(qso.DXCall)
+	if err == nil {
+		result.Callsigns = []callsign.Callsign{call}
+		result.From = call
+	}
+	result.Locator, _ = locator.Parse(qso.DXGrid)
+	if prefixes != nil {
+		result.DXCC, _ = prefixes.FindCallsign(result.From)
+	}
+	return result
+}
+
+func isCallsign(s string) bool {
+	_, err := callsign.Parse(s)
+	return err == nil
+}
+
+// parseGrid parses a grid square with four characters. RR73 is a valid grid square, but it is used to confirm
+// the report in FT8 and FT4.
+func parseGrid(s string) locator.Locator {
+	if len(s) != 4 || s == "RR73" {
+		return locator.Locator{}
+	}
+	result, err := locator.Parse(s)
+	if err != nil {
+		return locator.Locator{}
+	}
+	return result
+}
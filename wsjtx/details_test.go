@@ -0,0 +1,67 @@
+package wsjtx
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ftl/hamradio/callsign"
+	"github.com/ftl/hamradio/dxcc"
+	"github.com/ftl/hamradio/dxcc/dxcctest"
+	"github.com/ftl/hamradio/locator"
+)
+
+func TestAnalyze(t *testing.T) {
+	prefixes := dxcctest.Prefixes()
+	tt := []struct {
+		text      string
+		callsigns []string
+		cq        bool
+		from      string
+		to        string
+		locator   string
+		dxcc      string
+	}{
+		{"CQ VK2ABC QF56", []string{"VK2ABC"}, true, "VK2ABC", "", "QF56", "VK"},
+		{"CQ DX DL1ABC JO62", []string{"DL1ABC"}, true, "DL1ABC", "", "JO62", "DL"},
+		{"CQ POTA K1ABC", []string{"K1ABC"}, true, "K1ABC", "", "", ""},
+		{"VK2ABC DL1ABC JO62", []string{"VK2ABC", "DL1ABC"}, false, "DL1ABC", "VK2ABC", "JO62", "DL"},
+		{"DL1ABC VK2ABC -12", []string{"DL1ABC", "VK2ABC"}, false, "VK2ABC", "DL1ABC", "", "VK"},
+		{"DL1ABC VK2ABC RR73", []string{"DL1ABC", "VK2ABC"}, false, "VK2ABC", "DL1ABC", "", "VK"},
+		{"DL1ABC <PJ4/K1ABC> R-05", []string{"DL1ABC", "PJ4/K1ABC"}, false, "PJ4/K1ABC", "DL1ABC", "", "PJ4"},
+		{"TNX 73 GL", []string{}, false, "", "", "", ""},
+		{"", []string{}, false, "", "", "", ""},
+	}
+	for _, tc := range tt {
+		t.Run(tc.text, func(t *testing.T) {
+			actual := Analyze(tc.text, prefixes)
+
+			callsigns := make([]string, len(actual.Callsigns))
+			for i, call := range actual.Callsigns {
+				callsigns[i] = call.String()
+			}
+			assert.Equal(t, tc.callsigns, callsigns, "callsigns")
+			assert.Equal(t, tc.cq, actual.CQ, "cq")
+			assert.Equal(t, tc.from, actual.From.String(), "from")
+			assert.Equal(t, tc.to, actual.To.String(), "to")
+			assert.Equal(t, tc.locator, actual.Locator.String(), "locator")
+			assert.Equal(t, tc.dxcc, actual.DXCC.Prefix, "dxcc")
+		})
+	}
+}
+
+func TestAnalyze_WithoutPrefixes(t *testing.T) {
+	actual := Analyze("CQ VK2ABC QF56", nil)
+
+	assert.Equal(t, callsign.MustParse("VK2ABC"), actual.From)
+	assert.Equal(t, dxcc.Prefix{}, actual.DXCC)
+}
+
+func TestAnalyzeQSO(t *testing.T) {
+	actual := AnalyzeQSO(QSOLogged{DXCall: "vk2abc", DXGrid: "QF56od"}, dxcctest.Prefixes())
+
+	assert.Equal(t, []callsign.Callsign{callsign.MustParse("VK2ABC")}, actual.Callsigns)
+	assert.Equal(t, callsign.MustParse("VK2ABC"), actual.From)
+	assert.Equal(t, locator.MustParse("QF56od"), actual.Locator)
+	assert.Equal(t, "Australia", actual.DXCC.Name)
+}
@@ -0,0 +1,224 @@
+package wsjtx
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+
+	"github.com/ftl/hamradio/dxcc"
+)
+
+// ErrUnknownInstance is returned by Listener.Send if no message was received yet from the addressed WSJT-X instance.
+var ErrUnknownInstance = errors.New("unknown WSJT-X instance")
+
+// maxDatagramSize is the maximum size of a UDP datagram.
+const maxDatagramSize = 65535
+
+// MessageHandler is notified about every message that is received.
+type MessageHandler func(Message)
+
+// DecodeHandler is notified about every decoded message, together with the details extracted from its text.
+type DecodeHandler func(Decode, Details)
+
+// QSOLoggedHandler is notified about every logged QSO, together with the details of the DX station.
+type QSOLoggedHandler func(QSOLogged, Details)
+
+// ErrorHandler is notified about datagrams that cannot be decoded.
+type ErrorHandler func(err error)
+
+// Listener receives the messages of one or more WSJT-X instances on a UDP address. It remembers the address of every
+// instance, to send messages back to it.
+type Listener struct {
+	conn *net.UDPConn
+
+	lock            sync.RWMutex
+	prefixes        *dxcc.Prefixes
+	instances       map[string]*net.UDPAddr
+	messageHandlers []MessageHandler
+	decodeHandlers  []DecodeHandler
+	qsoHandlers     []QSOLoggedHandler
+	errorHandlers   []ErrorHandler
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// Listen starts to receive messages on the given UDP address. If the address is empty, 127.0.0.1:2237 is used.
+func Listen(address string) (*Listener, error) {
+	if address == "" {
+		address = fmt.Sprintf("127.0.0.1:%d", DefaultPort)
+	}
+	udpAddr, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", udpAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Listener{
+		conn:      conn,
+		instances: make(map[string]*net.UDPAddr),
+		done:      make(chan struct{}),
+	}
+	go result.run()
+	return result, nil
+}
+
+// Addr returns the local address of the listener.
+func (l *Listener) Addr() net.Addr {
+	return l.conn.LocalAddr()
+}
+
+// SetPrefixes sets the DXCC prefixes that are used to find the DXCC entities of the decoded callsigns.
+func (l *Listener) SetPrefixes(prefixes *dxcc.Prefixes) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.prefixes = prefixes
+}
+
+// OnMessage registers the given handler to be notified about every received message. The handlers are called on the
+// goroutine of the listener.
+func (l *Listener) OnMessage(handler MessageHandler) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.messageHandlers = append(l.messageHandlers, handler)
+}
+
+// OnDecode registers the given handler to be notified about every decoded message. The handlers are called on the
+// goroutine of the listener.
+func (l *Listener) OnDecode(handler DecodeHandler) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.decodeHandlers = append(l.decodeHandlers, handler)
+}
+
+// OnQSOLogged registers the given handler to be notified about every logged QSO. The handlers are called on the
+// goroutine of the listener.
+func (l *Listener) OnQSOLogged(handler QSOLoggedHandler) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.qsoHandlers = append(l.qsoHandlers, handler)
+}
+
+// OnError registers the given handler to be notified about datagrams that cannot be decoded.
+func (l *Listener) OnError(handler ErrorHandler) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.errorHandlers = append(l.errorHandlers, handler)
+}
+
+// Instances returns the IDs of all WSJT-X instances that sent a message to this listener, in alphabetical order.
+func (l *Listener) Instances() []string {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	result := make([]string, 0, len(l.instances))
+	for id := range l.instances {
+		result = append(result, id)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// Send sends the given message to the WSJT-X instance with the ID of the message.
+func (l *Listener) Send(message Message) error {
+	l.lock.RLock()
+	addr, ok := l.instances[message.Instance()]
+	l.lock.RUnlock()
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownInstance, message.Instance())
+	}
+
+	data, err := Marshal(message)
+	if err != nil {
+		return err
+	}
+	_, err = l.conn.WriteToUDP(data, addr)
+	return err
+}
+
+// Close stops receiving messages and closes the UDP connection.
+func (l *Listener) Close() error {
+	var err error
+	l.closeOnce.Do(func() {
+		err = l.conn.Close()
+	})
+	<-l.done
+	return err
+}
+
+func (l *Listener) run() {
+	defer close(l.done)
+
+	buffer := make([]byte, maxDatagramSize)
+	for {
+		n, addr, err := l.conn.ReadFromUDP(buffer)
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+		if err != nil {
+			l.emitError(err)
+			continue
+		}
+
+		message, err := Unmarshal(buffer[:n])
+		if err != nil {
+			l.emitError(fmt.Errorf("invalid datagram from %v: %w", addr, err))
+			continue
+		}
+		l.handle(message, addr)
+	}
+}
+
+func (l *Listener) handle(message Message, addr *net.UDPAddr) {
+	l.lock.Lock()
+	if _, ok := message.(Close); ok {
+		delete(l.instances, message.Instance())
+	} else {
+		l.instances[message.Instance()] = addr
+	}
+	prefixes := l.prefixes
+	messageHandlers := make([]MessageHandler, len(l.messageHandlers))
+	copy(messageHandlers, l.messageHandlers)
+	decodeHandlers := make([]DecodeHandler, len(l.decodeHandlers))
+	copy(decodeHandlers, l.decodeHandlers)
+	qsoHandlers := make([]QSOLoggedHandler, len(l.qsoHandlers))
+	copy(qsoHandlers, l.qsoHandlers)
+	l.lock.Unlock()
+
+	for _, handler := range messageHandlers {
+		handler(message)
+	}
+	switch m := message.(type) {
+	case Decode:
+		if len(decodeHandlers) == 0 {
+			return
+		}
+		details := Analyze(m.Message, prefixes)
+		for _, handler := range decodeHandlers {
+			handler(m, details)
+		}
+	case QSOLogged:
+		if len(qsoHandlers) == 0 {
+			return
+		}
+		details := AnalyzeQSO(m, prefixes)
+		for _, handler := range qsoHandlers {
+			handler(m, details)
+		}
+	}
+}
+
+func (l *Listener) emitError(err error) {
+	l.lock.RLock()
+	errorHandlers := make([]ErrorHandler, len(l.errorHandlers))
+	copy(errorHandlers, l.errorHandlers)
+	l.lock.RUnlock()
+
+	for _, handler := range errorHandlers {
+		handler(err)
+	}
+}
@@ -0,0 +1,169 @@
+package wsjtx
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ftl/hamradio/dxcc/dxcctest"
+)
+
+// fakeWSJTX sends messages to a listener like an instance of WSJT-X and receives the messages sent back to it.
+type fakeWSJTX struct {
+	t    *testing.T
+	id   string
+	conn *net.UDPConn
+}
+
+func newFakeWSJTX(t *testing.T, id string, listener *Listener) *fakeWSJTX {
+	t.Helper()
+	conn, err := net.DialUDP("udp", nil, listener.Addr().(*net.UDPAddr))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return &fakeWSJTX{t: t, id: id, conn: conn}
+}
+
+func (f *fakeWSJTX) header() Header {
+	return Header{ID: f.id}
+}
+
+func (f *fakeWSJTX) send(message Message) {
+	f.t.Helper()
+	data, err := Marshal(message)
+	require.NoError(f.t, err)
+	_, err = f.conn.Write(data)
+	require.NoError(f.t, err)
+}
+
+func (f *fakeWSJTX) sendRaw(data []byte) {
+	f.t.Helper()
+	_, err := f.conn.Write(data)
+	require.NoError(f.t, err)
+}
+
+func (f *fakeWSJTX) receive() Message {
+	f.t.Helper()
+	buffer := make([]byte, maxDatagramSize)
+	f.conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := f.conn.Read(buffer)
+	require.NoError(f.t, err)
+	message, err := Unmarshal(buffer[:n])
+	require.NoError(f.t, err)
+	return message
+}
+
+func setupListener(t *testing.T) *Listener {
+	t.Helper()
+	listener, err := Listen("127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	listener.SetPrefixes(dxcctest.Prefixes())
+	return listener
+}
+
+type decoded struct {
+	decode  Decode
+	details Details
+}
+
+func TestListener_Decode(t *testing.T) {
+	listener := setupListener(t)
+	messages := make(chan Message, 10)
+	listener.OnMessage(func(m Message) { messages <- m })
+	decodes := make(chan decoded, 10)
+	listener.OnDecode(func(d Decode, details Details) { decodes <- decoded{d, details} })
+	wsjtx := newFakeWSJTX(t, "WSJT-X", listener)
+
+	heartbeat := Heartbeat{Header: wsjtx.header(), MaxSchema: 3, Version: "2.6.1"}
+	wsjtx.send(heartbeat)
+	decode := Decode{Header: wsjtx.header(), New: true, Time: 15 * time.Second, SNR: -7, Mode: "~", Message: "CQ VK2ABC QF56"}
+	wsjtx.send(decode)
+
+	assert.Equal(t, heartbeat, <-messages)
+	assert.Equal(t, decode, <-messages)
+	select {
+	case actual := <-decodes:
+		assert.Equal(t, decode, actual.decode)
+		assert.True(t, actual.details.CQ)
+		assert.Equal(t, "VK2ABC", actual.details.From.String())
+		assert.Equal(t, "QF56", actual.details.Locator.String())
+		assert.Equal(t, "Australia", actual.details.DXCC.Name)
+	case <-time.After(time.Second):
+		t.Fatal("no decode received")
+	}
+	assert.Equal(t, []string{"WSJT-X"}, listener.Instances())
+}
+
+func TestListener_QSOLogged(t *testing.T) {
+	listener := setupListener(t)
+	details := make(chan Details, 1)
+	listener.OnQSOLogged(func(_ QSOLogged, d Details) { details <- d })
+	wsjtx := newFakeWSJTX(t, "WSJT-X", listener)
+
+	wsjtx.send(QSOLogged{Header: wsjtx.header(), DXCall: "DL1ABC", DXGrid: "JO62", TimeOn: time.Now().UTC().Truncate(time.Second)})
+
+	select {
+	case actual := <-details:
+		assert.Equal(t, "DL1ABC", actual.From.String())
+		assert.Equal(t, "JO62", actual.Locator.String())
+		assert.Equal(t, "Fed. Rep. of Germany", actual.DXCC.Name)
+	case <-time.After(time.Second):
+		t.Fatal("no QSO received")
+	}
+}
+
+func TestListener_Send(t *testing.T) {
+	listener := setupListener(t)
+	decodes := make(chan Decode, 1)
+	listener.OnDecode(func(d Decode, _ Details) { decodes <- d })
+	wsjtx := newFakeWSJTX(t, "WSJT-X", listener)
+
+	err := listener.Send(Replay{Header: wsjtx.header()})
+	assert.ErrorIs(t, err, ErrUnknownInstance)
+
+	wsjtx.send(Decode{Header: wsjtx.header(), New: true, Time: 30 * time.Second, SNR: -3, DeltaTime: 0.1, DeltaFrequency: 1500,
+		Mode: "~", Message: "CQ DL1ABC JO62"})
+	decode := <-decodes
+
+	reply := NewReply(decode, NoModifier)
+	require.NoError(t, listener.Send(reply))
+	assert.Equal(t, reply, wsjtx.receive())
+
+	highlight := HighlightCallsign{Header: wsjtx.header(), Callsign: "DL1ABC", Background: RGB(0, 128, 0), Foreground: RGB(255, 255, 255)}
+	require.NoError(t, listener.Send(highlight))
+	assert.Equal(t, highlight, wsjtx.receive())
+}
+
+func TestListener_Close(t *testing.T) {
+	listener := setupListener(t)
+	messages := make(chan Message, 10)
+	listener.OnMessage(func(m Message) { messages <- m })
+	wsjtx := newFakeWSJTX(t, "WSJT-X", listener)
+
+	wsjtx.send(Heartbeat{Header: wsjtx.header(), MaxSchema: 3})
+	wsjtx.send(Close{Header: wsjtx.header()})
+	<-messages
+	<-messages
+
+	assert.Empty(t, listener.Instances())
+	assert.ErrorIs(t, listener.Send(Replay{Header: wsjtx.header()}), ErrUnknownInstance)
+}
+
+func TestListener_InvalidDatagram(t *testing.T) {
+	listener := setupListener(t)
+	errs := make(chan error, 1)
+	listener.OnError(func(err error) { errs <- err })
+	wsjtx := newFakeWSJTX(t, "WSJT-X", listener)
+
+	wsjtx.sendRaw([]byte("hello"))
+
+	select {
+	case err := <-errs:
+		assert.ErrorIs(t, err, ErrInvalidMagic)
+	case <-time.After(time.Second):
+		t.Fatal("no error received")
+	}
+}
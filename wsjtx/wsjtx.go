@@ -0,0 +1,341 @@
+/*
+Package wsjtx implements the UDP protocol of WSJT-X (https://wsjt.sourceforge.io/wsjtx.html).
+
+WSJT-X sends its status, the decoded messages and the logged QSOs as UDP datagrams to a configurable address, by
+default 127.0.0.1:2237. A client that receives these messages may send messages back to the sending WSJT-X instance,
+e.g. to reply to a CQ or to highlight a callsign in the band activity window. The format of the messages is described
+in NetworkMessage.hpp in the source code of WSJT-X. The values are serialized in the binary format of Qt's QDataStream.
+
+Marshal and Unmarshal convert between the datagrams and the message types of this package. A Listener receives the
+messages, extracts the callsigns, locators and DXCC entities from the decoded messages (see Analyze) and sends
+messages back to the instances of WSJT-X.
+*/
+package wsjtx
+
+import (
+	"time"
+)
+
+// DefaultPort is the default UDP port that WSJT-X sends its messages to.
+const DefaultPort = 2237
+
+// Magic is the magic number at the start of every message.
+const Magic uint32 = 0xadbccbda
+
+// Schema is the schema version of the messages encoded by this package.
+const Schema uint32 = 2
+
+// MessageType identifies the type of a message.
+type MessageType uint32
+
+// The message types.
+const (
+	HeartbeatType MessageType = iota
+	StatusType
+	DecodeType
+	ClearType
+	ReplyType
+	QSOLoggedType
+	CloseType
+	ReplayType
+	HaltTxType
+	FreeTextType
+	WSPRDecodeType
+	LocationType
+	LoggedADIFType
+	HighlightCallsignType
+	SwitchConfigurationType
+	ConfigureType
+)
+
+var messageTypeNames = map[MessageType]string{
+	HeartbeatType:           "Heartbeat",
+	StatusType:              "Status",
+	DecodeType:              "Decode",
+	ClearType:               "Clear",
+	ReplyType:               "Reply",
+	QSOLoggedType:           "QSOLogged",
+	CloseType:               "Close",
+	ReplayType:              "Replay",
+	HaltTxType:              "HaltTx",
+	FreeTextType:            "FreeText",
+	WSPRDecodeType:          "WSPRDecode",
+	LocationType:            "Location",
+	LoggedADIFType:          "LoggedADIF",
+	HighlightCallsignType:   "HighlightCallsign",
+	SwitchConfigurationType: "SwitchConfiguration",
+	ConfigureType:           "Configure",
+}
+
+func (t MessageType) String() string {
+	name, ok := messageTypeNames[t]
+	if !ok {
+		return "Unknown"
+	}
+	return name
+}
+
+// Message is a message of the WSJT-X protocol.
+type Message interface {
+	// Type returns the type of the message.
+	Type() MessageType
+	// Instance returns the ID of the WSJT-X instance that sent or receives the message.
+	Instance() string
+}
+
+// Header contains the ID of the WSJT-X instance, it is part of every message.
+type Header struct {
+	ID string
+}
+
+// Instance returns the ID of the WSJT-X instance that sent or receives the message.
+func (h Header) Instance() string {
+	return h.ID
+}
+
+// NoChange is used in the numeric fields of Configure to keep the current value.
+const NoChange uint32 = 0xffffffff
+
+// SpecialOperationMode is the special operating activity that is selected in WSJT-X.
+type SpecialOperationMode uint8
+
+// The special operation modes.
+const (
+	NoSpecialOperation SpecialOperationMode = iota
+	NAVHFContest
+	EUVHFContest
+	FieldDay
+	RTTYRoundup
+	WWDigiContest
+	Fox
+	Hound
+)
+
+// Modifiers are the keyboard modifiers of a Reply, they select how WSJT-X handles the reply.
+type Modifiers uint8
+
+// The keyboard modifiers.
+const (
+	NoModifier  Modifiers = 0x00
+	Shift       Modifiers = 0x02
+	Control     Modifiers = 0x04
+	Alt         Modifiers = 0x08
+	Meta        Modifiers = 0x10
+	Keypad      Modifiers = 0x20
+	GroupSwitch Modifiers = 0x40
+)
+
+// Color is a color of HighlightCallsign. The zero value is the invalid color, it removes the highlighting.
+type Color struct {
+	Valid      bool
+	R, G, B, A uint8
+}
+
+// RGB returns the opaque color with the given red, green and blue components.
+func RGB(r, g, b uint8) Color {
+	return Color{Valid: true, R: r, G: g, B: b, A: 0xff}
+}
+
+// Heartbeat is sent regularly by WSJT-X and should also be sent by clients. It announces the highest supported
+// schema version.
+type Heartbeat struct {
+	Header
+	MaxSchema uint32
+	Version   string
+	Revision  string
+}
+
+// Status is sent by WSJT-X whenever its state changes.
+type Status struct {
+	Header
+	DialFrequency        uint64
+	Mode                 string
+	DXCall               string
+	Report               string
+	TxMode               string
+	TxEnabled            bool
+	Transmitting         bool
+	Decoding             bool
+	RxDF                 uint32
+	TxDF                 uint32
+	DECall               string
+	DEGrid               string
+	DXGrid               string
+	TxWatchdog           bool
+	SubMode              string
+	FastMode             bool
+	SpecialOperationMode SpecialOperationMode
+	FrequencyTolerance   uint32
+	TRPeriod             uint32
+	ConfigurationName    string
+	TxMessage            string
+}
+
+// Decode is sent by WSJT-X for every decoded message. Time is the time of the period since midnight UTC.
+type Decode struct {
+	Header
+	New            bool
+	Time           time.Duration
+	SNR            int32
+	DeltaTime      float64
+	DeltaFrequency uint32
+	Mode           string
+	Message        string
+	LowConfidence  bool
+	OffAir         bool
+}
+
+// Clear is sent by WSJT-X when the band activity window is cleared. Sent to WSJT-X, it clears the given window:
+// 0 = band activity, 1 = Rx frequency, 2 = both.
+type Clear struct {
+	Header
+	Window uint8
+}
+
+// Reply is sent to WSJT-X to reply to a decoded message, as if the message was double clicked. The fields must match
+// the fields of a Decode exactly.
+type Reply struct {
+	Header
+	Time           time.Duration
+	SNR            int32
+	DeltaTime      float64
+	DeltaFrequency uint32
+	Mode           string
+	Message        string
+	LowConfidence  bool
+	Modifiers      Modifiers
+}
+
+// NewReply returns the reply to the given decoded message.
+func NewReply(decode Decode, modifiers Modifiers) Reply {
+	return Reply{
+		Header:         decode.Header,
+		Time:           decode.Time,
+		SNR:            decode.SNR,
+		DeltaTime:      decode.DeltaTime,
+		DeltaFrequency: decode.DeltaFrequency,
+		Mode:           decode.Mode,
+		Message:        decode.Message,
+		LowConfidence:  decode.LowConfidence,
+		Modifiers:      modifiers,
+	}
+}
+
+// QSOLogged is sent by WSJT-X when a QSO is logged.
+type QSOLogged struct {
+	Header
+	TimeOff          time.Time
+	DXCall           string
+	DXGrid           string
+	TxFrequency      uint64
+	Mode             string
+	ReportSent       string
+	ReportReceived   string
+	TxPower          string
+	Comments         string
+	Name             string
+	TimeOn           time.Time
+	OperatorCall     string
+	MyCall           string
+	MyGrid           string
+	ExchangeSent     string
+	ExchangeReceived string
+	PropagationMode  string
+}
+
+// Close is sent by WSJT-X when it is closed. Sent to WSJT-X, it closes the application.
+type Close struct {
+	Header
+}
+
+// Replay is sent to WSJT-X to request the decodes of the current period again.
+type Replay struct {
+	Header
+}
+
+// HaltTx is sent to WSJT-X to stop transmitting, either immediately or only the automatic transmission.
+type HaltTx struct {
+	Header
+	AutoTxOnly bool
+}
+
+// FreeText is sent to WSJT-X to set the free text message and optionally send it in the next period.
+type FreeText struct {
+	Header
+	Text string
+	Send bool
+}
+
+// WSPRDecode is sent by WSJT-X for every decoded WSPR message.
+type WSPRDecode struct {
+	Header
+	New       bool
+	Time      time.Duration
+	SNR       int32
+	DeltaTime float64
+	Frequency uint64
+	Drift     int32
+	Callsign  string
+	Grid      string
+	Power     int32
+	OffAir    bool
+}
+
+// Location is sent to WSJT-X to set the own locator for the current session.
+type Location struct {
+	Header
+	Location string
+}
+
+// LoggedADIF is sent by WSJT-X when a QSO is logged, it contains the QSO as ADIF record.
+type LoggedADIF struct {
+	Header
+	ADIF string
+}
+
+// HighlightCallsign is sent to WSJT-X to highlight the given callsign in the band activity window. Invalid colors
+// remove the highlighting.
+type HighlightCallsign struct {
+	Header
+	Callsign      string
+	Background    Color
+	Foreground    Color
+	HighlightLast bool
+}
+
+// SwitchConfiguration is sent to WSJT-X to switch to the configuration with the given name.
+type SwitchConfiguration struct {
+	Header
+	ConfigurationName string
+}
+
+// Configure is sent to WSJT-X to change its settings. Empty strings and NoChange keep the current values.
+type Configure struct {
+	Header
+	Mode               string
+	FrequencyTolerance uint32
+	SubMode            string
+	FastMode           bool
+	TRPeriod           uint32
+	RxDF               uint32
+	DXCall             string
+	DXGrid             string
+	GenerateMessages   bool
+}
+
+func (Heartbeat) Type() MessageType           { return HeartbeatType }
+func (Status) Type() MessageType              { return StatusType }
+func (Decode) Type() MessageType              { return DecodeType }
+func (Clear) Type() MessageType               { return ClearType }
+func (Reply) Type() MessageType               { return ReplyType }
+func (QSOLogged) Type() MessageType           { return QSOLoggedType }
+func (Close) Type() MessageType               { return CloseType }
+func (Replay) Type() MessageType              { return ReplayType }
+func (HaltTx) Type() MessageType              { return HaltTxType }
+func (FreeText) Type() MessageType            { return FreeTextType }
+func (WSPRDecode) Type() MessageType          { return WSPRDecodeType }
+func (Location) Type() MessageType            { return LocationType }
+func (LoggedADIF) Type() MessageType          { return LoggedADIFType }
+func (HighlightCallsign) Type() MessageType   { return HighlightCallsignType }
+func (SwitchConfiguration) Type() MessageType { return SwitchConfigurationType }
+func (Configure) Type() MessageType           { return ConfigureType }